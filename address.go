@@ -0,0 +1,391 @@
+package t2z
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// AddressKind is the type of a Zcash address
+type AddressKind int
+
+const (
+	// AddressP2PKH is a transparent pay-to-public-key-hash address (t1, tm)
+	AddressP2PKH AddressKind = iota + 1
+	// AddressP2SH is a transparent pay-to-script-hash address (t3, t2)
+	AddressP2SH
+	// AddressSapling is a Sapling shielded address (zs, ztestsapling)
+	AddressSapling
+	// AddressUnified is a unified address (u, utest, uregtest)
+	AddressUnified
+)
+
+// String returns the string representation of an AddressKind
+func (k AddressKind) String() string {
+	switch k {
+	case AddressP2PKH:
+		return "P2PKH"
+	case AddressP2SH:
+		return "P2SH"
+	case AddressSapling:
+		return "Sapling"
+	case AddressUnified:
+		return "Unified"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(k))
+	}
+}
+
+// IsTransparent reports whether the address kind produces a transparent output
+func (k AddressKind) IsTransparent() bool {
+	return k == AddressP2PKH || k == AddressP2SH
+}
+
+// Network is a Zcash network
+type Network int
+
+const (
+	// NetworkMainnet is the Zcash main network
+	NetworkMainnet Network = iota + 1
+	// NetworkTestnet is the Zcash public test network. Transparent addresses
+	// for regtest use the same encoding and decode as testnet.
+	NetworkTestnet
+	// NetworkRegtest is a local regression-test network
+	NetworkRegtest
+)
+
+// String returns the string representation of a Network
+func (n Network) String() string {
+	switch n {
+	case NetworkMainnet:
+		return "mainnet"
+	case NetworkTestnet:
+		return "testnet"
+	case NetworkRegtest:
+		return "regtest"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(n))
+	}
+}
+
+// AddressInfo describes a decoded address
+type AddressInfo struct {
+	Kind    AddressKind
+	Network Network
+}
+
+// Base58Check version prefixes for transparent addresses
+var transparentPrefixes = map[[2]byte]AddressInfo{
+	{0x1C, 0xB8}: {AddressP2PKH, NetworkMainnet}, // t1
+	{0x1C, 0xBD}: {AddressP2SH, NetworkMainnet},  // t3
+	{0x1D, 0x25}: {AddressP2PKH, NetworkTestnet}, // tm
+	{0x1C, 0xBA}: {AddressP2SH, NetworkTestnet},  // t2
+}
+
+// Bech32 human-readable parts for shielded addresses
+var shieldedHRPs = map[string]AddressInfo{
+	"zs":              {AddressSapling, NetworkMainnet},
+	"ztestsapling":    {AddressSapling, NetworkTestnet},
+	"zregtestsapling": {AddressSapling, NetworkRegtest},
+	"u":               {AddressUnified, NetworkMainnet},
+	"utest":           {AddressUnified, NetworkTestnet},
+	"uregtest":        {AddressUnified, NetworkRegtest},
+}
+
+// saplingAddressLength is the length of a raw Sapling payment address
+const saplingAddressLength = 43
+
+// ValidateAddress decodes addr and returns its kind and network.
+//
+// Transparent addresses are Base58Check decoded, Sapling addresses Bech32
+// decoded and unified addresses Bech32m decoded; the checksum is verified in
+// each case, and unified addresses must contain valid receivers (see
+// DecodeUnifiedAddress). Transparent testnet and regtest addresses share an
+// encoding and are reported as NetworkTestnet.
+//
+// Returns a descriptive error for malformed addresses and checksum failures.
+func ValidateAddress(addr string) (AddressInfo, error) {
+	if addr == "" {
+		return AddressInfo{}, errors.New("empty address")
+	}
+
+	if strings.HasPrefix(addr, "t") {
+		payload, err := base58CheckDecode(addr)
+		if err != nil {
+			return AddressInfo{}, fmt.Errorf("invalid transparent address %s: %w", addr, err)
+		}
+		if len(payload) != 22 {
+			return AddressInfo{}, fmt.Errorf("invalid transparent address %s: expected 22 byte payload, got %d", addr, len(payload))
+		}
+		info, ok := transparentPrefixes[[2]byte{payload[0], payload[1]}]
+		if !ok {
+			return AddressInfo{}, fmt.Errorf("invalid transparent address %s: unknown version prefix %x", addr, payload[:2])
+		}
+		return info, nil
+	}
+
+	hrp, data5, variant, err := bech32Decode(addr)
+	if err != nil {
+		return AddressInfo{}, fmt.Errorf("invalid address %s: %w", addr, err)
+	}
+	info, ok := shieldedHRPs[hrp]
+	if !ok {
+		return AddressInfo{}, fmt.Errorf("invalid address %s: unknown prefix %q", addr, hrp)
+	}
+	data, err := convertBits(data5, 5, 8, false)
+	if err != nil {
+		return AddressInfo{}, fmt.Errorf("invalid address %s: %w", addr, err)
+	}
+
+	switch info.Kind {
+	case AddressSapling:
+		if variant != bech32Variant {
+			return AddressInfo{}, fmt.Errorf("invalid Sapling address %s: expected Bech32 checksum", addr)
+		}
+		if len(data) != saplingAddressLength {
+			return AddressInfo{}, fmt.Errorf("invalid Sapling address %s: expected %d bytes, got %d", addr, saplingAddressLength, len(data))
+		}
+	case AddressUnified:
+		if variant != bech32mVariant {
+			return AddressInfo{}, fmt.Errorf("invalid unified address %s: expected Bech32m checksum", addr)
+		}
+		if _, err := DecodeUnifiedAddress(addr); err != nil {
+			return AddressInfo{}, err
+		}
+	}
+
+	return info, nil
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58CheckDecode decodes a Base58Check string and verifies its checksum
+func base58CheckDecode(s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for i, r := range s {
+		digit := strings.IndexRune(base58Alphabet, r)
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid Base58 character %q at position %d", r, i)
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(digit)))
+	}
+
+	decoded := n.Bytes()
+	for _, r := range s {
+		if r != '1' {
+			break
+		}
+		decoded = append([]byte{0}, decoded...)
+	}
+
+	if len(decoded) < 4 {
+		return nil, errors.New("too short")
+	}
+	payload, checksum := decoded[:len(decoded)-4], decoded[len(decoded)-4:]
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	if !bytes.Equal(second[:4], checksum) {
+		return nil, errors.New("checksum mismatch")
+	}
+	return payload, nil
+}
+
+// base58CheckEncode encodes payload with a 4-byte double-SHA-256 checksum
+func base58CheckEncode(payload []byte) string {
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	data := append(append([]byte(nil), payload...), second[:4]...)
+
+	n := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	var encoded []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		encoded = append(encoded, '1')
+	}
+
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
+
+// Bech32 checksum variants (BIP 173 and BIP 350)
+const (
+	bech32Variant  = 1
+	bech32mVariant = 0x2bc830a3
+)
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// bech32Polymod computes the Bech32 checksum polynomial over 5-bit values
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+// bech32Decode decodes a Bech32 or Bech32m string into its human-readable
+// part and 5-bit data values, reporting which checksum variant matched.
+//
+// Unlike BIP 173 there is no 90 character limit, since unified addresses
+// are longer.
+func bech32Decode(s string) (hrp string, data5 []byte, variant uint32, err error) {
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, 0, errors.New("mixed case")
+	}
+	s = strings.ToLower(s)
+
+	sep := strings.LastIndexByte(s, '1')
+	if sep < 1 || sep+7 > len(s) {
+		return "", nil, 0, errors.New("missing separator or checksum")
+	}
+	hrp = s[:sep]
+
+	values := make([]byte, 0, len(hrp)*2+1+len(s)-sep-1)
+	for i := 0; i < len(hrp); i++ {
+		values = append(values, hrp[i]>>5)
+	}
+	values = append(values, 0)
+	for i := 0; i < len(hrp); i++ {
+		values = append(values, hrp[i]&31)
+	}
+	for i := sep + 1; i < len(s); i++ {
+		v := strings.IndexByte(bech32Charset, s[i])
+		if v < 0 {
+			return "", nil, 0, fmt.Errorf("invalid Bech32 character %q at position %d", s[i], i)
+		}
+		values = append(values, byte(v))
+	}
+
+	variant = bech32Polymod(values)
+	if variant != bech32Variant && variant != bech32mVariant {
+		return "", nil, 0, errors.New("checksum mismatch")
+	}
+
+	return hrp, values[len(hrp)*2+1 : len(values)-6], variant, nil
+}
+
+// convertBits regroups a slice of fromBits-bit values into toBits-bit values
+func convertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var acc, bits uint
+	maxv := uint(1)<<toBits - 1
+	var out []byte
+	for _, v := range data {
+		acc = acc<<fromBits | uint(v)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(toBits-bits)&maxv))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxv != 0 {
+		return nil, errors.New("invalid padding")
+	}
+	return out, nil
+}
+
+// ErrNonStandardScript is returned by ScriptToAddress for a script that is
+// neither P2PKH nor P2SH
+var ErrNonStandardScript = errors.New("script is not P2PKH or P2SH")
+
+// ScriptToAddress returns the transparent address paid by a scriptPubKey on
+// net, and its kind. Regtest uses the testnet encoding (tm..., t2...).
+//
+// P2PKH and P2SH scripts are recognized; other scripts, such as the
+// OP_RETURN outputs of some coinbase transactions, return an error wrapping
+// ErrNonStandardScript.
+func ScriptToAddress(script []byte, net Network) (string, AddressKind, error) {
+	if net != NetworkMainnet && net != NetworkTestnet && net != NetworkRegtest {
+		return "", 0, fmt.Errorf("invalid network %s", net)
+	}
+	mainnet := net == NetworkMainnet
+
+	var prefix []byte
+	var hash []byte
+	var kind AddressKind
+	switch {
+	case isP2PKHScript(script):
+		prefix = []byte{0x1D, 0x25}
+		if mainnet {
+			prefix = []byte{0x1C, 0xB8}
+		}
+		hash = script[3:23]
+		kind = AddressP2PKH
+	case isP2SHScript(script):
+		prefix = []byte{0x1C, 0xBA}
+		if mainnet {
+			prefix = []byte{0x1C, 0xBD}
+		}
+		hash = script[2:22]
+		kind = AddressP2SH
+	default:
+		return "", 0, fmt.Errorf("%w (%d bytes: %x)", ErrNonStandardScript, len(script), script)
+	}
+
+	return base58CheckEncode(append(prefix, hash...)), kind, nil
+}
+
+// scriptToAddress returns the transparent address paid by a P2PKH or P2SH
+// script. Other scripts return an error wrapping ErrNotImplemented, since the
+// native library can only build outputs from addresses.
+func scriptToAddress(script []byte, mainnet bool) (string, error) {
+	net := NetworkTestnet
+	if mainnet {
+		net = NetworkMainnet
+	}
+	addr, _, err := ScriptToAddress(script, net)
+	if errors.Is(err, ErrNonStandardScript) {
+		return "", fmt.Errorf("only P2PKH and P2SH scripts can be used as outputs (%d bytes: %x): %w", len(script), script, ErrNotImplemented)
+	}
+	return addr, err
+}
+
+// addressToScript returns the P2PKH or P2SH script paid by a transparent
+// address
+func addressToScript(addr string) ([]byte, error) {
+	info, err := ValidateAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Kind.IsTransparent() {
+		return nil, fmt.Errorf("%s is not a transparent address", addr)
+	}
+
+	payload, err := base58CheckDecode(addr)
+	if err != nil {
+		return nil, err
+	}
+	hash := payload[2:]
+	if info.Kind == AddressP2SH {
+		script := append([]byte{0xa9, 0x14}, hash...) // OP_HASH160 PUSH20
+		return append(script, 0x87), nil              // OP_EQUAL
+	}
+	return p2pkhScript(hash), nil
+}
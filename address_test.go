@@ -0,0 +1,114 @@
+package t2z
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Test that ValidateAddress decodes kind and network
+func TestValidateAddress(t *testing.T) {
+	tests := []struct {
+		addr    string
+		kind    AddressKind
+		network Network
+	}{
+		{"tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", AddressP2PKH, NetworkTestnet},
+		{"t1HsdDMzmJfq4vc7T17XYjEkLMLvbgM1fCi", AddressP2PKH, NetworkMainnet},
+		{"t3JZe8uVCra9T1mot8DC99s7GVsDKFy2Xa2", AddressP2SH, NetworkMainnet},
+		{"zs1qqqsyqcyq5rqwzqfpg9scrgwpugpzysnzs23v9ccrydpk8qarc0jqgfzyvjz2f389q5j5ctfvp5", AddressSapling, NetworkMainnet},
+		{"ztestsapling1qqqsyqcyq5rqwzqfpg9scrgwpugpzysnzs23v9ccrydpk8qarc0jqgfzyvjz2f389q5j5sum0xq", AddressSapling, NetworkTestnet},
+		{testShieldedAddress, AddressUnified, NetworkMainnet},
+		{strings.ToUpper(testShieldedAddress), AddressUnified, NetworkMainnet},
+	}
+
+	for _, tt := range tests {
+		info, err := ValidateAddress(tt.addr)
+		if err != nil {
+			t.Errorf("ValidateAddress(%q) failed: %v", tt.addr, err)
+			continue
+		}
+		if info.Kind != tt.kind || info.Network != tt.network {
+			t.Errorf("ValidateAddress(%q) = %s/%s, want %s/%s", tt.addr, info.Kind, info.Network, tt.kind, tt.network)
+		}
+	}
+}
+
+// Test that malformed addresses are rejected with a descriptive error
+func TestValidateAddressInvalid(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"", "empty"},
+		{"tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Mb", "checksum mismatch"},
+		{"tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr70a", "invalid Base58 character"},
+		{testShieldedAddress[:len(testShieldedAddress)-1] + "q", "checksum mismatch"},
+		// Bech32m checksum on a Sapling address
+		{"zs1qqqsyqcyq5rqwzqfpg9scrgwpugpzysnzs23v9ccrydpk8qarc0jqgfzyvjz2f389q5j5dheqyk", "expected Bech32 checksum"},
+		{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", "unknown prefix"},
+		{"u1Eq7cm60un363n2sa862w4t5pq56tl5x0d7wqkzhhva0sxue7kqw85haa6w6xsz8n8ujmcpkzsza8knwgglau443s7ljdgu897yrvyhhz", "mixed case"},
+	}
+
+	for _, tt := range tests {
+		_, err := ValidateAddress(tt.addr)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ValidateAddress(%q): expected error containing %q, got %v", tt.addr, tt.want, err)
+		}
+	}
+}
+
+// Test that ScriptToAddress round-trips with addressToScript on every network
+func TestScriptToAddressExported(t *testing.T) {
+	for _, addr := range []string{"t1HsdDMzmJfq4vc7T17XYjEkLMLvbgM1fCi", "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma"} {
+		script, err := addressToScript(addr)
+		if err != nil {
+			t.Fatalf("addressToScript(%q) failed: %v", addr, err)
+		}
+		info, _ := ValidateAddress(addr)
+		got, kind, err := ScriptToAddress(script, info.Network)
+		if err != nil || got != addr || kind != AddressP2PKH {
+			t.Errorf("ScriptToAddress = %q, %s, %v; want %q", got, kind, err, addr)
+		}
+	}
+
+	// Regtest shares the testnet encoding
+	script, _ := addressToScript("tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma")
+	if got, _, _ := ScriptToAddress(script, NetworkRegtest); got != "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma" {
+		t.Errorf("Expected the testnet encoding on regtest, got %q", got)
+	}
+
+	p2sh := append(append([]byte{0xa9, 0x14}, make([]byte, 20)...), 0x87)
+	if addr, kind, err := ScriptToAddress(p2sh, NetworkMainnet); err != nil || kind != AddressP2SH || !strings.HasPrefix(addr, "t3") {
+		t.Errorf("ScriptToAddress(P2SH) = %q, %s, %v", addr, kind, err)
+	}
+
+	if _, _, err := ScriptToAddress([]byte{0x6a, 0x01, 0x00}, NetworkMainnet); !errors.Is(err, ErrNonStandardScript) {
+		t.Errorf("Expected ErrNonStandardScript for OP_RETURN, got %v", err)
+	}
+	if _, _, err := ScriptToAddress(script, Network(0)); err == nil {
+		t.Error("Expected an error for an invalid network")
+	}
+}
+
+// Test that scripts converted to addresses decode back to the same kind
+func TestScriptToAddress(t *testing.T) {
+	hash := make([]byte, 20)
+	p2sh := append(append([]byte{0xa9, 0x14}, hash...), 0x87)
+
+	for _, mainnet := range []bool{true, false} {
+		for script, kind := range map[string]AddressKind{string(p2pkhScript(hash)): AddressP2PKH, string(p2sh): AddressP2SH} {
+			addr, err := scriptToAddress([]byte(script), mainnet)
+			if err != nil {
+				t.Fatalf("scriptToAddress failed: %v", err)
+			}
+			info, err := ValidateAddress(addr)
+			if err != nil {
+				t.Fatalf("ValidateAddress(%q) failed: %v", addr, err)
+			}
+			if info.Kind != kind || (info.Network == NetworkMainnet) != mainnet {
+				t.Errorf("%s: got %s/%s, want %s (mainnet=%v)", addr, info.Kind, info.Network, kind, mainnet)
+			}
+		}
+	}
+}
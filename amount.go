@@ -0,0 +1,128 @@
+package t2z
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ZatoshisPerZec is the number of zatoshis in one ZEC
+	ZatoshisPerZec = 100_000_000
+
+	// MaxMoney is the total ZEC supply in zatoshis; no valid amount exceeds it
+	MaxMoney = 21_000_000 * ZatoshisPerZec
+)
+
+var (
+	// ErrAmountOverflow is returned when an Amount sum exceeds MaxMoney
+	ErrAmountOverflow = errors.New("amount exceeds the maximum ZEC supply")
+
+	// ErrAmountUnderflow is returned when an Amount difference is negative
+	ErrAmountUnderflow = errors.New("amount would be negative")
+)
+
+// Amount is a ZEC amount in zatoshis, with overflow-checked arithmetic for
+// summing many inputs or outputs. Convert from zatoshis with Amount(zat) or
+// NewAmount, and back with Zatoshis.
+type Amount uint64
+
+// NewAmount returns zat as an Amount, or an error wrapping ErrAmountOverflow
+// if it exceeds MaxMoney
+func NewAmount(zat uint64) (Amount, error) {
+	if zat > MaxMoney {
+		return 0, fmt.Errorf("%d zatoshis: %w", zat, ErrAmountOverflow)
+	}
+	return Amount(zat), nil
+}
+
+// AddChecked returns a + b, or an error wrapping ErrAmountOverflow if the sum
+// exceeds MaxMoney
+func (a Amount) AddChecked(b Amount) (Amount, error) {
+	sum, carry := bits.Add64(uint64(a), uint64(b), 0)
+	if carry != 0 || sum > MaxMoney {
+		return 0, fmt.Errorf("%d + %d zatoshis: %w", a, b, ErrAmountOverflow)
+	}
+	return Amount(sum), nil
+}
+
+// SubChecked returns a - b, or an error wrapping ErrAmountUnderflow if b is
+// greater than a
+func (a Amount) SubChecked(b Amount) (Amount, error) {
+	if b > a {
+		return 0, fmt.Errorf("%d - %d zatoshis: %w", a, b, ErrAmountUnderflow)
+	}
+	return a - b, nil
+}
+
+// Zatoshis returns the amount in zatoshis
+func (a Amount) Zatoshis() uint64 {
+	return uint64(a)
+}
+
+// String formats the amount in ZEC, like FormatZec
+func (a Amount) String() string {
+	return FormatZec(uint64(a))
+}
+
+// sumInputs returns the total value of inputs
+func sumInputs(inputs []TransparentInput) (Amount, error) {
+	var total Amount
+	for i, input := range inputs {
+		var err error
+		if total, err = total.AddChecked(Amount(input.Amount)); err != nil {
+			return 0, fmt.Errorf("input %d: %w", i, err)
+		}
+	}
+	return total, nil
+}
+
+// ParseZec parses a decimal ZEC amount such as "1", "0.5" or "0.00000001"
+// into zatoshis, without floating point. It is the inverse of FormatZec.
+//
+// The amount must be an integer part of ASCII digits, optionally followed by
+// a point and 1 to 8 fractional digits. Signs, exponents, separators and
+// amounts above MaxMoney are rejected.
+func ParseZec(s string) (uint64, error) {
+	whole, frac, hasPoint := strings.Cut(s, ".")
+	if whole == "" || !isDigits(whole) || (hasPoint && (frac == "" || !isDigits(frac))) {
+		return 0, fmt.Errorf("invalid ZEC amount %q", s)
+	}
+	if len(frac) > 8 {
+		return 0, fmt.Errorf("invalid ZEC amount %q: more than 8 decimal places", s)
+	}
+
+	zec, err := strconv.ParseUint(whole, 10, 64)
+	if err != nil || zec > MaxMoney/ZatoshisPerZec {
+		return 0, fmt.Errorf("invalid ZEC amount %q: exceeds the maximum supply", s)
+	}
+	var zat uint64
+	if frac != "" {
+		zat, _ = strconv.ParseUint(frac+strings.Repeat("0", 8-len(frac)), 10, 64)
+	}
+
+	total := zec*ZatoshisPerZec + zat
+	if total > MaxMoney {
+		return 0, fmt.Errorf("invalid ZEC amount %q: exceeds the maximum supply", s)
+	}
+	return total, nil
+}
+
+// FormatZec formats zatoshis as a ZEC amount with 8 decimal places, such as
+// "0.50000000". For amounts up to MaxMoney, ParseZec(FormatZec(zat)) returns
+// zat.
+func FormatZec(zat uint64) string {
+	return fmt.Sprintf("%d.%08d", zat/ZatoshisPerZec, zat%ZatoshisPerZec)
+}
+
+// isDigits reports whether s consists of ASCII digits only
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
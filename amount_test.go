@@ -0,0 +1,105 @@
+package t2z
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// Test that ZEC amounts parse exactly and reject malformed input
+func TestParseZec(t *testing.T) {
+	tests := []struct {
+		in   string
+		want uint64
+		ok   bool
+	}{
+		{"1", 100_000_000, true},
+		{"0.5", 50_000_000, true},
+		{"0.00000001", 1, true},
+		{"0.1", 10_000_000, true},
+		{"1.23456789", 123_456_789, true},
+		// 0.29 * 1e8 is 28999999.999999996 as a float64
+		{"0.29", 29_000_000, true},
+		{"21000000", MaxMoney, true},
+		{"20999999.99999999", MaxMoney - 1, true},
+		{"007.10", 710_000_000, true},
+		{"21000000.00000001", 0, false},
+		{"99999999999999999999", 0, false},
+		{"0.000000001", 0, false},
+		{"", 0, false},
+		{".5", 0, false},
+		{"1.", 0, false},
+		{"-1", 0, false},
+		{"+1", 0, false},
+		{"1e8", 0, false},
+		{"1,5", 0, false},
+		{" 1", 0, false},
+		{"1.2.3", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseZec(tt.in)
+		if tt.ok && (err != nil || got != tt.want) {
+			t.Errorf("ParseZec(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("ParseZec(%q) = %d; want error", tt.in, got)
+		}
+	}
+}
+
+// Test that FormatZec is exact and round-trips through ParseZec
+func TestFormatZec(t *testing.T) {
+	tests := map[uint64]string{
+		0:            "0.00000000",
+		1:            "0.00000001",
+		50_000_000:   "0.50000000",
+		123_456_789:  "1.23456789",
+		MaxMoney - 1: "20999999.99999999",
+	}
+	for zat, want := range tests {
+		got := FormatZec(zat)
+		if got != want {
+			t.Errorf("FormatZec(%d) = %q, want %q", zat, got, want)
+		}
+		if back, err := ParseZec(got); err != nil || back != zat {
+			t.Errorf("ParseZec(FormatZec(%d)) = %d, %v", zat, back, err)
+		}
+	}
+}
+
+// Test that Amount arithmetic reports overflow and underflow
+func TestAmount(t *testing.T) {
+	if _, err := NewAmount(MaxMoney + 1); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("NewAmount above MaxMoney: expected ErrAmountOverflow, got %v", err)
+	}
+	if sum, err := Amount(MaxMoney - 1).AddChecked(1); err != nil || sum != MaxMoney {
+		t.Errorf("AddChecked up to MaxMoney = %d, %v", sum, err)
+	}
+	if _, err := Amount(MaxMoney).AddChecked(1); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("AddChecked above MaxMoney: expected ErrAmountOverflow, got %v", err)
+	}
+	if _, err := Amount(math.MaxUint64).AddChecked(2); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("AddChecked wrapping uint64: expected ErrAmountOverflow, got %v", err)
+	}
+	if diff, err := Amount(5).SubChecked(5); err != nil || diff != 0 {
+		t.Errorf("SubChecked(5, 5) = %d, %v", diff, err)
+	}
+	if _, err := Amount(5).SubChecked(6); !errors.Is(err, ErrAmountUnderflow) {
+		t.Errorf("SubChecked below zero: expected ErrAmountUnderflow, got %v", err)
+	}
+	if s := Amount(123_456_789).String(); s != "1.23456789" {
+		t.Errorf("String() = %q", s)
+	}
+
+	// Input sums that would wrap a uint64 are errors, not small totals
+	huge := []TransparentInput{{Amount: math.MaxUint64}, {Amount: 2}}
+	if _, err := MaxSendableAmount(huge, 1, 0); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("MaxSendableAmount: expected ErrAmountOverflow, got %v", err)
+	}
+	if _, _, err := SelectUTXOs(huge, 10_000, func(n int) uint64 { return CalculateFee(n, 1, 0) }); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("SelectUTXOs: expected ErrAmountOverflow, got %v", err)
+	}
+	if _, err := ChangeAmount(huge, nil, 0); !errors.Is(err, ErrAmountOverflow) {
+		t.Errorf("ChangeAmount: expected ErrAmountOverflow, got %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package t2z
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Consensus branch IDs of the network upgrades that use v5 transactions
+var v5BranchIDs = map[uint32]string{
+	0xc2d6d0b4: "NU5",
+	0xc8e71055: "NU6",
+	0x4dec4df0: "NU6.1",
+}
+
+// SetConsensusBranchID overrides the consensus branch ID that SetTargetHeight
+// derives from the target height, for networks with a custom activation
+// schedule. The override takes precedence regardless of the order in which
+// the two are called; the target height still sets the expiry height.
+//
+// The native library can only compute signature hashes for upgrades it knows,
+// so the ID must be one of NU5 (0xc2d6d0b4), NU6 (0xc8e71055) or NU6.1
+// (0x4dec4df0). Other IDs return an error wrapping ErrNotImplemented.
+//
+// The library signs the dummy spends of Orchard actions during proposal, with
+// the height-derived branch ID, so an override cannot be applied to a
+// transaction with Orchard outputs. Proposing such a request returns an error
+// wrapping ErrNotImplemented.
+func (r *TransactionRequest) SetConsensusBranchID(id uint32) error {
+	if r == nil || r.handle == nil {
+		return errors.New("invalid transaction request")
+	}
+	if _, ok := v5BranchIDs[id]; !ok {
+		return fmt.Errorf("consensus branch ID 0x%08x is not supported by the native library: %w", id, ErrNotImplemented)
+	}
+
+	r.consensusBranchID = id
+	return nil
+}
+
+// withConsensusBranchID returns a PCZT equal to pczt but with the given
+// consensus branch ID. The input PCZT is consumed.
+func withConsensusBranchID(pczt *PCZT, id uint32) (*PCZT, error) {
+	defer pczt.Free()
+
+	data, err := SerializePCZT(pczt)
+	if err != nil {
+		return nil, err
+	}
+	p, err := decodePCZT(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.OrchardActions) > 0 {
+		return nil, fmt.Errorf("custom consensus branch ID: Orchard actions are signed during proposal with the derived branch ID: %w", ErrNotImplemented)
+	}
+
+	// The branch ID follows the tx version and version group ID
+	r := &pcztReader{buf: data, off: len(pcztMagic) + 4}
+	r.uint32("tx version")
+	r.uint32("version group ID")
+	start := r.off
+	r.uint32("consensus branch ID")
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	patched := make([]byte, 0, len(data)+binary.MaxVarintLen32)
+	patched = append(patched, data[:start]...)
+	patched = binary.AppendUvarint(patched, uint64(id))
+	patched = append(patched, data[r.off:]...)
+	return ParsePCZT(patched)
+}
@@ -0,0 +1,92 @@
+//go:build (cgo && (darwin || linux || windows) && (amd64 || arm64)) || t2z_mock
+
+package t2z
+
+import (
+	"errors"
+	"testing"
+)
+
+// Test that a custom consensus branch ID overrides the height-derived one
+// and ends up in the extracted transaction
+func TestSetConsensusBranchID(t *testing.T) {
+	privateKey, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_branch_id_000000000000"))
+
+	inputs := []TransparentInput{{
+		Pubkey:       pubkey,
+		TxID:         txid,
+		Amount:       100_000_000,
+		ScriptPubKey: createP2PKHScript(pubkey),
+	}}
+	request, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 50_000_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+
+	if err := request.SetConsensusBranchID(0xdeadbeef); !errors.Is(err, ErrNotImplemented) {
+		t.Errorf("Expected ErrNotImplemented for an unknown branch ID, got %v", err)
+	}
+
+	// Height 2,500,000 is in NU5 on mainnet
+	const nu6 uint32 = 0xc8e71055
+	if err := request.SetConsensusBranchID(nu6); err != nil {
+		t.Fatalf("SetConsensusBranchID failed: %v", err)
+	}
+	request.SetTargetHeight(2_500_000)
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+	data, err := SerializePCZT(pczt)
+	if err != nil {
+		t.Fatalf("Failed to serialize PCZT: %v", err)
+	}
+	contents, err := decodePCZT(data)
+	if err != nil {
+		t.Fatalf("decodePCZT failed: %v", err)
+	}
+	if contents.ConsensusBranchID != nu6 {
+		t.Errorf("PCZT branch ID = 0x%08x, want 0x%08x", contents.ConsensusBranchID, nu6)
+	}
+
+	sighash, err := GetSighash(pczt, 0)
+	if err != nil {
+		t.Fatalf("Failed to get sighash: %v", err)
+	}
+	signature, err := signMessage(privateKey, sighash)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	signed, err := AppendSignature(pczt, 0, signature)
+	if err != nil {
+		t.Fatalf("Failed to append signature: %v", err)
+	}
+	txBytes, err := FinalizeAndExtract(signed)
+	if err != nil {
+		t.Fatalf("Failed to finalize: %v", err)
+	}
+	tx, err := DecodeTransaction(txBytes)
+	if err != nil {
+		t.Fatalf("Failed to decode transaction: %v", err)
+	}
+	if tx.ConsensusBranchID != nu6 {
+		t.Errorf("transaction branch ID = 0x%08x, want 0x%08x", tx.ConsensusBranchID, nu6)
+	}
+
+	// Orchard dummy spends are signed during proposal
+	shielded, err := NewTransactionRequest([]Payment{{Address: testShieldedAddress, Amount: 100_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer shielded.Free()
+	shielded.SetTargetHeight(2_500_000)
+	shielded.SetConsensusBranchID(nu6)
+	if _, err := ProposeTransaction(inputs, shielded); !errors.Is(err, ErrNotImplemented) {
+		t.Errorf("Expected ErrNotImplemented for a shielded output, got %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package t2z
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Signature bundle layout: magic, format version, input index (little-endian),
+// sighash, signature and compressed pubkey
+var signatureBundleMagic = []byte("T2ZS")
+
+const (
+	signatureBundleVersion = 1
+	signatureBundleSize    = 4 + 1 + 4 + 32 + 64 + 33
+)
+
+// ErrSighashMismatch is returned by ApplyBundle when a bundle was signed for a
+// different input or transaction than the PCZT it is applied to
+var ErrSighashMismatch = errors.New("sighash does not match the PCZT")
+
+// SignatureBundle is a detached signature of a transparent input, carrying
+// what was signed along with the signature. Pass it between an online device
+// and a hardware wallet instead of a bare signature, so ApplyBundle can reject
+// a stale or mismatched signature.
+type SignatureBundle struct {
+	// InputIndex is the index of the signed transparent input
+	InputIndex uint
+
+	// Sighash is the signature hash that was signed, from GetSighash
+	Sighash [32]byte
+
+	// Signature is the 64-byte ECDSA signature (r: 32 bytes, s: 32 bytes)
+	Signature [64]byte
+
+	// Pubkey is the 33-byte compressed public key of the signer
+	Pubkey []byte
+}
+
+// MarshalBinary encodes the bundle in a fixed-size, versioned format
+func (b SignatureBundle) MarshalBinary() ([]byte, error) {
+	if len(b.Pubkey) != 33 {
+		return nil, fmt.Errorf("invalid pubkey length: expected 33, got %d", len(b.Pubkey))
+	}
+	if b.InputIndex > math.MaxUint32 {
+		return nil, fmt.Errorf("input index %d out of range", b.InputIndex)
+	}
+
+	data := make([]byte, 0, signatureBundleSize)
+	data = append(data, signatureBundleMagic...)
+	data = append(data, signatureBundleVersion)
+	data = binary.LittleEndian.AppendUint32(data, uint32(b.InputIndex))
+	data = append(data, b.Sighash[:]...)
+	data = append(data, b.Signature[:]...)
+	return append(data, b.Pubkey...), nil
+}
+
+// UnmarshalBinary decodes a bundle encoded by MarshalBinary
+func (b *SignatureBundle) UnmarshalBinary(data []byte) error {
+	if len(data) < len(signatureBundleMagic) || !bytes.Equal(data[:len(signatureBundleMagic)], signatureBundleMagic) {
+		return fmt.Errorf("not a signature bundle (missing %q header)", signatureBundleMagic)
+	}
+	if len(data) < 5 {
+		return errors.New("truncated signature bundle")
+	}
+	if data[4] != signatureBundleVersion {
+		return fmt.Errorf("unsupported signature bundle version %d (supported: %d)", data[4], signatureBundleVersion)
+	}
+	if len(data) != signatureBundleSize {
+		return fmt.Errorf("invalid signature bundle length: expected %d, got %d", signatureBundleSize, len(data))
+	}
+
+	b.InputIndex = uint(binary.LittleEndian.Uint32(data[5:]))
+	copy(b.Sighash[:], data[9:41])
+	copy(b.Signature[:], data[41:105])
+	b.Pubkey = append([]byte(nil), data[105:]...)
+	return nil
+}
+
+// ApplyBundle checks a signature bundle against a PCZT and appends its
+// signature.
+//
+// The bundle's sighash must equal the PCZT's sighash for the input (computed
+// with the sighash type chosen by GetSighashWithType, if any), the pubkey
+// must be the one the input's script pays, and the signature must verify.
+// A bundle for another input or transaction returns an error wrapping
+// ErrSighashMismatch.
+//
+// IMPORTANT: Like AppendSignature, this function ALWAYS consumes the input
+// PCZT, even on error.
+//
+// Returns a new PCZT with the signature added.
+func ApplyBundle(pczt *PCZT, b SignatureBundle) (*PCZT, error) {
+	if pczt == nil {
+		return nil, errors.New("invalid PCZT")
+	}
+	if err := checkBundle(pczt, b); err != nil {
+		pczt.Free()
+		return nil, err
+	}
+	return AppendSignature(pczt, b.InputIndex, b.Signature)
+}
+
+// CombineSignatures applies detached signatures from several signers to one
+// base PCZT, as a lighter alternative to Combine: each signer returns a
+// SignatureBundle instead of a whole serialized PCZT.
+//
+// Every bundle is checked against the base first, like by ApplyBundle, and
+// no signature is appended unless all of them pass. Two bundles for the same
+// input are an error.
+//
+// IMPORTANT: Like ApplyBundle, this function ALWAYS consumes the base PCZT,
+// even on error.
+//
+// Returns a new PCZT with all the signatures added.
+func CombineSignatures(base *PCZT, sigs []SignatureBundle) (*PCZT, error) {
+	if base == nil {
+		return nil, errors.New("invalid PCZT")
+	}
+
+	seen := make(map[uint]bool, len(sigs))
+	for i, b := range sigs {
+		if seen[b.InputIndex] {
+			base.Free()
+			return nil, fmt.Errorf("bundle %d: input %d is signed by another bundle", i, b.InputIndex)
+		}
+		seen[b.InputIndex] = true
+		if err := checkBundle(base, b); err != nil {
+			base.Free()
+			return nil, fmt.Errorf("bundle %d: %w", i, err)
+		}
+	}
+
+	current := base
+	for i, b := range sigs {
+		var err error
+		current, err = AppendSignature(current, b.InputIndex, b.Signature)
+		if err != nil {
+			return nil, fmt.Errorf("bundle %d: %w", i, err)
+		}
+	}
+	return current, nil
+}
+
+// checkBundle verifies a bundle against the input of a PCZT it signs
+func checkBundle(pczt *PCZT, b SignatureBundle) error {
+	var sighash [32]byte
+	var err error
+	if hashType, ok := pczt.sighashType(b.InputIndex); ok {
+		sighash, err = GetSighashWithType(pczt, b.InputIndex, hashType)
+	} else {
+		sighash, err = GetSighash(pczt, b.InputIndex)
+	}
+	if err != nil {
+		return err
+	}
+	if sighash != b.Sighash {
+		return fmt.Errorf("input %d: %w", b.InputIndex, ErrSighashMismatch)
+	}
+
+	contents, err := inspectPCZT(pczt)
+	if err != nil {
+		return err
+	}
+	if len(b.Pubkey) != 33 || !bytes.Equal(contents.Inputs[b.InputIndex].ScriptPubKey, p2pkhScript(hash160(b.Pubkey))) {
+		return fmt.Errorf("input %d: bundle pubkey %x is not the key the input pays to", b.InputIndex, b.Pubkey)
+	}
+	if !VerifySignature(b.Pubkey, b.Sighash, b.Signature) {
+		return fmt.Errorf("input %d: bundle signature does not verify", b.InputIndex)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package t2z
+
+import (
+	"errors"
+	"testing"
+)
+
+// Test that ApplyBundle appends a matching bundle and rejects one made for
+// another input
+func TestApplyBundle(t *testing.T) {
+	requireBackend(t)
+	privateKey, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_signature_bundle_00000"))
+
+	var inputs []TransparentInput
+	for i := 0; i < 2; i++ {
+		inputs = append(inputs, TransparentInput{
+			Pubkey:       pubkey,
+			TxID:         txid,
+			Vout:         uint32(i),
+			Amount:       10_000_000,
+			ScriptPubKey: createP2PKHScript(pubkey),
+		})
+	}
+
+	request, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 15_000_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+	request.SetTargetHeight(2_500_000)
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+
+	bundles := make([]SignatureBundle, len(inputs))
+	for i := range inputs {
+		sighash, err := GetSighash(pczt, uint(i))
+		if err != nil {
+			t.Fatalf("Failed to get sighash: %v", err)
+		}
+		signature, err := signMessage(privateKey, sighash)
+		if err != nil {
+			t.Fatalf("Failed to sign message: %v", err)
+		}
+		bundles[i] = SignatureBundle{InputIndex: uint(i), Sighash: sighash, Signature: signature, Pubkey: pubkey}
+	}
+
+	// The signature of input 0 applied to input 1
+	clone, err := ClonePCZT(pczt)
+	if err != nil {
+		t.Fatalf("Failed to clone PCZT: %v", err)
+	}
+	wrong := bundles[0]
+	wrong.InputIndex = 1
+	if _, err := ApplyBundle(clone, wrong); !errors.Is(err, ErrSighashMismatch) {
+		t.Errorf("Expected ErrSighashMismatch, got %v", err)
+	}
+
+	// A bundle from a different key
+	clone, err = ClonePCZT(pczt)
+	if err != nil {
+		t.Fatalf("Failed to clone PCZT: %v", err)
+	}
+	wrong = bundles[0]
+	wrong.Pubkey = make([]byte, 33)
+	if _, err := ApplyBundle(clone, wrong); err == nil {
+		t.Error("Expected error for a foreign pubkey, got nil")
+	}
+
+	for _, b := range bundles {
+		data, err := b.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary failed: %v", err)
+		}
+		var decoded SignatureBundle
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("UnmarshalBinary failed: %v", err)
+		}
+		if pczt, err = ApplyBundle(pczt, decoded); err != nil {
+			t.Fatalf("Failed to apply bundle %d: %v", b.InputIndex, err)
+		}
+	}
+
+	if _, err := FinalizeAndExtract(pczt); err != nil {
+		t.Fatalf("Failed to finalize signed PCZT: %v", err)
+	}
+}
+
+func TestSignatureBundleUnmarshalErrors(t *testing.T) {
+	_, pubkey := createTestKeypair()
+	data, err := SignatureBundle{InputIndex: 3, Pubkey: pubkey}.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+
+	var b SignatureBundle
+	if err := b.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Error("Expected error for truncated bundle, got nil")
+	}
+	if err := b.UnmarshalBinary(append([]byte("XXXX"), data[4:]...)); err == nil {
+		t.Error("Expected error for bad magic, got nil")
+	}
+	future := append([]byte(nil), data...)
+	future[4] = 2
+	if err := b.UnmarshalBinary(future); err == nil {
+		t.Error("Expected error for unsupported version, got nil")
+	}
+
+	if _, err := (SignatureBundle{Pubkey: pubkey[:32]}).MarshalBinary(); err == nil {
+		t.Error("Expected error for invalid pubkey length, got nil")
+	}
+}
+
+// Test that CombineSignatures applies all bundles at once, and rejects the
+// set if any bundle does not match the base
+func TestCombineSignatures(t *testing.T) {
+	requireBackend(t)
+	privateKey, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_combine_signatures_000"))
+
+	var inputs []TransparentInput
+	for i := 0; i < 2; i++ {
+		inputs = append(inputs, TransparentInput{
+			Pubkey:       pubkey,
+			TxID:         txid,
+			Vout:         uint32(i),
+			Amount:       10_000_000,
+			ScriptPubKey: createP2PKHScript(pubkey),
+		})
+	}
+
+	request, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 15_000_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+	request.SetTargetHeight(2_500_000)
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+
+	bundles := make([]SignatureBundle, len(inputs))
+	for i := range inputs {
+		sighash, err := GetSighash(pczt, uint(i))
+		if err != nil {
+			t.Fatalf("Failed to get sighash: %v", err)
+		}
+		signature, err := signMessage(privateKey, sighash)
+		if err != nil {
+			t.Fatalf("Failed to sign message: %v", err)
+		}
+		bundles[i] = SignatureBundle{InputIndex: uint(i), Sighash: sighash, Signature: signature, Pubkey: pubkey}
+	}
+
+	// One bad bundle rejects the whole set
+	clone, err := ClonePCZT(pczt)
+	if err != nil {
+		t.Fatalf("Failed to clone PCZT: %v", err)
+	}
+	wrong := bundles[0]
+	wrong.InputIndex = 1
+	if _, err := CombineSignatures(clone, []SignatureBundle{bundles[0], wrong}); !errors.Is(err, ErrSighashMismatch) {
+		t.Errorf("Expected ErrSighashMismatch, got %v", err)
+	}
+
+	// Two bundles for the same input
+	clone, err = ClonePCZT(pczt)
+	if err != nil {
+		t.Fatalf("Failed to clone PCZT: %v", err)
+	}
+	if _, err := CombineSignatures(clone, []SignatureBundle{bundles[1], bundles[1]}); err == nil {
+		t.Error("Expected error for a duplicate input, got nil")
+	}
+
+	if _, err := CombineSignatures(nil, bundles); err == nil {
+		t.Error("Expected error for a nil PCZT, got nil")
+	}
+
+	// Bundles may arrive in any order
+	signed, err := CombineSignatures(pczt, []SignatureBundle{bundles[1], bundles[0]})
+	if err != nil {
+		t.Fatalf("CombineSignatures failed: %v", err)
+	}
+	if _, err := FinalizeAndExtract(signed); err != nil {
+		t.Fatalf("Failed to finalize signed PCZT: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package t2z
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// Commitment output format
+//
+// A commitment is an OP_RETURN output that binds a transaction to external
+// data, e.g. the SHA-256 of an off-chain document for timestamping:
+//
+//	OP_RETURN <push: version || len(tag) || tag || hash>
+//
+// where version is CommitmentVersion (1 byte), len(tag) is 1 byte, tag is
+// 1 to MaxCommitmentTagLength bytes of printable ASCII, and hash is 32 bytes.
+// The whole payload fits in the standard 80-byte OP_RETURN limit.
+//
+// The native library does not yet accept OP_RETURN outputs in a
+// TransactionRequest, so these helpers only build and parse the script (see
+// NewDataScript).
+const (
+	// CommitmentVersion is the version byte of the commitment payload
+	CommitmentVersion = 0x01
+
+	// MaxCommitmentTagLength is the maximum tag length in bytes
+	MaxCommitmentTagLength = 40
+
+	opReturn    = 0x6a
+	opPushData1 = 0x4c
+)
+
+// Commitment is a parsed commitment output
+type Commitment struct {
+	Tag  string
+	Hash [32]byte
+}
+
+// NewCommitmentScript builds the OP_RETURN script committing to hash under tag.
+//
+// Returns an error if the tag is empty, too long, or not printable ASCII.
+func NewCommitmentScript(tag string, hash [32]byte) ([]byte, error) {
+	if len(tag) == 0 || len(tag) > MaxCommitmentTagLength {
+		return nil, fmt.Errorf("invalid commitment tag length: expected 1-%d, got %d", MaxCommitmentTagLength, len(tag))
+	}
+	for i := 0; i < len(tag); i++ {
+		if tag[i] < 0x20 || tag[i] > 0x7e {
+			return nil, fmt.Errorf("invalid commitment tag: byte %d is not printable ASCII", i)
+		}
+	}
+
+	payload := make([]byte, 0, 2+len(tag)+32)
+	payload = append(payload, CommitmentVersion, byte(len(tag)))
+	payload = append(payload, tag...)
+	payload = append(payload, hash[:]...)
+
+	return NewDataScript(payload)
+}
+
+// MaxDataOutputLength is the standard relay limit for an OP_RETURN payload in bytes
+const MaxDataOutputLength = 80
+
+// NewDataScript builds a zero-value OP_RETURN script carrying data as a
+// single push.
+//
+// The native library cannot add OP_RETURN outputs to a transaction yet:
+// TransactionRequest.AddRawOutput rejects the script with ErrNotImplemented.
+// The script is still useful for constructing transactions elsewhere or for
+// matching outputs of transactions built by other tools.
+//
+// Returns an error if data exceeds MaxDataOutputLength bytes.
+func NewDataScript(data []byte) ([]byte, error) {
+	if len(data) > MaxDataOutputLength {
+		return nil, fmt.Errorf("OP_RETURN payload too long: %d bytes exceeds the %d byte limit", len(data), MaxDataOutputLength)
+	}
+
+	script := []byte{opReturn}
+	if len(data) > 75 {
+		script = append(script, opPushData1)
+	}
+	script = append(script, byte(len(data)))
+	return append(script, data...), nil
+}
+
+// ParseCommitmentScript parses a script built by NewCommitmentScript.
+//
+// Returns an error if the script is not a commitment output.
+func ParseCommitmentScript(script []byte) (*Commitment, error) {
+	if len(script) < 2 || script[0] != opReturn {
+		return nil, errors.New("not an OP_RETURN script")
+	}
+
+	payload := script[1:]
+	if payload[0] == opPushData1 {
+		payload = payload[1:]
+		if len(payload) == 0 {
+			return nil, errors.New("truncated OP_RETURN push")
+		}
+	} else if payload[0] > 75 {
+		return nil, errors.New("unsupported OP_RETURN push opcode")
+	}
+	if int(payload[0]) != len(payload)-1 {
+		return nil, errors.New("OP_RETURN push length mismatch")
+	}
+	payload = payload[1:]
+
+	if len(payload) < 2 || payload[0] != CommitmentVersion {
+		return nil, errors.New("not a commitment payload")
+	}
+	tagLen := int(payload[1])
+	if tagLen == 0 || tagLen > MaxCommitmentTagLength || len(payload) != 2+tagLen+32 {
+		return nil, errors.New("invalid commitment payload length")
+	}
+
+	c := &Commitment{Tag: string(payload[2 : 2+tagLen])}
+	copy(c.Hash[:], payload[2+tagLen:])
+
+	// Reject anything NewCommitmentScript would not have produced
+	rebuilt, err := NewCommitmentScript(c.Tag, c.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(rebuilt, script) {
+		return nil, errors.New("non-canonical commitment script")
+	}
+
+	return c, nil
+}
@@ -0,0 +1,84 @@
+package t2z
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+// Test commitment script round-trip
+func TestCommitmentScriptRoundtrip(t *testing.T) {
+	hash := sha256.Sum256([]byte("document"))
+
+	for _, tag := range []string{"notary", "0123456789012345678901234567890123456789"} {
+		script, err := NewCommitmentScript(tag, hash)
+		if err != nil {
+			t.Fatalf("Failed to build commitment script: %v", err)
+		}
+		if script[0] != opReturn {
+			t.Errorf("Expected OP_RETURN, got %#x", script[0])
+		}
+		if len(script)-2 > 80 {
+			t.Errorf("Payload exceeds 80 bytes: %d", len(script))
+		}
+
+		c, err := ParseCommitmentScript(script)
+		if err != nil {
+			t.Fatalf("Failed to parse commitment script: %v", err)
+		}
+		if c.Tag != tag || c.Hash != hash {
+			t.Errorf("Round-trip mismatch: got tag %q", c.Tag)
+		}
+	}
+}
+
+// Test commitment validation
+func TestCommitmentScriptInvalid(t *testing.T) {
+	var hash [32]byte
+
+	for _, tag := range []string{"", "tag\n", "01234567890123456789012345678901234567890"} {
+		if _, err := NewCommitmentScript(tag, hash); err == nil {
+			t.Errorf("Expected error for tag %q, got nil", tag)
+		}
+	}
+
+	script, _ := NewCommitmentScript("notary", hash)
+	for _, bad := range [][]byte{
+		nil,
+		createP2PKHScript(make([]byte, 33)),
+		script[:len(script)-1],
+		append(append([]byte{}, script...), 0x00),
+	} {
+		if _, err := ParseCommitmentScript(bad); err == nil {
+			t.Errorf("Expected error parsing %x, got nil", bad)
+		}
+	}
+}
+
+// Test OP_RETURN data script encoding and the 80-byte limit
+func TestNewDataScript(t *testing.T) {
+	for _, n := range []int{0, 75, 76, MaxDataOutputLength} {
+		data := make([]byte, n)
+		script, err := NewDataScript(data)
+		if err != nil {
+			t.Fatalf("Failed to build %d byte data script: %v", n, err)
+		}
+		if script[0] != opReturn {
+			t.Errorf("Expected OP_RETURN, got %#x", script[0])
+		}
+
+		header := 2
+		if n > 75 {
+			header = 3
+			if script[1] != opPushData1 {
+				t.Errorf("Expected OP_PUSHDATA1 for %d bytes, got %#x", n, script[1])
+			}
+		}
+		if len(script) != header+n || int(script[header-1]) != n {
+			t.Errorf("Bad encoding for %d bytes: %x", n, script)
+		}
+	}
+
+	if _, err := NewDataScript(make([]byte, MaxDataOutputLength+1)); err == nil {
+		t.Error("Expected error for oversized payload, got nil")
+	}
+}
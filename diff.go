@@ -0,0 +1,253 @@
+package t2z
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// PCZTDiff lists the differences between two PCZTs, see DiffPCZT. Field
+// names are those of DumpPCZTJSON.
+type PCZTDiff struct {
+	// Fields names the differing transaction-wide fields, such as
+	// "expiry_height" or "orchard_proof"
+	Fields []string
+
+	Inputs  []InputDiff
+	Outputs []OutputDiff
+
+	// Fee of each PCZT, in zatoshis
+	FeeA, FeeB int64
+}
+
+// InputDiff lists the differences of one transparent input
+type InputDiff struct {
+	Index int
+	// Added or Removed is set when the input is only in b or only in a
+	Added, Removed bool
+	// Fields names the differing fields other than the partial signatures
+	Fields []string
+
+	// Pubkeys of the partial signatures only in b, only in a, and in both
+	// but with different signatures
+	SignaturesAdded   [][]byte
+	SignaturesRemoved [][]byte
+	SignaturesChanged [][]byte
+}
+
+// OutputDiff lists the differences of one transparent output
+type OutputDiff struct {
+	Index int
+	// Added or Removed is set when the output is only in b or only in a
+	Added, Removed bool
+	Fields         []string
+}
+
+// DiffPCZT compares two PCZTs, such as one sent to a signer and the one it
+// returned, and reports which inputs, outputs, signatures and fees differ.
+// Lock times, sequences and redeem scripts are compared too, so a signer
+// cannot delay the transaction unnoticed.
+// Orchard actions are compared by their transaction fields (value commitment,
+// nullifier, rk, note commitment, ephemeral key and ciphertexts) and
+// authorization, so a changed shielded recipient or amount is reported as a
+// change of "orchard_actions".
+//
+// A coordinator can check that a signer only added signatures with
+// OnlySignaturesAdded.
+//
+// Neither PCZT is consumed.
+func DiffPCZT(a, b *PCZT) (*PCZTDiff, error) {
+	if a == nil || b == nil {
+		return nil, errors.New("invalid PCZT")
+	}
+	pa, err := inspectPCZT(a)
+	if err != nil {
+		return nil, fmt.Errorf("PCZT a: %w", err)
+	}
+	pb, err := inspectPCZT(b)
+	if err != nil {
+		return nil, fmt.Errorf("PCZT b: %w", err)
+	}
+	return diffContents(pa, pb), nil
+}
+
+// diffContents compares two decoded PCZTs
+func diffContents(a, b *pcztContents) *PCZTDiff {
+	d := &PCZTDiff{FeeA: a.fee(), FeeB: b.fee()}
+
+	var fields fieldDiff
+	fields.check("tx_version", a.TxVersion == b.TxVersion)
+	fields.check("version_group_id", a.VersionGroupID == b.VersionGroupID)
+	fields.check("consensus_branch_id", a.ConsensusBranchID == b.ConsensusBranchID)
+	fields.check("fallback_lock_time", equalOptional(a.FallbackLockTime, b.FallbackLockTime))
+	fields.check("expiry_height", a.ExpiryHeight == b.ExpiryHeight)
+	fields.check("coin_type", a.CoinType == b.CoinType)
+	fields.check("tx_modifiable", a.TxModifiable == b.TxModifiable)
+	fields.check("orchard_actions", slices.EqualFunc(a.OrchardActions, b.OrchardActions, pcztAction.equal))
+	fields.check("orchard_flags", a.OrchardFlags == b.OrchardFlags)
+	fields.check("orchard_value_balance", a.OrchardValueBalance == b.OrchardValueBalance)
+	fields.check("orchard_proof", bytes.Equal(a.OrchardProof, b.OrchardProof))
+	d.Fields = fields
+
+	for i := 0; i < max(len(a.Inputs), len(b.Inputs)); i++ {
+		switch {
+		case i >= len(a.Inputs):
+			d.Inputs = append(d.Inputs, InputDiff{Index: i, Added: true})
+		case i >= len(b.Inputs):
+			d.Inputs = append(d.Inputs, InputDiff{Index: i, Removed: true})
+		default:
+			if in := diffInput(i, &a.Inputs[i], &b.Inputs[i]); in != nil {
+				d.Inputs = append(d.Inputs, *in)
+			}
+		}
+	}
+
+	for i := 0; i < max(len(a.Outputs), len(b.Outputs)); i++ {
+		switch {
+		case i >= len(a.Outputs):
+			d.Outputs = append(d.Outputs, OutputDiff{Index: i, Added: true})
+		case i >= len(b.Outputs):
+			d.Outputs = append(d.Outputs, OutputDiff{Index: i, Removed: true})
+		default:
+			oa, ob := &a.Outputs[i], &b.Outputs[i]
+			var fields fieldDiff
+			fields.check("value", oa.Value == ob.Value)
+			fields.check("script_pubkey", bytes.Equal(oa.ScriptPubKey, ob.ScriptPubKey))
+			fields.check("redeem_script", bytes.Equal(oa.RedeemScript, ob.RedeemScript))
+			fields.check("address", oa.UserAddress == ob.UserAddress)
+			if len(fields) > 0 {
+				d.Outputs = append(d.Outputs, OutputDiff{Index: i, Fields: fields})
+			}
+		}
+	}
+	return d
+}
+
+// diffInput compares an input of two PCZTs, and returns nil if it is equal
+func diffInput(index int, a, b *pcztInput) *InputDiff {
+	var fields fieldDiff
+	fields.check("prevout", a.PrevTxID == b.PrevTxID && a.PrevIndex == b.PrevIndex)
+	fields.check("sequence", equalOptional(a.Sequence, b.Sequence))
+	fields.check("required_time_lock_time", equalOptional(a.RequiredTimeLockTime, b.RequiredTimeLockTime))
+	fields.check("required_height_lock_time", equalOptional(a.RequiredHeightLockTime, b.RequiredHeightLockTime))
+	fields.check("value", a.Value == b.Value)
+	fields.check("script_pubkey", bytes.Equal(a.ScriptPubKey, b.ScriptPubKey))
+	fields.check("redeem_script", bytes.Equal(a.RedeemScript, b.RedeemScript))
+	fields.check("sighash_type", a.SighashType == b.SighashType)
+	fields.check("script_sig", bytes.Equal(a.ScriptSig, b.ScriptSig))
+	fields.check("hash160_preimages", maps.EqualFunc(a.Hash160Preimages, b.Hash160Preimages, bytes.Equal))
+
+	d := InputDiff{Index: index, Fields: fields}
+	for _, pubkey := range slices.Sorted(maps.Keys(b.PartialSignatures)) {
+		sig, ok := a.PartialSignatures[pubkey]
+		switch {
+		case !ok:
+			d.SignaturesAdded = append(d.SignaturesAdded, []byte(pubkey))
+		case !bytes.Equal(sig, b.PartialSignatures[pubkey]):
+			d.SignaturesChanged = append(d.SignaturesChanged, []byte(pubkey))
+		}
+	}
+	for _, pubkey := range slices.Sorted(maps.Keys(a.PartialSignatures)) {
+		if _, ok := b.PartialSignatures[pubkey]; !ok {
+			d.SignaturesRemoved = append(d.SignaturesRemoved, []byte(pubkey))
+		}
+	}
+
+	if len(d.Fields) == 0 && d.SignaturesAdded == nil && d.SignaturesRemoved == nil && d.SignaturesChanged == nil {
+		return nil
+	}
+	return &d
+}
+
+// fieldDiff collects the names of differing fields
+type fieldDiff []string
+
+func (f *fieldDiff) check(name string, equal bool) {
+	if !equal {
+		*f = append(*f, name)
+	}
+}
+
+// equalOptional reports whether two optional fields are both unset or equal
+func equalOptional(a, b *uint32) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+// fee returns the fee of a decoded PCZT: transparent in + Orchard value
+// balance - transparent out
+func (p *pcztContents) fee() int64 {
+	fee := p.OrchardValueBalance
+	for _, in := range p.Inputs {
+		fee += int64(in.Value)
+	}
+	for _, out := range p.Outputs {
+		fee -= int64(out.Value)
+	}
+	return fee
+}
+
+// Empty reports whether the two PCZTs are equal in every compared field
+func (d *PCZTDiff) Empty() bool {
+	return len(d.Fields) == 0 && len(d.Inputs) == 0 && len(d.Outputs) == 0 && d.FeeA == d.FeeB
+}
+
+// OnlySignaturesAdded reports whether b differs from a only by new partial
+// signatures on existing inputs, which is all a transparent signer should
+// change. It is false if nothing was added.
+func (d *PCZTDiff) OnlySignaturesAdded() bool {
+	if len(d.Fields) > 0 || len(d.Outputs) > 0 || len(d.Inputs) == 0 || d.FeeA != d.FeeB {
+		return false
+	}
+	for _, in := range d.Inputs {
+		if in.Added || in.Removed || len(in.Fields) > 0 || in.SignaturesRemoved != nil || in.SignaturesChanged != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// String lists the differences, one per line
+func (d *PCZTDiff) String() string {
+	var sb strings.Builder
+	if len(d.Fields) > 0 {
+		fmt.Fprintf(&sb, "transaction: %s\n", strings.Join(d.Fields, ", "))
+	}
+	if d.FeeA != d.FeeB {
+		fmt.Fprintf(&sb, "fee: %d -> %d\n", d.FeeA, d.FeeB)
+	}
+	for _, in := range d.Inputs {
+		switch {
+		case in.Added:
+			fmt.Fprintf(&sb, "input %d: added\n", in.Index)
+		case in.Removed:
+			fmt.Fprintf(&sb, "input %d: removed\n", in.Index)
+		default:
+			if len(in.Fields) > 0 {
+				fmt.Fprintf(&sb, "input %d: %s\n", in.Index, strings.Join(in.Fields, ", "))
+			}
+			for _, pubkey := range in.SignaturesAdded {
+				fmt.Fprintf(&sb, "input %d: signature added for key %x\n", in.Index, pubkey)
+			}
+			for _, pubkey := range in.SignaturesRemoved {
+				fmt.Fprintf(&sb, "input %d: signature removed for key %x\n", in.Index, pubkey)
+			}
+			for _, pubkey := range in.SignaturesChanged {
+				fmt.Fprintf(&sb, "input %d: signature changed for key %x\n", in.Index, pubkey)
+			}
+		}
+	}
+	for _, out := range d.Outputs {
+		switch {
+		case out.Added:
+			fmt.Fprintf(&sb, "output %d: added\n", out.Index)
+		case out.Removed:
+			fmt.Fprintf(&sb, "output %d: removed\n", out.Index)
+		default:
+			fmt.Fprintf(&sb, "output %d: %s\n", out.Index, strings.Join(out.Fields, ", "))
+		}
+	}
+	return sb.String()
+}
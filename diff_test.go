@@ -0,0 +1,235 @@
+//go:build (cgo && (darwin || linux || windows) && (amd64 || arm64)) || t2z_mock
+
+package t2z
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+// Test that DiffPCZT reports added signatures, and changed outputs and fees
+func TestDiffPCZT(t *testing.T) {
+	privateKey, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_diff_pczt_000000000000"))
+
+	var inputs []TransparentInput
+	for i := 0; i < 2; i++ {
+		inputs = append(inputs, TransparentInput{
+			Pubkey:       pubkey,
+			TxID:         txid,
+			Vout:         uint32(i),
+			Amount:       10_000_000,
+			ScriptPubKey: createP2PKHScript(pubkey),
+		})
+	}
+
+	propose := func(amount uint64) *PCZT {
+		request, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: amount}})
+		if err != nil {
+			t.Fatalf("Failed to create transaction request: %v", err)
+		}
+		defer request.Free()
+		request.SetTargetHeight(2_500_000)
+
+		pczt, err := ProposeTransaction(inputs, request)
+		if err != nil {
+			t.Fatalf("Failed to propose transaction: %v", err)
+		}
+		return pczt
+	}
+
+	sent := propose(15_000_000)
+	defer sent.Free()
+
+	// A signer adds a signature to input 1
+	clone, err := ClonePCZT(sent)
+	if err != nil {
+		t.Fatalf("Failed to clone PCZT: %v", err)
+	}
+	sighash, err := GetSighash(clone, 1)
+	if err != nil {
+		t.Fatalf("Failed to get sighash: %v", err)
+	}
+	signature, err := signMessage(privateKey, sighash)
+	if err != nil {
+		t.Fatalf("Failed to sign message: %v", err)
+	}
+	returned, err := AppendSignature(clone, 1, signature)
+	if err != nil {
+		t.Fatalf("Failed to append signature: %v", err)
+	}
+	defer returned.Free()
+
+	diff, err := DiffPCZT(sent, returned)
+	if err != nil {
+		t.Fatalf("DiffPCZT failed: %v", err)
+	}
+	if !diff.OnlySignaturesAdded() {
+		t.Errorf("Expected only signatures to be added, got:\n%s", diff)
+	}
+	if len(diff.Inputs) != 1 || diff.Inputs[0].Index != 1 || len(diff.Inputs[0].SignaturesAdded) != 1 || !bytes.Equal(diff.Inputs[0].SignaturesAdded[0], pubkey) {
+		t.Errorf("Expected a signature for input 1, got %+v", diff.Inputs)
+	}
+
+	// The reverse direction removes it
+	diff, err = DiffPCZT(returned, sent)
+	if err != nil {
+		t.Fatalf("DiffPCZT failed: %v", err)
+	}
+	if diff.OnlySignaturesAdded() || len(diff.Inputs) != 1 || len(diff.Inputs[0].SignaturesRemoved) != 1 {
+		t.Errorf("Expected a removed signature, got:\n%s", diff)
+	}
+
+	// A PCZT equals itself
+	diff, err = DiffPCZT(sent, sent)
+	if err != nil {
+		t.Fatalf("DiffPCZT failed: %v", err)
+	}
+	if !diff.Empty() || diff.OnlySignaturesAdded() || diff.String() != "" {
+		t.Errorf("Expected an empty diff, got:\n%s", diff)
+	}
+
+	// A PCZT paying a different amount
+	other := propose(12_000_000)
+	defer other.Free()
+	diff, err = DiffPCZT(sent, other)
+	if err != nil {
+		t.Fatalf("DiffPCZT failed: %v", err)
+	}
+	if diff.OnlySignaturesAdded() || len(diff.Outputs) == 0 || !slices.Contains(diff.Outputs[0].Fields, "value") {
+		t.Errorf("Expected a changed payment output, got:\n%s", diff)
+	}
+	t.Logf("Diff of a different payment:\n%s", diff)
+
+	if _, err := DiffPCZT(sent, nil); err == nil {
+		t.Error("Expected error for a nil PCZT, got nil")
+	}
+}
+
+// Test that DiffPCZT compares the fields of Orchard actions, so a swapped
+// shielded recipient is not mistaken for an added signature
+func TestDiffPCZTOrchardActions(t *testing.T) {
+	_, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_diff_pczt_orchard_0000"))
+
+	inputs := []TransparentInput{{
+		Pubkey:       pubkey,
+		TxID:         txid,
+		Amount:       10_000_000,
+		ScriptPubKey: createP2PKHScript(pubkey),
+	}}
+	request, err := NewTransactionRequest([]Payment{{Address: testShieldedAddress, Amount: 1_000_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+	request.SetTargetHeight(2_500_000)
+
+	sent, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+	defer sent.Free()
+
+	data, err := SerializePCZT(sent)
+	if err != nil {
+		t.Fatalf("Failed to serialize PCZT: %v", err)
+	}
+	contents, err := decodePCZT(data)
+	if err != nil {
+		t.Fatalf("Failed to decode PCZT: %v", err)
+	}
+	if len(contents.OrchardActions) == 0 {
+		t.Fatal("Expected Orchard actions")
+	}
+
+	// Change the note commitment of one action
+	cmx := contents.OrchardActions[0].Cmx
+	offset := bytes.Index(data, cmx)
+	if len(cmx) != 32 || offset < 0 {
+		t.Fatalf("Note commitment %x not found in the PCZT", cmx)
+	}
+	tampered := slices.Clone(data)
+	tampered[offset] ^= 1
+	returned, err := ParsePCZT(tampered)
+	if err != nil {
+		t.Fatalf("Failed to parse PCZT: %v", err)
+	}
+	defer returned.Free()
+
+	diff, err := DiffPCZT(sent, returned)
+	if err != nil {
+		t.Fatalf("DiffPCZT failed: %v", err)
+	}
+	if diff.Empty() || diff.OnlySignaturesAdded() || !slices.Contains(diff.Fields, "orchard_actions") {
+		t.Errorf("Expected changed Orchard actions, got:\n%s", diff)
+	}
+}
+
+// Test that DiffPCZT reports a lock time set by a signer, which would delay
+// the transaction
+func TestDiffPCZTLockTime(t *testing.T) {
+	_, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_diff_pczt_locktime_000"))
+
+	inputs := []TransparentInput{{
+		Pubkey:       pubkey,
+		TxID:         txid,
+		Amount:       10_000_000,
+		ScriptPubKey: createP2PKHScript(pubkey),
+	}}
+	request, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 5_000_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+	request.SetTargetHeight(2_500_000)
+
+	sent, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+	defer sent.Free()
+
+	data, err := SerializePCZT(sent)
+	if err != nil {
+		t.Fatalf("Failed to serialize PCZT: %v", err)
+	}
+
+	// Read past the prevout, sequence and required time lock time of the
+	// input to the tag of its required height lock time
+	offset := bytes.Index(data, txid[:])
+	if offset < 0 {
+		t.Fatal("Input not found in the PCZT")
+	}
+	r := &pcztReader{buf: data, off: offset + 32}
+	r.uint32("prevout index")
+	r.optionalUint32("sequence")
+	r.optionalUint32("required time lock time")
+	if r.err != nil || data[r.off] != 0 {
+		t.Fatalf("Expected an unset required height lock time: %v", r.err)
+	}
+
+	// Set it to a far-future height, 3_000_000 as a varint
+	tampered := slices.Concat(data[:r.off], []byte{1, 0xc0, 0x8d, 0xb7, 0x01}, data[r.off+1:])
+	returned, err := ParsePCZT(tampered)
+	if err != nil {
+		t.Fatalf("Failed to parse PCZT: %v", err)
+	}
+	defer returned.Free()
+
+	diff, err := DiffPCZT(sent, returned)
+	if err != nil {
+		t.Fatalf("DiffPCZT failed: %v", err)
+	}
+	if diff.OnlySignaturesAdded() || len(diff.Inputs) != 1 || !slices.Equal(diff.Inputs[0].Fields, []string{"required_height_lock_time"}) {
+		t.Errorf("Expected a changed required height lock time, got:\n%s", diff)
+	}
+}
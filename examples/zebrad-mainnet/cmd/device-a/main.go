@@ -4,26 +4,25 @@ package main
 
 import (
 	"bufio"
-	"bytes"
-	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
-	"net/http"
 	"os"
-	"strconv"
 	"strings"
 
-	"golang.org/x/crypto/ripemd160"
-	"t2z"
+	t2z "github.com/gstohl/t2z/go"
+	"github.com/gstohl/t2z/go/examples/zebrad-regtest/common"
 )
 
 func main() {
-	env := loadEnv()
-	zebraRPC := fmt.Sprintf("http://%s:%s", env["ZEBRA_HOST"], env["ZEBRA_PORT"])
+	cfg, err := common.LoadDefaultConfig()
+	if err != nil {
+		fmt.Printf("Failed to load wallet config: %v\nRun: go run ./cmd/generate-wallet\n", err)
+		os.Exit(1)
+	}
+	client := cfg.RPC.NewClient()
 
-	pubkey, _ := hex.DecodeString(env["PUBLIC_KEY"])
-	address := env["ADDRESS"]
+	pubkey, _ := hex.DecodeString(cfg.PublicKey)
+	address := cfg.Address
 
 	fmt.Println("\n" + strings.Repeat("=", 60))
 	fmt.Println("  DEVICE A - ONLINE DEVICE (Hardware Wallet Simulation)")
@@ -33,16 +32,16 @@ func main() {
 
 	// Fetch UTXOs
 	fmt.Print("Fetching balance... ")
-	utxos, err := getUTXOs(zebraRPC, address)
+	utxos, err := client.GetAddressUtxos([]string{address})
 	if err != nil {
 		fmt.Printf("error: %v\n", err)
 		os.Exit(1)
 	}
 	fmt.Println("done")
 
-	var totalSats int64
+	var totalSats uint64
 	for _, u := range utxos {
-		totalSats += u.Satoshis
+		totalSats += u.Value
 	}
 
 	if len(utxos) == 0 {
@@ -50,7 +49,7 @@ func main() {
 		return
 	}
 
-	fmt.Printf("\nBalance: %.8f ZEC\n\n", float64(totalSats)/1e8)
+	fmt.Printf("\nBalance: %s ZEC\n\n", common.ZatoshiToZec(totalSats))
 
 	reader := bufio.NewReader(os.Stdin)
 
@@ -61,26 +60,30 @@ func main() {
 		fmt.Println("No address entered. Exiting.")
 		return
 	}
+	recipientInfo, err := t2z.ValidateAddress(recipientAddr)
+	if err != nil {
+		fmt.Printf("Invalid address: %v\n", err)
+		os.Exit(1)
+	}
 
 	fmt.Print("Amount in ZEC: ")
 	amountStr, _ := reader.ReadString('\n')
-	amountZec, err := strconv.ParseFloat(strings.TrimSpace(amountStr), 64)
-	if err != nil || amountZec <= 0 {
+	amountSats, err := common.ZecToZatoshi(strings.TrimSpace(amountStr))
+	if err != nil || amountSats == 0 {
 		fmt.Println("Invalid amount. Exiting.")
 		os.Exit(1)
 	}
-	amountSats := uint64(amountZec * 1e8)
 
 	// Optional memo
 	var memo string
-	if !strings.HasPrefix(recipientAddr, "t") {
+	isShielded := !recipientInfo.Kind.IsTransparent()
+	if isShielded {
 		fmt.Print("Memo (optional, press Enter to skip): ")
 		memo, _ = reader.ReadString('\n')
 		memo = strings.TrimSpace(memo)
 	}
 
 	// Calculate fee
-	isShielded := !strings.HasPrefix(recipientAddr, "t")
 	var fee uint64
 	if isShielded {
 		fee = t2z.CalculateFee(1, 1, 1)
@@ -89,47 +92,29 @@ func main() {
 	}
 	totalNeeded := amountSats + fee
 
-	if totalNeeded > uint64(totalSats) {
-		fmt.Printf("\nInsufficient balance! Need %.8f ZEC\n", float64(totalNeeded)/1e8)
+	if totalNeeded > totalSats {
+		fmt.Printf("\nInsufficient balance! Need %s ZEC\n", common.ZatoshiToZec(totalNeeded))
 		os.Exit(1)
 	}
 
 	fmt.Println("\n--- Transaction Summary ---")
 	fmt.Printf("  To: %s\n", recipientAddr)
-	fmt.Printf("  Amount: %.8f ZEC\n", amountZec)
+	fmt.Printf("  Amount: %s ZEC\n", t2z.FormatZec(amountSats))
 	if memo != "" {
 		fmt.Printf("  Memo: \"%s\"\n", memo)
 	}
-	fmt.Printf("  Fee: %.8f ZEC\n", float64(fee)/1e8)
+	fmt.Printf("  Fee: %s ZEC\n", common.ZatoshiToZec(fee))
 
 	// Build input
-	h := sha256.Sum256(pubkey)
-	r := ripemd160.New()
-	r.Write(h[:])
-	pkh := r.Sum(nil)
-	script := append([]byte{0x76, 0xa9, 0x14}, pkh...)
-	script = append(script, 0x88, 0xac)
-
-	utxo := utxos[0]
-	txid, _ := hex.DecodeString(utxo.Txid)
-	// Reverse txid
-	for i, j := 0, len(txid)-1; i < j; i, j = i+1, j-1 {
-		txid[i], txid[j] = txid[j], txid[i]
-	}
-	var txidArr [32]byte
-	copy(txidArr[:], txid)
-
-	input := t2z.TransparentInput{
-		Pubkey:       pubkey,
-		TxID:         txidArr,
-		Vout:         uint32(utxo.OutputIndex),
-		Amount:       uint64(utxo.Satoshis),
-		ScriptPubKey: script,
-	}
+	input := utxos[0].TransparentInput(pubkey)
 
 	payment := t2z.Payment{Address: recipientAddr, Amount: amountSats, Memo: memo}
 
-	blockHeight, _ := getBlockHeight(zebraRPC)
+	targetHeight, err := client.SuggestTargetHeight(10)
+	if err != nil {
+		fmt.Printf("Failed to get block height: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Build and prove transaction
 	fmt.Println("\nBuilding transaction...")
@@ -137,7 +122,7 @@ func main() {
 	fmt.Print("  Proposing... ")
 	request, _ := t2z.NewTransactionRequest([]t2z.Payment{payment})
 	defer request.Free()
-	request.SetTargetHeight(uint32(blockHeight + 10))
+	request.SetTargetHeight(targetHeight)
 
 	pczt, err := t2z.ProposeTransaction([]t2z.TransparentInput{input}, request)
 	if err != nil {
@@ -158,13 +143,12 @@ func main() {
 	sighash, _ := t2z.GetSighash(proved, 0)
 	sighashHex := hex.EncodeToString(sighash[:])
 
-	// Serialize PCZT
-	psztBytes, _ := t2z.SerializePCZT(proved)
-	psztHex := hex.EncodeToString(psztBytes)
-
-	// Save to temp file
+	// Save PCZT to temp file (versioned, checksummed)
 	tempFile := ".pczt-temp"
-	os.WriteFile(tempFile, []byte(psztHex), 0600)
+	if err := t2z.WritePCZTFile(tempFile, proved); err != nil {
+		fmt.Printf("Error saving PCZT: %v\n", err)
+		os.Exit(1)
+	}
 
 	fmt.Println("\n" + strings.Repeat("=", 60))
 	fmt.Println("  SIGHASH READY FOR OFFLINE SIGNING")
@@ -174,33 +158,50 @@ func main() {
 	fmt.Println("\n" + strings.Repeat("=", 60))
 
 	// Wait for signature
-	fmt.Println("\nRun Device B with the sighash, then paste the signature here.\n")
-	fmt.Print("Paste signature from Device B: ")
-	sigHex, _ := reader.ReadString('\n')
-	sigHex = strings.TrimSpace(sigHex)
-
-	if len(sigHex) != 128 {
-		fmt.Println("\nInvalid signature (expected 64 bytes / 128 hex chars). Exiting.")
+	fmt.Println("\nRun Device B with the sighash, then paste the signature bundle here.\n")
+	fmt.Print("Paste bundle from Device B: ")
+	bundleHex, _ := reader.ReadString('\n')
+	bundleBytes, err := hex.DecodeString(strings.TrimSpace(bundleHex))
+	if err != nil {
+		fmt.Println("\nInvalid bundle (not valid hex). Exiting.")
+		os.Exit(1)
+	}
+	var bundle t2z.SignatureBundle
+	if err := bundle.UnmarshalBinary(bundleBytes); err != nil {
+		fmt.Printf("\nInvalid bundle: %v. Exiting.\n", err)
 		os.Exit(1)
 	}
-
-	sigBytes, _ := hex.DecodeString(sigHex)
-	var sig [64]byte
-	copy(sig[:], sigBytes)
 
 	// Load PCZT and finalize
 	fmt.Println("\nFinalizing transaction...")
-	psztData, _ := os.ReadFile(tempFile)
-	loadedPczt, _ := t2z.ParsePCZT(mustHex(string(psztData)))
-	signed, _ := t2z.AppendSignature(loadedPczt, 0, sig)
+	loadedPczt, err := t2z.ReadPCZTFile(tempFile)
+	if err != nil {
+		fmt.Printf("Error loading PCZT: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Rejects a mistyped, stale or mismatched signature
+	signed, err := t2z.ApplyBundle(loadedPczt, bundle)
+	if err != nil {
+		fmt.Printf("\nSignature bundle rejected: %v\n", err)
+		fmt.Println("Check that it was copied completely and that Device B signed the sighash above. Exiting.")
+		os.Exit(1)
+	}
 
 	fmt.Print("  Extracting... ")
 	txBytes, _ := t2z.FinalizeAndExtract(signed)
 	fmt.Println("done")
 
-	fmt.Print("  Broadcasting... ")
-	txidResult, err := broadcast(zebraRPC, hex.EncodeToString(txBytes))
+	// Record the txid first, so it is known even if broadcasting fails
+	txid, err := t2z.TransactionID(txBytes)
 	if err != nil {
+		fmt.Printf("Error computing txid: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("  TXID: %s\n", txid)
+
+	fmt.Print("  Broadcasting... ")
+	if _, err := client.Broadcast(hex.EncodeToString(txBytes)); err != nil {
 		fmt.Printf("error: %v\n", err)
 		os.Exit(1)
 	}
@@ -212,71 +213,6 @@ func main() {
 	fmt.Println("\n" + strings.Repeat("=", 60))
 	fmt.Println("  TRANSACTION BROADCAST SUCCESSFUL!")
 	fmt.Println(strings.Repeat("=", 60))
-	fmt.Printf("\nTXID: %s\n", txidResult)
+	fmt.Printf("\nTXID: %s\n", txid)
 	fmt.Println("\nThe private key NEVER touched this device!")
 }
-
-type UTXO struct {
-	Txid        string `json:"txid"`
-	OutputIndex int    `json:"outputIndex"`
-	Satoshis    int64  `json:"satoshis"`
-}
-
-func getUTXOs(rpcURL, address string) ([]UTXO, error) {
-	body, _ := json.Marshal(map[string]any{
-		"jsonrpc": "2.0", "method": "getaddressutxos",
-		"params": []any{map[string]any{"addresses": []string{address}}}, "id": 1,
-	})
-	resp, _ := http.Post(rpcURL, "application/json", bytes.NewReader(body))
-	defer resp.Body.Close()
-	var result struct {
-		Result []UTXO                   `json:"result"`
-		Error  *struct{ Message string } `json:"error"`
-	}
-	json.NewDecoder(resp.Body).Decode(&result)
-	if result.Error != nil {
-		return nil, fmt.Errorf("%s", result.Error.Message)
-	}
-	return result.Result, nil
-}
-
-func getBlockHeight(rpcURL string) (int, error) {
-	body, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "method": "getblockchaininfo", "params": []any{}, "id": 1})
-	resp, _ := http.Post(rpcURL, "application/json", bytes.NewReader(body))
-	defer resp.Body.Close()
-	var result struct{ Result struct{ Blocks int } }
-	json.NewDecoder(resp.Body).Decode(&result)
-	return result.Result.Blocks, nil
-}
-
-func broadcast(rpcURL, txHex string) (string, error) {
-	body, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "method": "sendrawtransaction", "params": []string{txHex}, "id": 1})
-	resp, _ := http.Post(rpcURL, "application/json", bytes.NewReader(body))
-	defer resp.Body.Close()
-	var result struct {
-		Result string                   `json:"result"`
-		Error  *struct{ Message string } `json:"error"`
-	}
-	json.NewDecoder(resp.Body).Decode(&result)
-	if result.Error != nil {
-		return "", fmt.Errorf("%s", result.Error.Message)
-	}
-	return result.Result, nil
-}
-
-func loadEnv() map[string]string {
-	envPath := ".env"
-	data, _ := os.ReadFile(envPath)
-	env := map[string]string{"ZEBRA_HOST": "localhost", "ZEBRA_PORT": "8232"}
-	for _, line := range strings.Split(string(data), "\n") {
-		if idx := strings.Index(line, "="); idx > 0 && !strings.HasPrefix(line, "#") {
-			env[strings.TrimSpace(line[:idx])] = strings.Trim(strings.TrimSpace(line[idx+1:]), "\"'")
-		}
-	}
-	return env
-}
-
-func mustHex(s string) []byte {
-	b, _ := hex.DecodeString(s)
-	return b
-}
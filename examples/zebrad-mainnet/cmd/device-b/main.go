@@ -1,5 +1,5 @@
 // Device B - Offline Signer (Hardware Wallet Simulation)
-// Signs sighash and returns signature
+// Signs sighash and returns a signature bundle
 package main
 
 import (
@@ -11,11 +11,17 @@ import (
 
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	t2z "github.com/gstohl/t2z/go"
+	"github.com/gstohl/t2z/go/examples/zebrad-regtest/common"
 )
 
 func main() {
-	env := loadEnv()
-	address := env["ADDRESS"]
+	cfg, err := common.LoadDefaultConfig()
+	if err != nil {
+		fmt.Printf("Failed to load wallet config: %v\nRun: go run ./cmd/generate-wallet\n", err)
+		os.Exit(1)
+	}
+	address := cfg.Address
 
 	fmt.Println("\n" + strings.Repeat("=", 60))
 	fmt.Println("  DEVICE B - OFFLINE SIGNER (Hardware Wallet Simulation)")
@@ -40,36 +46,34 @@ func main() {
 
 	fmt.Println("\nSigning...")
 
-	privKeyBytes, _ := hex.DecodeString(env["PRIVATE_KEY"])
+	privKeyBytes, err := common.ParsePrivateKey(cfg.PrivateKey, t2z.NetworkMainnet)
+	if err != nil {
+		fmt.Printf("Invalid PRIVATE_KEY: %v\n", err)
+		os.Exit(1)
+	}
 	privKey := secp256k1.PrivKeyFromBytes(privKeyBytes)
+	clear(privKeyBytes) // privKey holds its own copy
 
 	sig := ecdsa.SignCompact(privKey, sighash, true)
-	// Extract 64-byte signature (skip recovery byte)
-	sigHex := hex.EncodeToString(sig[1:65])
+	pubkey := privKey.PubKey().SerializeCompressed()
+	privKey.Zero()
+
+	// Bundle the signature (skip recovery byte) with what it signs, so
+	// Device A can reject it if it belongs to another transaction
+	bundle := t2z.SignatureBundle{InputIndex: 0, Pubkey: pubkey}
+	copy(bundle.Sighash[:], sighash)
+	copy(bundle.Signature[:], sig[1:65])
+	bundleBytes, err := bundle.MarshalBinary()
+	if err != nil {
+		fmt.Printf("Error encoding signature bundle: %v\n", err)
+		os.Exit(1)
+	}
 
 	fmt.Println("\n" + strings.Repeat("=", 60))
 	fmt.Println("  SIGNATURE READY")
 	fmt.Println(strings.Repeat("=", 60))
-	fmt.Println("\nCopy this signature back to Device A:\n")
-	fmt.Printf("SIGNATURE: %s\n", sigHex)
+	fmt.Println("\nCopy this signature bundle back to Device A:\n")
+	fmt.Printf("BUNDLE: %s\n", hex.EncodeToString(bundleBytes))
 	fmt.Println("\n" + strings.Repeat("=", 60))
 	fmt.Println("\nThe private key stayed on this device!")
 }
-
-func loadEnv() map[string]string {
-	envPath := ".env"
-	data, err := os.ReadFile(envPath)
-	if err != nil {
-		fmt.Println("No .env file found. Run: go run ./cmd/generate-wallet")
-		os.Exit(1)
-	}
-	env := make(map[string]string)
-	for _, line := range strings.Split(string(data), "\n") {
-		if idx := strings.Index(line, "="); idx > 0 && !strings.HasPrefix(line, "#") {
-			key := strings.TrimSpace(line[:idx])
-			val := strings.Trim(strings.TrimSpace(line[idx+1:]), "\"'")
-			env[key] = val
-		}
-	}
-	return env
-}
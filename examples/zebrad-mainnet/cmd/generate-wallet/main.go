@@ -1,51 +1,86 @@
 // Generate Wallet - Creates a new wallet and saves to .env file
+//
+// New wallets are backed by a 24-word BIP39 mnemonic; the key is derived at
+// m/44'/133'/0'/0/0. Pass an existing mnemonic (as one quoted argument or as
+// separate words) to restore it, or a mainnet WIF private key to import a
+// single key.
 package main
 
 import (
-	"crypto/rand"
-	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
-	"golang.org/x/crypto/ripemd160"
+	t2z "github.com/gstohl/t2z/go"
+	"github.com/gstohl/t2z/go/examples/zebrad-regtest/common"
 )
 
 func main() {
-	envPath := ".env"
+	envPath := common.DefaultEnvPath
 
 	// Check if wallet already exists
-	if _, err := os.Stat(envPath); err == nil {
-		fmt.Println("Wallet already exists at .env")
-		fmt.Println("Delete .env first if you want to generate a new wallet.")
-		if env, err := os.ReadFile(envPath); err == nil {
-			for _, line := range splitLines(string(env)) {
-				if len(line) > 8 && line[:8] == "ADDRESS=" {
-					fmt.Printf("\nCurrent address: %s\n", line[8:])
-				}
-			}
+	for _, path := range []string{common.DefaultConfigPath, envPath} {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		fmt.Printf("Wallet already exists at %s\n", path)
+		fmt.Printf("Delete %s first if you want to generate a new wallet.\n", path)
+		if cfg, err := common.LoadConfig(path); err == nil && cfg.Address != "" {
+			fmt.Printf("\nCurrent address: %s\n", cfg.Address)
 		}
 		return
 	}
 
-	// Generate random private key
-	privKeyBytes := make([]byte, 32)
-	if _, err := rand.Read(privKeyBytes); err != nil {
-		fmt.Printf("Error generating random bytes: %v\n", err)
-		os.Exit(1)
+	var privKeyBytes []byte
+	var mnemonic string
+	arg := strings.Join(os.Args[1:], " ")
+	switch {
+	case arg == "":
+		// Generate a new 24-word mnemonic
+		generated, err := common.GenerateMnemonic(256)
+		if err != nil {
+			fmt.Printf("Error generating mnemonic: %v\n", err)
+			os.Exit(1)
+		}
+		mnemonic = generated
+	case len(strings.Fields(arg)) == 1:
+		// Import an existing key
+		imported, err := common.ParseWIFForNetwork(arg, t2z.NetworkMainnet)
+		if err != nil {
+			fmt.Printf("Error importing key: %v\n", err)
+			os.Exit(1)
+		}
+		privKeyBytes = imported
+	default:
+		// Restore from an existing mnemonic
+		if err := common.ValidateMnemonic(arg); err != nil {
+			fmt.Printf("Error importing mnemonic: %v\n", err)
+			os.Exit(1)
+		}
+		mnemonic = strings.ToLower(strings.Join(strings.Fields(arg), " "))
+	}
+
+	if mnemonic != "" {
+		keypair, err := common.DeriveTransparentKey(common.SeedFromMnemonic(mnemonic, ""), 0, 0, 0)
+		if err != nil {
+			fmt.Printf("Error deriving key: %v\n", err)
+			os.Exit(1)
+		}
+		privKeyBytes = keypair.PrivateKey
 	}
 
 	privKey := secp256k1.PrivKeyFromBytes(privKeyBytes)
 	pubkey := privKey.PubKey().SerializeCompressed()
-	address := pubkeyToMainnetAddress(pubkey)
+	address := common.PubkeyToMainnetAddress(pubkey)
 
 	// Build .env content
 	envContent := fmt.Sprintf(`# Zcash Mainnet Wallet
 # Generated: %s
 # WARNING: Keep this file secret! Never commit to git.
-
+%s
 PRIVATE_KEY=%s
 PUBLIC_KEY=%s
 ADDRESS=%s
@@ -54,6 +89,7 @@ ADDRESS=%s
 ZEBRA_HOST=localhost
 ZEBRA_PORT=8232
 `, time.Now().Format(time.RFC3339),
+		mnemonicLine(mnemonic),
 		hex.EncodeToString(privKeyBytes),
 		hex.EncodeToString(pubkey),
 		address)
@@ -66,59 +102,20 @@ ZEBRA_PORT=8232
 	fmt.Println("New wallet generated!\n")
 	fmt.Printf("Address: %s\n", address)
 	fmt.Printf("\nSaved to: %s\n", envPath)
-	fmt.Println("\nIMPORTANT: Back up your private key securely!")
-}
-
-func pubkeyToMainnetAddress(pubkey []byte) string {
-	h := sha256.Sum256(pubkey)
-	r := ripemd160.New()
-	r.Write(h[:])
-	pkh := r.Sum(nil)
-	data := append([]byte{0x1c, 0xb8}, pkh...) // mainnet prefix
-	check := sha256.Sum256(data)
-	check = sha256.Sum256(check[:])
-	return base58Encode(append(data, check[:4]...))
-}
-
-func base58Encode(data []byte) string {
-	const alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
-	var result []byte
-	for _, b := range data {
-		carry := int(b)
-		for i := len(result) - 1; i >= 0; i-- {
-			carry += 256 * int(result[i])
-			result[i] = byte(carry % 58)
-			carry /= 58
-		}
-		for carry > 0 {
-			result = append([]byte{byte(carry % 58)}, result...)
-			carry /= 58
-		}
+	if mnemonic != "" {
+		fmt.Println("\nRecovery phrase (m/44'/133'/0'/0/0):")
+		fmt.Printf("\n  %s\n", mnemonic)
+		fmt.Println("\nIMPORTANT: Write down the recovery phrase and store it securely!")
+	} else {
+		fmt.Println("\nIMPORTANT: Back up your private key securely!")
 	}
-	for _, b := range data {
-		if b != 0 {
-			break
-		}
-		result = append([]byte{0}, result...)
-	}
-	out := make([]byte, len(result))
-	for i, b := range result {
-		out[i] = alphabet[b]
-	}
-	return string(out)
 }
 
-func splitLines(s string) []string {
-	var lines []string
-	start := 0
-	for i := 0; i < len(s); i++ {
-		if s[i] == '\n' {
-			lines = append(lines, s[start:i])
-			start = i + 1
-		}
-	}
-	if start < len(s) {
-		lines = append(lines, s[start:])
+// mnemonicLine returns the MNEMONIC entry for .env, or a blank line for an
+// imported WIF key
+func mnemonicLine(mnemonic string) string {
+	if mnemonic == "" {
+		return ""
 	}
-	return lines
+	return fmt.Sprintf("\nMNEMONIC=\"%s\"\n", mnemonic)
 }
@@ -3,36 +3,48 @@ package main
 
 import (
 	"bufio"
-	"bytes"
-	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	"io/fs"
 	"os"
-	"strconv"
 	"strings"
 
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
-	"golang.org/x/crypto/ripemd160"
-	"t2z"
+	t2z "github.com/gstohl/t2z/go"
+	"github.com/gstohl/t2z/go/examples/zebrad-regtest/common"
 )
 
+// addressBookPath is where recipients are saved under a label
+const addressBookPath = "addressbook.json"
+
 type Recipient struct {
 	Address string
 	Amount  uint64
 	Memo    string
+	// Shielded is set for Sapling and unified addresses
+	Shielded bool
 }
 
 func main() {
-	env := loadEnv()
-	zebraRPC := fmt.Sprintf("http://%s:%s", env["ZEBRA_HOST"], env["ZEBRA_PORT"])
+	cfg, err := common.LoadDefaultConfig()
+	if err != nil {
+		fmt.Printf("Failed to load wallet config: %v\nRun: go run ./cmd/generate-wallet\n", err)
+		os.Exit(1)
+	}
+	client := cfg.RPC.NewClient()
 
-	privKeyBytes, _ := hex.DecodeString(env["PRIVATE_KEY"])
+	privKeyBytes, err := common.ParsePrivateKey(cfg.PrivateKey, t2z.NetworkMainnet)
+	if err != nil {
+		fmt.Printf("Invalid PRIVATE_KEY: %v\n", err)
+		os.Exit(1)
+	}
 	privKey := secp256k1.PrivKeyFromBytes(privKeyBytes)
+	clear(privKeyBytes) // privKey holds its own copy
+	defer privKey.Zero()
 	pubkey := privKey.PubKey().SerializeCompressed()
-	address := env["ADDRESS"]
+	address := cfg.Address
 
 	fmt.Println("\n" + strings.Repeat("=", 60))
 	fmt.Println("  t2z Mainnet Send")
@@ -41,16 +53,16 @@ func main() {
 
 	// Fetch UTXOs
 	fmt.Print("Fetching balance... ")
-	utxos, err := getUTXOs(zebraRPC, address)
+	utxos, err := client.GetAddressUtxos([]string{address})
 	if err != nil {
 		fmt.Printf("error: %v\n", err)
 		os.Exit(1)
 	}
 	fmt.Println("done")
 
-	var totalSats int64
+	var totalSats uint64
 	for _, u := range utxos {
-		totalSats += u.Satoshis
+		totalSats += u.Value
 	}
 
 	if len(utxos) == 0 {
@@ -58,47 +70,76 @@ func main() {
 		return
 	}
 
-	fmt.Printf("\nBalance: %.8f ZEC (%d UTXO%s)\n\n", float64(totalSats)/1e8, len(utxos), plural(len(utxos)))
+	fmt.Printf("\nBalance: %s ZEC (%d UTXO%s)\n\n", common.ZatoshiToZec(totalSats), len(utxos), plural(len(utxos)))
+
+	book, err := common.LoadAddressBook(addressBookPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		book = common.NewAddressBook(t2z.NetworkMainnet)
+	} else if err != nil {
+		fmt.Printf("Failed to load address book: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Interactive recipient input
 	reader := bufio.NewReader(os.Stdin)
 	var recipients []Recipient
 
 	fmt.Println("Enter recipients (shielded addresses starting with 'u' recommended)")
+	if labels := book.Labels(); len(labels) > 0 {
+		fmt.Printf("Address book labels: %s\n", strings.Join(labels, ", "))
+	}
 	fmt.Println("Press Enter with empty address to finish.\n")
 
 	for {
-		fmt.Printf("Recipient %d address: ", len(recipients)+1)
+		fmt.Printf("Recipient %d address or label: ", len(recipients)+1)
 		addr, _ := reader.ReadString('\n')
 		addr = strings.TrimSpace(addr)
 		if addr == "" {
 			break
 		}
+		labeled, fromBook := book.Lookup(addr)
+		if fromBook {
+			addr = labeled
+		}
+		info, err := t2z.ValidateAddress(addr)
+		if err != nil {
+			fmt.Printf("Invalid address: %v\n\n", err)
+			continue
+		}
+		if !fromBook {
+			fmt.Print("Save to address book as (press Enter to skip): ")
+			label, _ := reader.ReadString('\n')
+			if label = strings.TrimSpace(label); label != "" {
+				if err := book.Add(label, addr); err != nil {
+					fmt.Printf("Not saved: %v\n", err)
+				} else if err := book.Save(addressBookPath); err != nil {
+					fmt.Printf("Failed to save address book: %v\n", err)
+				}
+			}
+		}
 
 		fmt.Print("Amount in ZEC: ")
 		amountStr, _ := reader.ReadString('\n')
-		amountZec, err := strconv.ParseFloat(strings.TrimSpace(amountStr), 64)
-		if err != nil || amountZec <= 0 {
+		amountSats, err := common.ZecToZatoshi(strings.TrimSpace(amountStr))
+		if err != nil || amountSats == 0 {
 			fmt.Println("Invalid amount, skipping.\n")
 			continue
 		}
 
-		amountSats := uint64(amountZec * 1e8)
-
 		// Ask for memo if shielded
 		var memo string
-		if !strings.HasPrefix(addr, "t") {
+		if !info.Kind.IsTransparent() {
 			fmt.Print("Memo (optional, press Enter to skip): ")
 			memo, _ = reader.ReadString('\n')
 			memo = strings.TrimSpace(memo)
 		}
 
-		recipients = append(recipients, Recipient{Address: addr, Amount: amountSats, Memo: memo})
+		recipients = append(recipients, Recipient{Address: addr, Amount: amountSats, Memo: memo, Shielded: !info.Kind.IsTransparent()})
 		memoInfo := ""
 		if memo != "" {
 			memoInfo = fmt.Sprintf(" [memo: \"%s\"]", truncate(memo, 20))
 		}
-		fmt.Printf("Added: %.8f ZEC → %s...%s\n\n", amountZec, truncate(addr, 30), memoInfo)
+		fmt.Printf("Added: %s ZEC → %s...%s\n\n", t2z.FormatZec(amountSats), truncate(addr, 30), memoInfo)
 	}
 
 	if len(recipients) == 0 {
@@ -106,22 +147,32 @@ func main() {
 		return
 	}
 
-	// Calculate fee
+	// Build inputs
+	available := common.TransparentInputs(utxos, pubkey)
+
+	// Select inputs; the fee depends on how many are spent
 	numTransparent := 0
 	numShielded := 0
 	for _, r := range recipients {
-		if strings.HasPrefix(r.Address, "t") {
-			numTransparent++
-		} else {
+		if r.Shielded {
 			numShielded++
+		} else {
+			numTransparent++
 		}
 	}
-	fee := t2z.CalculateFee(len(utxos), numTransparent+1, numShielded)
 
 	var totalSend uint64
 	for _, r := range recipients {
 		totalSend += r.Amount
 	}
+
+	inputs, fee, err := t2z.SelectUTXOs(available, totalSend, func(n int) uint64 {
+		return t2z.CalculateFee(n, numTransparent+1, numShielded)
+	})
+	if err != nil {
+		fmt.Printf("\nInsufficient balance: %v\n", err)
+		os.Exit(1)
+	}
 	totalNeeded := totalSend + fee
 
 	fmt.Println("\n--- Transaction Summary ---")
@@ -130,47 +181,11 @@ func main() {
 		if r.Memo != "" {
 			memoInfo = " [memo]"
 		}
-		fmt.Printf("  %.8f ZEC → %s...%s\n", float64(r.Amount)/1e8, truncate(r.Address, 40), memoInfo)
-	}
-	fmt.Printf("  Fee: %.8f ZEC\n", float64(fee)/1e8)
-	fmt.Printf("  Total: %.8f ZEC\n", float64(totalNeeded)/1e8)
-
-	if totalNeeded > uint64(totalSats) {
-		fmt.Printf("\nInsufficient balance! Need %.8f ZEC\n", float64(totalNeeded)/1e8)
-		os.Exit(1)
-	}
-
-	// Build inputs
-	h := sha256.Sum256(pubkey)
-	r := ripemd160.New()
-	r.Write(h[:])
-	pkh := r.Sum(nil)
-	script := append([]byte{0x76, 0xa9, 0x14}, pkh...)
-	script = append(script, 0x88, 0xac)
-
-	var inputs []t2z.TransparentInput
-	var inputTotal uint64
-	for _, utxo := range utxos {
-		txid, _ := hex.DecodeString(utxo.Txid)
-		// Reverse txid bytes
-		for i, j := 0, len(txid)-1; i < j; i, j = i+1, j-1 {
-			txid[i], txid[j] = txid[j], txid[i]
-		}
-		var txidArr [32]byte
-		copy(txidArr[:], txid)
-
-		inputs = append(inputs, t2z.TransparentInput{
-			Pubkey:       pubkey,
-			TxID:         txidArr,
-			Vout:         uint32(utxo.OutputIndex),
-			Amount:       uint64(utxo.Satoshis),
-			ScriptPubKey: script,
-		})
-		inputTotal += uint64(utxo.Satoshis)
-		if inputTotal >= totalNeeded {
-			break
-		}
+		fmt.Printf("  %s ZEC → %s...%s\n", common.ZatoshiToZec(r.Amount), truncate(r.Address, 40), memoInfo)
 	}
+	fmt.Printf("  Inputs: %d of %d UTXOs\n", len(inputs), len(available))
+	fmt.Printf("  Fee: %s ZEC\n", common.ZatoshiToZec(fee))
+	fmt.Printf("  Total: %s ZEC\n", common.ZatoshiToZec(totalNeeded))
 
 	// Build payments
 	var payments []t2z.Payment
@@ -183,7 +198,11 @@ func main() {
 	}
 
 	// Get block height
-	blockHeight, _ := getBlockHeight(zebraRPC)
+	targetHeight, err := client.SuggestTargetHeight(10)
+	if err != nil {
+		fmt.Printf("Failed to get block height: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Build transaction
 	fmt.Println("\nBuilding transaction...")
@@ -191,7 +210,7 @@ func main() {
 	fmt.Print("  Proposing... ")
 	request, _ := t2z.NewTransactionRequest(payments)
 	defer request.Free()
-	request.SetTargetHeight(uint32(blockHeight + 10))
+	request.SetTargetHeight(targetHeight)
 
 	pczt, err := t2z.ProposeTransaction(inputs, request)
 	if err != nil {
@@ -209,13 +228,18 @@ func main() {
 	fmt.Println("done")
 
 	fmt.Print("  Signing... ")
-	signed := proved
-	for i := range inputs {
-		sighash, _ := t2z.GetSighash(signed, uint(i))
+	// All inputs pay to our key, so the signer can ignore the pubkey;
+	// SignAllInputs verifies each signature against it
+	signer := t2z.SignerFunc(func(sighash [32]byte, _ []byte) ([64]byte, error) {
 		sig := ecdsa.SignCompact(privKey, sighash[:], true)
 		var sigBytes [64]byte
-		copy(sigBytes[:], sig[1:])
-		signed, _ = t2z.AppendSignature(signed, uint(i), sigBytes)
+		copy(sigBytes[:], sig[1:]) // Skip recovery ID
+		return sigBytes, nil
+	})
+	signed, err := t2z.SignAllInputs(proved, inputs, signer)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		os.Exit(1)
 	}
 	fmt.Println("done")
 
@@ -223,9 +247,16 @@ func main() {
 	txBytes, _ := t2z.FinalizeAndExtract(signed)
 	fmt.Println("done")
 
-	fmt.Print("  Broadcasting... ")
-	txid, err := broadcast(zebraRPC, hex.EncodeToString(txBytes))
+	// Record the txid first, so it is known even if broadcasting fails
+	txid, err := t2z.TransactionID(txBytes)
 	if err != nil {
+		fmt.Printf("Error computing txid: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("  TXID: %s\n", txid)
+
+	fmt.Print("  Broadcasting... ")
+	if _, err := client.Broadcast(hex.EncodeToString(txBytes)); err != nil {
 		fmt.Printf("error: %v\n", err)
 		os.Exit(1)
 	}
@@ -235,87 +266,6 @@ func main() {
 	fmt.Printf("TXID: %s\n", txid)
 }
 
-type UTXO struct {
-	Txid        string `json:"txid"`
-	OutputIndex int    `json:"outputIndex"`
-	Satoshis    int64  `json:"satoshis"`
-}
-
-func getUTXOs(rpcURL, address string) ([]UTXO, error) {
-	body, _ := json.Marshal(map[string]any{
-		"jsonrpc": "2.0",
-		"method":  "getaddressutxos",
-		"params":  []any{map[string]any{"addresses": []string{address}}},
-		"id":      1,
-	})
-	resp, err := http.Post(rpcURL, "application/json", bytes.NewReader(body))
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	var result struct {
-		Result []UTXO `json:"result"`
-		Error  *struct{ Message string } `json:"error"`
-	}
-	json.NewDecoder(resp.Body).Decode(&result)
-	if result.Error != nil {
-		return nil, fmt.Errorf("%s", result.Error.Message)
-	}
-	return result.Result, nil
-}
-
-func getBlockHeight(rpcURL string) (int, error) {
-	body, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "method": "getblockchaininfo", "params": []any{}, "id": 1})
-	resp, err := http.Post(rpcURL, "application/json", bytes.NewReader(body))
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-	var result struct {
-		Result struct{ Blocks int } `json:"result"`
-	}
-	json.NewDecoder(resp.Body).Decode(&result)
-	return result.Result.Blocks, nil
-}
-
-func broadcast(rpcURL, txHex string) (string, error) {
-	body, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "method": "sendrawtransaction", "params": []string{txHex}, "id": 1})
-	resp, err := http.Post(rpcURL, "application/json", bytes.NewReader(body))
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	var result struct {
-		Result string `json:"result"`
-		Error  *struct{ Message string } `json:"error"`
-	}
-	json.NewDecoder(resp.Body).Decode(&result)
-	if result.Error != nil {
-		return "", fmt.Errorf("%s", result.Error.Message)
-	}
-	return result.Result, nil
-}
-
-func loadEnv() map[string]string {
-	envPath := ".env"
-	data, err := os.ReadFile(envPath)
-	if err != nil {
-		fmt.Println("No .env file found. Run: go run ./cmd/generate-wallet")
-		os.Exit(1)
-	}
-	env := make(map[string]string)
-	env["ZEBRA_HOST"] = "localhost"
-	env["ZEBRA_PORT"] = "8232"
-	for _, line := range strings.Split(string(data), "\n") {
-		if idx := strings.Index(line, "="); idx > 0 && !strings.HasPrefix(line, "#") {
-			key := strings.TrimSpace(line[:idx])
-			val := strings.Trim(strings.TrimSpace(line[idx+1:]), "\"'")
-			env[key] = val
-		}
-	}
-	return env
-}
-
 func truncate(s string, n int) string {
 	if len(s) <= n {
 		return s
@@ -4,8 +4,21 @@ go 1.24.0
 
 require (
 	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.3.0
-	golang.org/x/crypto v0.31.0
-	t2z v0.0.0
+	github.com/gstohl/t2z/go v0.0.0
+	github.com/gstohl/t2z/go/examples/zebrad-regtest v0.0.0
 )
 
-replace t2z => ../..
+require (
+	golang.org/x/crypto v0.45.0 // indirect
+	golang.org/x/net v0.47.0 // indirect
+	golang.org/x/sys v0.38.0 // indirect
+	golang.org/x/text v0.31.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250218202821-56aae31c358a // indirect
+	google.golang.org/grpc v1.72.2 // indirect
+	google.golang.org/protobuf v1.36.6 // indirect
+)
+
+replace (
+	github.com/gstohl/t2z/go => ../..
+	github.com/gstohl/t2z/go/examples/zebrad-regtest => ../zebrad-regtest
+)
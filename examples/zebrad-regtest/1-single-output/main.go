@@ -13,6 +13,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"os"
+	"sort"
 
 	t2z "github.com/gstohl/t2z/go"
 	"github.com/gstohl/t2z/go/examples/zebrad-regtest/common"
@@ -29,7 +30,13 @@ func main() {
 	common.InitDataDir()
 
 	// Create Zebra client
-	client := common.NewZebraClient()
+	client := common.NewZebraClient(t2z.NetworkRegtest)
+
+	// Check node, setup and UTXO availability up front
+	if err := client.Preflight(common.TEST_KEYPAIR, 5, 0); err != nil {
+		common.PrintError("Preflight check failed", err)
+		os.Exit(1)
+	}
 
 	// Load test data for address info
 	testData, err := common.LoadTestData()
@@ -56,8 +63,22 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Use multiple UTXOs to have enough value (each is ~2-5k zatoshis, fee is 10000)
-	inputs := utxos[:5]
+	// Send 50% of the first 5 UTXOs' value, leaving room for fee and change
+	var available uint64
+	for _, u := range utxos[:5] {
+		available += u.Amount
+	}
+	paymentAmount := available / 2
+
+	// Select the fewest UTXOs (largest first) that cover the payment plus fee
+	// for 2 outputs (1 payment + 1 change)
+	sort.Slice(utxos, func(i, j int) bool { return utxos[i].Amount > utxos[j].Amount })
+	numInputs, err := t2z.MinInputsForTarget(utxos, paymentAmount, 2, 0)
+	if err != nil {
+		common.PrintError("Insufficient funds", err)
+		os.Exit(1)
+	}
+	inputs := utxos[:numInputs]
 	var totalInput uint64
 	for _, u := range inputs {
 		totalInput += u.Amount
@@ -68,8 +89,6 @@ func main() {
 	destAddress := testData.Transparent.Address
 	// Calculate fee: inputs, 2 outputs (1 payment + 1 change), 0 orchard
 	fee := t2z.CalculateFee(len(inputs), 2, 0)
-	// Use 50% of the total input value, leaving room for fee and change
-	paymentAmount := totalInput / 2
 
 	payments := []t2z.Payment{
 		{
@@ -139,21 +158,12 @@ func main() {
 
 	// Step 4-6: Sign each input
 	fmt.Println("4. Signing each input...")
-	currentPczt := proved
-	for i := 0; i < len(inputs); i++ {
-		sighash, err := t2z.GetSighash(currentPczt, uint(i))
-		if err != nil {
-			common.PrintError(fmt.Sprintf("Failed to get sighash for input %d", i), err)
-			os.Exit(1)
-		}
-		signature := common.SignCompact(sighash[:], common.TEST_KEYPAIR)
-		currentPczt, err = t2z.AppendSignature(currentPczt, uint(i), signature)
-		if err != nil {
-			common.PrintError(fmt.Sprintf("Failed to append signature for input %d", i), err)
-			os.Exit(1)
-		}
-		fmt.Printf("   Input %d: signed\n", i)
+	currentPczt, err := t2z.SignAllInputs(proved, inputs, common.TEST_KEYPAIR)
+	if err != nil {
+		common.PrintError("Failed to sign inputs", err)
+		os.Exit(1)
 	}
+	fmt.Printf("   Signed %d inputs\n", len(inputs))
 	fmt.Println()
 
 	// Step 7: Finalize and extract transaction bytes
@@ -164,7 +174,25 @@ func main() {
 		os.Exit(1)
 	}
 	txHex := hex.EncodeToString(txBytes)
-	fmt.Printf("   Transaction finalized (%d bytes)\n\n", len(txBytes))
+	fmt.Printf("   Transaction finalized (%d bytes)\n", len(txBytes))
+
+	// Check that the transaction spends exactly the UTXOs we selected
+	txInputs, err := common.ParseTxInputs(txHex)
+	if err != nil {
+		common.PrintError("Failed to parse transaction inputs", err)
+		os.Exit(1)
+	}
+	if len(txInputs) != len(inputs) {
+		common.PrintError("Transaction spends unexpected inputs", fmt.Errorf("expected %d inputs, got %d", len(inputs), len(txInputs)))
+		os.Exit(1)
+	}
+	for i, in := range txInputs {
+		if in.TxID != inputs[i].TxID || in.Vout != inputs[i].Vout {
+			common.PrintError("Transaction spends unexpected inputs", fmt.Errorf("input %d spends %x:%d", i, common.ReverseBytes(in.TxID[:]), in.Vout))
+			os.Exit(1)
+		}
+	}
+	fmt.Printf("   Inputs match the %d selected UTXOs\n\n", len(txInputs))
 
 	// Step 8: Broadcast transaction
 	fmt.Println("6. Broadcasting transaction to network...")
@@ -175,6 +203,11 @@ func main() {
 	}
 	common.PrintBroadcastResult(txid, txHex)
 
+	// The node relays the transaction once it is in the mempool
+	if inMempool, err := client.IsInMempool(txid); err == nil && inMempool {
+		fmt.Println("   Accepted into mempool")
+	}
+
 	// Mark UTXOs as spent for subsequent examples
 	if err := common.MarkUtxosSpent(inputs); err != nil {
 		fmt.Printf("Warning: Failed to mark UTXOs as spent: %v\n", err)
@@ -182,8 +215,7 @@ func main() {
 
 	// Wait for confirmation
 	fmt.Println("Waiting for confirmation...")
-	currentHeight := info.Blocks
-	_, err = client.WaitForBlocks(currentHeight+1, 60000)
+	_, err = client.WaitForConfirmation(txid, 1, 60000)
 	if err != nil {
 		fmt.Printf("Warning: %v\n", err)
 	} else {
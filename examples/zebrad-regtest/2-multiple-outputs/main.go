@@ -29,7 +29,13 @@ func main() {
 	common.InitDataDir()
 
 	// Create Zebra client
-	client := common.NewZebraClient()
+	client := common.NewZebraClient(t2z.NetworkRegtest)
+
+	// Check node, setup and UTXO availability up front
+	if err := client.Preflight(common.TEST_KEYPAIR, 5, 0); err != nil {
+		common.PrintError("Preflight check failed", err)
+		os.Exit(1)
+	}
 
 	// Load test data
 	testData, err := common.LoadTestData()
@@ -39,9 +45,9 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Two destination addresses - using same address for simplicity
+	// Two destination addresses - ZIP-321 forbids paying the same address twice
 	dest1 := testData.Transparent.Address
-	dest2 := testData.Transparent.Address
+	dest2 := "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma"
 
 	fmt.Println("Configuration:")
 	fmt.Printf("  Source address: %s\n", testData.Transparent.Address)
@@ -141,21 +147,12 @@ func main() {
 
 	// Sign each input
 	fmt.Println("4. Signing each input...")
-	currentPczt := proved
-	for i := 0; i < len(inputs); i++ {
-		sighash, err := t2z.GetSighash(currentPczt, uint(i))
-		if err != nil {
-			common.PrintError(fmt.Sprintf("Failed to get sighash for input %d", i), err)
-			os.Exit(1)
-		}
-		signature := common.SignCompact(sighash[:], common.TEST_KEYPAIR)
-		currentPczt, err = t2z.AppendSignature(currentPczt, uint(i), signature)
-		if err != nil {
-			common.PrintError(fmt.Sprintf("Failed to append signature for input %d", i), err)
-			os.Exit(1)
-		}
-		fmt.Printf("   Input %d: signed\n", i)
+	currentPczt, err := t2z.SignAllInputs(proved, inputs, common.TEST_KEYPAIR)
+	if err != nil {
+		common.PrintError("Failed to sign inputs", err)
+		os.Exit(1)
 	}
+	fmt.Printf("   Signed %d inputs\n", len(inputs))
 	fmt.Println()
 
 	fmt.Println("5. Finalizing transaction...")
@@ -29,7 +29,13 @@ func main() {
 	common.InitDataDir()
 
 	// Create Zebra client
-	client := common.NewZebraClient()
+	client := common.NewZebraClient(t2z.NetworkRegtest)
+
+	// Check node, setup and UTXO availability up front
+	if err := client.Preflight(common.TEST_KEYPAIR, 6, 0); err != nil {
+		common.PrintError("Preflight check failed", err)
+		os.Exit(1)
+	}
 
 	// Load test data
 	testData, err := common.LoadTestData()
@@ -137,26 +143,20 @@ func main() {
 
 	// Sign each input separately (key difference for multiple inputs!)
 	fmt.Println("4. Getting sighashes and signing each input...")
-	currentPczt := proved
-	for i := range inputs {
-		fmt.Printf("   Input %d:\n", i)
-
-		sighash, err := t2z.GetSighash(currentPczt, uint(i))
-		if err != nil {
-			common.PrintError(fmt.Sprintf("Failed to get sighash for input %d", i), err)
-			os.Exit(1)
-		}
-		fmt.Printf("     Sighash: %s...\n", hex.EncodeToString(sighash[:])[:24])
-
-		signature := common.SignCompact(sighash[:], common.TEST_KEYPAIR)
-		fmt.Printf("     Signature: %s...\n", hex.EncodeToString(signature[:])[:24])
-
-		currentPczt, err = t2z.AppendSignature(currentPczt, uint(i), signature)
-		if err != nil {
-			common.PrintError(fmt.Sprintf("Failed to append signature for input %d", i), err)
-			os.Exit(1)
+	signer := t2z.SignerFunc(func(sighash [32]byte, pubkey []byte) ([64]byte, error) {
+		fmt.Printf("   Sighash: %s...\n", hex.EncodeToString(sighash[:])[:24])
+		signature, err := common.TEST_KEYPAIR.Sign(sighash, pubkey)
+		if err == nil {
+			fmt.Printf("   Signature: %s...\n", hex.EncodeToString(signature[:])[:24])
 		}
-		fmt.Println("     Signature appended")
+		return signature, err
+	})
+	currentPczt, err := t2z.SignAllInputsProgress(proved, signer, func(done, total int) {
+		fmt.Printf("   Input %d signed (%d of %d)\n", done-1, done, total)
+	})
+	if err != nil {
+		common.PrintError("Failed to sign inputs", err)
+		os.Exit(1)
 	}
 	fmt.Println()
 
@@ -13,6 +13,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -35,7 +36,13 @@ func main() {
 	common.InitDataDir()
 
 	// Create Zebra client
-	client := common.NewZebraClient()
+	client := common.NewZebraClient(t2z.NetworkRegtest)
+
+	// Check node, setup and UTXO availability up front
+	if err := client.Preflight(common.TEST_KEYPAIR, 5, 0); err != nil {
+		common.PrintError("Preflight check failed", err)
+		os.Exit(1)
+	}
 
 	// Load test data
 	testData, err := common.LoadTestData()
@@ -141,10 +148,13 @@ func main() {
 
 	fmt.Println("User verifies PCZT before signing...")
 	err = t2z.VerifyBeforeSigning(proved, maliciousRequest1, []t2z.TransparentOutput{})
-	if err != nil {
+	if errors.Is(err, t2z.ErrVerification) {
 		fmt.Println("   ATTACK DETECTED! Verification failed:")
 		fmt.Printf("   Error: %v\n\n", err)
 		fmt.Println("   Transaction NOT signed - funds are SAFE!")
+	} else if err != nil {
+		fmt.Printf("   Verification could not run: %v\n", err)
+		fmt.Println("   Transaction NOT signed.")
 	} else {
 		fmt.Println("   DANGER: Verification passed (should not happen!)")
 	}
@@ -168,10 +178,13 @@ func main() {
 
 	fmt.Println("User verifies PCZT before signing...")
 	err = t2z.VerifyBeforeSigning(proved, maliciousRequest2, []t2z.TransparentOutput{})
-	if err != nil {
+	if errors.Is(err, t2z.ErrVerification) {
 		fmt.Println("   ATTACK DETECTED! Verification failed:")
 		fmt.Printf("   Error: %v\n\n", err)
 		fmt.Println("   Transaction NOT signed - funds are SAFE!")
+	} else if err != nil {
+		fmt.Printf("   Verification could not run: %v\n", err)
+		fmt.Println("   Transaction NOT signed.")
 	} else {
 		fmt.Println("   DANGER: Verification passed (should not happen!)")
 	}
@@ -196,10 +209,13 @@ func main() {
 
 	fmt.Println("User verifies PCZT before signing...")
 	err = t2z.VerifyBeforeSigning(proved, maliciousRequest3, []t2z.TransparentOutput{})
-	if err != nil {
+	if errors.Is(err, t2z.ErrVerification) {
 		fmt.Println("   ATTACK DETECTED! Verification failed:")
 		fmt.Printf("   Error: %v\n\n", err)
 		fmt.Println("   Transaction NOT signed - funds are SAFE!")
+	} else if err != nil {
+		fmt.Printf("   Verification could not run: %v\n", err)
+		fmt.Println("   Transaction NOT signed.")
 	} else {
 		fmt.Println("   DANGER: Verification passed (should not happen!)")
 	}
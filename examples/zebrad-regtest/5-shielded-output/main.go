@@ -26,7 +26,7 @@ import (
 
 // Deterministic mainnet unified address with Orchard receiver
 // Generated from SpendingKey::from_bytes([42u8; 32])
-const shieldedAddress = "u1eq7cm60un363n2sa862w4t5pq56tl5x0d7wqkzhhva0sxue7kqw85haa6w6xsz8n8ujmcpkzsza8knwgglau443s7ljdgu897yrvyhhz"
+const defaultShieldedAddress = "u1eq7cm60un363n2sa862w4t5pq56tl5x0d7wqkzhhva0sxue7kqw85haa6w6xsz8n8ujmcpkzsza8knwgglau443s7ljdgu897yrvyhhz"
 
 func main() {
 	fmt.Println()
@@ -39,7 +39,13 @@ func main() {
 	common.InitDataDir()
 
 	// Create Zebra client
-	client := common.NewZebraClient()
+	client := common.NewZebraClient(t2z.NetworkRegtest)
+
+	// Check node, setup and UTXO availability up front
+	if err := client.Preflight(common.TEST_KEYPAIR, 5, 0); err != nil {
+		common.PrintError("Preflight check failed", err)
+		os.Exit(1)
+	}
 
 	// Load test data
 	testData, err := common.LoadTestData()
@@ -49,10 +55,26 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Set T2Z_SHIELDED_ADDRESS to send to another address, e.g. a utest1...
+	// address when running against public testnet
+	shieldedAddress := defaultShieldedAddress
+	if addr := os.Getenv("T2Z_SHIELDED_ADDRESS"); addr != "" {
+		shieldedAddress = addr
+	}
+	ua, err := t2z.DecodeUnifiedAddress(shieldedAddress)
+	if err != nil {
+		common.PrintError("Invalid shielded address", err)
+		os.Exit(1)
+	}
+	if _, err := ua.OrchardReceiver(); err != nil {
+		common.PrintError("Shielded address cannot receive Orchard", err)
+		os.Exit(1)
+	}
+
 	fmt.Println("Configuration:")
 	fmt.Printf("  Source address: %s\n", testData.Transparent.Address)
 	fmt.Printf("  Destination (shielded): %s...\n", shieldedAddress[:30])
-	fmt.Println("  Note: This is an Orchard address (u1... prefix = mainnet)")
+	fmt.Printf("  Note: This is an Orchard address on %s\n", ua.Network)
 	fmt.Println()
 
 	// Fetch mature coinbase UTXOs
@@ -116,7 +138,13 @@ func main() {
 	fmt.Printf("Current block height: %d\n", info.Blocks)
 
 	request.SetTargetHeight(2_500_000)
-	fmt.Println("Using mainnet parameters (target height: 2,500,000)")
+	if ua.Network == t2z.NetworkTestnet {
+		// Testnet addresses need testnet consensus parameters
+		request.SetNetwork(t2z.NetworkTestnet)
+		fmt.Println("Using testnet parameters (target height: 2,500,000)")
+	} else {
+		fmt.Println("Using mainnet parameters (target height: 2,500,000)")
+	}
 	fmt.Println()
 
 	// Workflow
@@ -150,21 +178,12 @@ func main() {
 
 	// Sign each input
 	fmt.Println("4. Signing each input...")
-	currentPczt := proved
-	for i := 0; i < len(inputs); i++ {
-		sighash, err := t2z.GetSighash(currentPczt, uint(i))
-		if err != nil {
-			common.PrintError(fmt.Sprintf("Failed to get sighash for input %d", i), err)
-			os.Exit(1)
-		}
-		signature := common.SignCompact(sighash[:], common.TEST_KEYPAIR)
-		currentPczt, err = t2z.AppendSignature(currentPczt, uint(i), signature)
-		if err != nil {
-			common.PrintError(fmt.Sprintf("Failed to append signature for input %d", i), err)
-			os.Exit(1)
-		}
-		fmt.Printf("   Input %d: signed\n", i)
+	currentPczt, err := t2z.SignAllInputs(proved, inputs, common.TEST_KEYPAIR)
+	if err != nil {
+		common.PrintError("Failed to sign inputs", err)
+		os.Exit(1)
 	}
+	fmt.Printf("   Signed %d inputs\n", len(inputs))
 	fmt.Println()
 
 	fmt.Println("5. Finalizing transaction...")
@@ -174,7 +193,15 @@ func main() {
 		os.Exit(1)
 	}
 	txHex := hex.EncodeToString(txBytes)
-	fmt.Printf("   Transaction finalized (%d bytes)\n\n", len(txBytes))
+	fmt.Printf("   Transaction finalized (%d bytes)\n", len(txBytes))
+
+	// The single shielded output is padded to two Orchard actions
+	decoded, err := t2z.DecodeTransaction(txBytes)
+	if err != nil {
+		common.PrintError("Failed to decode transaction", err)
+		os.Exit(1)
+	}
+	fmt.Printf("   Orchard actions: %d, value balance: %d zatoshis\n\n", len(decoded.OrchardActions), decoded.OrchardValueBalance)
 
 	fmt.Println("======================================================================")
 	fmt.Println("  TRANSACTION CREATED (NOT BROADCAST)")
@@ -39,7 +39,13 @@ func main() {
 	common.InitDataDir()
 
 	// Create Zebra client
-	client := common.NewZebraClient()
+	client := common.NewZebraClient(t2z.NetworkRegtest)
+
+	// Check node, setup and UTXO availability up front
+	if err := client.Preflight(common.TEST_KEYPAIR, 5, 0); err != nil {
+		common.PrintError("Preflight check failed", err)
+		os.Exit(1)
+	}
 
 	// Load test data
 	testData, err := common.LoadTestData()
@@ -107,7 +113,8 @@ func main() {
 	fmt.Println("   - Each recipient receives private funds")
 	fmt.Println()
 
-	request, err := t2z.NewTransactionRequest(payments)
+	// Both payments go to the same address, which ZIP-321 requests forbid
+	request, err := t2z.NewTransactionRequestAllowDuplicates(payments)
 	if err != nil {
 		common.PrintError("Failed to create transaction request", err)
 		os.Exit(1)
@@ -157,21 +164,12 @@ func main() {
 
 	// Sign each input
 	fmt.Println("4. Signing each input...")
-	currentPczt := proved
-	for i := 0; i < len(inputs); i++ {
-		sighash, err := t2z.GetSighash(currentPczt, uint(i))
-		if err != nil {
-			common.PrintError(fmt.Sprintf("Failed to get sighash for input %d", i), err)
-			os.Exit(1)
-		}
-		signature := common.SignCompact(sighash[:], common.TEST_KEYPAIR)
-		currentPczt, err = t2z.AppendSignature(currentPczt, uint(i), signature)
-		if err != nil {
-			common.PrintError(fmt.Sprintf("Failed to append signature for input %d", i), err)
-			os.Exit(1)
-		}
-		fmt.Printf("   Input %d: signed\n", i)
+	currentPczt, err := t2z.SignAllInputs(proved, inputs, common.TEST_KEYPAIR)
+	if err != nil {
+		common.PrintError("Failed to sign inputs", err)
+		os.Exit(1)
 	}
+	fmt.Printf("   Signed %d inputs\n", len(inputs))
 	fmt.Println()
 
 	fmt.Println("5. Finalizing transaction...")
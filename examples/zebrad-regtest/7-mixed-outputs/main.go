@@ -39,7 +39,13 @@ func main() {
 	common.InitDataDir()
 
 	// Create Zebra client
-	client := common.NewZebraClient()
+	client := common.NewZebraClient(t2z.NetworkRegtest)
+
+	// Check node, setup and UTXO availability up front
+	if err := client.Preflight(common.TEST_KEYPAIR, 5, 0); err != nil {
+		common.PrintError("Preflight check failed", err)
+		os.Exit(1)
+	}
 
 	// Load test data
 	testData, err := common.LoadTestData()
@@ -80,7 +86,7 @@ func main() {
 	fmt.Printf("  Selected %d UTXOs totaling: %s ZEC\n\n", len(inputs), common.ZatoshiToZec(totalInput))
 
 	// Create mixed payments
-	// Calculate fee: inputs, 2 transparent (1 payment + 1 change), 1 orchard
+	// Estimate fee: inputs, 2 transparent (1 payment + 1 change), 1 orchard
 	fee := t2z.CalculateFee(len(inputs), 2, 1)
 	availableForPayments := totalInput - fee
 
@@ -93,6 +99,16 @@ func main() {
 		{Address: shieldedAddress, Amount: shieldedPayment},
 	}
 
+	request, err := t2z.NewTransactionRequest(payments)
+	if err != nil {
+		common.PrintError("Failed to create transaction request", err)
+		os.Exit(1)
+	}
+	defer request.Free()
+
+	// Derive the fee from the request itself: 1 transparent + 1 orchard payment, plus change
+	fee = t2z.CalculateFeeForRequest(inputs, request, true)
+
 	fmt.Println("======================================================================")
 	fmt.Println("  TRANSACTION SUMMARY - MIXED T+Z")
 	fmt.Println("======================================================================")
@@ -112,13 +128,6 @@ func main() {
 	fmt.Println("   - Real-world use case: pay merchant + shield savings")
 	fmt.Println()
 
-	request, err := t2z.NewTransactionRequest(payments)
-	if err != nil {
-		common.PrintError("Failed to create transaction request", err)
-		os.Exit(1)
-	}
-	defer request.Free()
-
 	// Get current block height
 	info, err := client.GetBlockchainInfo()
 	if err != nil {
@@ -162,21 +171,12 @@ func main() {
 
 	// Sign each input
 	fmt.Println("4. Signing each input...")
-	currentPczt := proved
-	for i := 0; i < len(inputs); i++ {
-		sighash, err := t2z.GetSighash(currentPczt, uint(i))
-		if err != nil {
-			common.PrintError(fmt.Sprintf("Failed to get sighash for input %d", i), err)
-			os.Exit(1)
-		}
-		signature := common.SignCompact(sighash[:], common.TEST_KEYPAIR)
-		currentPczt, err = t2z.AppendSignature(currentPczt, uint(i), signature)
-		if err != nil {
-			common.PrintError(fmt.Sprintf("Failed to append signature for input %d", i), err)
-			os.Exit(1)
-		}
-		fmt.Printf("   Input %d: signed\n", i)
+	currentPczt, err := t2z.SignAllInputs(proved, inputs, common.TEST_KEYPAIR)
+	if err != nil {
+		common.PrintError("Failed to sign inputs", err)
+		os.Exit(1)
 	}
+	fmt.Printf("   Signed %d inputs\n", len(inputs))
 	fmt.Println()
 
 	fmt.Println("5. Finalizing transaction...")
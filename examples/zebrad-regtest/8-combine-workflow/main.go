@@ -2,7 +2,7 @@
 //
 // Demonstrates the combine() function for multi-party signing workflows:
 // - Create a transaction with multiple inputs
-// - Serialize the PCZT and create copies for parallel signing
+// - Clone the PCZT to create copies for parallel signing
 // - Each "signer" signs their input independently
 // - Combine the partially-signed PCZTs into one
 // - Finalize the transaction
@@ -39,7 +39,13 @@ func main() {
 	common.InitDataDir()
 
 	// Create Zebra client
-	client := common.NewZebraClient()
+	client := common.NewZebraClient(t2z.NetworkRegtest)
+
+	// Check node, setup and UTXO availability up front
+	if err := client.Preflight(common.TEST_KEYPAIR, 3, 0); err != nil {
+		common.PrintError("Preflight check failed", err)
+		os.Exit(1)
+	}
 
 	// Load test data
 	testData, err := common.LoadTestData()
@@ -68,7 +74,9 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Use 3 UTXOs to simulate 3 different parties
+	// Use 3 UTXOs to simulate 3 different parties. They all belong to the
+	// test key, so ProposeTransaction can return the change to it; inputs
+	// from different keys need ProposeTransactionWithChange instead.
 	inputs := utxos[:3]
 	var totalInput uint64
 	for _, u := range inputs {
@@ -115,21 +123,25 @@ func main() {
 	}
 	fmt.Println("   PCZT created and proved\n")
 
-	// Step 2: Serialize the proved PCZT
-	fmt.Println("2. Serializing PCZT for distribution to signers...")
-	pcztBytes, err := t2z.SerializePCZT(proved)
-	if err != nil {
-		common.PrintError("Failed to serialize", err)
-		os.Exit(1)
+	// Step 2: Clone the proved PCZT, one independent copy per signer
+	fmt.Println("2. Cloning PCZT for distribution to signers...")
+	signerPczts := make([]*t2z.PCZT, 3)
+	for i := range signerPczts {
+		signerPczts[i], err = t2z.ClonePCZT(proved)
+		if err != nil {
+			common.PrintError("Failed to clone", err)
+			os.Exit(1)
+		}
 	}
-	fmt.Printf("   Serialized PCZT: %d bytes\n\n", len(pcztBytes))
+	proved.Free()
+	fmt.Printf("   Created %d independent copies\n\n", len(signerPczts))
 
 	// Step 3: Simulate parallel signing by different parties
 	fmt.Println("3. Simulating parallel signing by 3 different parties...\n")
 
 	// Signer A signs input 0
 	fmt.Println("   Signer A: Signing input 0...")
-	pcztA, _ := t2z.ParsePCZT(pcztBytes)
+	pcztA := signerPczts[0]
 	sighashA, _ := t2z.GetSighash(pcztA, 0)
 	signatureA := common.SignCompact(sighashA[:], common.TEST_KEYPAIR)
 	signedA, _ := t2z.AppendSignature(pcztA, 0, signatureA)
@@ -138,7 +150,7 @@ func main() {
 
 	// Signer B signs input 1
 	fmt.Println("   Signer B: Signing input 1...")
-	pcztB, _ := t2z.ParsePCZT(pcztBytes)
+	pcztB := signerPczts[1]
 	sighashB, _ := t2z.GetSighash(pcztB, 1)
 	signatureB := common.SignCompact(sighashB[:], common.TEST_KEYPAIR)
 	signedB, _ := t2z.AppendSignature(pcztB, 1, signatureB)
@@ -147,7 +159,7 @@ func main() {
 
 	// Signer C signs input 2
 	fmt.Println("   Signer C: Signing input 2...")
-	pcztC, _ := t2z.ParsePCZT(pcztBytes)
+	pcztC := signerPczts[2]
 	sighashC, _ := t2z.GetSighash(pcztC, 2)
 	signatureC := common.SignCompact(sighashC[:], common.TEST_KEYPAIR)
 	signedC, _ := t2z.AppendSignature(pcztC, 2, signatureC)
@@ -165,6 +177,16 @@ func main() {
 		common.PrintError("Failed to combine", err)
 		os.Exit(1)
 	}
+	// Make sure no signer was skipped before finalizing consumes the PCZT
+	missing, err := t2z.MissingSignatures(fullySignedPczt)
+	if err != nil {
+		common.PrintError("Failed to check signatures", err)
+		os.Exit(1)
+	}
+	if len(missing) > 0 {
+		fmt.Printf("   Inputs still unsigned: %v\n", missing)
+		os.Exit(1)
+	}
 	fmt.Println("   All signatures combined into single PCZT\n")
 
 	// Step 5: Finalize
@@ -37,7 +37,13 @@ func main() {
 	common.InitDataDir()
 
 	// Create Zebra client
-	client := common.NewZebraClient()
+	client := common.NewZebraClient(t2z.NetworkRegtest)
+
+	// Check node, setup and UTXO availability up front
+	if err := client.Preflight(common.TEST_KEYPAIR, 1, 0); err != nil {
+		common.PrintError("Preflight check failed", err)
+		os.Exit(1)
+	}
 
 	// Load test data
 	testData, err := common.LoadTestData()
@@ -129,6 +135,15 @@ func main() {
 	}
 	fmt.Printf("   PCZT serialized: %d bytes\n", len(pcztBytes))
 
+	// A proved PCZT is too large for one QR code; split it into UR parts for
+	// an animated QR code that hardware wallets can scan
+	urParts, err := t2z.EncodePCZTToURParts(proved, 200)
+	if err != nil {
+		common.PrintError("Failed to encode UR parts", err)
+		os.Exit(1)
+	}
+	fmt.Printf("   Animated QR: %d UR frames (e.g. %s...)\n", len(urParts), urParts[0][:32])
+
 	fmt.Println("\n4. Getting sighash for offline signing...")
 	sighash, err := t2z.GetSighash(proved, 0)
 	if err != nil {
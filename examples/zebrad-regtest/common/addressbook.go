@@ -0,0 +1,137 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	t2z "github.com/gstohl/t2z/go"
+)
+
+// AddressBook maps labels to payment addresses of one network
+type AddressBook struct {
+	network t2z.Network
+	entries map[string]string
+}
+
+// addressBookJSON is the file format of an address book
+type addressBookJSON struct {
+	Network string            `json:"network"`
+	Entries map[string]string `json:"entries"`
+}
+
+// NewAddressBook returns an empty address book for addresses of net
+func NewAddressBook(net t2z.Network) *AddressBook {
+	return &AddressBook{network: net, entries: make(map[string]string)}
+}
+
+// Network returns the network of the addresses in the book
+func (b *AddressBook) Network() t2z.Network {
+	return b.network
+}
+
+// Add stores address under label. The address must decode with
+// t2z.ValidateAddress and belong to the network of the book; regtest books
+// also accept transparent addresses with the testnet encoding, which regtest
+// shares. Adding an existing label is an error, so that a stored recipient
+// is never replaced by accident; Remove it first.
+func (b *AddressBook) Add(label, address string) error {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return errors.New("empty label")
+	}
+	if existing, ok := b.entries[label]; ok {
+		return fmt.Errorf("label %q already exists for %s", label, existing)
+	}
+	if err := b.checkAddress(address); err != nil {
+		return fmt.Errorf("label %q: %w", label, err)
+	}
+
+	b.entries[label] = address
+	return nil
+}
+
+// checkAddress checks that address decodes and belongs to the book's network
+func (b *AddressBook) checkAddress(address string) error {
+	info, err := t2z.ValidateAddress(address)
+	if err != nil {
+		return err
+	}
+	if info.Network == b.network {
+		return nil
+	}
+	if b.network == t2z.NetworkRegtest && info.Network == t2z.NetworkTestnet && info.Kind.IsTransparent() {
+		return nil
+	}
+	return fmt.Errorf("address %s is %s but address book is %s: %w", address, info.Network, b.network, t2z.ErrNetworkMismatch)
+}
+
+// Lookup returns the address stored under label
+func (b *AddressBook) Lookup(label string) (string, bool) {
+	address, ok := b.entries[strings.TrimSpace(label)]
+	return address, ok
+}
+
+// Remove removes label from the book, and reports whether it was there
+func (b *AddressBook) Remove(label string) bool {
+	label = strings.TrimSpace(label)
+	_, ok := b.entries[label]
+	delete(b.entries, label)
+	return ok
+}
+
+// Labels returns the labels in the book in sorted order
+func (b *AddressBook) Labels() []string {
+	labels := make([]string, 0, len(b.entries))
+	for label := range b.entries {
+		labels = append(labels, label)
+	}
+	slices.Sort(labels)
+	return labels
+}
+
+// Save writes the address book to path as JSON
+func (b *AddressBook) Save(path string) error {
+	data, err := json.MarshalIndent(addressBookJSON{Network: b.network.String(), Entries: b.entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadAddressBook reads an address book written by Save. Every entry is
+// checked like by Add, so a file edited by hand cannot smuggle in an invalid
+// or foreign-network address. A missing file returns an error wrapping
+// fs.ErrNotExist.
+func LoadAddressBook(path string) (*AddressBook, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file addressBookJSON
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse address book %s: %w", path, err)
+	}
+
+	var net t2z.Network
+	for _, n := range []t2z.Network{t2z.NetworkMainnet, t2z.NetworkTestnet, t2z.NetworkRegtest} {
+		if file.Network == n.String() {
+			net = n
+		}
+	}
+	if net == 0 {
+		return nil, fmt.Errorf("address book %s: unknown network %q", path, file.Network)
+	}
+
+	book := NewAddressBook(net)
+	for label, address := range file.Entries {
+		if err := book.Add(label, address); err != nil {
+			return nil, fmt.Errorf("address book %s: %w", path, err)
+		}
+	}
+	return book, nil
+}
@@ -0,0 +1,89 @@
+package common
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	t2z "github.com/gstohl/t2z/go"
+)
+
+const (
+	testMainnetAddress = "t1HsdDMzmJfq4vc7T17XYjEkLMLvbgM1fCi"
+	testTestnetAddress = "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma"
+)
+
+// Test adding, looking up and persisting address book entries
+func TestAddressBook(t *testing.T) {
+	book := NewAddressBook(t2z.NetworkMainnet)
+	if err := book.Add("alice", testMainnetAddress); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if addr, ok := book.Lookup(" alice "); !ok || addr != testMainnetAddress {
+		t.Errorf("Lookup = %q, %v", addr, ok)
+	}
+	if _, ok := book.Lookup("bob"); ok {
+		t.Error("Expected no entry for an unknown label")
+	}
+
+	// Bad entries are rejected
+	if err := book.Add("alice", testMainnetAddress); err == nil {
+		t.Error("Expected an error for an existing label")
+	}
+	if err := book.Add("bob", testTestnetAddress); !errors.Is(err, t2z.ErrNetworkMismatch) {
+		t.Errorf("Expected ErrNetworkMismatch for a testnet address, got %v", err)
+	}
+	if err := book.Add("bob", "t1HsdDMzmJfq4vc7T17XYjEkLMLvbgM1fCj"); err == nil {
+		t.Error("Expected an error for a bad checksum")
+	}
+	if err := book.Add(" ", testMainnetAddress); err == nil {
+		t.Error("Expected an error for an empty label")
+	}
+
+	path := filepath.Join(t.TempDir(), "addressbook.json")
+	if err := book.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := LoadAddressBook(path)
+	if err != nil {
+		t.Fatalf("LoadAddressBook failed: %v", err)
+	}
+	if loaded.Network() != t2z.NetworkMainnet || !slices.Equal(loaded.Labels(), []string{"alice"}) {
+		t.Errorf("Unexpected loaded book %s %v", loaded.Network(), loaded.Labels())
+	}
+
+	if !loaded.Remove("alice") || loaded.Remove("alice") {
+		t.Error("Expected Remove to report whether the label was there")
+	}
+
+	// Regtest books accept testnet-encoded transparent addresses
+	if err := NewAddressBook(t2z.NetworkRegtest).Add("miner", testTestnetAddress); err != nil {
+		t.Errorf("Expected a regtest book to accept %s: %v", testTestnetAddress, err)
+	}
+}
+
+// Test that loading rejects files with invalid entries
+func TestLoadAddressBookErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := LoadAddressBook(filepath.Join(dir, "missing.json")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist, got %v", err)
+	}
+
+	files := map[string]string{
+		"network.json":  `{"network":"devnet","entries":{}}`,
+		"mismatch.json": `{"network":"mainnet","entries":{"bob":"` + testTestnetAddress + `"}}`,
+		"syntax.json":   `{"network":`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadAddressBook(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package common
+
+import (
+	t2z "github.com/gstohl/t2z/go"
+)
+
+// ChainClient is the chain backend used to look up UTXOs and broadcast
+// transactions. It is implemented by ZebraClient (JSON-RPC) and
+// LightwalletdClient (gRPC).
+type ChainClient interface {
+	t2z.Broadcaster
+
+	// GetBlockchainInfo returns the chain name and current tip height
+	GetBlockchainInfo() (*BlockchainInfo, error)
+	// GetBlockCount returns the current tip height
+	GetBlockCount() (int, error)
+	// GetAddressUtxos returns the unspent outputs paying to any of the
+	// transparent addresses
+	GetAddressUtxos(addresses []string) ([]AddressUtxo, error)
+	// SendTransaction broadcasts a raw transaction and returns its txid
+	SendTransaction(txHex string) (string, error)
+}
+
+// AddressUtxo is an unspent transparent output returned by a ChainClient
+type AddressUtxo struct {
+	Address     string
+	TxID        [32]byte // internal byte order, as used by t2z.TransparentInput
+	OutputIndex uint32
+	Script      []byte
+	Value       uint64
+	Height      int
+}
+
+// TransparentInput converts the UTXO into a t2z input spendable by pubkey
+func (u AddressUtxo) TransparentInput(pubkey []byte) t2z.TransparentInput {
+	return t2z.TransparentInput{
+		Pubkey:       pubkey,
+		TxID:         u.TxID,
+		Vout:         u.OutputIndex,
+		Amount:       u.Value,
+		ScriptPubKey: u.Script,
+	}
+}
+
+// TransparentInputs converts UTXOs into t2z inputs spendable by pubkey.
+//
+// Chain backends only know the address an output pays to, not the public
+// key behind it, so the caller supplies the key. All UTXOs must belong to
+// that key.
+func TransparentInputs(utxos []AddressUtxo, pubkey []byte) []t2z.TransparentInput {
+	inputs := make([]t2z.TransparentInput, len(utxos))
+	for i, u := range utxos {
+		inputs[i] = u.TransparentInput(pubkey)
+	}
+	return inputs
+}
+
+var (
+	_ ChainClient = (*ZebraClient)(nil)
+	_ ChainClient = (*LightwalletdClient)(nil)
+)
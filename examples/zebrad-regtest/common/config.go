@@ -0,0 +1,205 @@
+package common
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	t2z "github.com/gstohl/t2z/go"
+)
+
+// Config file names tried by LoadDefaultConfig, in order
+const (
+	DefaultConfigPath = "wallet.json"
+	DefaultEnvPath    = ".env"
+)
+
+// Config is the wallet and node configuration of the example tools
+type Config struct {
+	Network t2z.Network `json:"-"`
+
+	// Key material: a 64-character hex or WIF private key (see
+	// ParsePrivateKey), its compressed public key in hex, the transparent
+	// address, and the recovery phrase if the key was derived from one
+	PrivateKey string `json:"privateKey,omitempty"`
+	PublicKey  string `json:"publicKey,omitempty"`
+	Address    string `json:"address,omitempty"`
+	Mnemonic   string `json:"mnemonic,omitempty"`
+
+	RPC RPCConfig `json:"rpc"`
+}
+
+// RPCConfig is the endpoint and authentication of a Zebra node
+type RPCConfig struct {
+	Host string `json:"host,omitempty"`
+	Port string `json:"port,omitempty"`
+	// TLS selects https://, and TLSInsecure skips certificate verification
+	// during development
+	TLS         bool `json:"tls,omitempty"`
+	TLSInsecure bool `json:"tlsInsecure,omitempty"`
+	// HTTP Basic auth, or a cookie file, see ZebraClient.SetCookieFile
+	User       string `json:"user,omitempty"`
+	Password   string `json:"password,omitempty"`
+	CookieFile string `json:"cookieFile,omitempty"`
+}
+
+// configJSON is the file format of a Config
+type configJSON struct {
+	Network string `json:"network,omitempty"`
+	*Config
+}
+
+// DefaultRPCPort returns the default Zebra RPC port of net: 8232 for
+// mainnet, and 18232 for testnet and regtest, like zcashd
+func DefaultRPCPort(net t2z.Network) string {
+	if net == t2z.NetworkMainnet {
+		return "8232"
+	}
+	return "18232"
+}
+
+// rpcConfigFromEnv reads the ZEBRA_* settings with getenv
+func rpcConfigFromEnv(getenv func(string) string) RPCConfig {
+	return RPCConfig{
+		Host:        getenv("ZEBRA_HOST"),
+		Port:        getenv("ZEBRA_PORT"),
+		TLS:         envBool(getenv("ZEBRA_TLS")),
+		TLSInsecure: envBool(getenv("ZEBRA_TLS_INSECURE")),
+		User:        getenv("ZEBRA_RPC_USER"),
+		Password:    getenv("ZEBRA_RPC_PASSWORD"),
+		CookieFile:  getenv("ZEBRA_RPC_COOKIE_FILE"),
+	}
+}
+
+// setDefaults fills in the default host and the default port of net
+func (r *RPCConfig) setDefaults(net t2z.Network) {
+	if r.Host == "" {
+		r.Host = "localhost"
+	}
+	if r.Port == "" {
+		r.Port = DefaultRPCPort(net)
+	}
+}
+
+// URL returns the http:// or https:// URL of the node
+func (r RPCConfig) URL() string {
+	scheme := "http"
+	if r.TLS {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s:%s", scheme, r.Host, r.Port)
+}
+
+// NewClient returns a ZebraClient for the node, with its TLS and
+// authentication settings applied
+func (r RPCConfig) NewClient() *ZebraClient {
+	client := NewZebraClientURL(r.URL())
+	if r.TLSInsecure {
+		client.SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	}
+	if r.User != "" {
+		client.SetBasicAuth(r.User, r.Password)
+	}
+	if r.CookieFile != "" {
+		client.SetCookieFile(r.CookieFile)
+	}
+	return client
+}
+
+// LoadConfig reads a configuration file. Files ending in .json hold a Config
+// as JSON, with "network" set to "mainnet", "testnet" or "regtest"; other
+// files are read as .env files (see ParseEnv) with the keys NETWORK,
+// PRIVATE_KEY, PUBLIC_KEY, ADDRESS, MNEMONIC, ZEBRA_HOST, ZEBRA_PORT,
+// ZEBRA_TLS, ZEBRA_TLS_INSECURE, ZEBRA_RPC_USER, ZEBRA_RPC_PASSWORD and
+// ZEBRA_RPC_COOKIE_FILE.
+//
+// The network defaults to mainnet, the host to localhost, and the port to
+// the DefaultRPCPort of the network.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	network := ""
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		file := configJSON{Config: cfg}
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("parse config %s: %w", path, err)
+		}
+		network = file.Network
+	} else {
+		env := ParseEnv(data)
+		network = env["NETWORK"]
+		cfg.PrivateKey = env["PRIVATE_KEY"]
+		cfg.PublicKey = env["PUBLIC_KEY"]
+		cfg.Address = env["ADDRESS"]
+		cfg.Mnemonic = env["MNEMONIC"]
+		cfg.RPC = rpcConfigFromEnv(func(key string) string { return env[key] })
+	}
+
+	if err := cfg.setDefaults(network); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// LoadDefaultConfig loads DefaultConfigPath, or DefaultEnvPath if it does not
+// exist. If neither exists the error wraps fs.ErrNotExist.
+func LoadDefaultConfig() (*Config, error) {
+	cfg, err := LoadConfig(DefaultConfigPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return LoadConfig(DefaultEnvPath)
+	}
+	return cfg, err
+}
+
+// setDefaults parses the network name and fills in the default endpoint
+func (c *Config) setDefaults(network string) error {
+	switch strings.ToLower(network) {
+	case "", "mainnet":
+		c.Network = t2z.NetworkMainnet
+	case "testnet":
+		c.Network = t2z.NetworkTestnet
+	case "regtest":
+		c.Network = t2z.NetworkRegtest
+	default:
+		return fmt.Errorf("unknown network %q", network)
+	}
+
+	c.RPC.setDefaults(c.Network)
+	if port, err := strconv.ParseUint(c.RPC.Port, 10, 16); err != nil || port == 0 {
+		return fmt.Errorf("invalid RPC port %q", c.RPC.Port)
+	}
+	return nil
+}
+
+// ParseEnv parses a .env file: one KEY=value per line, with lines starting
+// with # ignored, and spaces and surrounding quotes trimmed from keys and
+// values
+func ParseEnv(data []byte) map[string]string {
+	env := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if idx := strings.Index(line, "="); idx > 0 {
+			key := strings.TrimSpace(line[:idx])
+			env[key] = strings.Trim(strings.TrimSpace(line[idx+1:]), "\"'")
+		}
+	}
+	return env
+}
+
+// envBool reports whether an environment value enables a flag
+func envBool(value string) bool {
+	return value == "1" || value == "true"
+}
@@ -0,0 +1,100 @@
+package common
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	t2z "github.com/gstohl/t2z/go"
+)
+
+// writeConfig writes a config file into a temporary directory
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Test reading a .env file as written by generate-wallet
+func TestLoadConfigEnv(t *testing.T) {
+	path := writeConfig(t, ".env", `# Zcash Mainnet Wallet
+# WARNING: Keep this file secret!
+
+MNEMONIC="abandon abandon art"
+
+PRIVATE_KEY=0101010101010101010101010101010101010101010101010101010101010101
+ADDRESS = t1HsdDMzmJfq4vc7T17XYjEkLMLvbgM1fCi
+ZEBRA_RPC_USER='user'
+ZEBRA_TLS=1
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Network != t2z.NetworkMainnet || cfg.Mnemonic != "abandon abandon art" || cfg.Address != "t1HsdDMzmJfq4vc7T17XYjEkLMLvbgM1fCi" || len(cfg.PrivateKey) != 64 {
+		t.Errorf("Unexpected config %+v", cfg)
+	}
+	if cfg.RPC.URL() != "https://localhost:8232" || cfg.RPC.User != "user" {
+		t.Errorf("Unexpected RPC config %+v", cfg.RPC)
+	}
+}
+
+// Test reading a JSON config, and the default port of each network
+func TestLoadConfigJSON(t *testing.T) {
+	tests := []struct {
+		config string
+		net    t2z.Network
+		url    string
+	}{
+		{`{"address":"t1HsdDMzmJfq4vc7T17XYjEkLMLvbgM1fCi"}`, t2z.NetworkMainnet, "http://localhost:8232"},
+		{`{"network":"testnet","rpc":{"host":"node"}}`, t2z.NetworkTestnet, "http://node:18232"},
+		{`{"network":"regtest","rpc":{"port":"28232","cookieFile":"/tmp/cookie"}}`, t2z.NetworkRegtest, "http://localhost:28232"},
+	}
+	for _, tt := range tests {
+		cfg, err := LoadConfig(writeConfig(t, "wallet.json", tt.config))
+		if err != nil {
+			t.Errorf("%s: LoadConfig failed: %v", tt.config, err)
+			continue
+		}
+		if cfg.Network != tt.net || cfg.RPC.URL() != tt.url {
+			t.Errorf("%s: got %s at %s", tt.config, cfg.Network, cfg.RPC.URL())
+		}
+	}
+
+	for _, config := range []string{`{"network":"devnet"}`, `{"rpc":{"port":"http"}}`, `{"rpc":`} {
+		if _, err := LoadConfig(writeConfig(t, "wallet.json", config)); err == nil {
+			t.Errorf("%s: expected an error", config)
+		}
+	}
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+// Test that the client's default port follows its network
+func TestNewZebraClientDefaultPort(t *testing.T) {
+	t.Setenv("ZEBRA_HOST", "")
+	t.Setenv("ZEBRA_PORT", "")
+	t.Setenv("ZEBRA_TLS", "")
+
+	tests := map[t2z.Network]string{
+		t2z.NetworkMainnet: "http://localhost:8232",
+		t2z.NetworkTestnet: "http://localhost:18232",
+		t2z.NetworkRegtest: "http://localhost:18232",
+	}
+	for net, url := range tests {
+		if client := NewZebraClient(net); client.url != url {
+			t.Errorf("%s: expected %s, got %s", net, url, client.url)
+		}
+	}
+
+	t.Setenv("ZEBRA_PORT", "28232")
+	if client := NewZebraClient(t2z.NetworkMainnet); client.url != "http://localhost:28232" {
+		t.Errorf("Expected ZEBRA_PORT to override the default, got %s", client.url)
+	}
+}
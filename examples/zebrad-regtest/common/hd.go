@@ -0,0 +1,125 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	t2z "github.com/gstohl/t2z/go"
+)
+
+// BIP44 coin types (SLIP-44)
+const (
+	ZcashCoinType   uint32 = 133
+	TestnetCoinType uint32 = 1
+)
+
+// HardenedKeyStart is the first hardened child index (BIP32)
+const HardenedKeyStart uint32 = 0x80000000
+
+// ExtendedKey is a BIP32 extended private key
+type ExtendedKey struct {
+	PrivateKey []byte
+	ChainCode  []byte
+}
+
+// NewMasterKey derives the BIP32 master key from a seed of 16 to 64 bytes
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, fmt.Errorf("seed must be 16 to 64 bytes, got %d", len(seed))
+	}
+
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	var k secp256k1.ModNScalar
+	if overflow := k.SetByteSlice(sum[:32]); overflow || k.IsZero() {
+		return nil, errors.New("invalid master key, use another seed")
+	}
+	return &ExtendedKey{PrivateKey: sum[:32], ChainCode: sum[32:]}, nil
+}
+
+// Child derives the child key at index (BIP32 CKDpriv). Indexes from
+// HardenedKeyStart up are hardened.
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	mac := hmac.New(sha512.New, k.ChainCode)
+	if index >= HardenedKeyStart {
+		mac.Write([]byte{0})
+		mac.Write(k.PrivateKey)
+	} else {
+		privKey := secp256k1.PrivKeyFromBytes(k.PrivateKey)
+		mac.Write(privKey.PubKey().SerializeCompressed())
+		privKey.Zero()
+	}
+	mac.Write(binary.BigEndian.AppendUint32(nil, index))
+	sum := mac.Sum(nil)
+
+	var tweak, parent secp256k1.ModNScalar
+	if overflow := tweak.SetByteSlice(sum[:32]); overflow {
+		return nil, fmt.Errorf("invalid child key at index %d, use the next index", index)
+	}
+	parent.SetByteSlice(k.PrivateKey)
+	child := tweak.Add(&parent)
+	if child.IsZero() {
+		return nil, fmt.Errorf("invalid child key at index %d, use the next index", index)
+	}
+
+	childBytes := child.Bytes()
+	return &ExtendedKey{PrivateKey: childBytes[:], ChainCode: sum[32:]}, nil
+}
+
+// DerivePath derives the key at a path of child indexes from the master key
+func (k *ExtendedKey) DerivePath(path ...uint32) (*ExtendedKey, error) {
+	key := k
+	for _, index := range path {
+		child, err := key.Child(index)
+		if err != nil {
+			return nil, err
+		}
+		key = child
+	}
+	return key, nil
+}
+
+// DeriveTransparentKey derives the mainnet keypair at the BIP44 path
+// m/44'/133'/account'/change/index from a seed
+func DeriveTransparentKey(seed []byte, account, change, index uint32) (*ZcashKeypair, error) {
+	return DeriveTransparentKeyForNetwork(seed, t2z.NetworkMainnet, account, change, index)
+}
+
+// DeriveTransparentKeyForNetwork derives the keypair at the BIP44 path
+// m/44'/coin'/account'/change/index, using coin type 133 for mainnet and 1
+// for testnet and regtest. The address and WIF are encoded for network.
+func DeriveTransparentKeyForNetwork(seed []byte, network t2z.Network, account, change, index uint32) (*ZcashKeypair, error) {
+	if account >= HardenedKeyStart || index >= HardenedKeyStart {
+		return nil, errors.New("account and index must be below 2^31")
+	}
+	if change > 1 {
+		return nil, fmt.Errorf("change must be 0 (external) or 1 (internal), got %d", change)
+	}
+
+	coinType := TestnetCoinType
+	if network == t2z.NetworkMainnet {
+		coinType = ZcashCoinType
+	}
+
+	master, err := NewMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	key, err := master.DerivePath(
+		44+HardenedKeyStart,
+		coinType+HardenedKeyStart,
+		account+HardenedKeyStart,
+		change,
+		index,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return KeypairFromPrivateKeyForNetwork(key.PrivateKey, network), nil
+}
@@ -0,0 +1,96 @@
+package common
+
+import (
+	"encoding/hex"
+	"testing"
+
+	t2z "github.com/gstohl/t2z/go"
+)
+
+// Test BIP32 derivation against test vector 1 of BIP32
+func TestBIP32Vector1(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := NewMasterKey(seed)
+	if err != nil {
+		t.Fatalf("NewMasterKey failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		path      []uint32
+		key       string
+		chainCode string
+	}{
+		{
+			"m", nil,
+			"e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35",
+			"873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508",
+		},
+		{
+			"m/0H/1/2H/2/1000000000", []uint32{0 + HardenedKeyStart, 1, 2 + HardenedKeyStart, 2, 1000000000},
+			"471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8",
+			"c783e67b921d2beb8f6b389cc646d7263b4145701dadd2161548a8b078e65e9e",
+		},
+	}
+	for _, tt := range tests {
+		key, err := master.DerivePath(tt.path...)
+		if err != nil {
+			t.Fatalf("%s: DerivePath failed: %v", tt.name, err)
+		}
+		if got := hex.EncodeToString(key.PrivateKey); got != tt.key {
+			t.Errorf("%s: private key = %s, want %s", tt.name, got, tt.key)
+		}
+		if got := hex.EncodeToString(key.ChainCode); got != tt.chainCode {
+			t.Errorf("%s: chain code = %s, want %s", tt.name, got, tt.chainCode)
+		}
+	}
+
+	if _, err := NewMasterKey(seed[:15]); err == nil {
+		t.Error("Expected error for a 15-byte seed, got nil")
+	}
+}
+
+// Test BIP44 transparent key derivation for coin types 133 and 1 from the
+// mnemonic "abandon ... about"
+func TestDeriveTransparentKey(t *testing.T) {
+	seed := SeedFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "")
+
+	tests := []struct {
+		network t2z.Network
+		index   uint32
+		key     string
+		address string
+	}{
+		// m/44'/133'/0'/0/index
+		{t2z.NetworkMainnet, 0, "59eb13b2361c4fee7dcc028444052c64d28f40a89db24ead9e14ab48c260a0d7", "t1XVXWCvpMgBvUaed4XDqWtgQgJSu1Ghz7F"},
+		{t2z.NetworkMainnet, 1, "0a8e17ca4f6f646e4a9b3ba8d05eff7d885fc6139b06e4528387f039356479aa", "t1aQ2b1XszNVo15BguYLbQGqETBL9QZA8Jq"},
+		// m/44'/1'/0'/0/index
+		{t2z.NetworkTestnet, 0, "e01fea8a48e2854fdd0255c12b1d704967d9401f11c3f4980006ced8977574dc", "tmF1xjfhsSzhy55dmhorzTnKjtHhZmPKzts"},
+		{t2z.NetworkRegtest, 1, "c4640899c331482720477a47ccb60d447502fbda8aae1d35a36d1866e04cc4c5", "tmV1zYhR2xisn6VWdCNKHpeD4S7L1U1nPH6"},
+	}
+	for _, tt := range tests {
+		keypair, err := DeriveTransparentKeyForNetwork(seed, tt.network, 0, 0, tt.index)
+		if err != nil {
+			t.Fatalf("%s/%d: derivation failed: %v", tt.network, tt.index, err)
+		}
+		if got := hex.EncodeToString(keypair.PrivateKey); got != tt.key {
+			t.Errorf("%s/%d: private key = %s, want %s", tt.network, tt.index, got, tt.key)
+		}
+		if keypair.Address != tt.address {
+			t.Errorf("%s/%d: address = %s, want %s", tt.network, tt.index, keypair.Address, tt.address)
+		}
+	}
+
+	// DeriveTransparentKey uses the mainnet path
+	keypair, err := DeriveTransparentKey(seed, 0, 0, 0)
+	if err != nil || keypair.Address != tests[0].address {
+		t.Errorf("DeriveTransparentKey = %+v, %v, want address %s", keypair, err, tests[0].address)
+	}
+
+	if _, err := DeriveTransparentKey(seed, HardenedKeyStart, 0, 0); err == nil {
+		t.Error("Expected error for a hardened account, got nil")
+	}
+	if _, err := DeriveTransparentKey(seed, 0, 2, 0); err == nil {
+		t.Error("Expected error for change 2, got nil")
+	}
+}
@@ -1,11 +1,16 @@
 package common
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	t2z "github.com/gstohl/t2z/go"
 	"golang.org/x/crypto/ripemd160"
 )
 
@@ -17,8 +22,11 @@ type ZcashKeypair struct {
 	WIF        string
 }
 
-// Zcash testnet/regtest P2PKH version bytes
-var zcashTestnetP2PKH = []byte{0x1d, 0x25}
+// Zcash P2PKH version bytes
+var (
+	zcashMainnetP2PKH = []byte{0x1c, 0xb8}
+	zcashTestnetP2PKH = []byte{0x1d, 0x25} // also used by regtest
+)
 
 // TEST_KEYPAIR is the pre-generated test keypair matching TypeScript
 // Private key: e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35
@@ -31,13 +39,20 @@ func init() {
 	TEST_KEYPAIR = KeypairFromPrivateKey(privateKeyBytes)
 }
 
-// KeypairFromPrivateKey creates a keypair from a private key
+// KeypairFromPrivateKey creates a testnet/regtest keypair from a private key
 func KeypairFromPrivateKey(privateKey []byte) *ZcashKeypair {
+	return KeypairFromPrivateKeyForNetwork(privateKey, t2z.NetworkTestnet)
+}
+
+// KeypairFromPrivateKeyForNetwork creates a keypair whose address and WIF
+// are encoded for network
+func KeypairFromPrivateKeyForNetwork(privateKey []byte, network t2z.Network) *ZcashKeypair {
 	privKey := secp256k1.PrivKeyFromBytes(privateKey)
+	defer privKey.Zero()
 	pubKey := privKey.PubKey().SerializeCompressed()
 
-	address := PubkeyToAddress(pubKey)
-	wif := PrivateKeyToWIF(privateKey)
+	address := PubkeyToAddressForNetwork(pubKey, network)
+	wif := PrivateKeyToWIFForNetwork(privateKey, network)
 
 	return &ZcashKeypair{
 		PrivateKey: privateKey,
@@ -47,6 +62,17 @@ func KeypairFromPrivateKey(privateKey []byte) *ZcashKeypair {
 	}
 }
 
+// Zeroize overwrites the private key bytes with zeros.
+//
+// Callers handling real funds should defer this right after creating the
+// keypair so the key does not stay in memory longer than necessary. The WIF
+// string is dropped as well, although Go strings cannot be wiped in place.
+// The keypair cannot sign after being zeroized.
+func (k *ZcashKeypair) Zeroize() {
+	clear(k.PrivateKey)
+	k.WIF = ""
+}
+
 // Hash160 computes RIPEMD160(SHA256(data))
 func Hash160(data []byte) []byte {
 	sha256Hash := sha256.Sum256(data)
@@ -64,19 +90,47 @@ func DoubleSHA256(data []byte) []byte {
 
 // PubkeyToAddress converts a public key to a Zcash testnet address
 func PubkeyToAddress(pubkey []byte) string {
+	return PubkeyToTestnetAddress(pubkey)
+}
+
+// PubkeyToAddressForNetwork converts a public key to a P2PKH address for
+// network. Regtest shares the testnet encoding.
+func PubkeyToAddressForNetwork(pubkey []byte, network t2z.Network) string {
 	hash := Hash160(pubkey)
 
 	// Zcash uses 2-byte version prefix
-	payload := append(zcashTestnetP2PKH, hash...)
+	prefix := zcashTestnetP2PKH
+	if network == t2z.NetworkMainnet {
+		prefix = zcashMainnetP2PKH
+	}
+	payload := append(append([]byte(nil), prefix...), hash...)
 
 	// Base58check encode
 	return Base58CheckEncode(payload)
 }
 
-// PrivateKeyToWIF converts a private key to WIF format
+// PubkeyToMainnetAddress converts a public key to a Zcash mainnet (t1) address
+func PubkeyToMainnetAddress(pubkey []byte) string {
+	return PubkeyToAddressForNetwork(pubkey, t2z.NetworkMainnet)
+}
+
+// PubkeyToTestnetAddress converts a public key to a Zcash testnet or regtest
+// (tm) address
+func PubkeyToTestnetAddress(pubkey []byte) string {
+	return PubkeyToAddressForNetwork(pubkey, t2z.NetworkTestnet)
+}
+
+// PrivateKeyToWIF converts a private key to testnet WIF format
 func PrivateKeyToWIF(privateKey []byte) string {
-	// Testnet WIF version byte
-	version := byte(0xef)
+	return PrivateKeyToWIFForNetwork(privateKey, t2z.NetworkTestnet)
+}
+
+// PrivateKeyToWIFForNetwork converts a private key to WIF format for network
+func PrivateKeyToWIFForNetwork(privateKey []byte, network t2z.Network) string {
+	version := byte(wifTestnet)
+	if network == t2z.NetworkMainnet {
+		version = wifMainnet
+	}
 
 	// Add version byte and compression flag
 	payload := make([]byte, 0, 34)
@@ -87,22 +141,91 @@ func PrivateKeyToWIF(privateKey []byte) string {
 	return Base58CheckEncode(payload)
 }
 
-// CreateP2PKHScript creates a P2PKH script for the given public key
+// WIF version bytes
+const (
+	wifMainnet = 0x80
+	wifTestnet = 0xef
+)
+
+// ParseWIF decodes a WIF private key, verifying its checksum.
+//
+// Returns the 32-byte private key and its network. Regtest keys use the
+// testnet version byte and are reported as t2z.NetworkTestnet.
+//
+// Keys marked for an uncompressed public key are rejected: the examples
+// always derive the compressed public key and its address, so such a key
+// would silently import as a different address.
+func ParseWIF(wif string) (privKey []byte, network t2z.Network, err error) {
+	payload, err := Base58CheckDecode(strings.TrimSpace(wif))
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid WIF: %w", err)
+	}
+	defer clear(payload)
+
+	switch len(payload) {
+	case 33:
+		return nil, 0, errors.New("invalid WIF: uncompressed keys are not supported")
+	case 34:
+		if payload[33] != 0x01 {
+			return nil, 0, fmt.Errorf("invalid WIF: unknown compression flag 0x%02x", payload[33])
+		}
+	default:
+		return nil, 0, fmt.Errorf("invalid WIF: expected 33 or 34 byte payload, got %d", len(payload))
+	}
+
+	switch payload[0] {
+	case wifMainnet:
+		network = t2z.NetworkMainnet
+	case wifTestnet:
+		network = t2z.NetworkTestnet
+	default:
+		return nil, 0, fmt.Errorf("invalid WIF: unknown version byte 0x%02x", payload[0])
+	}
+
+	return append([]byte(nil), payload[1:33]...), network, nil
+}
+
+// ParseWIFForNetwork decodes a WIF private key like ParseWIF and rejects keys
+// for a different network. Regtest expects testnet keys.
+func ParseWIFForNetwork(wif string, network t2z.Network) ([]byte, error) {
+	privKey, keyNetwork, err := ParseWIF(wif)
+	if err != nil {
+		return nil, err
+	}
+	if network == t2z.NetworkRegtest {
+		network = t2z.NetworkTestnet
+	}
+	if keyNetwork != network {
+		clear(privKey)
+		return nil, fmt.Errorf("WIF is for %s but %s was expected", keyNetwork, network)
+	}
+	return privKey, nil
+}
+
+// ParsePrivateKey decodes a private key given either as 64 hex characters or
+// as a WIF for network
+func ParsePrivateKey(s string, network t2z.Network) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 64 {
+		if key, err := hex.DecodeString(s); err == nil {
+			return key, nil
+		}
+	}
+	return ParseWIFForNetwork(s, network)
+}
+
+// CreateP2PKHScript creates a P2PKH script for the given public key. Unlike
+// t2z.P2PKHScript it does not check the key, for the examples' fixed keys.
 func CreateP2PKHScript(pubkey []byte) []byte {
-	pubkeyHash := Hash160(pubkey)
-	script := make([]byte, 25)
-	script[0] = 0x76 // OP_DUP
-	script[1] = 0xa9 // OP_HASH160
-	script[2] = 0x14 // PUSH 20 bytes
-	copy(script[3:23], pubkeyHash)
-	script[23] = 0x88 // OP_EQUALVERIFY
-	script[24] = 0xac // OP_CHECKSIG
+	script, _ := t2z.P2PKHScriptFromHash(Hash160(pubkey))
 	return script
 }
 
-// SignCompact signs a message hash and returns a 64-byte compact signature
+// SignCompact signs a message hash and returns a 64-byte compact signature.
+// ecdsa.SignCompact always produces low-S signatures, as Zcash requires.
 func SignCompact(messageHash []byte, keypair *ZcashKeypair) [64]byte {
 	privKey := secp256k1.PrivKeyFromBytes(keypair.PrivateKey)
+	defer privKey.Zero()
 
 	var hash [32]byte
 	copy(hash[:], messageHash)
@@ -114,6 +237,15 @@ func SignCompact(messageHash []byte, keypair *ZcashKeypair) [64]byte {
 	return sigBytes
 }
 
+// Sign implements t2z.Signer, so a keypair can be passed to
+// t2z.SignAllInputs. It refuses to sign for any pubkey other than its own.
+func (k *ZcashKeypair) Sign(sighash [32]byte, pubkey []byte) ([64]byte, error) {
+	if !bytes.Equal(pubkey, k.PublicKey) {
+		return [64]byte{}, fmt.Errorf("keypair %s cannot sign for pubkey %x", k.Address, pubkey)
+	}
+	return SignCompact(sighash[:], k), nil
+}
+
 // Base58 alphabet
 const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
 
@@ -124,6 +256,57 @@ func Base58CheckEncode(payload []byte) string {
 	return Base58Encode(data)
 }
 
+// Base58CheckDecode decodes a Base58Check string and verifies its checksum,
+// returning the payload
+func Base58CheckDecode(s string) ([]byte, error) {
+	data, err := Base58Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 4 {
+		return nil, errors.New("too short")
+	}
+	payload, checksum := data[:len(data)-4], data[len(data)-4:]
+	if !bytes.Equal(DoubleSHA256(payload)[:4], checksum) {
+		return nil, errors.New("checksum mismatch")
+	}
+	return payload, nil
+}
+
+// Base58Decode decodes a base58 string to bytes
+func Base58Decode(s string) ([]byte, error) {
+	// Count leading '1's, which encode zero bytes
+	leadingZeros := 0
+	for leadingZeros < len(s) && s[leadingZeros] == '1' {
+		leadingZeros++
+	}
+
+	size := len(s)*733/1000 + 1
+	output := make([]byte, size)
+
+	for i := 0; i < len(s); i++ {
+		carry := strings.IndexByte(base58Alphabet, s[i])
+		if carry < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", s[i])
+		}
+		for j := size - 1; j >= 0; j-- {
+			carry += 58 * int(output[j])
+			output[j] = byte(carry % 256)
+			carry /= 256
+		}
+	}
+
+	// Skip leading zeros in output
+	startIdx := 0
+	for startIdx < len(output) && output[startIdx] == 0 {
+		startIdx++
+	}
+
+	result := make([]byte, leadingZeros+len(output)-startIdx)
+	copy(result[leadingZeros:], output[startIdx:])
+	return result, nil
+}
+
 // Base58Encode encodes bytes to base58
 func Base58Encode(input []byte) string {
 	if len(input) == 0 {
@@ -0,0 +1,82 @@
+package common
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	t2z "github.com/gstohl/t2z/go"
+)
+
+// Test that WIF keys round-trip on each network
+func TestWIFRoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{0x01}, 32)
+
+	tests := []struct {
+		network t2z.Network
+		wif     string
+		parsed  t2z.Network
+	}{
+		{t2z.NetworkMainnet, "KwFfNUhSDaASSAwtG7ssQM1uVX8RgX5GHWnnLfhfiQDigjioWXHH", t2z.NetworkMainnet},
+		{t2z.NetworkTestnet, "cMceqPhHedrhbcR9eXgzmfWy7kRqLyAxMYwFT6ABDWsiwUp9Nsq9", t2z.NetworkTestnet},
+		{t2z.NetworkRegtest, "cMceqPhHedrhbcR9eXgzmfWy7kRqLyAxMYwFT6ABDWsiwUp9Nsq9", t2z.NetworkTestnet},
+	}
+	for _, tt := range tests {
+		t.Run(tt.network.String(), func(t *testing.T) {
+			wif := PrivateKeyToWIFForNetwork(key, tt.network)
+			if wif != tt.wif {
+				t.Errorf("PrivateKeyToWIFForNetwork = %s, want %s", wif, tt.wif)
+			}
+
+			parsed, network, err := ParseWIF(wif)
+			if err != nil {
+				t.Fatalf("ParseWIF failed: %v", err)
+			}
+			if !bytes.Equal(parsed, key) || network != tt.parsed {
+				t.Errorf("ParseWIF = %x, %s, want %x, %s", parsed, network, key, tt.parsed)
+			}
+
+			parsed, err = ParseWIFForNetwork(wif, tt.network)
+			if err != nil || !bytes.Equal(parsed, key) {
+				t.Errorf("ParseWIFForNetwork = %x, %v", parsed, err)
+			}
+		})
+	}
+}
+
+// Test that malformed and unsupported WIF keys are rejected
+func TestParseWIFErrors(t *testing.T) {
+	key := bytes.Repeat([]byte{0x01}, 32)
+	mainnet := PrivateKeyToWIFForNetwork(key, t2z.NetworkMainnet)
+
+	// Change the last character, which only affects the checksum
+	badChecksum := mainnet[:len(mainnet)-1] + "J"
+
+	tests := []struct {
+		name string
+		wif  string
+		want string
+	}{
+		{"bad checksum", badChecksum, "checksum mismatch"},
+		{"wrong version byte", Base58CheckEncode(append(append([]byte{0x81}, key...), 0x01)), "unknown version byte 0x81"},
+		{"address version", Base58CheckEncode(append([]byte{0x1c, 0xb8}, key[:20]...)), "expected 33 or 34 byte payload"},
+		{"uncompressed", Base58CheckEncode(append([]byte{wifMainnet}, key...)), "uncompressed"},
+		{"bad compression flag", Base58CheckEncode(append(append([]byte{wifMainnet}, key...), 0x02)), "compression flag"},
+		{"not base58", "0OIl", "invalid WIF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ParseWIF(tt.wif)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseWIF(%q) = %v, want an error containing %q", tt.wif, err, tt.want)
+			}
+		})
+	}
+
+	// A mainnet key is not accepted for testnet or regtest
+	for _, network := range []t2z.Network{t2z.NetworkTestnet, t2z.NetworkRegtest} {
+		if _, err := ParseWIFForNetwork(mainnet, network); err == nil {
+			t.Errorf("ParseWIFForNetwork(mainnet key, %s): expected error, got nil", network)
+		}
+	}
+}
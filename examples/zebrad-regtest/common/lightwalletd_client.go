@@ -0,0 +1,261 @@
+package common
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// compactTxStreamer is the lightwalletd gRPC service (service.proto)
+const compactTxStreamer = "/cash.z.wallet.sdk.rpc.CompactTxStreamer/"
+
+// LightwalletdClient is a gRPC client for lightwalletd.
+//
+// Only the CompactTxStreamer calls needed by ChainClient are used, so
+// the request and reply messages are encoded by hand with protowire instead
+// of depending on generated walletrpc code.
+type LightwalletdClient struct {
+	target  string
+	conn    *grpc.ClientConn
+	timeout time.Duration
+}
+
+// NewLightwalletdClient connects to lightwalletd at LIGHTWALLETD_HOST and
+// LIGHTWALLETD_PORT (default localhost:9067). Set LIGHTWALLETD_TLS=1 for
+// endpoints served over TLS.
+func NewLightwalletdClient() (*LightwalletdClient, error) {
+	host := os.Getenv("LIGHTWALLETD_HOST")
+	if host == "" {
+		host = "localhost"
+	}
+	port := os.Getenv("LIGHTWALLETD_PORT")
+	if port == "" {
+		port = "9067"
+	}
+	target := fmt.Sprintf("%s:%s", host, port)
+
+	creds := insecure.NewCredentials()
+	if tlsEnv := os.Getenv("LIGHTWALLETD_TLS"); tlsEnv == "1" || tlsEnv == "true" {
+		creds = credentials.NewTLS(&tls.Config{ServerName: host})
+	}
+
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("dial lightwalletd: %w", err)
+	}
+
+	return &LightwalletdClient{
+		target:  target,
+		conn:    conn,
+		timeout: 30 * time.Second,
+	}, nil
+}
+
+// Close closes the underlying gRPC connection
+func (c *LightwalletdClient) Close() error {
+	return c.conn.Close()
+}
+
+// rawCodec passes pre-encoded protobuf messages through gRPC unchanged
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) {
+	return *v.(*[]byte), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	*v.(*[]byte) = append([]byte(nil), data...)
+	return nil
+}
+
+func (rawCodec) Name() string { return "proto" }
+
+// invoke makes a unary CompactTxStreamer call with an encoded request
+func (c *LightwalletdClient) invoke(method string, req []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	var reply []byte
+	err := c.conn.Invoke(ctx, compactTxStreamer+method, &req, &reply, grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		return nil, fmt.Errorf("lightwalletd %s at %s: %w", method, c.target, err)
+	}
+	return reply, nil
+}
+
+// protoFields calls fn for every field in an encoded message. Varint fields
+// are passed in v, length-delimited fields in b; other types are skipped.
+func protoFields(msg []byte, fn func(num protowire.Number, v uint64, b []byte)) error {
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(msg)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			fn(num, v, nil)
+			msg = msg[n:]
+		case protowire.BytesType:
+			b, n := protowire.ConsumeBytes(msg)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			fn(num, 0, b)
+			msg = msg[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, msg)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			msg = msg[n:]
+		}
+	}
+	return nil
+}
+
+// GetBlockchainInfo returns the chain name and tip height from GetLightdInfo
+func (c *LightwalletdClient) GetBlockchainInfo() (*BlockchainInfo, error) {
+	reply, err := c.invoke("GetLightdInfo", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var info BlockchainInfo
+	err = protoFields(reply, func(num protowire.Number, v uint64, b []byte) {
+		switch num {
+		case 4: // chainName
+			info.Chain = string(b)
+		case 7: // blockHeight
+			info.Blocks = int(v)
+			info.Headers = int(v)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decode LightdInfo: %w", err)
+	}
+	return &info, nil
+}
+
+// GetBlockCount returns the height of the chain tip from GetLatestBlock
+func (c *LightwalletdClient) GetBlockCount() (int, error) {
+	// ChainSpec{} is empty
+	reply, err := c.invoke("GetLatestBlock", nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var height int
+	err = protoFields(reply, func(num protowire.Number, v uint64, _ []byte) {
+		if num == 1 { // BlockID.height
+			height = int(v)
+		}
+	})
+	if err != nil {
+		return 0, fmt.Errorf("decode BlockID: %w", err)
+	}
+	return height, nil
+}
+
+// GetAddressUtxos returns the unspent outputs for transparent addresses
+func (c *LightwalletdClient) GetAddressUtxos(addresses []string) ([]AddressUtxo, error) {
+	// GetAddressUtxosArg{addresses: addresses, startHeight: 0, maxEntries: 0}
+	var req []byte
+	for _, address := range addresses {
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendString(req, address)
+	}
+
+	reply, err := c.invoke("GetAddressUtxos", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var utxos []AddressUtxo
+	var decodeErr error
+	err = protoFields(reply, func(num protowire.Number, _ uint64, b []byte) {
+		if num != 1 || decodeErr != nil { // addressUtxos
+			return
+		}
+
+		var utxo AddressUtxo
+		decodeErr = protoFields(b, func(num protowire.Number, v uint64, b []byte) {
+			switch num {
+			case 1: // txid, already in internal byte order
+				copy(utxo.TxID[:], b)
+			case 2: // index
+				utxo.OutputIndex = uint32(v)
+			case 3: // script
+				utxo.Script = append([]byte(nil), b...)
+			case 4: // valueZat
+				utxo.Value = v
+			case 5: // height
+				utxo.Height = int(v)
+			case 6: // address
+				utxo.Address = string(b)
+			}
+		})
+		utxos = append(utxos, utxo)
+	})
+	if err == nil {
+		err = decodeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode GetAddressUtxosReplyList: %w", err)
+	}
+	return utxos, nil
+}
+
+// SendTransaction broadcasts a raw transaction and returns its txid
+func (c *LightwalletdClient) SendTransaction(txHex string) (string, error) {
+	txBytes, err := HexToBytes(txHex)
+	if err != nil {
+		return "", fmt.Errorf("invalid transaction hex: %w", err)
+	}
+
+	// RawTransaction{data: txBytes}
+	var req []byte
+	req = protowire.AppendTag(req, 1, protowire.BytesType)
+	req = protowire.AppendBytes(req, txBytes)
+
+	reply, err := c.invoke("SendTransaction", req)
+	if err != nil {
+		return "", err
+	}
+
+	// SendResponse carries the txid in errorMessage when errorCode is 0
+	var code int32
+	var message string
+	err = protoFields(reply, func(num protowire.Number, v uint64, b []byte) {
+		switch num {
+		case 1: // errorCode
+			code = int32(v)
+		case 2: // errorMessage
+			message = string(b)
+		}
+	})
+	if err != nil {
+		return "", fmt.Errorf("decode SendResponse: %w", err)
+	}
+	if code != 0 {
+		return "", fmt.Errorf("broadcast rejected (code %d): %s", code, message)
+	}
+	return message, nil
+}
+
+// Broadcast broadcasts a raw transaction, implementing t2z.Broadcaster
+func (c *LightwalletdClient) Broadcast(txHex string) (string, error) {
+	return c.SendTransaction(txHex)
+}
@@ -0,0 +1,120 @@
+package common
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+// bip39English is the BIP39 English wordlist
+// (sha256 2f5eed53a4727b4bf8880d8f3f199efc90e58503646d9ff8eff3a2ed3b24dbda)
+//
+//go:embed bip39_english.txt
+var bip39English string
+
+var (
+	bip39Words   = strings.Fields(bip39English)
+	bip39Indexes = func() map[string]int {
+		indexes := make(map[string]int, len(bip39Words))
+		for i, word := range bip39Words {
+			indexes[word] = i
+		}
+		return indexes
+	}()
+)
+
+// GenerateMnemonic returns a new BIP39 mnemonic with bits of entropy: 128
+// (12 words) to 256 (24 words) in steps of 32
+func GenerateMnemonic(bits int) (string, error) {
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", fmt.Errorf("entropy must be 128 to 256 bits in steps of 32, got %d", bits)
+	}
+
+	entropy := make([]byte, bits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", fmt.Errorf("generate entropy: %w", err)
+	}
+	defer clear(entropy)
+
+	return MnemonicFromEntropy(entropy)
+}
+
+// MnemonicFromEntropy encodes 16 to 32 bytes of entropy as a BIP39 mnemonic
+func MnemonicFromEntropy(entropy []byte) (string, error) {
+	if len(entropy) < 16 || len(entropy) > 32 || len(entropy)%4 != 0 {
+		return "", fmt.Errorf("entropy must be 16 to 32 bytes in steps of 4, got %d", len(entropy))
+	}
+
+	// Entropy followed by the first len/32 bits of its SHA-256, split into
+	// 11-bit word indexes
+	checksumBits := len(entropy) / 4
+	hash := sha256.Sum256(entropy)
+	n := new(big.Int).SetBytes(entropy)
+	n.Lsh(n, uint(checksumBits))
+	n.Or(n, big.NewInt(int64(hash[0]>>(8-checksumBits))))
+
+	numWords := (len(entropy)*8 + checksumBits) / 11
+	words := make([]string, numWords)
+	mask := big.NewInt(2047)
+	for i := numWords - 1; i >= 0; i-- {
+		words[i] = bip39Words[new(big.Int).And(n, mask).Int64()]
+		n.Rsh(n, 11)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// ValidateMnemonic checks the word count, the words and the checksum of a
+// BIP39 mnemonic
+func ValidateMnemonic(mnemonic string) error {
+	words := mnemonicWords(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return fmt.Errorf("mnemonic must have 12, 15, 18, 21 or 24 words, got %d", len(words))
+	}
+
+	n := new(big.Int)
+	for i, word := range words {
+		index, ok := bip39Indexes[word]
+		if !ok {
+			return fmt.Errorf("word %d (%q) is not in the BIP39 English wordlist", i+1, word)
+		}
+		n.Lsh(n, 11)
+		n.Or(n, big.NewInt(int64(index)))
+	}
+
+	checksumBits := len(words) / 3
+	checksum := new(big.Int).And(n, big.NewInt(1<<checksumBits-1)).Int64()
+	n.Rsh(n, uint(checksumBits))
+
+	entropy := n.FillBytes(make([]byte, checksumBits*4))
+	defer clear(entropy)
+	hash := sha256.Sum256(entropy)
+	if int64(hash[0]>>(8-checksumBits)) != checksum {
+		return fmt.Errorf("invalid mnemonic checksum")
+	}
+	return nil
+}
+
+// SeedFromMnemonic derives the 64-byte BIP39 seed for BIP32 derivation (see
+// DeriveTransparentKey). Any passphrase is valid and yields a different
+// seed. The mnemonic is not validated; call ValidateMnemonic when importing.
+// It is normalized like by ValidateMnemonic, so a phrase typed in another
+// case derives the same seed.
+func SeedFromMnemonic(mnemonic, passphrase string) []byte {
+	normalized := strings.Join(mnemonicWords(mnemonic), " ")
+	salt := "mnemonic" + norm.NFKD.String(passphrase)
+	return pbkdf2.Key([]byte(normalized), []byte(salt), 2048, 64, sha512.New)
+}
+
+// mnemonicWords returns the NFKD-normalized, lowercase words of a mnemonic.
+// The wordlist is lowercase, so this is the phrase both ValidateMnemonic and
+// SeedFromMnemonic work on.
+func mnemonicWords(mnemonic string) []string {
+	return strings.Fields(strings.ToLower(norm.NFKD.String(mnemonic)))
+}
@@ -0,0 +1,119 @@
+package common
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// Test BIP39 encoding and seed derivation against the TREZOR test vectors
+func TestMnemonicVectors(t *testing.T) {
+	tests := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+	}{
+		{
+			"00000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			"legal winner thank year wave sausage worth useful legal winner thank yellow",
+			"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+		{
+			"80808080808080808080808080808080",
+			"letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+			"d71de856f81a8acc65e6fc851a38d4d7ec216fd0796d0a6827a3ad6ed5511a30fa280f12eb2e47ed2ac03b5c462a0358d18d69fe4f985ec81778c1b370b652a8",
+		},
+		{
+			"ffffffffffffffffffffffffffffffff",
+			"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+			"ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+		},
+		{
+			"9e885d952ad362caeb4efe34a8e91bd2",
+			"ozone drill grab fiber curtain grace pudding thank cruise elder eight picnic",
+			"274ddc525802f7c828d8ef7ddbcdc5304e87ac3535913611fbbfa986d0c9e5476c91689f9c8a54fd55bd38606aa6a8595ad213d4c9c9f9aca3fb217069a41028",
+		},
+		{
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+			"bda85446c68413707090a52022edd26a1c9462295029f2e60cd7c4f2bbd3097170af7a4d73245cafa9c3cca8d561a7c3de6f5d4a10be8ed2a5e608d68f92fcc8",
+		},
+	}
+
+	for _, tt := range tests {
+		entropy, _ := hex.DecodeString(tt.entropy)
+		mnemonic, err := MnemonicFromEntropy(entropy)
+		if err != nil {
+			t.Fatalf("MnemonicFromEntropy(%s) failed: %v", tt.entropy, err)
+		}
+		if mnemonic != tt.mnemonic {
+			t.Errorf("MnemonicFromEntropy(%s) = %q, want %q", tt.entropy, mnemonic, tt.mnemonic)
+		}
+		if err := ValidateMnemonic(tt.mnemonic); err != nil {
+			t.Errorf("ValidateMnemonic(%q) failed: %v", tt.mnemonic, err)
+		}
+		if seed := hex.EncodeToString(SeedFromMnemonic(tt.mnemonic, "TREZOR")); seed != tt.seed {
+			t.Errorf("SeedFromMnemonic(%q) = %s, want %s", tt.mnemonic, seed, tt.seed)
+		}
+	}
+}
+
+// Test that a phrase in another case or with extra spaces validates and
+// derives the same seed as the canonical one
+func TestMnemonicNormalization(t *testing.T) {
+	canonical := "legal winner thank year wave sausage worth useful legal winner thank yellow"
+	typed := "  Legal WINNER thank year wave sausage worth useful legal winner thank Yellow\n"
+
+	if err := ValidateMnemonic(typed); err != nil {
+		t.Fatalf("ValidateMnemonic(%q) failed: %v", typed, err)
+	}
+	if got, want := hex.EncodeToString(SeedFromMnemonic(typed, "TREZOR")), hex.EncodeToString(SeedFromMnemonic(canonical, "TREZOR")); got != want {
+		t.Errorf("Seed of %q = %s, want %s", typed, got, want)
+	}
+}
+
+// Test that invalid mnemonics and entropy are rejected
+func TestMnemonicErrors(t *testing.T) {
+	abandon := func(n int) string {
+		return strings.TrimSpace(strings.Repeat("abandon ", n))
+	}
+
+	tests := []struct {
+		name     string
+		mnemonic string
+		want     string
+	}{
+		{"bad checksum", abandon(12), "checksum"},
+		{"bad checksum 24 words", abandon(24), "checksum"},
+		{"swapped words", "about abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon", "checksum"},
+		{"11 words", abandon(10) + " about", "12, 15, 18, 21 or 24 words"},
+		{"13 words", abandon(12) + " about", "12, 15, 18, 21 or 24 words"},
+		{"27 words", abandon(26) + " about", "12, 15, 18, 21 or 24 words"},
+		{"empty", "", "12, 15, 18, 21 or 24 words"},
+		{"unknown word", abandon(11) + " zcash", "not in the BIP39 English wordlist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateMnemonic(tt.mnemonic)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ValidateMnemonic(%q) = %v, want an error containing %q", tt.mnemonic, err, tt.want)
+			}
+		})
+	}
+
+	for _, n := range []int{0, 12, 15, 17, 33} {
+		if _, err := MnemonicFromEntropy(make([]byte, n)); err == nil {
+			t.Errorf("MnemonicFromEntropy with %d bytes: expected error, got nil", n)
+		}
+	}
+	for _, bits := range []int{96, 100, 255, 288} {
+		if _, err := GenerateMnemonic(bits); err == nil {
+			t.Errorf("GenerateMnemonic(%d): expected error, got nil", bits)
+		}
+	}
+}
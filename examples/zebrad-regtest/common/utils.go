@@ -1,7 +1,6 @@
 package common
 
 import (
-	"bytes"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -62,12 +61,20 @@ func GetDataDir() string {
 	return dataDir
 }
 
+// spentUtxosFile is the name of the spent UTXO tracker in the data directory
+const spentUtxosFile = "spent-utxos.json"
+
 // LoadSpentUtxos loads the set of spent UTXOs from file
 func LoadSpentUtxos() map[string]bool {
+	return loadSpentFile(filepath.Join(dataDir, spentUtxosFile))
+}
+
+// loadSpentFile reads a spent UTXO tracker: a JSON array of outpoint keys
+// (see outpointKey). A missing or unreadable file is an empty set.
+func loadSpentFile(path string) map[string]bool {
 	spent := make(map[string]bool)
 
-	filePath := filepath.Join(dataDir, "spent-utxos.json")
-	data, err := os.ReadFile(filePath)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return spent
 	}
@@ -85,7 +92,12 @@ func LoadSpentUtxos() map[string]bool {
 
 // SaveSpentUtxos saves the set of spent UTXOs to file
 func SaveSpentUtxos(spent map[string]bool) error {
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
+	return saveSpentFile(filepath.Join(dataDir, spentUtxosFile), spent)
+}
+
+// saveSpentFile writes a spent UTXO tracker, creating its directory
+func saveSpentFile(path string, spent map[string]bool) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return err
 	}
 
@@ -99,15 +111,20 @@ func SaveSpentUtxos(spent map[string]bool) error {
 		return err
 	}
 
-	return os.WriteFile(filepath.Join(dataDir, "spent-utxos.json"), data, 0644)
+	return os.WriteFile(path, data, 0644)
+}
+
+// outpointKey is the key of an outpoint in the spent UTXO tracker: the txid
+// in internal byte order as hex, a colon, and the output index
+func outpointKey(txid [32]byte, vout uint32) string {
+	return fmt.Sprintf("%s:%d", BytesToHex(txid[:]), vout)
 }
 
 // MarkUtxosSpent marks UTXOs as spent
 func MarkUtxosSpent(inputs []t2z.TransparentInput) error {
 	spent := LoadSpentUtxos()
 	for _, input := range inputs {
-		key := fmt.Sprintf("%s:%d", BytesToHex(input.TxID[:]), input.Vout)
-		spent[key] = true
+		spent[outpointKey(input.TxID, input.Vout)] = true
 	}
 	return SaveSpentUtxos(spent)
 }
@@ -119,7 +136,13 @@ func ClearSpentUtxos() error {
 
 // ZatoshiToZec converts zatoshis to ZEC string
 func ZatoshiToZec(zatoshi uint64) string {
-	return fmt.Sprintf("%.8f", float64(zatoshi)/100_000_000)
+	return t2z.FormatZec(zatoshi)
+}
+
+// ZecToZatoshi parses a ZEC amount such as "0.5" into zatoshis without
+// floating point, see t2z.ParseZec. It is the inverse of ZatoshiToZec.
+func ZecToZatoshi(zec string) (uint64, error) {
+	return t2z.ParseZec(zec)
 }
 
 // TxOutput represents a parsed transaction output
@@ -135,58 +158,46 @@ func ParseTxOutputs(txHex string) ([]TxOutput, error) {
 		return nil, err
 	}
 
-	offset := 0
-
-	// Skip header (4 bytes version + 4 bytes version group id)
-	offset += 8
-
-	// Read vin count (varint - simplified, assuming single byte)
-	if offset >= len(tx) {
-		return nil, fmt.Errorf("tx too short for vin count")
+	offset, err := skipTxHeader(tx)
+	if err != nil {
+		return nil, err
 	}
-	vinCount := int(tx[offset])
-	offset++
 
-	// Skip all inputs
-	for i := 0; i < vinCount; i++ {
-		offset += 32 // prev txid
-		offset += 4  // prev vout
-		if offset >= len(tx) {
-			return nil, fmt.Errorf("tx too short for script length")
-		}
-		scriptLen := int(tx[offset])
-		offset += 1 + scriptLen // script length + script
-		offset += 4             // sequence
+	_, offset, err = parseTxInputs(tx, offset)
+	if err != nil {
+		return nil, err
 	}
 
-	// Read vout count
-	if offset >= len(tx) {
-		return nil, fmt.Errorf("tx too short for vout count")
+	voutCount, offset, err := readCompactSize(tx, offset, "vout count")
+	if err != nil {
+		return nil, err
+	}
+	// Each output takes at least 9 bytes
+	if voutCount > uint64(len(tx)-offset)/9 {
+		return nil, fmt.Errorf("tx too short for %d outputs", voutCount)
 	}
-	voutCount := int(tx[offset])
-	offset++
 
 	outputs := make([]TxOutput, 0, voutCount)
 
-	for i := 0; i < voutCount; i++ {
+	for i := uint64(0); i < voutCount; i++ {
 		if offset+8 > len(tx) {
 			return nil, fmt.Errorf("tx too short for value")
 		}
 		value := binary.LittleEndian.Uint64(tx[offset : offset+8])
 		offset += 8
 
-		if offset >= len(tx) {
-			return nil, fmt.Errorf("tx too short for script pubkey length")
+		scriptLen, next, err := readCompactSize(tx, offset, "script pubkey length")
+		if err != nil {
+			return nil, err
 		}
-		scriptLen := int(tx[offset])
-		offset++
+		offset = next
 
-		if offset+scriptLen > len(tx) {
+		if scriptLen > uint64(len(tx)-offset) {
 			return nil, fmt.Errorf("tx too short for script pubkey")
 		}
 		scriptPubKey := make([]byte, scriptLen)
-		copy(scriptPubKey, tx[offset:offset+scriptLen])
-		offset += scriptLen
+		copy(scriptPubKey, tx[offset:offset+int(scriptLen)])
+		offset += int(scriptLen)
 
 		outputs = append(outputs, TxOutput{
 			Value:        value,
@@ -197,12 +208,146 @@ func ParseTxOutputs(txHex string) ([]TxOutput, error) {
 	return outputs, nil
 }
 
+// TxInput represents a parsed transaction input
+type TxInput struct {
+	TxID      [32]byte // internal byte order, as used by t2z.TransparentInput
+	Vout      uint32
+	ScriptSig []byte
+	Sequence  uint32
+}
+
+// ParseTxInputs parses transaction inputs from raw tx hex
+//
+// Use it to check that a finalized transaction spends exactly the UTXOs that
+// were intended before broadcasting it.
+func ParseTxInputs(txHex string) ([]TxInput, error) {
+	tx, err := HexToBytes(txHex)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err := skipTxHeader(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs, _, err := parseTxInputs(tx, offset)
+	return inputs, err
+}
+
+// parseTxInputs parses the inputs starting at the vin count, returning them
+// and the offset of the vout count
+func parseTxInputs(tx []byte, offset int) ([]TxInput, int, error) {
+	vinCount, offset, err := readCompactSize(tx, offset, "vin count")
+	if err != nil {
+		return nil, 0, err
+	}
+	// Each input takes at least 41 bytes
+	if vinCount > uint64(len(tx)-offset)/41 {
+		return nil, 0, fmt.Errorf("tx too short for %d inputs", vinCount)
+	}
+
+	inputs := make([]TxInput, 0, vinCount)
+
+	for i := uint64(0); i < vinCount; i++ {
+		if offset+36 > len(tx) {
+			return nil, 0, fmt.Errorf("tx too short for prevout")
+		}
+		var input TxInput
+		copy(input.TxID[:], tx[offset:offset+32])
+		input.Vout = binary.LittleEndian.Uint32(tx[offset+32 : offset+36])
+		offset += 36
+
+		scriptLen, next, err := readCompactSize(tx, offset, "script sig length")
+		if err != nil {
+			return nil, 0, err
+		}
+		offset = next
+
+		if scriptLen+4 > uint64(len(tx)-offset) {
+			return nil, 0, fmt.Errorf("tx too short for script sig")
+		}
+		input.ScriptSig = make([]byte, scriptLen)
+		copy(input.ScriptSig, tx[offset:offset+int(scriptLen)])
+		offset += int(scriptLen)
+
+		input.Sequence = binary.LittleEndian.Uint32(tx[offset : offset+4])
+		offset += 4
+
+		inputs = append(inputs, input)
+	}
+
+	return inputs, offset, nil
+}
+
+// skipTxHeader returns the offset of the vin count in a raw transaction.
+//
+// Overwintered transactions (v3+) add a version group id after the header,
+// and v5 transactions also move the consensus branch id, lock time and expiry
+// height in front of the inputs.
+func skipTxHeader(tx []byte) (int, error) {
+	if len(tx) < 4 {
+		return 0, fmt.Errorf("tx too short for header")
+	}
+	header := binary.LittleEndian.Uint32(tx)
+	offset := 4
+	if header&0x80000000 != 0 {
+		offset += 4 // version group id
+		if header&0x7fffffff >= 5 {
+			offset += 12 // consensus branch id, lock time, expiry height
+		}
+	}
+	if offset > len(tx) {
+		return 0, fmt.Errorf("tx too short for header")
+	}
+	return offset, nil
+}
+
+// readCompactSize decodes the CompactSize at offset, returning the value and
+// the offset after it. Non-canonical encodings are rejected, as by consensus.
+func readCompactSize(tx []byte, offset int, what string) (uint64, int, error) {
+	if offset >= len(tx) {
+		return 0, 0, fmt.Errorf("tx too short for %s", what)
+	}
+
+	var size int
+	var minValue uint64
+	switch tx[offset] {
+	case 0xfd:
+		size, minValue = 2, 0xfd
+	case 0xfe:
+		size, minValue = 4, 0x10000
+	case 0xff:
+		size, minValue = 8, 0x100000000
+	default:
+		return uint64(tx[offset]), offset + 1, nil
+	}
+
+	offset++
+	if offset+size > len(tx) {
+		return 0, 0, fmt.Errorf("tx too short for %s", what)
+	}
+	var value uint64
+	for i := size - 1; i >= 0; i-- {
+		value = value<<8 | uint64(tx[offset+i])
+	}
+	if value < minValue {
+		return 0, 0, fmt.Errorf("non-canonical CompactSize for %s", what)
+	}
+	return value, offset + size, nil
+}
+
 // ComputeTxid computes the txid from raw transaction hex
+//
+// v5 transactions use the ZIP 244 txid; older versions the double-SHA256.
 func ComputeTxid(txHex string) (string, error) {
 	tx, err := HexToBytes(txHex)
 	if err != nil {
 		return "", err
 	}
+	if len(tx) >= 4 && binary.LittleEndian.Uint32(tx)&0x7fffffff >= 5 {
+		return t2z.TransactionID(tx)
+	}
 	hash := DoubleSHA256(tx)
 	return BytesToHex(ReverseBytes(hash)), nil
 }
@@ -242,72 +387,115 @@ func GetCoinbaseUtxo(client *ZebraClient, blockHeight int, keypair *ZcashKeypair
 		return nil, err
 	}
 
-	expectedPubkeyHash := Hash160(keypair.PublicKey)
+	info, err := t2z.ValidateAddress(keypair.Address)
+	if err != nil {
+		return nil, fmt.Errorf("keypair address: %w", err)
+	}
 
 	for index, output := range outputs {
-		// Check if this is a P2PKH output matching our pubkey
-		// P2PKH: OP_DUP OP_HASH160 <20 bytes> OP_EQUALVERIFY OP_CHECKSIG
-		if len(output.ScriptPubKey) == 25 &&
-			output.ScriptPubKey[0] == 0x76 &&
-			output.ScriptPubKey[1] == 0xa9 &&
-			output.ScriptPubKey[2] == 0x14 &&
-			output.ScriptPubKey[23] == 0x88 &&
-			output.ScriptPubKey[24] == 0xac {
-
-			pubkeyHashInScript := output.ScriptPubKey[3:23]
-			if bytes.Equal(pubkeyHashInScript, expectedPubkeyHash) {
-				txidHex, err := ComputeTxid(coinbaseTx.Hex)
-				if err != nil {
-					return nil, err
-				}
-				txidBytes, _ := HexToBytes(txidHex)
-				txidReversed := ReverseBytes(txidBytes)
-
-				var txid [32]byte
-				copy(txid[:], txidReversed)
-
-				return &t2z.TransparentInput{
-					Pubkey:       keypair.PublicKey,
-					TxID:         txid,
-					Vout:         uint32(index),
-					Amount:       output.Value,
-					ScriptPubKey: output.ScriptPubKey,
-				}, nil
-			}
+		// Find the P2PKH output paying our address
+		address, kind, err := t2z.ScriptToAddress(output.ScriptPubKey, info.Network)
+		if err != nil || kind != t2z.AddressP2PKH || address != keypair.Address {
+			continue
 		}
+
+		txidHex, err := ComputeTxid(coinbaseTx.Hex)
+		if err != nil {
+			return nil, err
+		}
+		txidBytes, _ := HexToBytes(txidHex)
+		txidReversed := ReverseBytes(txidBytes)
+
+		var txid [32]byte
+		copy(txid[:], txidReversed)
+
+		return &t2z.TransparentInput{
+			Pubkey:       keypair.PublicKey,
+			TxID:         txid,
+			Vout:         uint32(index),
+			Amount:       output.Value,
+			ScriptPubKey: output.ScriptPubKey,
+		}, nil
 	}
 
 	return nil, nil
 }
 
-// GetMatureCoinbaseUtxos gets mature coinbase UTXOs (100+ confirmations)
+// GetMatureCoinbaseUtxos gets mature coinbase UTXOs (100+ confirmations),
+// most recent first, skipping those marked spent. Scanned blocks are
+// remembered in the DefaultUTXOCache, so later calls only fetch new blocks.
 func GetMatureCoinbaseUtxos(client *ZebraClient, keypair *ZcashKeypair, maxCount int) ([]t2z.TransparentInput, error) {
-	info, err := client.GetBlockchainInfo()
+	return DefaultUTXOCache().MatureCoinbaseUtxos(client, keypair, maxCount)
+}
+
+// GetMatureCoinbaseUtxosWithOptions is GetMatureCoinbaseUtxos with a bounded
+// scan window, parallel block fetches, or a preference for large UTXOs, see
+// ScanOptions
+func GetMatureCoinbaseUtxosWithOptions(client *ZebraClient, keypair *ZcashKeypair, maxCount int, opts ScanOptions) ([]t2z.TransparentInput, error) {
+	return DefaultUTXOCache().MatureCoinbaseUtxosWithOptions(client, keypair, maxCount, opts)
+}
+
+// coinbaseMaturity is the number of confirmations before a coinbase output can be spent
+const coinbaseMaturity = 100
+
+// Preflight checks everything an example needs before building a transaction:
+// the node is reachable, it is not running mainnet, coinbase outputs have
+// matured, setup has been run for this keypair, and at least requiredUtxos
+// unspent UTXOs totaling requiredValue zatoshis are available.
+//
+// All problems found are reported together in a single error.
+func (c *ZebraClient) Preflight(keypair *ZcashKeypair, requiredUtxos int, requiredValue uint64) error {
+	var problems []string
+
+	info, err := c.GetBlockchainInfo()
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("preflight failed: node not reachable at %s: %w (is zebrad running? try: docker-compose up -d)", c.url, err)
 	}
 
-	currentHeight := info.Blocks
-	matureHeight := currentHeight - 100
+	if info.Chain == "main" {
+		problems = append(problems, fmt.Sprintf("node is on %q but the examples use testnet/regtest addresses (%s)", info.Chain, keypair.Address))
+	}
 
-	spentUtxos := LoadSpentUtxos()
-	var utxos []t2z.TransparentInput
+	if info.Blocks <= coinbaseMaturity {
+		problems = append(problems, fmt.Sprintf("chain height is %d, coinbase outputs mature after %d blocks (run: go run ./setup)", info.Blocks, coinbaseMaturity+1))
+	}
 
-	// Scan from most recent mature blocks backwards
-	for height := matureHeight; height >= 1 && len(utxos) < maxCount; height-- {
-		utxo, err := GetCoinbaseUtxo(client, height, keypair)
-		if err != nil {
-			continue
+	testData, err := LoadTestData()
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("no test data in %s (run: go run ./setup)", dataDir))
+	} else {
+		if testData.Transparent.Address != keypair.Address {
+			problems = append(problems, fmt.Sprintf("test data is for %s, not %s (re-run: go run ./setup)", testData.Transparent.Address, keypair.Address))
+		}
+		if testData.SetupHeight > info.Blocks {
+			problems = append(problems, fmt.Sprintf("test data was created at height %d but the chain is at %d; the node was reset (re-run: go run ./setup)", testData.SetupHeight, info.Blocks))
 		}
-		if utxo != nil {
-			key := fmt.Sprintf("%s:%d", BytesToHex(utxo.TxID[:]), utxo.Vout)
-			if !spentUtxos[key] {
-				utxos = append(utxos, *utxo)
+	}
+
+	if info.Blocks > coinbaseMaturity {
+		// Each mature block pays the keypair at most once, so this finds
+		// every UTXO, however many are needed to cover requiredValue
+		utxos, err := GetMatureCoinbaseUtxos(c, keypair, info.Blocks-coinbaseMaturity)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("failed to scan UTXOs: %v", err))
+		} else {
+			var total uint64
+			for _, u := range utxos {
+				total += u.Amount
+			}
+			if len(utxos) < requiredUtxos {
+				problems = append(problems, fmt.Sprintf("need %d unspent mature UTXOs, found %d (reset the node or clear %s)", requiredUtxos, len(utxos), filepath.Join(dataDir, spentUtxosFile)))
+			}
+			if total < requiredValue {
+				problems = append(problems, fmt.Sprintf("need %s ZEC in unspent UTXOs, found %s ZEC", ZatoshiToZec(requiredValue), ZatoshiToZec(total)))
 			}
 		}
 	}
 
-	return utxos, nil
+	if len(problems) > 0 {
+		return fmt.Errorf("preflight failed:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
 }
 
 // PrintWorkflowSummary prints a transaction workflow summary
@@ -0,0 +1,268 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	t2z "github.com/gstohl/t2z/go"
+)
+
+// appendCompactSize appends n as a Bitcoin/Zcash CompactSize
+func appendCompactSize(buf []byte, n uint64) []byte {
+	switch {
+	case n < 0xfd:
+		return append(buf, byte(n))
+	case n <= 0xffff:
+		return binary.LittleEndian.AppendUint16(append(buf, 0xfd), uint16(n))
+	case n <= 0xffffffff:
+		return binary.LittleEndian.AppendUint32(append(buf, 0xfe), uint32(n))
+	default:
+		return binary.LittleEndian.AppendUint64(append(buf, 0xff), n)
+	}
+}
+
+// buildV5Tx builds a v5 transaction with the given transparent inputs' script
+// sigs and outputs, and empty shielded bundles
+func buildV5Tx(scriptSigs [][]byte, outputs []TxOutput) []byte {
+	tx := binary.LittleEndian.AppendUint32(nil, 5|0x80000000)
+	tx = binary.LittleEndian.AppendUint32(tx, 0x26A7270A) // version group id
+	tx = binary.LittleEndian.AppendUint32(tx, 0xc2d6d0b4) // consensus branch id
+	tx = binary.LittleEndian.AppendUint32(tx, 0)          // lock time
+	tx = binary.LittleEndian.AppendUint32(tx, 0)          // expiry height
+
+	tx = appendCompactSize(tx, uint64(len(scriptSigs)))
+	for i, scriptSig := range scriptSigs {
+		tx = append(tx, bytes.Repeat([]byte{byte(i)}, 32)...)
+		tx = binary.LittleEndian.AppendUint32(tx, uint32(i))
+		tx = appendCompactSize(tx, uint64(len(scriptSig)))
+		tx = append(tx, scriptSig...)
+		tx = binary.LittleEndian.AppendUint32(tx, 0xffffffff)
+	}
+
+	tx = appendCompactSize(tx, uint64(len(outputs)))
+	for _, out := range outputs {
+		tx = binary.LittleEndian.AppendUint64(tx, out.Value)
+		tx = appendCompactSize(tx, uint64(len(out.ScriptPubKey)))
+		tx = append(tx, out.ScriptPubKey...)
+	}
+
+	return append(tx, 0, 0, 0) // no Sapling spends or outputs, no Orchard actions
+}
+
+// Test that counts and script lengths of 253 and more are decoded
+func TestParseTxOutputsLargeCounts(t *testing.T) {
+	scriptSigs := make([][]byte, 300)
+	for i := range scriptSigs {
+		scriptSigs[i] = []byte{0x00}
+	}
+	scriptSigs[7] = bytes.Repeat([]byte{0x51}, 260)
+
+	outputs := make([]TxOutput, 260)
+	for i := range outputs {
+		outputs[i] = TxOutput{Value: uint64(i) * 1000, ScriptPubKey: bytes.Repeat([]byte{0x76}, 25)}
+	}
+	outputs[3].ScriptPubKey = bytes.Repeat([]byte{0x6a}, 300)
+
+	got, err := ParseTxOutputs(hex.EncodeToString(buildV5Tx(scriptSigs, outputs)))
+	if err != nil {
+		t.Fatalf("ParseTxOutputs failed: %v", err)
+	}
+	if len(got) != len(outputs) {
+		t.Fatalf("Expected %d outputs, got %d", len(outputs), len(got))
+	}
+	for i := range outputs {
+		if got[i].Value != outputs[i].Value || !bytes.Equal(got[i].ScriptPubKey, outputs[i].ScriptPubKey) {
+			t.Fatalf("Output %d mismatch: got %d/%x", i, got[i].Value, got[i].ScriptPubKey)
+		}
+	}
+}
+
+// Test that inputs are parsed with their prevouts, script sigs and sequences
+func TestParseTxInputs(t *testing.T) {
+	scriptSigs := make([][]byte, 260)
+	for i := range scriptSigs {
+		scriptSigs[i] = []byte{byte(i)}
+	}
+	scriptSigs[5] = bytes.Repeat([]byte{0x51}, 300)
+	outputs := []TxOutput{{Value: 5000, ScriptPubKey: bytes.Repeat([]byte{0x76}, 25)}}
+
+	got, err := ParseTxInputs(hex.EncodeToString(buildV5Tx(scriptSigs, outputs)))
+	if err != nil {
+		t.Fatalf("ParseTxInputs failed: %v", err)
+	}
+	if len(got) != len(scriptSigs) {
+		t.Fatalf("Expected %d inputs, got %d", len(scriptSigs), len(got))
+	}
+	for i, in := range got {
+		if in.TxID != [32]byte(bytes.Repeat([]byte{byte(i)}, 32)) || in.Vout != uint32(i) {
+			t.Fatalf("Input %d prevout mismatch: got %x:%d", i, in.TxID, in.Vout)
+		}
+		if !bytes.Equal(in.ScriptSig, scriptSigs[i]) || in.Sequence != 0xffffffff {
+			t.Fatalf("Input %d mismatch: got %x/%08x", i, in.ScriptSig, in.Sequence)
+		}
+	}
+
+	// A script sig running into the end of the transaction
+	tx := buildV5Tx([][]byte{bytes.Repeat([]byte{0x51}, 10)}, nil)
+	if _, err := ParseTxInputs(hex.EncodeToString(tx[:len(tx)-10])); err == nil {
+		t.Error("Expected error for truncated input, got nil")
+	}
+}
+
+// Test that non-canonical CompactSize encodings are rejected
+func TestReadCompactSizeNonCanonical(t *testing.T) {
+	if _, _, err := readCompactSize([]byte{0xfd, 0xfc, 0x00}, 0, "count"); err == nil {
+		t.Error("Expected error for 0xfc encoded in 3 bytes, got nil")
+	}
+	if v, next, err := readCompactSize([]byte{0xfd, 0xfd, 0x00}, 0, "count"); err != nil || v != 0xfd || next != 3 {
+		t.Errorf("readCompactSize = %d, %d, %v; want 253, 3, nil", v, next, err)
+	}
+}
+
+func FuzzParseTx(f *testing.F) {
+	outputs := []TxOutput{{Value: 5000, ScriptPubKey: bytes.Repeat([]byte{0x76}, 25)}}
+	f.Add(buildV5Tx([][]byte{{0x00}}, outputs))
+	f.Add(buildV5Tx([][]byte{bytes.Repeat([]byte{0x51}, 253)}, outputs))
+	f.Add([]byte{0x05, 0x00, 0x00, 0x80, 0xfe, 0xff, 0xff, 0xff, 0xff})
+
+	f.Fuzz(func(t *testing.T, tx []byte) {
+		if inputs, err := ParseTxInputs(hex.EncodeToString(tx)); err == nil {
+			for _, in := range inputs {
+				if len(in.ScriptSig) > len(tx) {
+					t.Fatalf("script of %d bytes from a %d byte transaction", len(in.ScriptSig), len(tx))
+				}
+			}
+		}
+
+		got, err := ParseTxOutputs(hex.EncodeToString(tx))
+		if err != nil {
+			return
+		}
+		// Every parsed script lies within the transaction
+		for _, out := range got {
+			if len(out.ScriptPubKey) > len(tx) {
+				t.Fatalf("script of %d bytes from a %d byte transaction", len(out.ScriptPubKey), len(tx))
+			}
+		}
+	})
+}
+
+// Test that ZecToZatoshi(ZatoshiToZec(x)) == x up to MaxMoney
+func TestZecToZatoshiRoundTrip(t *testing.T) {
+	for _, zat := range []uint64{0, 1, 9, 10, 99_999_999, t2z.ZatoshisPerZec, 123_456_789, t2z.MaxMoney - 1, t2z.MaxMoney} {
+		if back, err := ZecToZatoshi(ZatoshiToZec(zat)); err != nil || back != zat {
+			t.Errorf("ZecToZatoshi(ZatoshiToZec(%d)) = %d, %v", zat, back, err)
+		}
+	}
+
+	roundTrip := func(zat uint64) bool {
+		zat %= t2z.MaxMoney + 1
+		back, err := ZecToZatoshi(ZatoshiToZec(zat))
+		return err == nil && back == zat
+	}
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 10_000}); err != nil {
+		t.Error(err)
+	}
+
+	// Amounts a float64 cannot hold exactly
+	for s, want := range map[string]uint64{"0.1": 10_000_000, "0.29": 29_000_000, "20999999.99999999": t2z.MaxMoney - 1} {
+		if got, err := ZecToZatoshi(s); err != nil || got != want {
+			t.Errorf("ZecToZatoshi(%q) = %d, %v; want %d", s, got, err, want)
+		}
+	}
+
+	for _, s := range []string{"21000000.00000001", "1e8", "-1", "0.000000001", ""} {
+		if got, err := ZecToZatoshi(s); err == nil {
+			t.Errorf("ZecToZatoshi(%q) = %d; want error", s, got)
+		}
+	}
+}
+
+// Test that Preflight passes on a prepared regtest node and reports each
+// missing prerequisite
+func TestPreflight(t *testing.T) {
+	other := KeypairFromPrivateKey(bytes.Repeat([]byte{0x02}, 32))
+
+	tests := []struct {
+		name string
+		// chain is applied to a regtest chain at height 150 whose even
+		// blocks pay TEST_KEYPAIR
+		chain    func(*fakeChain)
+		testData *TestData
+		utxos    int
+		value    uint64
+		want     []string
+	}{
+		{"ready", nil, &TestData{Transparent: TransparentData{Address: TEST_KEYPAIR.Address}, SetupHeight: 120}, 3, 100, nil},
+		{"value needs more UTXOs", nil, &TestData{Transparent: TransparentData{Address: TEST_KEYPAIR.Address}, SetupHeight: 120}, 1, 1500, nil},
+		{"value only", nil, &TestData{Transparent: TransparentData{Address: TEST_KEYPAIR.Address}, SetupHeight: 120}, 0, 100, nil},
+		{"mainnet", func(f *fakeChain) { f.chain = "main" }, &TestData{Transparent: TransparentData{Address: TEST_KEYPAIR.Address}, SetupHeight: 120}, 3, 100,
+			[]string{`node is on "main"`}},
+		{"immature", func(f *fakeChain) { f.height = 100 }, &TestData{Transparent: TransparentData{Address: TEST_KEYPAIR.Address}, SetupHeight: 100}, 3, 100,
+			[]string{"chain height is 100, coinbase outputs mature after 101 blocks"}},
+		{"no setup", nil, nil, 3, 100, []string{"no test data"}},
+		{"other keypair", nil, &TestData{Transparent: TransparentData{Address: other.Address}, SetupHeight: 120}, 3, 100,
+			[]string{"test data is for " + other.Address}},
+		{"node reset", nil, &TestData{Transparent: TransparentData{Address: TEST_KEYPAIR.Address}, SetupHeight: 500}, 3, 100,
+			[]string{"the node was reset"}},
+		{"too few UTXOs", nil, &TestData{Transparent: TransparentData{Address: TEST_KEYPAIR.Address}, SetupHeight: 120}, 100, 100,
+			[]string{"need 100 unspent mature UTXOs, found 25"}},
+		{"too little value", nil, &TestData{Transparent: TransparentData{Address: TEST_KEYPAIR.Address}, SetupHeight: 120}, 3, 100_000_000,
+			[]string{"need 1.00000000 ZEC in unspent UTXOs"}},
+		{"scan fails", func(f *fakeChain) { f.failBlocks = true }, &TestData{Transparent: TransparentData{Address: TEST_KEYPAIR.Address}, SetupHeight: 120}, 3, 100,
+			[]string{"failed to scan UTXOs"}},
+		{"several problems", func(f *fakeChain) { f.chain = "main"; f.height = 50 }, nil, 3, 100,
+			[]string{`node is on "main"`, "chain height is 50", "no test data"}},
+	}
+
+	defer func(dir string) { dataDir = dir }(dataDir)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir = t.TempDir()
+			if tt.testData != nil {
+				if err := SaveTestData(tt.testData); err != nil {
+					t.Fatalf("SaveTestData failed: %v", err)
+				}
+			}
+
+			chain := &fakeChain{t: t, height: 150, spent: map[string]bool{}}
+			if tt.chain != nil {
+				tt.chain(chain)
+			}
+			server := httptest.NewServer(chain)
+			defer server.Close()
+
+			err := NewZebraClientURL(server.URL).Preflight(TEST_KEYPAIR, tt.utxos, tt.value)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Preflight failed: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Expected Preflight to fail with %q", tt.want)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Expected %q in:\n%v", want, err)
+				}
+			}
+			if n := strings.Count(err.Error(), "\n  - "); n != len(tt.want) {
+				t.Errorf("Expected %d problems, got %d:\n%v", len(tt.want), n, err)
+			}
+		})
+	}
+
+	// An unreachable node fails before anything else is checked
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	if err := NewZebraClientURL(server.URL).Preflight(TEST_KEYPAIR, 1, 0); err == nil || !strings.Contains(err.Error(), "node not reachable") {
+		t.Errorf("Expected an unreachable node error, got %v", err)
+	}
+}
@@ -0,0 +1,419 @@
+package common
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	t2z "github.com/gstohl/t2z/go"
+)
+
+// utxoCacheFile is the name of the discovered UTXO cache in a cache directory
+const utxoCacheFile = "utxo-cache.json"
+
+// DefaultUTXOCacheTTL is how long DefaultUTXOCache trusts a block scan
+// before rescanning
+const DefaultUTXOCacheTTL = time.Hour
+
+// UTXOCache remembers the coinbase UTXOs found by scanning blocks, so that
+// MatureCoinbaseUtxos only scans blocks it has not seen before, and which
+// UTXOs have been spent.
+//
+// The cache lives in a directory: the scanned blocks and their UTXOs in
+// utxo-cache.json, and the spent UTXOs in spent-utxos.json, in the format of
+// LoadSpentUtxos. A scan is discarded once it is older than the TTL, or when
+// the block it ended at is no longer in the best chain, as after a node
+// reset. Extending a scan with new or older blocks refreshes its age.
+//
+// A UTXOCache is not safe for concurrent use.
+type UTXOCache struct {
+	dir   string
+	ttl   time.Duration
+	now   func() time.Time
+	scans map[string]*utxoScan // by address
+	spent map[string]bool      // by outpointKey
+}
+
+// utxoScan is a scanned range of blocks for one address, and the UTXOs paying
+// the address in it, highest first
+type utxoScan struct {
+	Low       int          `json:"low"`
+	High      int          `json:"high"`
+	HighHash  string       `json:"highHash"`
+	ScannedAt time.Time    `json:"scannedAt"`
+	Utxos     []cachedUtxo `json:"utxos"`
+}
+
+// cachedUtxo is a coinbase output found by a scan
+type cachedUtxo struct {
+	TxID   string `json:"txid"` // internal byte order, like outpointKey
+	Vout   uint32 `json:"vout"`
+	Amount uint64 `json:"amount"`
+	Script string `json:"script"`
+	Height int    `json:"height"`
+}
+
+// NewUTXOCache opens the cache in dir, whose scans expire after ttl (never
+// if ttl is 0). Missing or unreadable cache files start an empty cache.
+func NewUTXOCache(dir string, ttl time.Duration) *UTXOCache {
+	c := &UTXOCache{
+		dir:   dir,
+		ttl:   ttl,
+		now:   time.Now,
+		scans: make(map[string]*utxoScan),
+		spent: loadSpentFile(filepath.Join(dir, spentUtxosFile)),
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, utxoCacheFile)); err == nil {
+		if err := json.Unmarshal(data, &c.scans); err != nil || c.scans == nil {
+			c.scans = make(map[string]*utxoScan)
+		}
+	}
+	return c
+}
+
+// DefaultUTXOCache opens the cache in the data directory with
+// DefaultUTXOCacheTTL
+func DefaultUTXOCache() *UTXOCache {
+	return NewUTXOCache(dataDir, DefaultUTXOCacheTTL)
+}
+
+// Save writes the cache to its directory
+func (c *UTXOCache) Save() error {
+	if err := saveSpentFile(filepath.Join(c.dir, spentUtxosFile), c.spent); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c.scans, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(c.dir, utxoCacheFile), data, 0644)
+}
+
+// Clear forgets all scans and spent UTXOs, and removes the cache files
+func (c *UTXOCache) Clear() error {
+	c.scans = make(map[string]*utxoScan)
+	c.spent = make(map[string]bool)
+	if err := os.Remove(filepath.Join(c.dir, utxoCacheFile)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return saveSpentFile(filepath.Join(c.dir, spentUtxosFile), c.spent)
+}
+
+// IsSpent reports whether an outpoint has been marked spent
+func (c *UTXOCache) IsSpent(txid [32]byte, vout uint32) bool {
+	return c.spent[outpointKey(txid, vout)]
+}
+
+// MarkSpent marks the UTXOs of inputs as spent, and saves the cache
+func (c *UTXOCache) MarkSpent(inputs []t2z.TransparentInput) error {
+	for _, input := range inputs {
+		c.spent[outpointKey(input.TxID, input.Vout)] = true
+	}
+	return c.Save()
+}
+
+// Invalidate drops a cached UTXO that turned out to be spent on-chain, for
+// example by another wallet, and marks it spent. The cache is saved.
+func (c *UTXOCache) Invalidate(txid [32]byte, vout uint32) error {
+	key := outpointKey(txid, vout)
+	c.spent[key] = true
+	for _, scan := range c.scans {
+		for i, u := range scan.Utxos {
+			if u.key() == key {
+				scan.Utxos = append(scan.Utxos[:i], scan.Utxos[i+1:]...)
+				break
+			}
+		}
+	}
+	return c.Save()
+}
+
+// Prune checks every cached UTXO not yet marked spent with GetTxOut, including
+// the mempool, and invalidates those that are spent. It returns the number of
+// UTXOs invalidated.
+func (c *UTXOCache) Prune(client *ZebraClient) (int, error) {
+	type outpoint struct {
+		txid [32]byte
+		vout uint32
+	}
+	var gone []outpoint
+	for _, scan := range c.scans {
+		for _, u := range scan.Utxos {
+			txid, err := u.txid()
+			if err != nil {
+				return 0, err
+			}
+			if c.IsSpent(txid, u.Vout) {
+				continue
+			}
+			out, err := client.GetTxOut(BytesToHex(ReverseBytes(txid[:])), u.Vout, true)
+			if err != nil {
+				return 0, fmt.Errorf("check %s:%d: %w", u.TxID, u.Vout, err)
+			}
+			if out == nil {
+				gone = append(gone, outpoint{txid, u.Vout})
+			}
+		}
+	}
+
+	for _, o := range gone {
+		if err := c.Invalidate(o.txid, o.vout); err != nil {
+			return 0, err
+		}
+	}
+	return len(gone), nil
+}
+
+// DefaultScanWorkers is the number of blocks fetched in parallel when
+// ScanOptions.Workers is not set
+const DefaultScanWorkers = 4
+
+// DefaultEconomicalWindow is the scan window of an economical scan when
+// ScanOptions.Window is not set
+const DefaultEconomicalWindow = 1000
+
+// ErrNotEnoughUtxos is returned, with the UTXOs that were found, when a scan
+// window holds fewer unspent UTXOs than requested
+var ErrNotEnoughUtxos = errors.New("not enough UTXOs found in scan window")
+
+// ScanOptions bound and order a scan for coinbase UTXOs
+type ScanOptions struct {
+	// Window is the number of mature blocks, counted down from the most
+	// recent, that may be scanned. 0 scans down to block 1, or
+	// DefaultEconomicalWindow blocks if Economical is set. If the window
+	// holds fewer than the requested number of unspent UTXOs, the scan
+	// returns those it found and an error wrapping ErrNotEnoughUtxos.
+	Window int
+	// Workers is the number of blocks fetched in parallel, DefaultScanWorkers
+	// if 0
+	Workers int
+	// Economical prefers the largest UTXOs over the most recent ones, so that
+	// an amount is covered by fewer inputs and a lower ZIP-317 fee. It scans
+	// the whole window.
+	Economical bool
+}
+
+// MatureCoinbaseUtxos returns up to maxCount unspent mature coinbase UTXOs
+// paying keypair, most recent first, like GetMatureCoinbaseUtxos. Only blocks
+// outside the cached scan of the address are fetched, and the cache is saved.
+func (c *UTXOCache) MatureCoinbaseUtxos(client *ZebraClient, keypair *ZcashKeypair, maxCount int) ([]t2z.TransparentInput, error) {
+	return c.MatureCoinbaseUtxosWithOptions(client, keypair, maxCount, ScanOptions{})
+}
+
+// MatureCoinbaseUtxosWithOptions is MatureCoinbaseUtxos with a bounded scan
+// window, parallel block fetches, or a preference for large UTXOs, see
+// ScanOptions
+func (c *UTXOCache) MatureCoinbaseUtxosWithOptions(client *ZebraClient, keypair *ZcashKeypair, maxCount int, opts ScanOptions) ([]t2z.TransparentInput, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = DefaultScanWorkers
+	}
+
+	info, err := client.GetBlockchainInfo()
+	if err != nil {
+		return nil, err
+	}
+	matureHeight := info.Blocks - coinbaseMaturity
+	window := opts.Window
+	if window <= 0 && opts.Economical {
+		window = DefaultEconomicalWindow
+	}
+	floor := 1
+	if window > 0 {
+		floor = max(floor, matureHeight-window+1)
+	}
+
+	// A scan that ends below the window would have to be extended past it
+	scan := c.scans[keypair.Address]
+	if scan != nil && (!c.scanValid(client, scan, matureHeight) || scan.High < floor-1) {
+		scan = nil
+	}
+	if scan == nil {
+		// An empty range just above the mature tip
+		scan = &utxoScan{Low: matureHeight + 1, High: matureHeight, ScannedAt: c.now()}
+		c.scans[keypair.Address] = scan
+	}
+
+	// Blocks that matured since the last scan
+	extended := false
+	if matureHeight > scan.High {
+		fresh, err := scanBlocks(client, keypair, matureHeight, max(scan.High+1, 1), workers)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := client.GetBlockHash(matureHeight)
+		if err != nil {
+			return nil, err
+		}
+		scan.Utxos = append(fresh, scan.Utxos...)
+		scan.High, scan.HighHash = matureHeight, hash
+		extended = true
+	}
+
+	// Unspent UTXOs in the window, most recent first
+	candidates := func() []cachedUtxo {
+		var found []cachedUtxo
+		for _, u := range scan.Utxos {
+			if u.Height >= floor && !c.spent[u.key()] {
+				found = append(found, u)
+			}
+		}
+		return found
+	}
+
+	// Older blocks, until enough UTXOs are found
+	for scan.Low > floor && (opts.Economical || len(candidates()) < maxCount) {
+		low := max(floor, scan.Low-workers)
+		found, err := scanBlocks(client, keypair, scan.Low-1, low, workers)
+		if err != nil {
+			return nil, err
+		}
+		scan.Utxos = append(scan.Utxos, found...)
+		scan.Low = low
+		extended = true
+	}
+	if scan.HighHash == "" && scan.Low <= scan.High {
+		if scan.HighHash, err = client.GetBlockHash(scan.High); err != nil {
+			return nil, err
+		}
+	}
+	// The tip of an extended scan was checked against the best chain, so
+	// the scan is as fresh as a new one
+	if extended {
+		scan.ScannedAt = c.now()
+	}
+	if err := c.Save(); err != nil {
+		return nil, fmt.Errorf("save UTXO cache: %w", err)
+	}
+
+	selected := candidates()
+	if opts.Economical {
+		slices.SortStableFunc(selected, func(a, b cachedUtxo) int {
+			return cmp.Compare(b.Amount, a.Amount)
+		})
+	}
+	selected = selected[:min(len(selected), max(maxCount, 0))]
+
+	utxos := make([]t2z.TransparentInput, 0, len(selected))
+	for _, u := range selected {
+		input, err := u.input(keypair.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		utxos = append(utxos, input)
+	}
+	if window > 0 && len(utxos) < maxCount {
+		return utxos, fmt.Errorf("found %d of %d UTXOs in blocks %d to %d: %w", len(utxos), maxCount, floor, matureHeight, ErrNotEnoughUtxos)
+	}
+	return utxos, nil
+}
+
+// scanValid reports whether a cached scan can still be used: it has not
+// expired, and the block it ended at is still in the best chain
+func (c *UTXOCache) scanValid(client *ZebraClient, scan *utxoScan, matureHeight int) bool {
+	if c.ttl > 0 && c.now().Sub(scan.ScannedAt) > c.ttl {
+		return false
+	}
+	if scan.High > matureHeight {
+		return false
+	}
+	if scan.Low > scan.High {
+		return true
+	}
+	hash, err := client.GetBlockHash(scan.High)
+	return err == nil && hash == scan.HighHash
+}
+
+// scanCoinbase returns the coinbase UTXO paying keypair at height, if any
+func scanCoinbase(client *ZebraClient, height int, keypair *ZcashKeypair) (*cachedUtxo, error) {
+	input, err := GetCoinbaseUtxo(client, height, keypair)
+	if err != nil {
+		return nil, fmt.Errorf("scan block %d: %w", height, err)
+	}
+	if input == nil {
+		return nil, nil
+	}
+	return &cachedUtxo{
+		TxID:   BytesToHex(input.TxID[:]),
+		Vout:   input.Vout,
+		Amount: input.Amount,
+		Script: BytesToHex(input.ScriptPubKey),
+		Height: height,
+	}, nil
+}
+
+// scanBlocks fetches the coinbase UTXOs paying keypair in blocks high down to
+// low, workers blocks at a time, and returns them highest first
+func scanBlocks(client *ZebraClient, keypair *ZcashKeypair, high, low, workers int) ([]cachedUtxo, error) {
+	if high < low {
+		return nil, nil
+	}
+	results := make([]*cachedUtxo, high-low+1)
+	errs := make([]error, len(results))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	for i := range results {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i], errs[i] = scanCoinbase(client, high-i, keypair)
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	var found []cachedUtxo
+	for _, u := range results {
+		if u != nil {
+			found = append(found, *u)
+		}
+	}
+	return found, nil
+}
+
+// key returns the outpointKey of a cached UTXO
+func (u cachedUtxo) key() string {
+	return fmt.Sprintf("%s:%d", u.TxID, u.Vout)
+}
+
+// txid decodes the txid of a cached UTXO
+func (u cachedUtxo) txid() ([32]byte, error) {
+	var txid [32]byte
+	b, err := HexToBytes(u.TxID)
+	if err != nil || len(b) != 32 {
+		return txid, fmt.Errorf("invalid cached txid %q", u.TxID)
+	}
+	copy(txid[:], b)
+	return txid, nil
+}
+
+// input converts a cached UTXO into a t2z input spendable by pubkey
+func (u cachedUtxo) input(pubkey []byte) (t2z.TransparentInput, error) {
+	txid, err := u.txid()
+	if err != nil {
+		return t2z.TransparentInput{}, err
+	}
+	script, err := HexToBytes(u.Script)
+	if err != nil {
+		return t2z.TransparentInput{}, fmt.Errorf("invalid cached script for %s:%d: %w", u.TxID, u.Vout, err)
+	}
+	return t2z.TransparentInput{
+		Pubkey:       pubkey,
+		TxID:         txid,
+		Vout:         u.Vout,
+		Amount:       u.Amount,
+		ScriptPubKey: script,
+	}, nil
+}
@@ -0,0 +1,209 @@
+package common
+
+import (
+	"cmp"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeChain serves the RPCs used to scan coinbase outputs for a chain whose
+// even blocks pay TEST_KEYPAIR their height in zatoshis, except block 32,
+// which pays 1000
+type fakeChain struct {
+	t      *testing.T
+	height int
+	// hashPrefix changes the block hashes, as after a node reset
+	hashPrefix string
+	getBlocks  atomic.Int32
+	spent      map[string]bool // gettxout results by txid
+	// chain is the network getblockchaininfo reports, regtest if empty
+	chain string
+	// failBlocks makes getblock return an RPC error
+	failBlocks bool
+}
+
+func (f *fakeChain) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req rpcRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		f.t.Errorf("Bad request: %v", err)
+	}
+	var result any
+	switch req.Method {
+	case "getblockchaininfo":
+		result = BlockchainInfo{Chain: cmp.Or(f.chain, "regtest"), Blocks: f.height}
+	case "getblockhash":
+		result = fmt.Sprintf("%s%d", f.hashPrefix, int(req.Params[0].(float64)))
+	case "getblock":
+		f.getBlocks.Add(1)
+		if f.failBlocks {
+			json.NewEncoder(w).Encode(map[string]any{"error": RPCError{Code: -8, Message: "Block not found"}, "id": req.ID})
+			return
+		}
+		var height int
+		fmt.Sscanf(req.Params[0].(string)[len(f.hashPrefix):], "%d", &height)
+		script := CreateP2PKHScript(TEST_KEYPAIR.PublicKey)
+		if height%2 == 1 {
+			script = CreateP2PKHScript(make([]byte, 33))
+		}
+		value := uint64(height)
+		if height == 32 {
+			value = 1000
+		}
+		tx := buildV5Tx([][]byte{{byte(height), byte(height >> 8)}}, []TxOutput{{Value: value, ScriptPubKey: script}})
+		result = map[string]any{"tx": []map[string]string{{"hex": hex.EncodeToString(tx)}}}
+	case "gettxout":
+		if !f.spent[req.Params[0].(string)] {
+			result = map[string]any{"value": 1, "scriptPubKey": map[string]string{"hex": ""}}
+		}
+	default:
+		f.t.Errorf("Unexpected method %s", req.Method)
+	}
+	json.NewEncoder(w).Encode(map[string]any{"result": result, "id": req.ID})
+}
+
+// Test that the cache only scans new blocks, skips spent UTXOs, and rescans
+// after the TTL or a chain reset
+func TestUTXOCache(t *testing.T) {
+	chain := &fakeChain{t: t, height: 120, hashPrefix: "a", spent: map[string]bool{}}
+	server := httptest.NewServer(chain)
+	defer server.Close()
+	client := NewZebraClientURL(server.URL)
+	dir := t.TempDir()
+
+	// Mature blocks are 1..20, and the even ones pay us
+	utxos, err := NewUTXOCache(dir, time.Hour).MatureCoinbaseUtxos(client, TEST_KEYPAIR, 3)
+	if err != nil {
+		t.Fatalf("MatureCoinbaseUtxos failed: %v", err)
+	}
+	if len(utxos) != 3 || utxos[0].Amount != 20 || utxos[2].Amount != 16 || chain.getBlocks.Load() != 2*DefaultScanWorkers {
+		t.Fatalf("Expected blocks 20, 18, 16 after %d fetches, got %+v after %d", 2*DefaultScanWorkers, utxos, chain.getBlocks.Load())
+	}
+
+	// A new cache reads the scan back, and only fetches new or older blocks
+	cache := NewUTXOCache(dir, time.Hour)
+	spentKey := outpointKey(utxos[0].TxID, utxos[0].Vout)
+	if err := cache.MarkSpent(utxos[:1]); err != nil {
+		t.Fatalf("MarkSpent failed: %v", err)
+	}
+	chain.height = 122
+	chain.getBlocks.Store(0)
+	utxos, err = cache.MatureCoinbaseUtxos(client, TEST_KEYPAIR, 4)
+	if err != nil {
+		t.Fatalf("MatureCoinbaseUtxos failed: %v", err)
+	}
+	var amounts []uint64
+	for _, u := range utxos {
+		amounts = append(amounts, u.Amount)
+	}
+	if fmt.Sprint(amounts) != "[22 18 16 14]" || chain.getBlocks.Load() != 2 {
+		t.Errorf("Expected [22 18 16 14] after 2 fetches, got %v after %d", amounts, chain.getBlocks.Load())
+	}
+
+	// The spent tracker keeps the format of LoadSpentUtxos
+	if spent := loadSpentFile(filepath.Join(dir, spentUtxosFile)); len(spent) != 1 || !spent[spentKey] {
+		t.Errorf("Unexpected spent set %v", spent)
+	}
+
+	// Outputs spent on-chain are dropped by Prune
+	chain.spent[BytesToHex(ReverseBytes(utxos[1].TxID[:]))] = true
+	if n, err := cache.Prune(client); err != nil || n != 1 {
+		t.Errorf("Prune = %d, %v", n, err)
+	}
+	if !cache.IsSpent(utxos[1].TxID, utxos[1].Vout) {
+		t.Error("Expected the pruned UTXO to be marked spent")
+	}
+
+	// Nothing is fetched while the cache is valid
+	chain.getBlocks.Store(0)
+	if _, err := cache.MatureCoinbaseUtxos(client, TEST_KEYPAIR, 2); err != nil || chain.getBlocks.Load() != 0 {
+		t.Errorf("Expected no fetches, got %d: %v", chain.getBlocks.Load(), err)
+	}
+
+	// Extending the scan refreshes its age, so it outlives the first TTL
+	cache.now = func() time.Time { return time.Now().Add(50 * time.Minute) }
+	chain.height = 124
+	if _, err := cache.MatureCoinbaseUtxos(client, TEST_KEYPAIR, 2); err != nil || chain.getBlocks.Load() != 2 {
+		t.Errorf("Expected 2 fetches for the new blocks, got %d: %v", chain.getBlocks.Load(), err)
+	}
+	cache.now = func() time.Time { return time.Now().Add(100 * time.Minute) }
+	chain.getBlocks.Store(0)
+	if _, err := cache.MatureCoinbaseUtxos(client, TEST_KEYPAIR, 2); err != nil || chain.getBlocks.Load() != 0 {
+		t.Errorf("Expected the extended scan to be reused, got %d fetches: %v", chain.getBlocks.Load(), err)
+	}
+
+	// An expired scan is rescanned
+	cache.now = func() time.Time { return time.Now().Add(3 * time.Hour) }
+	if _, err := cache.MatureCoinbaseUtxos(client, TEST_KEYPAIR, 2); err != nil || chain.getBlocks.Load() == 0 {
+		t.Errorf("Expected an expired scan to be rescanned: %v", err)
+	}
+
+	// So is a scan of a chain that was reset
+	cache = NewUTXOCache(dir, time.Hour)
+	chain.hashPrefix = "b"
+	chain.getBlocks.Store(0)
+	if _, err := cache.MatureCoinbaseUtxos(client, TEST_KEYPAIR, 1); err != nil || chain.getBlocks.Load() != DefaultScanWorkers {
+		t.Errorf("Expected a reset chain to be rescanned from the tip, got %d fetches: %v", chain.getBlocks.Load(), err)
+	}
+
+	if err := cache.Clear(); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if len(loadSpentFile(filepath.Join(dir, spentUtxosFile))) != 0 || len(NewUTXOCache(dir, time.Hour).scans) != 0 {
+		t.Error("Expected Clear to empty the cache files")
+	}
+}
+
+// Test that a bounded window reports missing UTXOs, and that economical
+// scans pick the largest UTXOs in the window
+func TestUTXOCacheScanOptions(t *testing.T) {
+	chain := &fakeChain{t: t, height: 150, hashPrefix: "a"}
+	server := httptest.NewServer(chain)
+	defer server.Close()
+	client := NewZebraClientURL(server.URL)
+	cache := NewUTXOCache(t.TempDir(), time.Hour)
+
+	// Blocks 41..50 hold the 5 UTXOs 42..50
+	utxos, err := cache.MatureCoinbaseUtxosWithOptions(client, TEST_KEYPAIR, 6, ScanOptions{Window: 10, Workers: 3})
+	if !errors.Is(err, ErrNotEnoughUtxos) || len(utxos) != 5 {
+		t.Errorf("Expected 5 UTXOs and ErrNotEnoughUtxos, got %d: %v", len(utxos), err)
+	}
+	if n := chain.getBlocks.Load(); n != 10 {
+		t.Errorf("Expected the scan to stop at the window after 10 fetches, got %d", n)
+	}
+
+	// A wider window reuses the scan and fetches only the blocks below it
+	chain.getBlocks.Store(0)
+	utxos, err = cache.MatureCoinbaseUtxosWithOptions(client, TEST_KEYPAIR, 6, ScanOptions{Window: 20, Workers: 3})
+	if err != nil || len(utxos) != 6 || utxos[5].Amount != 40 || chain.getBlocks.Load() != 3 {
+		t.Errorf("Expected 6 UTXOs down to block 40 after 3 fetches, got %d after %d: %v", len(utxos), chain.getBlocks.Load(), err)
+	}
+
+	// Economical scans return the largest UTXOs, whatever their order
+	chain.height = 151
+	utxos, err = cache.MatureCoinbaseUtxosWithOptions(client, TEST_KEYPAIR, 2, ScanOptions{Window: 30, Economical: true})
+	if err != nil || len(utxos) != 2 || utxos[0].Amount != 1000 || utxos[1].Amount != 50 {
+		t.Errorf("Expected the UTXOs of blocks 32 and 50, got %+v: %v", utxos, err)
+	}
+
+	// Without a window, an economical scan stops at DefaultEconomicalWindow
+	chain = &fakeChain{t: t, height: DefaultEconomicalWindow + 150, hashPrefix: "a"}
+	server2 := httptest.NewServer(chain)
+	defer server2.Close()
+	client = NewZebraClientURL(server2.URL)
+	cache = NewUTXOCache(t.TempDir(), time.Hour)
+	utxos, err = cache.MatureCoinbaseUtxosWithOptions(client, TEST_KEYPAIR, DefaultEconomicalWindow, ScanOptions{Economical: true})
+	if !errors.Is(err, ErrNotEnoughUtxos) || len(utxos) != DefaultEconomicalWindow/2 {
+		t.Errorf("Expected %d UTXOs and ErrNotEnoughUtxos, got %d: %v", DefaultEconomicalWindow/2, len(utxos), err)
+	}
+	if n := chain.getBlocks.Load(); n != DefaultEconomicalWindow {
+		t.Errorf("Expected %d fetches, got %d", DefaultEconomicalWindow, n)
+	}
+}
@@ -3,21 +3,64 @@ package common
 
 import (
 	"bytes"
+	"cmp"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
+
+	t2z "github.com/gstohl/t2z/go"
 )
 
-// ZebraClient is a JSON-RPC client for Zebra
+// ZebraClient is a JSON-RPC client for Zebra. Calls may be made from
+// several goroutines; the setters must not run concurrently with calls.
 type ZebraClient struct {
 	url       string
 	client    *http.Client
-	idCounter int
+	idCounter atomic.Int64
+
+	// HTTP Basic auth credentials, or a zcashd-style cookie file read on
+	// each call since the node rewrites it on restart
+	user, password string
+	cookieFile     string
+
+	// MaxRetries is how often a failed read-only call is retried. Only the
+	// methods in RetrySafeMethods are retried, and only after transport
+	// errors or while the node is warming up. 0 disables retries.
+	MaxRetries int
+	// RetryBaseDelay is the delay before the first retry, doubled for each
+	// further attempt
+	RetryBaseDelay time.Duration
+}
+
+// RetrySafeMethods are the idempotent RPC methods rawCall may retry.
+// Broadcasts are deliberately absent.
+var RetrySafeMethods = map[string]bool{
+	"getblockchaininfo": true,
+	"getblockcount":     true,
+	"getblockhash":      true,
+	"getblock":          true,
+	"getbestblockhash":  true,
+	"getblockheader":    true,
+	"getrawtransaction": true,
+	"getrawmempool":     true,
+	"getaddressutxos":   true,
+	"gettxout":          true,
+	"estimatefee":       true,
 }
 
+// rpcInWarmup is the error code returned while the node is starting up
+const rpcInWarmup = -28
+
 // BlockchainInfo represents the response from getblockchaininfo
 type BlockchainInfo struct {
 	Chain                string  `json:"chain"`
@@ -35,6 +78,19 @@ type Block struct {
 	Tx     json.RawMessage `json:"tx"`
 }
 
+// BlockHeader represents a block header from getblockheader
+type BlockHeader struct {
+	Hash              string `json:"hash"`
+	Confirmations     int64  `json:"confirmations"` // -1 if not in the best chain
+	Height            int    `json:"height"`
+	Version           int32  `json:"version"`
+	MerkleRoot        string `json:"merkleroot"`
+	Time              int64  `json:"time"` // Unix time in seconds
+	Bits              string `json:"bits"`
+	PreviousBlockHash string `json:"previousblockhash"` // empty for the genesis block
+	NextBlockHash     string `json:"nextblockhash"`     // empty for the tip
+}
+
 // rpcRequest represents a JSON-RPC request
 type rpcRequest struct {
 	JSONRPC string        `json:"jsonrpc"`
@@ -46,37 +102,218 @@ type rpcRequest struct {
 // rpcResponse represents a JSON-RPC response
 type rpcResponse struct {
 	Result json.RawMessage `json:"result"`
-	Error  *rpcError       `json:"error"`
+	Error  *RPCError       `json:"error"`
 	ID     int             `json:"id"`
 }
 
-type rpcError struct {
+// RPCError is an error returned by the node in a JSON-RPC response
+type RPCError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 }
 
-// NewZebraClient creates a new Zebra RPC client
-func NewZebraClient() *ZebraClient {
-	host := os.Getenv("ZEBRA_HOST")
-	if host == "" {
-		host = "localhost"
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error: %s", e.Message)
+}
+
+// ErrUnauthorized is returned when the node rejects the RPC credentials
+var ErrUnauthorized = errors.New("rpc unauthorized: set ZEBRA_RPC_USER and ZEBRA_RPC_PASSWORD or ZEBRA_RPC_COOKIE_FILE")
+
+// rpcMethodNotFound is the JSON-RPC error code for an unknown method
+const rpcMethodNotFound = -32601
+
+// Broadcast rejections returned by SendRawTransaction, wrapping the node's
+// RPCError. Branch on them with errors.Is rather than matching messages.
+var (
+	// ErrAlreadyInMempool means the node already has the transaction, so
+	// the broadcast is effectively done
+	ErrAlreadyInMempool = errors.New("transaction already in mempool")
+
+	// ErrAlreadyInChain means the transaction is already mined
+	ErrAlreadyInChain = errors.New("transaction already in block chain")
+
+	// ErrMissingInputs means an input does not exist or is already spent.
+	// The transaction can never be accepted.
+	ErrMissingInputs = errors.New("transaction inputs missing or spent")
+
+	// ErrFeeTooLow means the fee is below what the node relays. The
+	// transaction must be rebuilt with a higher fee.
+	ErrFeeTooLow = errors.New("transaction fee too low")
+)
+
+// zcashd sendrawtransaction error codes, see rpc/protocol.h
+const (
+	rpcVerifyError          = -25
+	rpcVerifyAlreadyInChain = -27
+)
+
+// broadcastRejections maps fragments of zebrad and zcashd rejection messages
+// to the error they classify as
+var broadcastRejections = []struct {
+	fragment string
+	err      error
+}{
+	{"already in the mempool", ErrAlreadyInMempool},
+	{"already in mempool", ErrAlreadyInMempool},
+	{"txn-already-in-mempool", ErrAlreadyInMempool},
+	{"txn-already-known", ErrAlreadyInMempool},
+	{"already in block chain", ErrAlreadyInChain},
+	{"already in the chain", ErrAlreadyInChain},
+	{"missing inputs", ErrMissingInputs},
+	{"missingorspent", ErrMissingInputs},
+	{"inputs-spent", ErrMissingInputs},
+	{"missing or spent", ErrMissingInputs},
+	{"insufficient fee", ErrFeeTooLow},
+	{"min relay fee not met", ErrFeeTooLow},
+	{"mempool min fee not met", ErrFeeTooLow},
+	{"fee too low", ErrFeeTooLow},
+	{"fee is too low", ErrFeeTooLow},
+}
+
+// classifyBroadcastError wraps a sendrawtransaction RPCError in the matching
+// broadcast rejection, keeping the node's message. Other errors are returned
+// unchanged.
+func classifyBroadcastError(err error) error {
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) {
+		return err
+	}
+	if rpcErr.Code == rpcVerifyAlreadyInChain {
+		return fmt.Errorf("%w: %w", ErrAlreadyInChain, err)
+	}
+
+	message := strings.ToLower(rpcErr.Message)
+	for _, r := range broadcastRejections {
+		if strings.Contains(message, r.fragment) {
+			return fmt.Errorf("%w: %w", r.err, err)
+		}
+	}
+	// zcashd reports missing inputs with this code and no reason
+	if rpcErr.Code == rpcVerifyError && strings.Contains(message, "missing") {
+		return fmt.Errorf("%w: %w", ErrMissingInputs, err)
+	}
+	return err
+}
+
+// NewZebraClient creates a new Zebra RPC client for ZEBRA_HOST and
+// ZEBRA_PORT. The host defaults to localhost and the port to the
+// DefaultRPCPort of net, so a client for mainnet never falls back to the
+// testnet port.
+//
+// Set ZEBRA_RPC_USER and ZEBRA_RPC_PASSWORD for HTTP Basic auth, or
+// ZEBRA_RPC_COOKIE_FILE to authenticate with a cookie file. Set ZEBRA_TLS=1
+// for a node behind TLS, and ZEBRA_TLS_INSECURE=1 to skip certificate
+// verification during development.
+func NewZebraClient(net t2z.Network) *ZebraClient {
+	rpc := rpcConfigFromEnv(os.Getenv)
+	rpc.setDefaults(net)
+	return rpc.NewClient()
+}
+
+// SetTLSConfig sets the TLS configuration for https:// URLs, e.g. to trust
+// a private CA or a self-signed certificate
+func (c *ZebraClient) SetTLSConfig(config *tls.Config) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	c.client.Transport = transport
+}
+
+// SetBasicAuth authenticates RPC calls with HTTP Basic auth
+func (c *ZebraClient) SetBasicAuth(user, password string) {
+	c.user, c.password = user, password
+	c.cookieFile = ""
+}
+
+// SetCookieFile authenticates RPC calls with the credentials in a cookie
+// file, as written by zcashd (and zebrad with cookie auth enabled) in the
+// form "__cookie__:password"
+func (c *ZebraClient) SetCookieFile(path string) {
+	c.cookieFile = path
+	c.user, c.password = "", ""
+}
+
+// credentials returns the Basic auth credentials for the next call
+func (c *ZebraClient) credentials() (user, password string, ok bool, err error) {
+	if c.cookieFile == "" {
+		return c.user, c.password, c.user != "", nil
+	}
+	data, err := os.ReadFile(c.cookieFile)
+	if err != nil {
+		return "", "", false, fmt.Errorf("read cookie file: %w", err)
 	}
-	port := os.Getenv("ZEBRA_PORT")
-	if port == "" {
-		port = "18232"
+	user, password, found := strings.Cut(strings.TrimSpace(string(data)), ":")
+	if !found {
+		return "", "", false, fmt.Errorf("invalid cookie file %s: expected user:password", c.cookieFile)
 	}
+	return user, password, true, nil
+}
 
+// NewZebraClientURL creates a Zebra RPC client for the node at url, which
+// may use http:// or https://
+func NewZebraClientURL(url string) *ZebraClient {
 	return &ZebraClient{
-		url: fmt.Sprintf("http://%s:%s", host, port),
+		url: url,
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		RetryBaseDelay: 500 * time.Millisecond,
 	}
 }
 
-// rawCall makes a raw JSON-RPC call
+// rawCall makes a raw JSON-RPC call, retrying transient failures of
+// RetrySafeMethods with exponential backoff
 func (c *ZebraClient) rawCall(method string, params ...interface{}) (json.RawMessage, error) {
-	c.idCounter++
+	return c.rawCallContext(context.Background(), method, params...)
+}
+
+// rawCallContext is rawCall with a context that cancels the request and any
+// pending retry
+func (c *ZebraClient) rawCallContext(ctx context.Context, method string, params ...interface{}) (json.RawMessage, error) {
+	result, err := c.call(ctx, method, params)
+	if !RetrySafeMethods[method] {
+		return result, err
+	}
+
+	delay := c.RetryBaseDelay
+	for attempt := 0; attempt < c.MaxRetries && isTransient(err); attempt++ {
+		if sleepErr := sleepContext(ctx, delay); sleepErr != nil {
+			return nil, sleepErr
+		}
+		delay *= 2
+		result, err = c.call(ctx, method, params)
+	}
+	return result, err
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isTransient reports whether a failed call may succeed when retried. RPC
+// errors returned by the node are permanent, except while it is warming up.
+func isTransient(err error) bool {
+	if err == nil || errors.Is(err, ErrUnauthorized) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code == rpcInWarmup
+	}
+	return true
+}
+
+// call makes a single JSON-RPC call
+func (c *ZebraClient) call(ctx context.Context, method string, params []interface{}) (json.RawMessage, error) {
+	id := int(c.idCounter.Add(1))
 
 	if params == nil {
 		params = []interface{}{}
@@ -86,7 +323,7 @@ func (c *ZebraClient) rawCall(method string, params ...interface{}) (json.RawMes
 		JSONRPC: "2.0",
 		Method:  method,
 		Params:  params,
-		ID:      c.idCounter,
+		ID:      id,
 	}
 
 	jsonBody, err := json.Marshal(reqBody)
@@ -94,19 +331,31 @@ func (c *ZebraClient) rawCall(method string, params ...interface{}) (json.RawMes
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", c.url, bytes.NewReader(jsonBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.url, bytes.NewReader(jsonBody))
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Connection", "close") // Prevent keep-alive connection issues
 
+	user, password, ok, err := c.credentials()
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		req.SetBasicAuth(user, password)
+	}
+
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("http post: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrUnauthorized
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("read response: %w", err)
@@ -118,7 +367,7 @@ func (c *ZebraClient) rawCall(method string, params ...interface{}) (json.RawMes
 	}
 
 	if rpcResp.Error != nil {
-		return nil, fmt.Errorf("rpc error: %s", rpcResp.Error.Message)
+		return nil, rpcResp.Error
 	}
 
 	return rpcResp.Result, nil
@@ -145,7 +394,12 @@ func (c *ZebraClient) WaitForReady(maxAttempts int, delayMs int) error {
 
 // GetBlockchainInfo returns blockchain info
 func (c *ZebraClient) GetBlockchainInfo() (*BlockchainInfo, error) {
-	result, err := c.rawCall("getblockchaininfo")
+	return c.GetBlockchainInfoContext(context.Background())
+}
+
+// GetBlockchainInfoContext is GetBlockchainInfo with a context
+func (c *ZebraClient) GetBlockchainInfoContext(ctx context.Context) (*BlockchainInfo, error) {
+	result, err := c.rawCallContext(ctx, "getblockchaininfo")
 	if err != nil {
 		return nil, err
 	}
@@ -171,6 +425,22 @@ func (c *ZebraClient) GetBlockCount() (int, error) {
 	return count, nil
 }
 
+// SuggestTargetHeight returns a target height for a new transaction: the
+// current chain height plus lookahead blocks, so that
+// TransactionRequest.SetTargetHeight picks the consensus branch ID of the
+// blocks the transaction can be mined in. A lookahead of 1 targets the next
+// block.
+func (c *ZebraClient) SuggestTargetHeight(lookahead uint32) (uint32, error) {
+	count, err := c.GetBlockCount()
+	if err != nil {
+		return 0, fmt.Errorf("get block count: %w", err)
+	}
+	if count < 0 || uint64(count)+uint64(lookahead) > math.MaxUint32 {
+		return 0, fmt.Errorf("invalid chain height %d", count)
+	}
+	return uint32(count) + lookahead, nil
+}
+
 // GetBlockHash returns the block hash at the given height
 func (c *ZebraClient) GetBlockHash(height int) (string, error) {
 	result, err := c.rawCall("getblockhash", height)
@@ -185,6 +455,38 @@ func (c *ZebraClient) GetBlockHash(height int) (string, error) {
 	return hash, nil
 }
 
+// GetBestBlockHash returns the hash of the tip of the best chain
+func (c *ZebraClient) GetBestBlockHash() (string, error) {
+	result, err := c.rawCall("getbestblockhash")
+	if err != nil {
+		return "", err
+	}
+
+	var hash string
+	if err := json.Unmarshal(result, &hash); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// GetBlockHeader returns the header of the block with the given hash.
+//
+// To detect a reorg, check that the header of a remembered tip still has
+// non-negative confirmations, or follow PreviousBlockHash back from the new
+// tip to the remembered one.
+func (c *ZebraClient) GetBlockHeader(hash string) (*BlockHeader, error) {
+	result, err := c.rawCall("getblockheader", hash, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var header BlockHeader
+	if err := json.Unmarshal(result, &header); err != nil {
+		return nil, fmt.Errorf("unmarshal block header: %w", err)
+	}
+	return &header, nil
+}
+
 // GetBlock returns block data
 func (c *ZebraClient) GetBlock(hash string, verbosity int) (json.RawMessage, error) {
 	result, err := c.rawCall("getblock", hash, verbosity)
@@ -194,19 +496,186 @@ func (c *ZebraClient) GetBlock(hash string, verbosity int) (json.RawMessage, err
 	return result, nil
 }
 
-// SendRawTransaction broadcasts a raw transaction with retry logic
+// GetRawTransaction returns a transaction by txid (display hex order).
+//
+// With verbose false the result is the raw transaction hex as a JSON string;
+// with verbose true it is the decoded transaction object, including
+// confirmations once the transaction is mined. Zebra only finds transactions
+// in the mempool or the best chain.
+func (c *ZebraClient) GetRawTransaction(txid string, verbose bool) (json.RawMessage, error) {
+	return c.GetRawTransactionContext(context.Background(), txid, verbose)
+}
+
+// GetRawTransactionContext is GetRawTransaction with a context
+func (c *ZebraClient) GetRawTransactionContext(ctx context.Context, txid string, verbose bool) (json.RawMessage, error) {
+	verbosity := 0
+	if verbose {
+		verbosity = 1
+	}
+	return c.rawCallContext(ctx, "getrawtransaction", txid, verbosity)
+}
+
+// GetRawMempool returns the txids of the transactions in the mempool
+func (c *ZebraClient) GetRawMempool() ([]string, error) {
+	result, err := c.rawCall("getrawmempool")
+	if err != nil {
+		return nil, err
+	}
+
+	var txids []string
+	if err := json.Unmarshal(result, &txids); err != nil {
+		return nil, fmt.Errorf("unmarshal mempool: %w", err)
+	}
+	return txids, nil
+}
+
+// IsInMempool reports whether the node has accepted txid into its mempool.
+// A mined transaction is no longer in the mempool, so check
+// GetRawTransaction for confirmations if this returns false.
+func (c *ZebraClient) IsInMempool(txid string) (bool, error) {
+	txids, err := c.GetRawMempool()
+	if err != nil {
+		return false, err
+	}
+	for _, id := range txids {
+		if id == txid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// TxOut is an unspent transaction output, as returned by gettxout
+type TxOut struct {
+	BestBlock     string // hash of the tip the output was checked against
+	Confirmations int64  // 0 for an output of a mempool transaction
+	Value         uint64 // zatoshis
+	Script        []byte
+	Coinbase      bool
+}
+
+// txOutJSON is the gettxout result
+type txOutJSON struct {
+	BestBlock     string      `json:"bestblock"`
+	Confirmations int64       `json:"confirmations"`
+	Value         json.Number `json:"value"`
+	ValueZat      *uint64     `json:"valueZat"`
+	ScriptPubKey  struct {
+		Hex string `json:"hex"`
+	} `json:"scriptPubKey"`
+	Coinbase bool `json:"coinbase"`
+}
+
+// GetTxOut returns output vout of transaction txid (display hex order) if it
+// is unspent, or nil if it is spent or does not exist. With includeMempool,
+// outputs spent by a mempool transaction count as spent and outputs created
+// by one are returned.
+//
+// Call it before building a transaction from cached UTXOs, so that an input
+// spent by another wallet is dropped before proving rather than rejected by
+// the node with ErrMissingInputs.
+func (c *ZebraClient) GetTxOut(txid string, vout uint32, includeMempool bool) (*TxOut, error) {
+	result, err := c.rawCall("gettxout", txid, vout, includeMempool)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 || string(result) == "null" {
+		return nil, nil
+	}
+
+	var raw txOutJSON
+	if err := json.Unmarshal(result, &raw); err != nil {
+		return nil, fmt.Errorf("unmarshal txout: %w", err)
+	}
+	script, err := HexToBytes(raw.ScriptPubKey.Hex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid script for %s:%d: %w", txid, vout, err)
+	}
+
+	out := &TxOut{
+		BestBlock:     raw.BestBlock,
+		Confirmations: raw.Confirmations,
+		Script:        script,
+		Coinbase:      raw.Coinbase,
+	}
+	// zcashd also reports the value in zatoshis; otherwise parse the ZEC
+	// amount exactly rather than through a float
+	if raw.ValueZat != nil {
+		out.Value = *raw.ValueZat
+	} else if out.Value, err = ZecToZatoshi(raw.Value.String()); err != nil {
+		return nil, fmt.Errorf("invalid value for %s:%d: %w", txid, vout, err)
+	}
+	return out, nil
+}
+
+// EstimateFee returns the absolute fee in zatoshis, like CalculateFee, for a
+// transaction of the given shape to confirm within blocks blocks. The node's
+// estimatefee rate, in ZEC per 1000 bytes, is applied to the EstimateTxSize
+// of the shape and rounded up.
+//
+// The fee is never below the ZIP-317 fee of the shape, which is also returned
+// when the node has no estimate: Zebra does not implement estimatefee, and
+// zcashd returns -1 without enough data.
+//
+// t2z always pays exactly the ZIP-317 fee (see ProposeTransactionWithOptions),
+// so an estimate above it signals congestion rather than a fee to set.
+func (c *ZebraClient) EstimateFee(blocks, numTransparentInputs, numTransparentOutputs, numOrchardOutputs int) (uint64, error) {
+	minimum := t2z.CalculateFee(numTransparentInputs, numTransparentOutputs, numOrchardOutputs)
+
+	result, err := c.rawCall("estimatefee", blocks)
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) && rpcErr.Code == rpcMethodNotFound {
+		return minimum, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// ZEC per 1000 bytes, or -1 if the node has no estimate
+	var zecPerKB float64
+	if err := json.Unmarshal(result, &zecPerKB); err != nil {
+		return 0, fmt.Errorf("unmarshal fee estimate: %w", err)
+	}
+	if zecPerKB <= 0 {
+		return minimum, nil
+	}
+
+	zatPerKB := uint64(math.Round(zecPerKB * 1e8))
+	size := uint64(t2z.EstimateTxSize(numTransparentInputs, numTransparentOutputs, numOrchardOutputs))
+	return max(minimum, (zatPerKB*size+999)/1000), nil
+}
+
+// SendRawTransaction broadcasts a raw transaction with retry logic.
+//
+// Rejections by the node wrap ErrAlreadyInMempool, ErrAlreadyInChain,
+// ErrMissingInputs or ErrFeeTooLow when recognized, and the RPCError with the
+// node's message in any case. Rejections are not retried.
 func (c *ZebraClient) SendRawTransaction(txHex string) (string, error) {
+	return c.SendRawTransactionContext(context.Background(), txHex)
+}
+
+// broadcastRetryDelay is the delay between SendRawTransaction attempts
+var broadcastRetryDelay = 2 * time.Second
+
+// SendRawTransactionContext is SendRawTransaction with a context, which also
+// stops the retries
+func (c *ZebraClient) SendRawTransactionContext(ctx context.Context, txHex string) (string, error) {
 	maxRetries := 3
 	var lastErr error
 
 	for attempt := 0; attempt < maxRetries; attempt++ {
 		if attempt > 0 {
 			fmt.Printf("   Retry attempt %d/%d...\n", attempt+1, maxRetries)
-			time.Sleep(2 * time.Second)
+			if err := sleepContext(ctx, broadcastRetryDelay); err != nil {
+				return "", err
+			}
 		}
 
-		result, err := c.rawCall("sendrawtransaction", txHex)
+		result, err := c.rawCallContext(ctx, "sendrawtransaction", txHex)
 		if err != nil {
+			if !isTransient(err) {
+				return "", classifyBroadcastError(err)
+			}
 			lastErr = err
 			continue
 		}
@@ -222,6 +691,123 @@ func (c *ZebraClient) SendRawTransaction(txHex string) (string, error) {
 	return "", fmt.Errorf("broadcast failed after %d attempts: %w", maxRetries, lastErr)
 }
 
+// SendTransaction broadcasts a raw transaction, implementing ChainClient
+func (c *ZebraClient) SendTransaction(txHex string) (string, error) {
+	return c.SendRawTransaction(txHex)
+}
+
+// Broadcast broadcasts a raw transaction, implementing t2z.Broadcaster
+func (c *ZebraClient) Broadcast(txHex string) (string, error) {
+	return c.SendRawTransaction(txHex)
+}
+
+// GetAddressUtxos returns the unspent outputs for transparent addresses
+// using the getaddressutxos RPC. TxIDs are converted from the RPC's display
+// order to the internal byte order t2z expects.
+func (c *ZebraClient) GetAddressUtxos(addresses []string) ([]AddressUtxo, error) {
+	return c.GetAddressUtxosContext(context.Background(), addresses)
+}
+
+// GetAddressUtxosContext is GetAddressUtxos with a context
+func (c *ZebraClient) GetAddressUtxosContext(ctx context.Context, addresses []string) ([]AddressUtxo, error) {
+	raw, err := c.getAddressUtxos(ctx, addresses)
+	if err != nil {
+		return nil, err
+	}
+	return convertAddressUtxos(raw)
+}
+
+// GetAddressUtxosPaged returns up to limit of the unspent outputs for
+// transparent addresses, skipping the first offset, and whether there are
+// more after them.
+//
+// Zebra's getaddressutxos has no paging, so the node still sends the whole
+// set; only the requested page is converted and kept. UTXOs are ordered by
+// height, txid and output index, so pages are stable while no blocks arrive.
+func (c *ZebraClient) GetAddressUtxosPaged(addresses []string, offset, limit int) ([]AddressUtxo, bool, error) {
+	return c.GetAddressUtxosPagedContext(context.Background(), addresses, offset, limit)
+}
+
+// GetAddressUtxosPagedContext is GetAddressUtxosPaged with a context
+func (c *ZebraClient) GetAddressUtxosPagedContext(ctx context.Context, addresses []string, offset, limit int) ([]AddressUtxo, bool, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, false, fmt.Errorf("invalid page: offset %d, limit %d", offset, limit)
+	}
+
+	raw, err := c.getAddressUtxos(ctx, addresses)
+	if err != nil {
+		return nil, false, err
+	}
+	slices.SortFunc(raw, func(a, b addressUtxoJSON) int {
+		return cmp.Or(
+			cmp.Compare(a.Height, b.Height),
+			strings.Compare(a.Txid, b.Txid),
+			cmp.Compare(a.OutputIndex, b.OutputIndex),
+		)
+	})
+
+	start := min(offset, len(raw))
+	end := min(start+limit, len(raw))
+	utxos, err := convertAddressUtxos(raw[start:end])
+	if err != nil {
+		return nil, false, err
+	}
+	return utxos, end < len(raw), nil
+}
+
+// addressUtxoJSON is an entry of the getaddressutxos result
+type addressUtxoJSON struct {
+	Address     string `json:"address"`
+	Txid        string `json:"txid"`
+	OutputIndex uint32 `json:"outputIndex"`
+	Script      string `json:"script"`
+	Satoshis    uint64 `json:"satoshis"`
+	Height      int    `json:"height"`
+}
+
+// getAddressUtxos calls getaddressutxos for addresses
+func (c *ZebraClient) getAddressUtxos(ctx context.Context, addresses []string) ([]addressUtxoJSON, error) {
+	result, err := c.rawCallContext(ctx, "getaddressutxos", map[string]interface{}{
+		"addresses": addresses,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []addressUtxoJSON
+	if err := json.Unmarshal(result, &raw); err != nil {
+		return nil, fmt.Errorf("unmarshal address utxos: %w", err)
+	}
+	return raw, nil
+}
+
+// convertAddressUtxos decodes getaddressutxos entries, reversing the txids
+// to internal byte order
+func convertAddressUtxos(raw []addressUtxoJSON) ([]AddressUtxo, error) {
+	utxos := make([]AddressUtxo, 0, len(raw))
+	for _, r := range raw {
+		txidBytes, err := HexToBytes(r.Txid)
+		if err != nil || len(txidBytes) != 32 {
+			return nil, fmt.Errorf("invalid txid %q", r.Txid)
+		}
+		script, err := HexToBytes(r.Script)
+		if err != nil {
+			return nil, fmt.Errorf("invalid script for %s:%d: %w", r.Txid, r.OutputIndex, err)
+		}
+
+		utxo := AddressUtxo{
+			Address:     r.Address,
+			OutputIndex: r.OutputIndex,
+			Script:      script,
+			Value:       r.Satoshis,
+			Height:      r.Height,
+		}
+		copy(utxo.TxID[:], ReverseBytes(txidBytes))
+		utxos = append(utxos, utxo)
+	}
+	return utxos, nil
+}
+
 // WaitForBlocks waits until the specified block height is reached
 func (c *ZebraClient) WaitForBlocks(targetHeight int, timeoutMs int) (int, error) {
 	if timeoutMs == 0 {
@@ -254,3 +840,49 @@ func (c *ZebraClient) WaitForBlocks(targetHeight int, timeoutMs int) (int, error
 		time.Sleep(1 * time.Second)
 	}
 }
+
+// confirmationPollInterval is the delay between WaitForConfirmation polls
+var confirmationPollInterval = time.Second
+
+// WaitForConfirmation polls getrawtransaction until txid has at least
+// minConfs confirmations and returns the confirmation count.
+//
+// Unlike WaitForBlocks this confirms the transaction itself was mined. A
+// transaction that is not found (evicted, or reorged out and not yet back in
+// the mempool) keeps being polled until the timeout.
+func (c *ZebraClient) WaitForConfirmation(txid string, minConfs int, timeoutMs int) (int, error) {
+	if timeoutMs == 0 {
+		timeoutMs = 120000
+	}
+	if minConfs < 1 {
+		minConfs = 1
+	}
+
+	startTime := time.Now()
+	confirmations := 0
+
+	for {
+		result, err := c.GetRawTransaction(txid, true)
+		if err == nil {
+			var tx struct {
+				Confirmations int `json:"confirmations"`
+			}
+			if err := json.Unmarshal(result, &tx); err != nil {
+				return 0, fmt.Errorf("unmarshal transaction: %w", err)
+			}
+			confirmations = tx.Confirmations
+			if confirmations >= minConfs {
+				return confirmations, nil
+			}
+		}
+
+		if time.Since(startTime) > time.Duration(timeoutMs)*time.Millisecond {
+			if err != nil {
+				return confirmations, fmt.Errorf("timeout waiting for %d confirmations of %s: %w", minConfs, txid, err)
+			}
+			return confirmations, fmt.Errorf("timeout waiting for %d confirmations of %s (have %d)", minConfs, txid, confirmations)
+		}
+
+		time.Sleep(confirmationPollInterval)
+	}
+}
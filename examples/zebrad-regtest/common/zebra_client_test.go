@@ -0,0 +1,523 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test that sendrawtransaction rejections are classified and not retried
+func TestSendRawTransactionRejections(t *testing.T) {
+	tests := []struct {
+		code    int
+		message string
+		want    error
+	}{
+		{-26, "txn-already-in-mempool", ErrAlreadyInMempool},
+		{-25, "transaction is already in the mempool", ErrAlreadyInMempool},
+		{-27, "transaction already in block chain", ErrAlreadyInChain},
+		{-25, "Missing inputs", ErrMissingInputs},
+		{-26, "bad-txns-inputs-spent", ErrMissingInputs},
+		{-26, "18: bad-txns-inputs-missingorspent", ErrMissingInputs},
+		{-26, "66: min relay fee not met", ErrFeeTooLow},
+		{-26, "insufficient fee", ErrFeeTooLow},
+		{-26, "16: bad-txns-oversize", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				fmt.Fprintf(w, `{"result":null,"error":{"code":%d,"message":%q},"id":1}`, tt.code, tt.message)
+			}))
+			defer server.Close()
+
+			_, err := NewZebraClientURL(server.URL).SendRawTransaction("00")
+			if err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+			if calls != 1 {
+				t.Errorf("Expected 1 call, got %d", calls)
+			}
+
+			var rpcErr *RPCError
+			if !errors.As(err, &rpcErr) || rpcErr.Message != tt.message {
+				t.Errorf("Expected the node's RPCError to be wrapped, got %v", err)
+			}
+			for _, kind := range []error{ErrAlreadyInMempool, ErrAlreadyInChain, ErrMissingInputs, ErrFeeTooLow} {
+				if got := errors.Is(err, kind); got != (kind == tt.want) {
+					t.Errorf("errors.Is(err, %v) = %v for %v", kind, got, err)
+				}
+			}
+		})
+	}
+}
+
+// Test that a successful broadcast returns the txid
+func TestSendRawTransaction(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "sendrawtransaction" {
+			t.Errorf("Unexpected request %+v: %v", req, err)
+		}
+		fmt.Fprint(w, `{"result":"`+strings.Repeat("ab", 32)+`","error":null,"id":1}`)
+	}))
+	defer server.Close()
+
+	txid, err := NewZebraClientURL(server.URL).Broadcast("00")
+	if err != nil || txid != strings.Repeat("ab", 32) {
+		t.Errorf("Broadcast = %q, %v", txid, err)
+	}
+}
+
+// Test GetBestBlockHash and GetBlockHeader against canned node responses
+func TestGetBlockHeader(t *testing.T) {
+	tip := strings.Repeat("0a", 32)
+	parent := strings.Repeat("0b", 32)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		switch req.Method {
+		case "getbestblockhash":
+			fmt.Fprintf(w, `{"result":%q,"error":null,"id":1}`, tip)
+		case "getblockheader":
+			if len(req.Params) != 2 || req.Params[0] != tip || req.Params[1] != true {
+				t.Errorf("Unexpected getblockheader params %v", req.Params)
+			}
+			fmt.Fprintf(w, `{"result":{"hash":%q,"confirmations":1,"height":120,"version":4,"merkleroot":%q,"time":1700000000,"bits":"200a3d70","previousblockhash":%q},"error":null,"id":1}`,
+				tip, strings.Repeat("0c", 32), parent)
+		default:
+			t.Errorf("Unexpected method %s", req.Method)
+		}
+	}))
+	defer server.Close()
+
+	client := NewZebraClientURL(server.URL)
+	hash, err := client.GetBestBlockHash()
+	if err != nil || hash != tip {
+		t.Fatalf("GetBestBlockHash = %q, %v", hash, err)
+	}
+
+	header, err := client.GetBlockHeader(hash)
+	if err != nil {
+		t.Fatalf("GetBlockHeader failed: %v", err)
+	}
+	if header.Height != 120 || header.PreviousBlockHash != parent || header.Time != 1700000000 || header.MerkleRoot != strings.Repeat("0c", 32) {
+		t.Errorf("Unexpected header %+v", header)
+	}
+	if header.NextBlockHash != "" {
+		t.Errorf("Expected no next block for the tip, got %s", header.NextBlockHash)
+	}
+}
+
+// Test that the suggested target height is the chain height plus the lookahead
+func TestSuggestTargetHeight(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "getblockcount" {
+			t.Errorf("Unexpected request %+v: %v", req, err)
+		}
+		fmt.Fprint(w, `{"result":2700000,"error":null,"id":1}`)
+	}))
+	defer server.Close()
+
+	height, err := NewZebraClientURL(server.URL).SuggestTargetHeight(10)
+	if err != nil || height != 2_700_010 {
+		t.Errorf("SuggestTargetHeight = %d, %v", height, err)
+	}
+}
+
+// Test paging through the UTXOs of several addresses in a stable order
+func TestGetAddressUtxosPaged(t *testing.T) {
+	type utxo struct {
+		Address     string `json:"address"`
+		Txid        string `json:"txid"`
+		OutputIndex uint32 `json:"outputIndex"`
+		Script      string `json:"script"`
+		Satoshis    uint64 `json:"satoshis"`
+		Height      int    `json:"height"`
+	}
+	txid := func(b string) string { return strings.Repeat(b, 32) }
+	node := []utxo{
+		{"tmA", txid("03"), 0, "76", 300, 12},
+		{"tmB", txid("01"), 1, "76", 101, 10},
+		{"tmA", txid("02"), 0, "76", 200, 11},
+		{"tmB", txid("01"), 0, "76", 100, 10},
+		{"tmB", txid("04"), 0, "76", 400, 13},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, _ := json.Marshal(node)
+		fmt.Fprintf(w, `{"result":%s,"error":null,"id":1}`, result)
+	}))
+	defer server.Close()
+	client := NewZebraClientURL(server.URL)
+
+	var values []uint64
+	for offset := 0; ; offset += 2 {
+		page, more, err := client.GetAddressUtxosPaged([]string{"tmA", "tmB"}, offset, 2)
+		if err != nil {
+			t.Fatalf("GetAddressUtxosPaged(%d) failed: %v", offset, err)
+		}
+		for _, u := range page {
+			values = append(values, u.Value)
+		}
+		if !more {
+			break
+		}
+	}
+	if want := []uint64{100, 101, 200, 300, 400}; !slices.Equal(values, want) {
+		t.Errorf("Paged values = %v, want %v", values, want)
+	}
+
+	page, more, err := client.GetAddressUtxosPaged([]string{"tmA", "tmB"}, 4, 10)
+	if err != nil || more || len(page) != 1 {
+		t.Fatalf("Last page = %d UTXOs, more %v, %v", len(page), more, err)
+	}
+	// TxIDs are returned in internal byte order
+	if page[0].TxID != [32]byte(bytes.Repeat([]byte{0x04}, 32)) || page[0].Address != "tmB" {
+		t.Errorf("Unexpected UTXO %+v", page[0])
+	}
+
+	if page, more, err := client.GetAddressUtxosPaged([]string{"tmA"}, 10, 2); err != nil || more || len(page) != 0 {
+		t.Errorf("Page past the end = %d UTXOs, more %v, %v", len(page), more, err)
+	}
+	if _, _, err := client.GetAddressUtxosPaged([]string{"tmA"}, 0, 0); err == nil {
+		t.Error("Expected error for zero limit, got nil")
+	}
+}
+
+// Test that GetTxOut decodes unspent outputs and returns nil for spent ones
+func TestGetTxOut(t *testing.T) {
+	txid := strings.Repeat("ab", 32)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "gettxout" || len(req.Params) != 3 || req.Params[0] != txid || req.Params[2] != true {
+			t.Errorf("Unexpected request %+v: %v", req, err)
+		}
+		switch req.Params[1] {
+		case 0.0:
+			fmt.Fprint(w, `{"result":{"bestblock":"00ff","confirmations":3,"value":0.29000000,"scriptPubKey":{"hex":"76a914`+strings.Repeat("00", 20)+`88ac"},"coinbase":true},"error":null,"id":1}`)
+		case 1.0:
+			fmt.Fprint(w, `{"result":{"bestblock":"00ff","confirmations":0,"value":1.5,"valueZat":150000000,"scriptPubKey":{"hex":""}},"error":null,"id":1}`)
+		default:
+			fmt.Fprint(w, `{"result":null,"error":null,"id":1}`)
+		}
+	}))
+	defer server.Close()
+	client := NewZebraClientURL(server.URL)
+
+	out, err := client.GetTxOut(txid, 0, true)
+	if err != nil || out == nil {
+		t.Fatalf("GetTxOut = %v, %v", out, err)
+	}
+	if out.Value != 29_000_000 || out.Confirmations != 3 || !out.Coinbase || len(out.Script) != 25 || out.BestBlock != "00ff" {
+		t.Errorf("Unexpected txout %+v", out)
+	}
+
+	if out, err := client.GetTxOut(txid, 1, true); err != nil || out == nil || out.Value != 150_000_000 || out.Confirmations != 0 {
+		t.Errorf("GetTxOut of a mempool output = %+v, %v", out, err)
+	}
+
+	if out, err := client.GetTxOut(txid, 2, true); err != nil || out != nil {
+		t.Errorf("Expected nil for a spent output, got %+v, %v", out, err)
+	}
+}
+
+// Test that read-only calls are retried after transient failures, and that
+// broadcasts and permanent errors are not
+func TestRawCallRetry(t *testing.T) {
+	// failures is how often the next requests fail before one succeeds;
+	// abort drops the connection instead of returning a warmup error
+	var calls, failures int
+	var abort bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= failures {
+			if abort {
+				panic(http.ErrAbortHandler)
+			}
+			fmt.Fprint(w, `{"result":null,"error":{"code":-28,"message":"Loading block index..."},"id":1}`)
+			return
+		}
+		fmt.Fprint(w, `{"result":2700000,"error":null,"id":1}`)
+	}))
+	defer server.Close()
+
+	client := NewZebraClientURL(server.URL)
+	client.MaxRetries = 3
+	client.RetryBaseDelay = time.Millisecond
+
+	tests := []struct {
+		name      string
+		method    string
+		failures  int
+		abort     bool
+		wantCalls int
+		wantErr   bool
+	}{
+		{"warmup", "getblockcount", 2, false, 3, false},
+		{"connection dropped", "getblockcount", 1, true, 2, false},
+		{"retries exhausted", "getblockcount", 10, false, 4, true},
+		{"broadcast not retried", "sendrawtransaction", 1, false, 1, true},
+		{"unknown method not retried", "z_sendmany", 1, true, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, failures, abort = 0, tt.failures, tt.abort
+			_, err := client.rawCall(tt.method)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("rawCall(%s) error = %v, want error %v", tt.method, err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("rawCall(%s) made %d calls, want %d", tt.method, calls, tt.wantCalls)
+			}
+		})
+	}
+
+	// RPC errors other than warmup are permanent
+	if isTransient(&RPCError{Code: -8, Message: "Block height out of range"}) || isTransient(ErrUnauthorized) || isTransient(context.Canceled) {
+		t.Error("Expected permanent errors not to be transient")
+	}
+
+	// A cancelled context stops the retries
+	calls, failures, abort = 0, 10, false
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.rawCallContext(ctx, "getblockcount"); err == nil {
+		t.Error("Expected error for a cancelled context, got nil")
+	}
+	if calls > 1 {
+		t.Errorf("Expected no retries after cancellation, got %d calls", calls)
+	}
+}
+
+// Test that SendRawTransaction makes up to 3 attempts on transient failures
+func TestSendRawTransactionRetry(t *testing.T) {
+	delay := broadcastRetryDelay
+	broadcastRetryDelay = time.Millisecond
+	t.Cleanup(func() { broadcastRetryDelay = delay })
+
+	var calls, failures int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= failures {
+			panic(http.ErrAbortHandler)
+		}
+		fmt.Fprint(w, `{"result":"`+strings.Repeat("ab", 32)+`","error":null,"id":1}`)
+	}))
+	defer server.Close()
+	client := NewZebraClientURL(server.URL)
+	client.MaxRetries = 3
+
+	failures = 2
+	txid, err := client.SendRawTransaction("00")
+	if err != nil || txid != strings.Repeat("ab", 32) || calls != 3 {
+		t.Errorf("SendRawTransaction = %q, %v after %d calls, want success on the third", txid, err, calls)
+	}
+
+	calls, failures = 0, 10
+	if _, err := client.SendRawTransaction("00"); err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("Expected failure after 3 attempts, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls)
+	}
+}
+
+// Test each mapping of classifyBroadcastError
+func TestClassifyBroadcastError(t *testing.T) {
+	for _, r := range broadcastRejections {
+		err := classifyBroadcastError(&RPCError{Code: -26, Message: "16: " + strings.ToUpper(r.fragment)})
+		if !errors.Is(err, r.err) {
+			t.Errorf("%q: expected %v, got %v", r.fragment, r.err, err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"already in chain by code", &RPCError{Code: rpcVerifyAlreadyInChain, Message: "transaction already exists"}, ErrAlreadyInChain},
+		{"zcashd missing inputs", &RPCError{Code: rpcVerifyError, Message: "Missing"}, ErrMissingInputs},
+		{"missing with another code", &RPCError{Code: -26, Message: "missing"}, nil},
+		{"unknown rejection", &RPCError{Code: -26, Message: "16: bad-txns-oversize"}, nil},
+		{"not an RPC error", errors.New("http post: connection refused"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyBroadcastError(tt.err)
+			for _, kind := range []error{ErrAlreadyInMempool, ErrAlreadyInChain, ErrMissingInputs, ErrFeeTooLow} {
+				if got := errors.Is(err, kind); got != (kind == tt.want) {
+					t.Errorf("errors.Is(err, %v) = %v for %v", kind, got, err)
+				}
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Expected %v to wrap %v", err, tt.err)
+			}
+		})
+	}
+}
+
+// Test that calls carry Basic auth credentials, and that a cookie file is
+// read again on each call so a node restart does not break the client
+func TestZebraClientAuth(t *testing.T) {
+	var user, password string
+	var hasAuth bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, hasAuth = r.BasicAuth()
+		if password == "stale" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"result":2700000,"error":null,"id":1}`)
+	}))
+	defer server.Close()
+	client := NewZebraClientURL(server.URL)
+
+	if _, err := client.GetBlockCount(); err != nil || hasAuth {
+		t.Errorf("Expected no Authorization header by default, got %v (%v)", hasAuth, err)
+	}
+
+	client.SetBasicAuth("zebra", "secret")
+	if _, err := client.GetBlockCount(); err != nil || user != "zebra" || password != "secret" {
+		t.Errorf("Basic auth = %q:%q, %v", user, password, err)
+	}
+
+	cookie := filepath.Join(t.TempDir(), ".cookie")
+	if err := os.WriteFile(cookie, []byte("__cookie__:first\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	client.SetCookieFile(cookie)
+	if _, err := client.GetBlockCount(); err != nil || user != "__cookie__" || password != "first" {
+		t.Errorf("Cookie auth = %q:%q, %v", user, password, err)
+	}
+
+	// The node rewrites the cookie on restart
+	if err := os.WriteFile(cookie, []byte("__cookie__:second"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetBlockCount(); err != nil || password != "second" {
+		t.Errorf("Expected the rewritten cookie to be used, got %q:%q, %v", user, password, err)
+	}
+
+	// Rejected credentials are reported as ErrUnauthorized
+	if err := os.WriteFile(cookie, []byte("__cookie__:stale"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetBlockCount(); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized, got %v", err)
+	}
+
+	if err := os.WriteFile(cookie, []byte("no separator"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetBlockCount(); err == nil || !strings.Contains(err.Error(), "invalid cookie file") {
+		t.Errorf("Expected an invalid cookie file error, got %v", err)
+	}
+	client.SetCookieFile(filepath.Join(t.TempDir(), "missing"))
+	if _, err := client.GetBlockCount(); err == nil || !strings.Contains(err.Error(), "read cookie file") {
+		t.Errorf("Expected a missing cookie file error, got %v", err)
+	}
+}
+
+// Test that WaitForConfirmation polls until the transaction has enough
+// confirmations, keeps polling while it is not found, and times out
+func TestWaitForConfirmation(t *testing.T) {
+	interval := confirmationPollInterval
+	confirmationPollInterval = time.Millisecond
+	t.Cleanup(func() { confirmationPollInterval = interval })
+
+	txid := strings.Repeat("ab", 32)
+	polls := 0
+	missing := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "getrawtransaction" || req.Params[0] != txid {
+			t.Errorf("Unexpected request %+v: %v", req, err)
+		}
+		polls++
+		switch {
+		case polls == 1 || missing:
+			// Not yet relayed to this node
+			fmt.Fprint(w, `{"result":null,"error":{"code":-5,"message":"No such mempool or main chain transaction"},"id":1}`)
+		case polls < 4:
+			fmt.Fprint(w, `{"result":{"txid":"`+txid+`"},"error":null,"id":1}`)
+		default:
+			fmt.Fprintf(w, `{"result":{"txid":"%s","confirmations":%d},"error":null,"id":1}`, txid, polls-3)
+		}
+	}))
+	defer server.Close()
+	client := NewZebraClientURL(server.URL)
+
+	confirmations, err := client.WaitForConfirmation(txid, 2, 10_000)
+	if err != nil || confirmations != 2 || polls != 5 {
+		t.Errorf("WaitForConfirmation = %d, %v after %d polls, want 2 confirmations after 5", confirmations, err, polls)
+	}
+
+	// A transaction that stays unconfirmed times out with its count
+	polls = 1
+	confirmations, err = client.WaitForConfirmation(txid, 1, 1)
+	if err == nil || !strings.Contains(err.Error(), "timeout") || confirmations != 0 {
+		t.Errorf("Expected a timeout with 0 confirmations, got %d, %v", confirmations, err)
+	}
+
+	// A transaction that is never found times out with the node's error
+	missing = true
+	_, err = client.WaitForConfirmation(txid, 1, 1)
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("Expected a timeout wrapping the RPC error, got %v", err)
+	}
+}
+
+// Test that EstimateFee applies the node's rate to the size of a transaction
+// with one input and two transparent outputs (241 bytes), never returns less
+// than its ZIP-317 fee, and falls back to that fee when the node has no
+// estimate or does not implement estimatefee
+func TestEstimateFee(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     uint64
+		wantErr  bool
+	}{
+		{"estimate", `{"result":0.0005,"error":null,"id":1}`, 12_050, false},
+		{"rounded up", `{"result":0.00123457,"error":null,"id":1}`, 29_754, false},
+		{"below minimum", `{"result":0.00001,"error":null,"id":1}`, 10_000, false},
+		{"no estimate", `{"result":-1,"error":null,"id":1}`, 10_000, false},
+		{"method not found", `{"result":null,"error":{"code":-32601,"message":"Method not found"},"id":1}`, 10_000, false},
+		{"other RPC error", `{"result":null,"error":{"code":-8,"message":"Invalid parameter"},"id":1}`, 0, true},
+		{"malformed", `{"result":"fast","error":null,"id":1}`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req rpcRequest
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Method != "estimatefee" || len(req.Params) != 1 || req.Params[0] != 6.0 {
+					t.Errorf("Unexpected request %+v: %v", req, err)
+				}
+				fmt.Fprint(w, tt.response)
+			}))
+			defer server.Close()
+
+			fee, err := NewZebraClientURL(server.URL).EstimateFee(6, 1, 2, 0)
+			if (err != nil) != tt.wantErr || fee != tt.want {
+				t.Errorf("EstimateFee = %d, %v, want %d (error %v)", fee, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"strings"
 	"time"
 
+	t2z "github.com/gstohl/t2z/go"
 	"github.com/gstohl/t2z/go/examples/zebrad-regtest/common"
 )
 
@@ -26,11 +27,11 @@ func main() {
 	// Ensure data directory exists
 	os.MkdirAll(common.GetDataDir(), 0755)
 
-	// Clear spent UTXOs tracker from previous runs
-	common.ClearSpentUtxos()
-	fmt.Println("Cleared spent UTXO tracker\n")
+	// Clear the UTXO cache and spent UTXOs tracker from previous runs
+	common.DefaultUTXOCache().Clear()
+	fmt.Println("Cleared UTXO cache and spent UTXO tracker\n")
 
-	client := common.NewZebraClient()
+	client := common.NewZebraClient(t2z.NetworkRegtest)
 
 	// Wait for Zebra to be ready
 	fmt.Println("Waiting for Zebra...")
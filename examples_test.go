@@ -1,3 +1,5 @@
+//go:build (cgo && (darwin || linux || windows) && (amd64 || arm64)) || t2z_mock
+
 package t2z
 
 import (
@@ -18,7 +20,7 @@ func ExampleNewTransactionRequest() {
 
 	payments := []Payment{
 		{
-			Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma",
+			Address: "u1eq7cm60un363n2sa862w4t5pq56tl5x0d7wqkzhhva0sxue7kqw85haa6w6xsz8n8ujmcpkzsza8knwgglau443s7ljdgu897yrvyhhz",
 			Amount:  paymentAmount,
 			Memo:    "Example payment",
 		},
@@ -86,50 +88,6 @@ func ExampleProposeTransaction() {
 	// Output: PCZT created successfully
 }
 
-// ExampleSerializePCZT demonstrates serializing a PCZT for transmission or storage.
-// Follows TypeScript patterns.
-func ExampleSerializePCZT() {
-	// Match TypeScript amounts
-	inputAmount := uint64(100_000_000) // 1 ZEC
-	paymentAmount := inputAmount / 2   // 50%
-
-	payments := []Payment{
-		{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: paymentAmount},
-	}
-	request, _ := NewTransactionRequest(payments)
-	defer request.Free()
-
-	// Mainnet is the default
-	request.SetTargetHeight(2_500_000)
-
-	privateKeyBytes := make([]byte, 32)
-	for i := range privateKeyBytes {
-		privateKeyBytes[i] = 1
-	}
-	privKey := secp256k1.PrivKeyFromBytes(privateKeyBytes)
-	pubKeyBytes := privKey.PubKey().SerializeCompressed()
-	scriptPubKey, _ := hex.DecodeString("76a91479b000887626b294a914501a4cd226b58b23598388ac")
-
-	var txid [32]byte
-	inputs := []TransparentInput{
-		{Pubkey: pubKeyBytes, TxID: txid, Vout: 0, Amount: inputAmount, ScriptPubKey: scriptPubKey},
-	}
-
-	pczt, _ := ProposeTransaction(inputs, request)
-
-	// Serialize PCZT (does not consume it)
-	pcztBytes, err := SerializePCZT(pczt)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// Free after serialization
-	pczt.Free()
-
-	fmt.Printf("Serialized PCZT: %d bytes\n", len(pcztBytes))
-	// Output: Serialized PCZT: 367 bytes
-}
-
 // ExampleParsePCZT demonstrates parsing a serialized PCZT.
 // Follows TypeScript patterns.
 func ExampleParsePCZT() {
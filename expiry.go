@@ -0,0 +1,78 @@
+package t2z
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// MaxExpiryHeight is the largest expiry height a transaction may have, see
+// ZIP 203
+const MaxExpiryHeight uint32 = 499_999_999
+
+// SetExpiryHeight overrides the expiry height that the native library
+// derives from the target height (see ExpiryHeight). The height must be
+// between 1 and MaxExpiryHeight, and proposing fails if it is below the
+// target height.
+//
+// Like SetConsensusBranchID, the override is applied after proposal, so it
+// cannot be applied to a transaction with Orchard outputs, whose dummy
+// spends are signed during proposal. Proposing such a request returns an
+// error wrapping ErrNotImplemented.
+func (r *TransactionRequest) SetExpiryHeight(height uint32) error {
+	if r == nil || r.handle == nil {
+		return errors.New("invalid transaction request")
+	}
+	if height == 0 || height > MaxExpiryHeight {
+		return fmt.Errorf("invalid expiry height %d", height)
+	}
+
+	r.expiryHeight = height
+	return nil
+}
+
+// checkExpiryHeight checks the expiry height override against the target height
+func (r *TransactionRequest) checkExpiryHeight() error {
+	if r.expiryHeight != 0 && r.expiryHeight < r.targetHeight {
+		return fmt.Errorf("expiry height %d is below target height %d", r.expiryHeight, r.targetHeight)
+	}
+	return nil
+}
+
+// withExpiryHeight returns a PCZT equal to pczt but with the given expiry
+// height. The input PCZT is consumed.
+func withExpiryHeight(pczt *PCZT, height uint32) (*PCZT, error) {
+	defer pczt.Free()
+
+	data, err := SerializePCZT(pczt)
+	if err != nil {
+		return nil, err
+	}
+	p, err := decodePCZT(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.OrchardActions) > 0 {
+		return nil, fmt.Errorf("custom expiry height: Orchard actions are signed during proposal with the derived expiry height: %w", ErrNotImplemented)
+	}
+
+	// The expiry height follows the branch ID and optional fallback lock time
+	r := &pcztReader{buf: data, off: len(pcztMagic) + 4}
+	r.uint32("tx version")
+	r.uint32("version group ID")
+	r.uint32("consensus branch ID")
+	if r.option("fallback lock time") {
+		r.uint32("fallback lock time")
+	}
+	start := r.off
+	r.uint32("expiry height")
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	patched := make([]byte, 0, len(data)+binary.MaxVarintLen32)
+	patched = append(patched, data[:start]...)
+	patched = binary.AppendUvarint(patched, uint64(height))
+	patched = append(patched, data[r.off:]...)
+	return ParsePCZT(patched)
+}
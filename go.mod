@@ -2,4 +2,7 @@ module github.com/gstohl/t2z-go
 
 go 1.24.0
 
-require github.com/decred/dcrd/dcrec/secp256k1/v4 v4.0.1
+require (
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.0.1
+	golang.org/x/crypto v0.45.0
+)
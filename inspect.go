@@ -0,0 +1,535 @@
+package t2z
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Serialized PCZT header: magic bytes followed by a little-endian format
+// version. The rest is the postcard encoding of the PCZT roles' data.
+var pcztMagic = []byte("PCZT")
+
+const pcztFormatVersion = 1
+
+// pcztInput is the public part of a transparent input of a PCZT
+type pcztInput struct {
+	PrevTxID  [32]byte
+	PrevIndex uint32
+	// Sequence and the required lock times are nil when unset
+	Sequence               *uint32
+	RequiredTimeLockTime   *uint32
+	RequiredHeightLockTime *uint32
+	Value                  uint64
+	ScriptPubKey           []byte
+	RedeemScript           []byte
+	PartialSignatures      map[string][]byte // keyed by 33-byte pubkey
+	SighashType            byte
+	// ScriptSig is set once the spend finalizer has run
+	ScriptSig []byte
+	// Hash160Preimages maps 20-byte hashes to their preimages (for P2PKH
+	// inputs, the pubkey)
+	Hash160Preimages map[string][]byte
+
+	// signaturesOffset is the offset of the partial signature count in the
+	// serialized PCZT
+	signaturesOffset int
+}
+
+// Signed reports whether the input has a signature or has been finalized
+func (in *pcztInput) Signed() bool {
+	return len(in.PartialSignatures) > 0 || in.ScriptSig != nil
+}
+
+// Pubkey returns the public key of a P2PKH input, which the PCZT keeps as
+// the HASH160 preimage of the input's script
+func (in *pcztInput) Pubkey() ([]byte, bool) {
+	if !isP2PKHScript(in.ScriptPubKey) {
+		return nil, false
+	}
+	pubkey, ok := in.Hash160Preimages[string(in.ScriptPubKey[3:23])]
+	return pubkey, ok && len(pubkey) == 33
+}
+
+// pcztOutput is the public part of a transparent output of a PCZT
+type pcztOutput struct {
+	Value        uint64
+	ScriptPubKey []byte
+	RedeemScript []byte
+	UserAddress  string
+}
+
+// pcztAction is the public part of an Orchard action of a PCZT: the fields
+// that go into the transaction, and whether the spend is authorized
+type pcztAction struct {
+	CvNet         []byte
+	Nullifier     []byte
+	Rk            []byte
+	SpendAuthSig  bool
+	Cmx           []byte
+	EphemeralKey  []byte
+	EncCiphertext []byte
+	OutCiphertext []byte
+}
+
+// equal reports whether a and b are the same action with the same
+// authorization
+func (a pcztAction) equal(b pcztAction) bool {
+	return a.SpendAuthSig == b.SpendAuthSig &&
+		bytes.Equal(a.CvNet, b.CvNet) &&
+		bytes.Equal(a.Nullifier, b.Nullifier) &&
+		bytes.Equal(a.Rk, b.Rk) &&
+		bytes.Equal(a.Cmx, b.Cmx) &&
+		bytes.Equal(a.EphemeralKey, b.EphemeralKey) &&
+		bytes.Equal(a.EncCiphertext, b.EncCiphertext) &&
+		bytes.Equal(a.OutCiphertext, b.OutCiphertext)
+}
+
+// pcztContents is a decoded PCZT. Only fields needed for inspection are
+// kept; secrets (note randomness, dummy spending keys, blinding factors) are
+// read past and discarded.
+type pcztContents struct {
+	TxVersion         uint32
+	VersionGroupID    uint32
+	ConsensusBranchID uint32
+	FallbackLockTime  *uint32 // nil when unset
+	ExpiryHeight      uint32
+	CoinType          uint32
+	TxModifiable      byte
+
+	Inputs  []pcztInput
+	Outputs []pcztOutput
+
+	OrchardActions      []pcztAction
+	OrchardFlags        byte
+	OrchardValueBalance int64
+	OrchardProof        []byte // nil until ProveTransaction
+}
+
+// pcztReader reads postcard-encoded fields, remembering the first error
+type pcztReader struct {
+	buf []byte
+	off int
+	err error
+}
+
+func (r *pcztReader) read(n int, what string) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.buf)-r.off < n {
+		r.err = fmt.Errorf("PCZT too short for %s", what)
+		return nil
+	}
+	b := r.buf[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *pcztReader) byte(what string) byte {
+	b := r.read(1, what)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+// varint reads an unsigned LEB128 integer of at most 64 bits
+func (r *pcztReader) varint(what string) uint64 {
+	var v uint64
+	for shift := uint(0); shift < 70; shift += 7 {
+		b := r.byte(what)
+		if r.err != nil {
+			return 0
+		}
+		v |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return v
+		}
+	}
+	r.err = fmt.Errorf("invalid varint for %s", what)
+	return 0
+}
+
+func (r *pcztReader) uint32(what string) uint32 {
+	v := r.varint(what)
+	if v > 0xffffffff {
+		r.fail("invalid %s: %d overflows 32 bits", what, v)
+		return 0
+	}
+	return uint32(v)
+}
+
+// length reads a collection or byte-string length, which cannot exceed the
+// remaining bytes
+func (r *pcztReader) length(what string) int {
+	n := r.varint(what)
+	if r.err == nil && n > uint64(len(r.buf)-r.off) {
+		r.fail("invalid %s: %d exceeds remaining PCZT size", what, n)
+	}
+	if r.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+// bool reads a single-byte bool, which must be 0 or 1
+func (r *pcztReader) bool(what string) bool {
+	switch b := r.byte(what); {
+	case r.err != nil:
+		return false
+	case b > 1:
+		r.fail("invalid bool %d for %s", b, what)
+		return false
+	default:
+		return b == 1
+	}
+}
+
+// option reads the tag of an Option, reporting whether a value follows
+func (r *pcztReader) option(what string) bool {
+	return r.bool(what)
+}
+
+func (r *pcztReader) bytes(what string) []byte {
+	return r.read(r.length(what), what)
+}
+
+func (r *pcztReader) optionalBytes(what string) []byte {
+	if !r.option(what) {
+		return nil
+	}
+	return r.bytes(what)
+}
+
+func (r *pcztReader) optionalUint32(what string) *uint32 {
+	if !r.option(what) {
+		return nil
+	}
+	v := r.uint32(what)
+	return &v
+}
+
+func (r *pcztReader) skipOptional(n int, what string) bool {
+	if !r.option(what) {
+		return false
+	}
+	r.read(n, what)
+	return true
+}
+
+// bytesMap reads a map of fixed-size keys to byte strings
+func (r *pcztReader) bytesMap(keySize int, what string) map[string][]byte {
+	m := make(map[string][]byte)
+	for i := r.length(what); i > 0 && r.err == nil; i-- {
+		key := r.read(keySize, what)
+		m[string(key)] = r.bytes(what)
+	}
+	return m
+}
+
+// skipProprietary skips a map of string keys to byte strings
+func (r *pcztReader) skipProprietary(what string) {
+	for i := r.length(what); i > 0 && r.err == nil; i-- {
+		r.bytes(what)
+		r.bytes(what)
+	}
+}
+
+// skipZip32Derivation skips a seed fingerprint and derivation path
+func (r *pcztReader) skipZip32Derivation(what string) {
+	r.read(32, what)
+	for i := r.length(what); i > 0 && r.err == nil; i-- {
+		r.uint32(what)
+	}
+}
+
+// skipBip32Derivations skips a map of 33-byte pubkeys to ZIP 32 derivations
+func (r *pcztReader) skipBip32Derivations(what string) {
+	for i := r.length(what); i > 0 && r.err == nil; i-- {
+		r.read(33, what)
+		r.skipZip32Derivation(what)
+	}
+}
+
+func (r *pcztReader) fail(format string, args ...any) {
+	if r.err == nil {
+		r.err = fmt.Errorf(format, args...)
+	}
+}
+
+// decodePCZT decodes serialized PCZT bytes (as produced by SerializePCZT)
+func decodePCZT(data []byte) (*pcztContents, error) {
+	if len(data) < 8 || !bytes.Equal(data[:4], pcztMagic) {
+		return nil, errors.New("not a PCZT: missing magic bytes")
+	}
+	if v := binary.LittleEndian.Uint32(data[4:8]); v != pcztFormatVersion {
+		return nil, fmt.Errorf("unsupported PCZT format version %d", v)
+	}
+
+	r := &pcztReader{buf: data, off: 8}
+	p := &pcztContents{}
+
+	// Global
+	p.TxVersion = r.uint32("tx version")
+	p.VersionGroupID = r.uint32("version group ID")
+	p.ConsensusBranchID = r.uint32("consensus branch ID")
+	p.FallbackLockTime = r.optionalUint32("fallback lock time")
+	p.ExpiryHeight = r.uint32("expiry height")
+	p.CoinType = r.uint32("coin type")
+	p.TxModifiable = r.byte("tx modifiable flags")
+	r.skipProprietary("global proprietary")
+
+	// Transparent
+	for i := r.length("transparent input count"); i > 0 && r.err == nil; i-- {
+		var in pcztInput
+		copy(in.PrevTxID[:], r.read(32, "prevout txid"))
+		in.PrevIndex = r.uint32("prevout index")
+		in.Sequence = r.optionalUint32("sequence")
+		in.RequiredTimeLockTime = r.optionalUint32("required time lock time")
+		in.RequiredHeightLockTime = r.optionalUint32("required height lock time")
+		in.ScriptSig = r.optionalBytes("script sig")
+		in.Value = r.varint("input value")
+		in.ScriptPubKey = r.bytes("input script")
+		in.RedeemScript = r.optionalBytes("redeem script")
+		in.signaturesOffset = r.off
+		in.PartialSignatures = r.bytesMap(33, "partial signatures")
+		in.SighashType = r.byte("sighash type")
+		r.skipBip32Derivations("input BIP 32 derivations")
+		r.bytesMap(20, "RIPEMD-160 preimages")
+		r.bytesMap(32, "SHA-256 preimages")
+		in.Hash160Preimages = r.bytesMap(20, "HASH160 preimages")
+		r.bytesMap(32, "HASH256 preimages")
+		r.skipProprietary("input proprietary")
+		p.Inputs = append(p.Inputs, in)
+	}
+	for i := r.length("transparent output count"); i > 0 && r.err == nil; i-- {
+		var out pcztOutput
+		out.Value = r.varint("output value")
+		out.ScriptPubKey = r.bytes("output script")
+		out.RedeemScript = r.optionalBytes("redeem script")
+		r.skipBip32Derivations("output BIP 32 derivations")
+		out.UserAddress = string(r.optionalBytes("user address"))
+		r.skipProprietary("output proprietary")
+		p.Outputs = append(p.Outputs, out)
+	}
+
+	// Sapling: t2z never creates Sapling spends or outputs
+	if r.length("Sapling spend count") != 0 || r.length("Sapling output count") != 0 {
+		r.fail("PCZTs with Sapling spends or outputs are not supported")
+	}
+	r.varint("Sapling value sum") // zigzag i128, zero without spends/outputs
+	r.read(32, "Sapling anchor")
+	r.skipOptional(32, "Sapling bsk")
+
+	// Orchard
+	for i := r.length("Orchard action count"); i > 0 && r.err == nil; i-- {
+		p.OrchardActions = append(p.OrchardActions, r.orchardAction())
+	}
+	p.OrchardFlags = r.byte("Orchard flags")
+	magnitude := r.varint("Orchard value sum")
+	negative := r.bool("Orchard value sum sign")
+	if magnitude > 1<<63-1 {
+		r.fail("invalid Orchard value sum %d", magnitude)
+	}
+	p.OrchardValueBalance = int64(magnitude)
+	if negative {
+		p.OrchardValueBalance = -p.OrchardValueBalance
+	}
+	r.read(32, "Orchard anchor")
+	p.OrchardProof = r.optionalBytes("Orchard proof")
+	r.skipOptional(32, "Orchard bsk")
+
+	if r.err == nil && r.off != len(data) {
+		r.fail("%d unexpected trailing bytes", len(data)-r.off)
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid PCZT: %w", r.err)
+	}
+	return p, nil
+}
+
+// inspectPCZT serializes and decodes a PCZT without consuming it
+func inspectPCZT(pczt *PCZT) (*pcztContents, error) {
+	data, err := SerializePCZT(pczt)
+	if err != nil {
+		return nil, err
+	}
+	return decodePCZT(data)
+}
+
+// orchardAction reads an Orchard action, keeping its transaction fields and
+// whether it is authorized
+func (r *pcztReader) orchardAction() pcztAction {
+	var a pcztAction
+	a.CvNet = r.read(32, "cv_net")
+
+	// Spend
+	a.Nullifier = r.read(32, "nullifier")
+	a.Rk = r.read(32, "rk")
+	a.SpendAuthSig = r.skipOptional(64, "spend auth signature")
+	r.skipOptional(43, "spend recipient")
+	if r.option("spend value") {
+		r.varint("spend value")
+	}
+	r.skipOptional(32, "rho")
+	r.skipOptional(32, "spend rseed")
+	r.skipOptional(96, "full viewing key")
+	if r.option("witness") {
+		r.uint32("witness position")
+		r.read(32*32, "witness path")
+	}
+	r.skipOptional(32, "alpha")
+	if r.option("spend ZIP 32 derivation") {
+		r.skipZip32Derivation("spend ZIP 32 derivation")
+	}
+	r.skipOptional(32, "dummy spending key")
+	r.skipProprietary("spend proprietary")
+
+	// Output
+	a.Cmx = r.read(32, "cmx")
+	a.EphemeralKey = r.read(32, "ephemeral key")
+	a.EncCiphertext = r.bytes("encrypted ciphertext")
+	a.OutCiphertext = r.bytes("out ciphertext")
+	r.skipOptional(43, "output recipient")
+	if r.option("output value") {
+		r.varint("output value")
+	}
+	r.skipOptional(32, "output rseed")
+	r.skipOptional(32, "ock")
+	if r.option("output ZIP 32 derivation") {
+		r.skipZip32Derivation("output ZIP 32 derivation")
+	}
+	r.optionalBytes("output user address")
+	r.skipProprietary("output proprietary")
+
+	r.skipOptional(32, "rcv")
+	return a
+}
+
+// DumpPCZTJSON returns an indented, human-readable JSON view of a PCZT for
+// debugging and support requests.
+//
+// The dump lists the transparent inputs and outputs with their values,
+// scripts and addresses, which inputs are signed, the fee, and the number of
+// Orchard actions. It never contains private keys or signatures; Orchard
+// actions are reported only as a count and are otherwise marked opaque, since
+// their notes and randomness are not meant to be shared.
+//
+// The PCZT is not consumed.
+func DumpPCZTJSON(pczt *PCZT) ([]byte, error) {
+	p, err := inspectPCZT(pczt)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(p.dump(), "", "  ")
+}
+
+// pcztDump is the JSON layout of DumpPCZTJSON
+type pcztDump struct {
+	TxVersion         uint32 `json:"tx_version"`
+	ConsensusBranchID string `json:"consensus_branch_id"`
+	ExpiryHeight      uint32 `json:"expiry_height"`
+	Network           string `json:"network"`
+
+	Inputs  []inputDump  `json:"transparent_inputs"`
+	Outputs []outputDump `json:"transparent_outputs"`
+	Orchard orchardDump  `json:"orchard"`
+
+	TotalIn  uint64 `json:"total_in"`
+	TotalOut uint64 `json:"total_out"`
+	Fee      int64  `json:"fee"`
+
+	SignedInputs int `json:"signed_inputs"`
+}
+
+type inputDump struct {
+	TxID         string `json:"txid"`
+	Vout         uint32 `json:"vout"`
+	Value        uint64 `json:"value"`
+	ScriptPubKey string `json:"script_pubkey"`
+	Address      string `json:"address,omitempty"`
+	Signed       bool   `json:"signed"`
+	SighashType  byte   `json:"sighash_type"`
+}
+
+type outputDump struct {
+	Value        uint64 `json:"value"`
+	ScriptPubKey string `json:"script_pubkey"`
+	Address      string `json:"address,omitempty"`
+}
+
+type orchardDump struct {
+	Actions      int    `json:"actions"`
+	ValueBalance int64  `json:"value_balance"`
+	Proved       bool   `json:"proved"`
+	Flags        byte   `json:"flags"`
+	Contents     string `json:"contents"`
+}
+
+// orchardOpaque marks the Orchard actions in a dump as deliberately omitted
+const orchardOpaque = "opaque (notes, recipients and randomness omitted)"
+
+// dump builds the JSON view of a decoded PCZT
+func (p *pcztContents) dump() *pcztDump {
+	mainnet := p.CoinType == 133
+	d := &pcztDump{
+		TxVersion:         p.TxVersion,
+		ConsensusBranchID: fmt.Sprintf("0x%08x", p.ConsensusBranchID),
+		ExpiryHeight:      p.ExpiryHeight,
+		Network:           NetworkTestnet.String(),
+		Inputs:            []inputDump{},
+		Outputs:           []outputDump{},
+		Orchard: orchardDump{
+			Actions:      len(p.OrchardActions),
+			ValueBalance: p.OrchardValueBalance,
+			Proved:       p.OrchardProof != nil,
+			Flags:        p.OrchardFlags,
+			Contents:     orchardOpaque,
+		},
+	}
+	if mainnet {
+		d.Network = NetworkMainnet.String()
+	}
+
+	for _, in := range p.Inputs {
+		// Display txids in the reversed byte order used by block explorers
+		txid := in.PrevTxID
+		slices.Reverse(txid[:])
+		address, _ := scriptToAddress(in.ScriptPubKey, mainnet)
+		signed := in.Signed()
+		d.Inputs = append(d.Inputs, inputDump{
+			TxID:         hex.EncodeToString(txid[:]),
+			Vout:         in.PrevIndex,
+			Value:        in.Value,
+			ScriptPubKey: hex.EncodeToString(in.ScriptPubKey),
+			Address:      address,
+			Signed:       signed,
+			SighashType:  in.SighashType,
+		})
+		d.TotalIn += in.Value
+		if signed {
+			d.SignedInputs++
+		}
+	}
+	for _, out := range p.Outputs {
+		address := out.UserAddress
+		if address == "" {
+			address, _ = scriptToAddress(out.ScriptPubKey, mainnet)
+		}
+		d.Outputs = append(d.Outputs, outputDump{
+			Value:        out.Value,
+			ScriptPubKey: hex.EncodeToString(out.ScriptPubKey),
+			Address:      address,
+		})
+		d.TotalOut += out.Value
+	}
+
+	// Transparent in + Orchard value balance = transparent out + fee
+	d.Fee = int64(d.TotalIn) + p.OrchardValueBalance - int64(d.TotalOut)
+	return d
+}
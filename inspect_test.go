@@ -0,0 +1,159 @@
+//go:build (cgo && (darwin || linux || windows) && (amd64 || arm64)) || t2z_mock
+
+package t2z
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Test that DumpPCZTJSON reports inputs, outputs, signatures, fee and Orchard
+// actions without leaking key material
+func TestDumpPCZTJSON(t *testing.T) {
+	privateKey, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_dump_pczt_json_0000000"))
+
+	inputs := []TransparentInput{{
+		Pubkey:       pubkey,
+		TxID:         txid,
+		Vout:         3,
+		Amount:       100_000_000,
+		ScriptPubKey: createP2PKHScript(pubkey),
+	}}
+
+	request, err := NewTransactionRequest([]Payment{
+		{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 50_000_000},
+		{Address: testShieldedAddress, Amount: 100_000},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+	request.SetTargetHeight(2_500_000)
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+
+	var dump pcztDump
+	data, err := DumpPCZTJSON(pczt)
+	if err != nil {
+		t.Fatalf("DumpPCZTJSON failed: %v", err)
+	}
+	if err := json.Unmarshal(data, &dump); err != nil {
+		t.Fatalf("dump is not valid JSON: %v", err)
+	}
+
+	if dump.Network != "mainnet" || dump.TxVersion != 5 || dump.ExpiryHeight <= 2_500_000 {
+		t.Errorf("unexpected header: %+v", dump)
+	}
+	if len(dump.Inputs) != 1 || dump.Inputs[0].Vout != 3 || dump.Inputs[0].Value != 100_000_000 || dump.Inputs[0].Signed {
+		t.Errorf("unexpected inputs: %+v", dump.Inputs)
+	}
+	displayTxID := txid
+	slices.Reverse(displayTxID[:])
+	if want := hex.EncodeToString(displayTxID[:]); dump.Inputs[0].TxID != want {
+		t.Errorf("TxID = %s, want %s", dump.Inputs[0].TxID, want)
+	}
+	if len(dump.Outputs) != 2 || dump.Outputs[0].Value != 50_000_000 || !strings.HasPrefix(dump.Outputs[0].Address, "t1") {
+		t.Errorf("unexpected outputs: %+v", dump.Outputs)
+	}
+	if dump.Orchard.Actions < 1 || dump.Orchard.ValueBalance != -100_000 || dump.Orchard.Proved || dump.Orchard.Contents != orchardOpaque {
+		t.Errorf("unexpected Orchard section: %+v", dump.Orchard)
+	}
+	if want := CalculateFee(1, 2, 1); dump.Fee != int64(want) {
+		t.Errorf("Fee = %d, want %d", dump.Fee, want)
+	}
+
+	sighash, err := GetSighash(pczt, 0)
+	if err != nil {
+		t.Fatalf("Failed to get sighash: %v", err)
+	}
+	signature, err := signMessage(privateKey, sighash)
+	if err != nil {
+		t.Fatalf("Failed to sign message: %v", err)
+	}
+	signed, err := AppendSignature(pczt, 0, signature)
+	if err != nil {
+		t.Fatalf("Failed to append signature: %v", err)
+	}
+	defer signed.Free()
+
+	data, err = DumpPCZTJSON(signed)
+	if err != nil {
+		t.Fatalf("DumpPCZTJSON failed: %v", err)
+	}
+	dump = pcztDump{}
+	if err := json.Unmarshal(data, &dump); err != nil {
+		t.Fatalf("dump is not valid JSON: %v", err)
+	}
+	if dump.SignedInputs != 1 || !dump.Inputs[0].Signed {
+		t.Errorf("expected the input to be signed: %+v", dump.Inputs)
+	}
+
+	for _, secret := range [][]byte{privateKey, signature[:32], signature[32:]} {
+		if bytes.Contains(data, []byte(hex.EncodeToString(secret))) {
+			t.Errorf("dump contains key or signature material %x", secret)
+		}
+	}
+}
+
+// Test that truncated and malformed PCZT bytes are rejected
+func TestDecodePCZTInvalid(t *testing.T) {
+	_, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	inputs := []TransparentInput{{
+		Pubkey:       pubkey,
+		TxID:         txid,
+		Amount:       100_000_000,
+		ScriptPubKey: createP2PKHScript(pubkey),
+	}}
+	request, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 50_000_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+	request.SetTargetHeight(2_500_000)
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+	defer pczt.Free()
+
+	data, err := SerializePCZT(pczt)
+	if err != nil {
+		t.Fatalf("Failed to serialize PCZT: %v", err)
+	}
+	if _, err := decodePCZT(data); err != nil {
+		t.Fatalf("decodePCZT failed: %v", err)
+	}
+
+	badVersion := append([]byte(nil), data...)
+	badVersion[4] = 2
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, "missing magic"},
+		{"magic", append([]byte("PSBT"), data[4:]...), "missing magic"},
+		{"version", badVersion, "unsupported PCZT format version 2"},
+		{"truncated", data[:len(data)-1], "too short"},
+		{"trailing", append(append([]byte(nil), data...), 0), "trailing bytes"},
+	}
+	for _, tt := range tests {
+		if _, err := decodePCZT(tt.data); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package t2z
 
 import (
+	"bytes"
 	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/decred/dcrd/dcrec/secp256k1/v4"
@@ -55,6 +59,7 @@ func signMessage(privateKey []byte, message [32]byte) ([64]byte, error) {
 // TestFullTransparentWorkflow tests the complete transparent-to-transparent workflow
 // Follows the same pattern as TypeScript zebrad-t2z examples
 func TestFullTransparentWorkflow(t *testing.T) {
+	requireBackend(t)
 	// 1. Create test keypair
 	privateKey, pubkey := createTestKeypair()
 	_ = privateKey // Will be used for signing
@@ -137,6 +142,11 @@ func TestFullTransparentWorkflow(t *testing.T) {
 	}
 	t.Logf("✓ Signature created: %s", hex.EncodeToString(signature[:]))
 
+	// SignedSize fails while inputs are unsigned (does not consume)
+	if _, err := proved.SignedSize(); err == nil {
+		t.Error("Expected error for SignedSize on unsigned PCZT, got nil")
+	}
+
 	// 8. Append signature
 	// This consumes the input PCZT
 	signed, err := AppendSignature(proved, 0, signature)
@@ -146,6 +156,12 @@ func TestFullTransparentWorkflow(t *testing.T) {
 	// Note: Do NOT free signed - it's consumed by FinalizeAndExtract
 	t.Log("✓ Signature appended successfully")
 
+	// SignedSize does not consume the PCZT
+	signedSize, err := signed.SignedSize()
+	if err != nil {
+		t.Fatalf("Failed to get signed size: %v", err)
+	}
+
 	// 9. Finalize and extract
 	// This consumes the input PCZT
 	txBytes, err := FinalizeAndExtract(signed)
@@ -154,6 +170,15 @@ func TestFullTransparentWorkflow(t *testing.T) {
 	}
 	t.Logf("✓ Transaction finalized: %d bytes", len(txBytes))
 
+	if signedSize != len(txBytes) {
+		t.Errorf("SignedSize mismatch: expected %d, got %d", len(txBytes), signedSize)
+	}
+
+	// The estimate is an upper bound within a byte per input
+	if est := EstimateTxSize(1, 2, 0); est < len(txBytes) || est > len(txBytes)+1 {
+		t.Errorf("EstimateTxSize(1, 2, 0) = %d, actual size %d", est, len(txBytes))
+	}
+
 	if len(txBytes) == 0 {
 		t.Error("Transaction bytes should not be empty")
 	}
@@ -164,6 +189,7 @@ func TestFullTransparentWorkflow(t *testing.T) {
 // TestPCZTSerialization tests PCZT serialization and parsing
 // Follows TypeScript patterns for consistency
 func TestPCZTSerialization(t *testing.T) {
+	requireBackend(t)
 	// Create a simple PCZT
 	_, pubkey := createTestKeypair()
 
@@ -212,6 +238,48 @@ func TestPCZTSerialization(t *testing.T) {
 	}
 	t.Logf("✓ PCZT serialized: %d bytes", len(serialized))
 
+	// Checksummed round-trip, and detection of a single corrupted byte
+	withChecksum, err := pczt.SerializeWithChecksum()
+	if err != nil {
+		t.Fatalf("Failed to serialize PCZT with checksum: %v", err)
+	}
+	checked, err := ParsePCZTWithChecksum(withChecksum)
+	if err != nil {
+		t.Fatalf("Failed to parse checksummed PCZT: %v", err)
+	}
+	checked.Free()
+
+	withChecksum[len(withChecksum)/2] ^= 0x01
+	if _, err := ParsePCZTWithChecksum(withChecksum); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Expected ErrChecksumMismatch for corrupted data, got %v", err)
+	}
+	t.Log("✓ Checksum detects corruption")
+
+	// Base64 round-trip
+	encoded, err := SerializePCZTBase64(pczt)
+	if err != nil {
+		t.Fatalf("Failed to serialize PCZT as base64: %v", err)
+	}
+	if strings.ContainsAny(encoded, "+/=") {
+		t.Errorf("Base64 PCZT should be URL-safe and unpadded, got %q", encoded)
+	}
+	if len(encoded) >= len(serialized)*2 {
+		t.Errorf("Base64 PCZT (%d chars) should be shorter than hex (%d chars)", len(encoded), len(serialized)*2)
+	}
+	decoded, err := ParsePCZTBase64(encoded)
+	if err != nil {
+		t.Fatalf("Failed to parse base64 PCZT: %v", err)
+	}
+	decodedBytes, err := SerializePCZT(decoded)
+	decoded.Free()
+	if err != nil || !bytes.Equal(decodedBytes, serialized) {
+		t.Errorf("Base64 round-trip changed the PCZT (err: %v)", err)
+	}
+	if _, err := ParsePCZTBase64(encoded + "!"); err == nil {
+		t.Error("Expected error for invalid base64")
+	}
+	t.Log("✓ Base64 round-trip")
+
 	// Free the original PCZT after serialization
 	pczt.Free()
 
@@ -249,6 +317,7 @@ func TestPCZTSerialization(t *testing.T) {
 
 // TestGetSighashInvalidIndex tests error handling for invalid input index
 func TestGetSighashInvalidIndex(t *testing.T) {
+	requireBackend(t)
 	_, pubkey := createTestKeypair()
 
 	var txid [32]byte
@@ -300,6 +369,7 @@ func TestGetSighashInvalidIndex(t *testing.T) {
 // TestVerifyBeforeSigning tests the VerifyBeforeSigning function
 // Follows TypeScript pattern with mainnet config and 50% payment
 func TestVerifyBeforeSigning(t *testing.T) {
+	requireBackend(t)
 	_, pubkey := createTestKeypair()
 
 	var txid [32]byte
@@ -342,21 +412,54 @@ func TestVerifyBeforeSigning(t *testing.T) {
 		t.Fatalf("Failed to propose transaction: %v", err)
 	}
 
-	// For transparent-only, we need to provide expected change output
-	// The change output uses the same script as the input
-	expectedChange := []TransparentOutput{
-		{
-			ScriptPubKey: createP2PKHScript(pubkey), // Raw script, no prefix
-			Value:        expectedChangeAmount,       // amount - payment - fee
-		},
+	// The change the builder creates for these inputs
+	expectedChange, err := ExpectedChange(inputs, request, "")
+	if err != nil {
+		t.Fatalf("ExpectedChange failed: %v", err)
+	}
+	if len(expectedChange) != 1 {
+		t.Fatalf("Expected 1 change output, got %d", len(expectedChange))
+	}
+	if !bytes.Equal(expectedChange[0].ScriptPubKey, createP2PKHScript(pubkey)) {
+		t.Errorf("Change script = %x, want the input script", expectedChange[0].ScriptPubKey)
+	}
+	if expectedChange[0].Value != expectedChangeAmount {
+		t.Errorf("Change value = %d, want %d", expectedChange[0].Value, expectedChangeAmount)
 	}
 
 	// Verify before signing (does not consume PCZT)
-	err = VerifyBeforeSigning(pczt, request, expectedChange)
-	// Note: This may fail due to change amount mismatch, which is expected
-	// The important thing is that the function runs without crashing
+	if err := VerifyBeforeSigning(pczt, request, expectedChange); err != nil {
+		t.Errorf("VerifyBeforeSigning with the builder's change failed: %v", err)
+	}
+
+	// An output added to the PCZT does not pass as change
+	tamperedRequest, err := NewTransactionRequest([]Payment{
+		payments[0],
+		{Address: "t1HsdDMzmJfq4vc7T17XYjEkLMLvbgM1fCi", Amount: 1_000_000},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer tamperedRequest.Free()
+	tamperedRequest.SetTargetHeight(2_500_000)
+	tamperedPCZT, err := ProposeTransaction(inputs, tamperedRequest)
 	if err != nil {
-		t.Logf("VerifyBeforeSigning returned error (may be expected): %v", err)
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+	defer tamperedPCZT.Free()
+	if err := VerifyBeforeSigning(tamperedPCZT, request, expectedChange); err == nil {
+		t.Error("VerifyBeforeSigning accepted a PCZT with an extra output")
+	}
+
+	// Malformed scripts are rejected with a specific error
+	prefixed := append([]byte{25}, createP2PKHScript(pubkey)...)
+	err = VerifyBeforeSigning(pczt, request, []TransparentOutput{{ScriptPubKey: prefixed, Value: expectedChangeAmount}})
+	if err == nil || !strings.Contains(err.Error(), "CompactSize") {
+		t.Errorf("Expected CompactSize prefix error, got %v", err)
+	}
+	err = VerifyBeforeSigning(pczt, request, []TransparentOutput{{ScriptPubKey: []byte{0x6a}, Value: expectedChangeAmount}})
+	if err == nil || !strings.Contains(err.Error(), "not a P2PKH or P2SH") {
+		t.Errorf("Expected malformed script error, got %v", err)
 	}
 
 	// Clean up - Free the PCZT since we didn't consume it
@@ -366,6 +469,7 @@ func TestVerifyBeforeSigning(t *testing.T) {
 
 // TestAppendSignatureInvalidIndex tests error handling for invalid input index
 func TestAppendSignatureInvalidIndex(t *testing.T) {
+	requireBackend(t)
 	_, pubkey := createTestKeypair()
 
 	var txid [32]byte
@@ -412,4 +516,758 @@ func TestAppendSignatureInvalidIndex(t *testing.T) {
 		t.Error("Expected error for invalid input index, got nil")
 	}
 	t.Logf("✓ Got expected error: %v", err)
+
+	var t2zErr *Error
+	if errors.As(err, &t2zErr) && t2zErr.InputIndex != 999 {
+		t.Errorf("Expected InputIndex 999, got %d", t2zErr.InputIndex)
+	}
+}
+
+// TestClonePCZT tests that a cloned PCZT is independent of the original
+func TestClonePCZT(t *testing.T) {
+	requireBackend(t)
+	_, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_clone_pczt_test_000000"))
+
+	inputAmount := uint64(100_000_000)
+	paymentAmount := inputAmount / 2
+
+	inputs := []TransparentInput{
+		{
+			Pubkey:       pubkey,
+			TxID:         txid,
+			Vout:         0,
+			Amount:       inputAmount,
+			ScriptPubKey: createP2PKHScript(pubkey),
+		},
+	}
+
+	payments := []Payment{
+		{
+			Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma",
+			Amount:  paymentAmount,
+		},
+	}
+
+	request, err := NewTransactionRequest(payments)
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+
+	request.SetTargetHeight(2_500_000)
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+
+	clone, err := ClonePCZT(pczt)
+	if err != nil {
+		t.Fatalf("Failed to clone PCZT: %v", err)
+	}
+
+	original, err := SerializePCZT(pczt)
+	if err != nil {
+		t.Fatalf("Failed to serialize original PCZT: %v", err)
+	}
+
+	// Consuming the original must not affect the clone
+	if _, err := ProveTransaction(pczt); err != nil {
+		t.Fatalf("Failed to prove original PCZT: %v", err)
+	}
+
+	cloned, err := SerializePCZT(clone)
+	if err != nil {
+		t.Fatalf("Failed to serialize cloned PCZT: %v", err)
+	}
+	clone.Free()
+
+	if string(cloned) != string(original) {
+		t.Error("Cloned PCZT does not match the original")
+	}
+
+	if _, err := ClonePCZT(pczt); err == nil {
+		t.Error("Expected error when cloning a consumed PCZT, got nil")
+	}
+}
+
+// TestPCZTConcurrentReads tests that non-consuming operations can run in
+// parallel and that a concurrent consume invalidates the PCZT cleanly
+func TestPCZTConcurrentReads(t *testing.T) {
+	requireBackend(t)
+	_, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_concurrent_reads_00000"))
+
+	inputAmount := uint64(100_000_000)
+
+	inputs := []TransparentInput{
+		{
+			Pubkey:       pubkey,
+			TxID:         txid,
+			Vout:         0,
+			Amount:       inputAmount / 2,
+			ScriptPubKey: createP2PKHScript(pubkey),
+		},
+		{
+			Pubkey:       pubkey,
+			TxID:         txid,
+			Vout:         1,
+			Amount:       inputAmount / 2,
+			ScriptPubKey: createP2PKHScript(pubkey),
+		},
+	}
+
+	payments := []Payment{
+		{
+			Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma",
+			Amount:  inputAmount / 2,
+		},
+	}
+
+	request, err := NewTransactionRequest(payments)
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+
+	request.SetTargetHeight(2_500_000)
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(index uint) {
+			defer wg.Done()
+			if _, err := GetSighash(pczt, index); err != nil {
+				errs <- err
+			}
+		}(uint(i % 2))
+		go func() {
+			defer wg.Done()
+			if _, err := SerializePCZT(pczt); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Concurrent read failed: %v", err)
+	}
+
+	// Consume while reads race against it; reads must either succeed or
+	// report an invalid PCZT, never touch a freed handle
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			GetSighash(pczt, 0)
+		}
+	}()
+	if _, err := ProveTransaction(pczt); err != nil {
+		t.Fatalf("Failed to prove transaction: %v", err)
+	}
+	wg.Wait()
+
+	if _, err := GetSighash(pczt, 0); err == nil {
+		t.Error("Expected error for GetSighash on consumed PCZT, got nil")
+	}
+}
+
+// TestExactChangeOmitsChangeOutput tests that inputs exactly covering
+// payment plus fee produce no change output rather than a zero-value one
+func TestExactChangeOmitsChangeOutput(t *testing.T) {
+	requireBackend(t)
+	privateKey, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_exact_change_test_0000"))
+
+	paymentAmount := uint64(100_000)
+	fee := CalculateFee(1, 1, 0) // no change output
+
+	inputs := []TransparentInput{
+		{
+			Pubkey:       pubkey,
+			TxID:         txid,
+			Vout:         0,
+			Amount:       paymentAmount + fee,
+			ScriptPubKey: createP2PKHScript(pubkey),
+		},
+	}
+
+	payments := []Payment{
+		{
+			Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma",
+			Amount:  paymentAmount,
+		},
+	}
+
+	change, err := ChangeAmount(inputs, payments, fee)
+	if err != nil {
+		t.Fatalf("Failed to compute change: %v", err)
+	}
+	if change != 0 {
+		t.Fatalf("Expected zero change, got %d", change)
+	}
+
+	request, err := NewTransactionRequest(payments)
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+
+	request.SetTargetHeight(2_500_000)
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+
+	proved, err := ProveTransaction(pczt)
+	if err != nil {
+		t.Fatalf("Failed to prove transaction: %v", err)
+	}
+
+	sighash, err := GetSighash(proved, 0)
+	if err != nil {
+		t.Fatalf("Failed to get sighash: %v", err)
+	}
+
+	signature, err := signMessage(privateKey, sighash)
+	if err != nil {
+		t.Fatalf("Failed to sign message: %v", err)
+	}
+
+	signed, err := AppendSignature(proved, 0, signature)
+	if err != nil {
+		t.Fatalf("Failed to append signature: %v", err)
+	}
+
+	txBytes, err := FinalizeAndExtract(signed)
+	if err != nil {
+		t.Fatalf("Failed to finalize and extract: %v", err)
+	}
+
+	tx, err := DecodeTransaction(txBytes)
+	if err != nil {
+		t.Fatalf("Failed to decode transaction: %v", err)
+	}
+	if len(tx.Outputs) != 1 {
+		t.Fatalf("Expected 1 output (no change), got %d: %+v", len(tx.Outputs), tx.Outputs)
+	}
+	if tx.Outputs[0].Value != paymentAmount {
+		t.Errorf("Expected payment output %d, got %d", paymentAmount, tx.Outputs[0].Value)
+	}
+
+	// Implied fee: inputs minus outputs
+	if inputs[0].Amount-tx.Outputs[0].Value != fee {
+		t.Errorf("Expected fee %d, got %d", fee, inputs[0].Amount-tx.Outputs[0].Value)
+	}
+
+	// One zatoshi short must be rejected
+	inputs[0].Amount--
+	if _, err := ProposeTransaction(inputs, request); err == nil {
+		t.Error("Expected error for insufficient funds, got nil")
+	}
+}
+
+// TestProposeTransactionMixedPubkeys tests that inputs from different keys
+// need an explicit change address when there is change
+func TestProposeTransactionMixedPubkeys(t *testing.T) {
+	requireBackend(t)
+	_, pubkey := createTestKeypair()
+	otherPubkey := secp256k1.PrivKeyFromBytes(bytes.Repeat([]byte{0x02}, 32)).PubKey().SerializeCompressed()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_mixed_pubkeys_00000000"))
+
+	inputs := []TransparentInput{
+		{Pubkey: pubkey, TxID: txid, Vout: 0, Amount: 100_000, ScriptPubKey: createP2PKHScript(pubkey)},
+		{Pubkey: otherPubkey, TxID: txid, Vout: 1, Amount: 100_000, ScriptPubKey: p2pkhScript(hash160(otherPubkey))},
+	}
+
+	request, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 50_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+	request.SetTargetHeight(2_500_000)
+
+	if _, err := ProposeTransaction(inputs, request); !errors.Is(err, ErrAmbiguousChange) {
+		t.Fatalf("Expected ErrAmbiguousChange, got %v", err)
+	}
+
+	pczt, err := ProposeTransactionWithChange(inputs, request, "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma")
+	if err != nil {
+		t.Fatalf("Failed to propose with an explicit change address: %v", err)
+	}
+	pczt.Free()
+
+	// Without change there is nothing to send to the wrong party
+	exact, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 200_000 - CalculateFee(2, 1, 0)}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer exact.Free()
+	exact.SetTargetHeight(2_500_000)
+
+	pczt, err = ProposeTransaction(inputs, exact)
+	if err != nil {
+		t.Fatalf("Failed to propose without change: %v", err)
+	}
+	pczt.Free()
+}
+
+// TestProposeTransactionWithOptionsFee tests pinning the fee with ProposeOptions
+func TestProposeTransactionWithOptionsFee(t *testing.T) {
+	requireBackend(t)
+	_, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_fee_override_test_0000"))
+
+	inputs := []TransparentInput{
+		{
+			Pubkey:       pubkey,
+			TxID:         txid,
+			Vout:         0,
+			Amount:       1_000_000,
+			ScriptPubKey: createP2PKHScript(pubkey),
+		},
+	}
+
+	request, err := NewTransactionRequest([]Payment{
+		{
+			Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma",
+			Amount:  100_000,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+
+	request.SetTargetHeight(2_500_000)
+
+	fee := CalculateFee(1, 2, 0) // payment + change
+
+	low := fee - 1
+	if _, err := ProposeTransactionWithOptions(inputs, request, ProposeOptions{Fee: &low}); err == nil || !strings.Contains(err.Error(), "below the ZIP-317 minimum") {
+		t.Errorf("Expected below-minimum error, got %v", err)
+	}
+
+	high := fee + 1
+	if _, err := ProposeTransactionWithOptions(inputs, request, ProposeOptions{Fee: &high}); !errors.Is(err, ErrNotImplemented) {
+		t.Errorf("Expected ErrNotImplemented for overpayment, got %v", err)
+	}
+
+	pczt, err := ProposeTransactionWithOptions(inputs, request, ProposeOptions{Fee: &fee})
+	if err != nil {
+		t.Fatalf("Failed to propose with matching fee: %v", err)
+	}
+	pczt.Free()
+
+	pczt, err = ProposeTransactionWithOptions(inputs, request, ProposeOptions{})
+	if err != nil {
+		t.Fatalf("Failed to propose without options: %v", err)
+	}
+	pczt.Free()
+}
+
+// TestAddRawOutput tests that P2SH and P2PKH scripts can be paid by script
+// and that other scripts are rejected
+func TestAddRawOutput(t *testing.T) {
+	requireBackend(t)
+	privateKey, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_raw_output_test_000000"))
+
+	inputs := []TransparentInput{
+		{
+			Pubkey:       pubkey,
+			TxID:         txid,
+			Vout:         0,
+			Amount:       1_000_000,
+			ScriptPubKey: createP2PKHScript(pubkey),
+		},
+	}
+
+	request, err := NewTransactionRequest([]Payment{
+		{
+			Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma",
+			Amount:  100_000,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+
+	request.SetTargetHeight(2_500_000)
+
+	p2shScript, _ := hex.DecodeString("a914000102030405060708090a0b0c0d0e0f1011121387")
+	if err := request.AddRawOutput(p2shScript, 200_000); err != nil {
+		t.Fatalf("Failed to add P2SH output: %v", err)
+	}
+	if len(request.Payments) != 2 || !strings.HasPrefix(request.Payments[1].Address, "t3") {
+		t.Fatalf("Expected P2SH payment to t3 address, got %+v", request.Payments)
+	}
+
+	opReturn := []byte{0x6a, 0x04, 0xde, 0xad, 0xbe, 0xef}
+	if err := request.AddRawOutput(opReturn, 0); !errors.Is(err, ErrNotImplemented) {
+		t.Errorf("Expected ErrNotImplemented for OP_RETURN script, got %v", err)
+	}
+	if len(request.Payments) != 2 {
+		t.Errorf("Rejected output should not be added, got %d payments", len(request.Payments))
+	}
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+
+	sighash, err := GetSighash(pczt, 0)
+	if err != nil {
+		t.Fatalf("Failed to get sighash: %v", err)
+	}
+	signature, err := signMessage(privateKey, sighash)
+	if err != nil {
+		t.Fatalf("Failed to sign message: %v", err)
+	}
+	signed, err := AppendSignature(pczt, 0, signature)
+	if err != nil {
+		t.Fatalf("Failed to append signature: %v", err)
+	}
+	txBytes, err := FinalizeAndExtract(signed)
+	if err != nil {
+		t.Fatalf("Failed to finalize and extract: %v", err)
+	}
+
+	tx, err := DecodeTransaction(txBytes)
+	if err != nil {
+		t.Fatalf("Failed to decode transaction: %v", err)
+	}
+	found := false
+	for _, output := range tx.Outputs {
+		if bytes.Equal(output.ScriptPubKey, p2shScript) && output.Value == 200_000 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("P2SH output not found in transaction outputs: %+v", tx.Outputs)
+	}
+
+	// Fee accounts for the extra output: payment + raw output + change
+	var outputTotal uint64
+	for _, output := range tx.Outputs {
+		outputTotal += output.Value
+	}
+	if fee := inputs[0].Amount - outputTotal; fee != CalculateFee(1, 3, 0) {
+		t.Errorf("Expected fee %d, got %d", CalculateFee(1, 3, 0), fee)
+	}
+}
+
+// TestProposeTransactionDustPolicy tests detection and rejection of dust change
+func TestProposeTransactionDustPolicy(t *testing.T) {
+	requireBackend(t)
+	_, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_dust_policy_test_00000"))
+
+	fee := CalculateFee(1, 2, 0)
+	inputs := []TransparentInput{
+		{
+			Pubkey:       pubkey,
+			TxID:         txid,
+			Vout:         0,
+			Amount:       100_000 + fee + 500,
+			ScriptPubKey: createP2PKHScript(pubkey),
+		},
+	}
+
+	request, err := NewTransactionRequest([]Payment{
+		{
+			Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma",
+			Amount:  100_000,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+
+	request.SetTargetHeight(2_500_000)
+
+	change, err := ProposalChange(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to compute proposal change: %v", err)
+	}
+	if change != 500 {
+		t.Errorf("Expected 500 zatoshis change, got %d", change)
+	}
+
+	if _, err := ProposeTransactionWithOptions(inputs, request, ProposeOptions{DustPolicy: DustPolicyReject}); err == nil || !strings.Contains(err.Error(), "dust") {
+		t.Errorf("Expected dust error, got %v", err)
+	}
+
+	// Change above the relay dust limit but below the marginal fee is dust too
+	inputs[0].Amount = 100_000 + fee + DustThreshold() - 1
+	if _, err := ProposeTransactionWithOptions(inputs, request, ProposeOptions{DustPolicy: DustPolicyReject}); err == nil || !strings.Contains(err.Error(), "dust") {
+		t.Errorf("Expected dust error for %d zatoshis change, got %v", DustThreshold()-1, err)
+	}
+	inputs[0].Amount = 100_000 + fee + 500
+
+	// A lower threshold lets the same change through
+	pczt, err := ProposeTransactionWithOptions(inputs, request, ProposeOptions{DustPolicy: DustPolicyReject, DustThreshold: 500})
+	if err != nil {
+		t.Fatalf("Failed to propose with threshold 500: %v", err)
+	}
+	pczt.Free()
+
+	pczt, err = ProposeTransactionWithOptions(inputs, request, ProposeOptions{})
+	if err != nil {
+		t.Fatalf("Failed to propose with DustPolicyAllow: %v", err)
+	}
+	pczt.Free()
+
+	// Exact change creates no change output at all
+	inputs[0].Amount = 100_000 + CalculateFee(1, 1, 0)
+	change, err = ProposalChange(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to compute proposal change: %v", err)
+	}
+	if change != 0 {
+		t.Errorf("Expected no change, got %d", change)
+	}
+}
+
+// TestProposeTransactionShieldedChange tests sending change to a unified address
+func TestProposeTransactionShieldedChange(t *testing.T) {
+	requireBackend(t)
+	privateKey, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_shielded_change_test_0"))
+
+	inputs := []TransparentInput{
+		{
+			Pubkey:       pubkey,
+			TxID:         txid,
+			Vout:         0,
+			Amount:       1_000_000,
+			ScriptPubKey: createP2PKHScript(pubkey),
+		},
+	}
+
+	payments := []Payment{
+		{
+			Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma",
+			Amount:  100_000,
+		},
+	}
+
+	request, err := NewTransactionRequest(payments)
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+
+	request.SetTargetHeight(2_500_000)
+
+	pczt, err := ProposeTransactionWithChange(inputs, request, testShieldedAddress)
+	if err != nil {
+		t.Fatalf("Failed to propose with shielded change: %v", err)
+	}
+
+	// Only the transparent payment is checked; there is no transparent change
+	if err := VerifyBeforeSigning(pczt, request, nil); err != nil {
+		t.Errorf("Failed to verify PCZT: %v", err)
+	}
+
+	proved, err := ProveTransaction(pczt)
+	if err != nil {
+		t.Fatalf("Failed to prove transaction: %v", err)
+	}
+	sighash, err := GetSighash(proved, 0)
+	if err != nil {
+		t.Fatalf("Failed to get sighash: %v", err)
+	}
+	signature, err := signMessage(privateKey, sighash)
+	if err != nil {
+		t.Fatalf("Failed to sign message: %v", err)
+	}
+	signed, err := AppendSignature(proved, 0, signature)
+	if err != nil {
+		t.Fatalf("Failed to append signature: %v", err)
+	}
+	txBytes, err := FinalizeAndExtract(signed)
+	if err != nil {
+		t.Fatalf("Failed to finalize and extract: %v", err)
+	}
+
+	tx, err := DecodeTransaction(txBytes)
+	if err != nil {
+		t.Fatalf("Failed to decode transaction: %v", err)
+	}
+	if len(tx.Outputs) != 1 || tx.Outputs[0].Value != 100_000 {
+		t.Errorf("Expected only the transparent payment output, got %+v", tx.Outputs)
+	}
+
+	if est := EstimateTxSize(1, 1, 1); est < len(txBytes) || est > len(txBytes)+1 {
+		t.Errorf("EstimateTxSize(1, 1, 1) = %d, actual size %d", est, len(txBytes))
+	}
+
+	// The change leaves the transparent pool into Orchard
+	change := 1_000_000 - 100_000 - CalculateFee(1, 1, 1)
+	if tx.OrchardValueBalance != -int64(change) {
+		t.Errorf("Expected Orchard value balance %d, got %d", -int64(change), tx.OrchardValueBalance)
+	}
+}
+
+// TestProposeTransactionNetworkMismatch tests that a mainnet address is
+// rejected when the request uses testnet parameters
+func TestProposeTransactionNetworkMismatch(t *testing.T) {
+	requireBackend(t)
+	_, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_network_mismatch_00000"))
+
+	inputs := []TransparentInput{
+		{
+			Pubkey:       pubkey,
+			TxID:         txid,
+			Vout:         0,
+			Amount:       1_000_000,
+			ScriptPubKey: createP2PKHScript(pubkey),
+		},
+	}
+
+	request, err := NewTransactionRequest([]Payment{
+		{
+			Address: "t1HsdDMzmJfq4vc7T17XYjEkLMLvbgM1fCi",
+			Amount:  100_000,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+
+	request.SetTargetHeight(2_500_000)
+	if err := request.SetNetwork(NetworkTestnet); err != nil {
+		t.Fatalf("Failed to set network: %v", err)
+	}
+
+	_, err = ProposeTransaction(inputs, request)
+	if !errors.Is(err, ErrNetworkMismatch) || !strings.Contains(err.Error(), "is mainnet but request is testnet") {
+		t.Fatalf("Expected network mismatch error, got %v", err)
+	}
+
+	// A testnet change address is rejected for a mainnet request
+	request.SetNetwork(NetworkMainnet)
+	testnetUA := encodeTestUnifiedAddress("utest", []Receiver{{ReceiverOrchard, make([]byte, 43)}})
+	_, err = ProposeTransactionWithChange(inputs, request, testnetUA)
+	if !errors.Is(err, ErrNetworkMismatch) || !strings.Contains(err.Error(), "change address") {
+		t.Fatalf("Expected change address network mismatch error, got %v", err)
+	}
+}
+
+// TestTestnetUnifiedAddress tests the full shielded flow to a testnet
+// unified address with testnet parameters
+func TestTestnetUnifiedAddress(t *testing.T) {
+	requireBackend(t)
+	privateKey, pubkey := createTestKeypair()
+
+	// Re-encode the Orchard receiver of the mainnet test address for testnet
+	mainnetUA, err := DecodeUnifiedAddress(testShieldedAddress)
+	if err != nil {
+		t.Fatalf("Failed to decode test address: %v", err)
+	}
+	testnetAddress := encodeTestUnifiedAddress("utest", mainnetUA.Receivers)
+	if info, err := ValidateAddress(testnetAddress); err != nil || info.Network != NetworkTestnet {
+		t.Fatalf("Expected a testnet unified address, got %+v, %v", info, err)
+	}
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_testnet_unified_addr_0"))
+
+	inputs := []TransparentInput{
+		{
+			Pubkey:       pubkey,
+			TxID:         txid,
+			Vout:         0,
+			Amount:       1_000_000,
+			ScriptPubKey: createP2PKHScript(pubkey),
+		},
+	}
+
+	request, err := NewTransactionRequest([]Payment{
+		{
+			Address: testnetAddress,
+			Amount:  100_000,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+
+	request.SetTargetHeight(2_500_000)
+
+	// Mainnet parameters are refused for a testnet address
+	if _, err := ProposeTransaction(inputs, request); !errors.Is(err, ErrNetworkMismatch) {
+		t.Fatalf("Expected network mismatch with mainnet parameters, got %v", err)
+	}
+
+	if err := request.SetNetwork(NetworkTestnet); err != nil {
+		t.Fatalf("Failed to set network: %v", err)
+	}
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+
+	proved, err := ProveTransaction(pczt)
+	if err != nil {
+		t.Fatalf("Failed to prove transaction: %v", err)
+	}
+	sighash, err := GetSighash(proved, 0)
+	if err != nil {
+		t.Fatalf("Failed to get sighash: %v", err)
+	}
+	signature, err := signMessage(privateKey, sighash)
+	if err != nil {
+		t.Fatalf("Failed to sign message: %v", err)
+	}
+	signed, err := AppendSignature(proved, 0, signature)
+	if err != nil {
+		t.Fatalf("Failed to append signature: %v", err)
+	}
+	txBytes, err := FinalizeAndExtract(signed)
+	if err != nil {
+		t.Fatalf("Failed to finalize and extract: %v", err)
+	}
+
+	tx, err := DecodeTransaction(txBytes)
+	if err != nil {
+		t.Fatalf("Failed to decode transaction: %v", err)
+	}
+	if tx.OrchardValueBalance != -100_000 {
+		t.Errorf("Expected Orchard value balance -100000, got %d", tx.OrchardValueBalance)
+	}
 }
@@ -0,0 +1,100 @@
+package t2z
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Stages reported to the logger set with SetLogger
+const (
+	StagePropose  = "propose"
+	StageProve    = "prove"
+	StageSign     = "sign"
+	StageFinalize = "finalize"
+)
+
+// LogEvent describes one call into the native library, see SetLogger.
+type LogEvent struct {
+	// Stage is StagePropose, StageProve, StageSign or StageFinalize
+	Stage string
+	// Duration is the time spent in the native library
+	Duration time.Duration
+	// Code is Success, or the ResultCode of the failure. Failures that
+	// did not come from the native library, such as ErrUnsupportedPlatform,
+	// have code ErrorNotImplemented.
+	Code ResultCode
+	// InputIndex is the input signed by StageSign, and -1 for the other stages
+	InputIndex int
+	// Err is the error returned by the call, or nil
+	Err error
+}
+
+var logger atomic.Pointer[func(LogEvent)]
+
+// SetLogger sets a function that is called after each propose, prove, sign
+// and finalize call into the native library, to log or measure them. A nil
+// fn removes the logger; there is none by default.
+//
+// fn is called synchronously on the calling goroutine, possibly from several
+// goroutines at once (see ProveBatch), so it must be fast and safe for
+// concurrent use.
+func SetLogger(fn func(event LogEvent)) {
+	if fn == nil {
+		logger.Store(nil)
+		return
+	}
+	logger.Store(&fn)
+}
+
+// noStage is returned by startStage when no logger or tracer is set
+func noStage(error) {}
+
+// startStage starts timing a stage for the logger and a span for the tracer,
+// and returns the function that reports its result. Without a logger or
+// tracer it does nothing.
+func startStage(ctx context.Context, stage string, inputIndex int) func(error) {
+	fn, t := logger.Load(), tracer.Load()
+	if fn == nil && t == nil {
+		return noStage
+	}
+
+	var span Span
+	if t != nil {
+		span = (*t).StartSpan(ctx, "t2z."+stage)
+		if stage == StageSign {
+			span.SetAttribute(AttrInputIndex, int64(inputIndex))
+		}
+	}
+	start := time.Now()
+	return func(err error) {
+		duration := time.Since(start)
+		code := resultCode(err)
+		if span != nil {
+			span.SetAttribute(AttrResultCode, int64(code))
+			span.End()
+		}
+		if fn != nil {
+			(*fn)(LogEvent{
+				Stage:      stage,
+				Duration:   duration,
+				Code:       code,
+				InputIndex: inputIndex,
+				Err:        err,
+			})
+		}
+	}
+}
+
+// resultCode returns the ResultCode reported for err
+func resultCode(err error) ResultCode {
+	if err == nil {
+		return Success
+	}
+	var t2zErr *Error
+	if errors.As(err, &t2zErr) {
+		return t2zErr.Code
+	}
+	return ErrorNotImplemented
+}
@@ -0,0 +1,90 @@
+//go:build (cgo && (darwin || linux || windows) && (amd64 || arm64)) || t2z_mock
+
+package t2z
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// Test that the logger sees every stage of the workflow with its result
+func TestSetLogger(t *testing.T) {
+	var mu sync.Mutex
+	var events []LogEvent
+	SetLogger(func(event LogEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	})
+	defer SetLogger(nil)
+
+	privateKey, pubkey := createTestKeypair()
+	inputs := []TransparentInput{{
+		Pubkey:       pubkey,
+		Amount:       100_000,
+		ScriptPubKey: createP2PKHScript(pubkey),
+	}}
+	request, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 500_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+
+	// Insufficient funds
+	if _, err := ProposeTransaction(inputs, request); !errors.Is(err, ErrProposal) {
+		t.Fatalf("Expected ErrProposal, got %v", err)
+	}
+
+	inputs[0].Amount = 1_000_000
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("ProposeTransaction failed: %v", err)
+	}
+	proved, err := ProveTransaction(pczt)
+	if err != nil {
+		t.Fatalf("ProveTransaction failed: %v", err)
+	}
+	sighash, _ := GetSighash(proved, 0)
+	signature, _ := signMessage(privateKey, sighash)
+	signed, err := AppendSignature(proved, 0, signature)
+	if err != nil {
+		t.Fatalf("AppendSignature failed: %v", err)
+	}
+	if _, err := FinalizeAndExtract(signed); err != nil {
+		t.Fatalf("FinalizeAndExtract failed: %v", err)
+	}
+
+	want := []struct {
+		stage      string
+		code       ResultCode
+		inputIndex int
+	}{
+		{StagePropose, ErrorProposal, -1},
+		{StagePropose, Success, -1},
+		{StageProve, Success, -1},
+		{StageSign, Success, 0},
+		{StageFinalize, Success, -1},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("Expected %d events, got %+v", len(want), events)
+	}
+	for i, w := range want {
+		e := events[i]
+		if e.Stage != w.stage || e.Code != w.code || e.InputIndex != w.inputIndex || e.Duration <= 0 {
+			t.Errorf("Event %d: expected %s with code %d, got %+v", i, w.stage, w.code, e)
+		}
+		if (e.Err == nil) != (w.code == Success) {
+			t.Errorf("Event %d: unexpected error %v", i, e.Err)
+		}
+	}
+
+	// Removing the logger stops the events
+	SetLogger(nil)
+	if _, err := ProposeTransaction(inputs, request); err != nil {
+		t.Fatalf("ProposeTransaction failed: %v", err)
+	}
+	if len(events) != len(want) {
+		t.Errorf("Expected no events after SetLogger(nil), got %d", len(events)-len(want))
+	}
+}
@@ -0,0 +1,617 @@
+//go:build t2z_mock
+
+package t2z
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// Mock backend, built with the t2z_mock tag instead of native.go, for
+// testing code that uses t2z without the native library.
+//
+// A mock PCZT is kept serialized in the format read by decodePCZT, so
+// SerializePCZT, ParsePCZT and everything built on them (DumpPCZTJSON,
+// DiffPCZT, signature bundles, custom sighash types) behave as with the
+// native library. The mock differs from it in that:
+//   - Sighashes are BLAKE2b hashes of the unsigned PCZT, the input index and
+//     its sighash type, not ZIP 244 signature digests
+//   - Orchard action fields are placeholder hashes, and proofs and
+//     signatures are zero bytes of the right size; a proof that is not zero
+//     fails to verify. Memos are accepted but not encrypted
+//   - Serialized PCZTs leave out fields decodePCZT does not keep, so they
+//     are smaller than native ones
+//
+// Fees, change, the order of outputs and the error codes match the native
+// library, and signatures are checked against the input pubkeys, so
+// orchestration code sees the same results and failures.
+
+// mockRequest holds the payments and settings of a transaction request
+type mockRequest struct {
+	payments     []Payment
+	memos        []string
+	targetHeight uint32
+	mainnet      bool
+}
+
+// mockPCZT holds a serialized PCZT
+type mockPCZT struct {
+	data []byte
+}
+
+type (
+	requestHandle = *mockRequest
+	pcztHandle    = *mockPCZT
+)
+
+// Target height used until SetTargetHeight, as by the native library
+const mockDefaultTargetHeight = 2_500_000
+
+// mockUpgrade is a network upgrade that uses v5 transactions
+type mockUpgrade struct {
+	branchID      uint32
+	mainnetHeight uint32
+	testnetHeight uint32
+}
+
+// Upgrades in activation order
+var mockUpgrades = []mockUpgrade{
+	{0xc2d6d0b4, 1_687_104, 1_842_420}, // NU5
+	{0xc8e71055, 2_726_400, 2_976_000}, // NU6
+	{0x4dec4df0, 3_146_400, 3_536_500}, // NU6.1
+}
+
+var mockLastError struct {
+	mu  sync.Mutex
+	msg string
+}
+
+// lastError returns the message of the last mock error
+func lastError(int) string {
+	mockLastError.mu.Lock()
+	defer mockLastError.mu.Unlock()
+	return mockLastError.msg
+}
+
+// mockError records a failure as the last error and returns it. inputIndex
+// is -1 if the failure is not input-specific.
+func mockError(code ResultCode, inputIndex int, format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+
+	mockLastError.mu.Lock()
+	mockLastError.msg = msg
+	mockLastError.mu.Unlock()
+
+	return &Error{Code: code, Message: msg, InputIndex: inputIndex}
+}
+
+// ffiRequestNew keeps the payments; like the native library, addresses are
+// only decoded when proposing
+func ffiRequestNew(payments []Payment, memos []string) (requestHandle, error) {
+	return &mockRequest{
+		payments:     slices.Clone(payments),
+		memos:        slices.Clone(memos),
+		targetHeight: mockDefaultTargetHeight,
+		mainnet:      true,
+	}, nil
+}
+
+func ffiRequestFree(requestHandle) {}
+
+func ffiRequestSetTargetHeight(handle requestHandle, height uint32) error {
+	handle.targetHeight = height
+	return nil
+}
+
+func ffiRequestSetUseMainnet(handle requestHandle, useMainnet bool) error {
+	handle.mainnet = useMainnet
+	return nil
+}
+
+func ffiPCZTFree(pcztHandle) {}
+
+// mockBranchID returns the consensus branch ID for a target height, or false
+// if the height is before NU5
+func mockBranchID(height uint32, mainnet bool) (uint32, bool) {
+	var id uint32
+	for _, u := range mockUpgrades {
+		activation := u.testnetHeight
+		if mainnet {
+			activation = u.mainnetHeight
+		}
+		if height >= activation {
+			id = u.branchID
+		}
+	}
+	return id, id != 0
+}
+
+func ffiProposeTransaction(inputs []TransparentInput, request requestHandle, changeAddress string) (pcztHandle, error) {
+	branchID, ok := mockBranchID(request.targetHeight, request.mainnet)
+	if !ok {
+		return nil, mockError(ErrorProposal, -1, "target height %d is before NU5, v4 transactions are not supported", request.targetHeight)
+	}
+
+	p := &pcztContents{
+		TxVersion:         txVersion5,
+		VersionGroupID:    txVersionGroupIDV5,
+		ConsensusBranchID: branchID,
+		ExpiryHeight:      request.targetHeight + ExpiryDelta,
+		CoinType:          1,
+	}
+	if request.mainnet {
+		p.CoinType = 133
+	}
+
+	for i, input := range inputs {
+		if len(input.Pubkey) != 33 {
+			return nil, mockError(ErrorProposal, -1, "input %d: invalid pubkey length %d", i, len(input.Pubkey))
+		}
+		p.Inputs = append(p.Inputs, pcztInput{
+			PrevTxID:          input.TxID,
+			PrevIndex:         input.Vout,
+			Value:             input.Amount,
+			ScriptPubKey:      slices.Clone(input.ScriptPubKey),
+			PartialSignatures: map[string][]byte{},
+			SighashType:       byte(SighashAll),
+			Hash160Preimages:  map[string][]byte{string(hash160(input.Pubkey)): slices.Clone(input.Pubkey)},
+		})
+	}
+
+	orchardOutputs := 0
+	for i, payment := range request.payments {
+		info, err := ValidateAddress(payment.Address)
+		if err != nil {
+			return nil, mockError(ErrorProposal, -1, "payment %d: %v", i, err)
+		}
+		if info.Kind == AddressSapling {
+			return nil, mockError(ErrorProposal, -1, "payment %d: Sapling addresses are not supported", i)
+		}
+		if info.Kind == AddressUnified {
+			orchardOutputs++
+			p.OrchardValueBalance -= int64(payment.Amount)
+			continue
+		}
+		script, err := addressToScript(payment.Address)
+		if err != nil {
+			return nil, mockError(ErrorProposal, -1, "%v", err)
+		}
+		p.Outputs = append(p.Outputs, pcztOutput{Value: payment.Amount, ScriptPubKey: script})
+	}
+
+	_, change, err := proposalShape(inputs, &TransactionRequest{Payments: request.payments})
+	if err != nil {
+		return nil, mockError(ErrorProposal, -1, "%v", err)
+	}
+	if change > 0 {
+		script := p2pkhScript(hash160(inputs[0].Pubkey))
+		if changeAddress != "" {
+			if script, err = addressToScript(changeAddress); err != nil {
+				return nil, mockError(ErrorProposal, -1, "change address: %v", err)
+			}
+		}
+		p.Outputs = append(p.Outputs, pcztOutput{Value: change, ScriptPubKey: script})
+	}
+
+	if orchardOutputs > 0 {
+		// Dummy spends are signed during proposal
+		n := CalculateFeeDetailed(NewFeeParams(0, 0, orchardOutputs)).OrchardActions
+		for i := range n {
+			p.OrchardActions = append(p.OrchardActions, mockAction(p, i))
+		}
+		p.OrchardFlags = OrchardFlagSpendsEnabled | OrchardFlagOutputsEnabled
+	}
+
+	return &mockPCZT{data: encodeMockPCZT(p)}, nil
+}
+
+func ffiProveTransaction(handle pcztHandle) (pcztHandle, error) {
+	p, err := handle.contents()
+	if err != nil {
+		return nil, err
+	}
+	if len(p.OrchardActions) > 0 {
+		p.OrchardProof = make([]byte, orchardProofSize(len(p.OrchardActions)))
+	}
+	return &mockPCZT{data: encodeMockPCZT(p)}, nil
+}
+
+func ffiGetSighash(handle pcztHandle, inputIndex uint) ([32]byte, error) {
+	p, err := handle.contents()
+	if err != nil {
+		return [32]byte{}, err
+	}
+	if inputIndex >= uint(len(p.Inputs)) {
+		return [32]byte{}, mockError(ErrorSighash, int(inputIndex), "input index out of range, PCZT has %d transparent inputs", len(p.Inputs))
+	}
+	if !SighashType(p.Inputs[inputIndex].SighashType).valid() {
+		return [32]byte{}, mockError(ErrorSighash, int(inputIndex), "invalid sighash type 0x%02x", p.Inputs[inputIndex].SighashType)
+	}
+	return mockSighash(p, inputIndex), nil
+}
+
+func ffiAppendSignature(handle pcztHandle, inputIndex uint, signature [64]byte) (pcztHandle, error) {
+	p, err := handle.contents()
+	if err != nil {
+		return nil, err
+	}
+	if inputIndex >= uint(len(p.Inputs)) {
+		return nil, mockError(ErrorSignature, int(inputIndex), "input index out of range, PCZT has %d transparent inputs", len(p.Inputs))
+	}
+
+	in := &p.Inputs[inputIndex]
+	if !isP2PKHScript(in.ScriptPubKey) {
+		return nil, mockError(ErrorSignature, int(inputIndex), "only P2PKH inputs can be signed")
+	}
+	pubkey, ok := in.Hash160Preimages[string(in.ScriptPubKey[3:23])]
+	if !ok {
+		return nil, mockError(ErrorSignature, int(inputIndex), "PCZT does not contain the input's public key")
+	}
+	if !VerifySignature(pubkey, mockSighash(p, inputIndex), signature) {
+		return nil, mockError(ErrorSignature, int(inputIndex), "invalid signature")
+	}
+
+	var r, s secp256k1.ModNScalar
+	r.SetByteSlice(signature[:32])
+	s.SetByteSlice(signature[32:])
+	in.PartialSignatures[string(pubkey)] = append(ecdsa.NewSignature(&r, &s).Serialize(), in.SighashType)
+
+	return &mockPCZT{data: encodeMockPCZT(p)}, nil
+}
+
+func ffiFinalizeAndExtract(handle pcztHandle) ([]byte, error) {
+	p, err := handle.contents()
+	if err != nil {
+		return nil, err
+	}
+
+	scriptSigs := make([][]byte, len(p.Inputs))
+	for i, in := range p.Inputs {
+		if in.ScriptSig != nil {
+			scriptSigs[i] = in.ScriptSig
+			continue
+		}
+		if len(in.PartialSignatures) == 0 {
+			return nil, mockError(ErrorFinalization, -1, "input %d is not signed", i)
+		}
+		pubkey := slices.Min(slices.Collect(maps.Keys(in.PartialSignatures)))
+		sig := in.PartialSignatures[pubkey]
+
+		// <sig> <pubkey>
+		scriptSig := append([]byte{byte(len(sig))}, sig...)
+		scriptSig = append(scriptSig, byte(len(pubkey)))
+		scriptSigs[i] = append(scriptSig, pubkey...)
+	}
+	if len(p.OrchardActions) > 0 && p.OrchardProof == nil {
+		return nil, mockError(ErrorFinalization, -1, "Orchard bundle is not proved")
+	}
+	// The placeholder proof is all zero, anything else does not verify
+	if slices.ContainsFunc(p.OrchardProof, func(b byte) bool { return b != 0 }) {
+		return nil, mockError(ErrorFinalization, -1, "InvalidProof: Orchard proof does not verify")
+	}
+
+	return encodeMockTransaction(p, scriptSigs), nil
+}
+
+func ffiParsePCZT(pcztBytes []byte) (pcztHandle, error) {
+	if _, err := decodePCZT(pcztBytes); err != nil {
+		return nil, mockError(ErrorParse, -1, "%v", err)
+	}
+	return &mockPCZT{data: slices.Clone(pcztBytes)}, nil
+}
+
+func ffiSerializePCZT(handle pcztHandle) ([]byte, error) {
+	return slices.Clone(handle.data), nil
+}
+
+func ffiClonePCZT(handle pcztHandle) (pcztHandle, error) {
+	return &mockPCZT{data: slices.Clone(handle.data)}, nil
+}
+
+func ffiCombine(handles []pcztHandle) (pcztHandle, error) {
+	base, err := handles[0].contents()
+	if err != nil {
+		return nil, err
+	}
+	unsigned := encodeUnsigned(base)
+
+	for i, handle := range handles[1:] {
+		p, err := handle.contents()
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(encodeUnsigned(p), unsigned) {
+			return nil, mockError(ErrorCombine, -1, "PCZT %d is for a different transaction", i+1)
+		}
+
+		for j, in := range p.Inputs {
+			maps.Copy(base.Inputs[j].PartialSignatures, in.PartialSignatures)
+			if base.Inputs[j].ScriptSig == nil {
+				base.Inputs[j].ScriptSig = in.ScriptSig
+			}
+		}
+		if base.OrchardProof == nil {
+			base.OrchardProof = p.OrchardProof
+		}
+	}
+
+	return &mockPCZT{data: encodeMockPCZT(base)}, nil
+}
+
+func ffiVerifyBeforeSigning(handle pcztHandle, request requestHandle, expectedChange []TransparentOutput) error {
+	p, err := handle.contents()
+	if err != nil {
+		return err
+	}
+
+	matches := func(value uint64, script []byte) func(pcztOutput) bool {
+		return func(out pcztOutput) bool {
+			return out.Value == value && bytes.Equal(out.ScriptPubKey, script)
+		}
+	}
+
+	outputs := slices.Clone(p.Outputs)
+	var shielded int64
+	for i, payment := range request.payments {
+		if !isTransparentAddress(payment.Address) {
+			shielded += int64(payment.Amount)
+			continue
+		}
+		script, err := addressToScript(payment.Address)
+		if err != nil {
+			return mockError(ErrorVerification, -1, "payment %d: %v", i, err)
+		}
+		j := slices.IndexFunc(outputs, matches(payment.Amount, script))
+		if j < 0 {
+			return mockError(ErrorVerification, -1, "payment %d to %s is missing", i, payment.Address)
+		}
+		outputs = slices.Delete(outputs, j, j+1)
+	}
+	if -p.OrchardValueBalance < shielded {
+		return mockError(ErrorVerification, -1, "Orchard outputs pay %d zatoshis, payments require %d", -p.OrchardValueBalance, shielded)
+	}
+
+	for i, change := range expectedChange {
+		j := slices.IndexFunc(outputs, matches(change.Value, change.ScriptPubKey))
+		if j < 0 {
+			return mockError(ErrorVerification, -1, "expected change output %d is missing", i)
+		}
+		outputs = slices.Delete(outputs, j, j+1)
+	}
+	if len(outputs) > 0 {
+		return mockError(ErrorVerification, -1, "unexpected transparent output of %d zatoshis", outputs[0].Value)
+	}
+	return nil
+}
+
+// contents decodes the PCZT
+func (h *mockPCZT) contents() (*pcztContents, error) {
+	p, err := decodePCZT(h.data)
+	if err != nil {
+		return nil, mockError(ErrorParse, -1, "%v", err)
+	}
+	return p, nil
+}
+
+// mockAction returns a signed dummy Orchard action. Its fields are
+// placeholder hashes of the proposal and the action index, so that actions
+// differ between and within transactions like native ones; the ciphertexts
+// are zero.
+func mockAction(p *pcztContents, index int) pcztAction {
+	proposal := encodeMockPCZT(p)
+	field := func(name string) []byte {
+		h := blake2b256Personal([]byte("t2z_mock_action"), proposal, []byte{byte(index)}, []byte(name))
+		return h[:]
+	}
+	return pcztAction{
+		CvNet:        field("cv_net"),
+		Nullifier:    field("nullifier"),
+		Rk:           field("rk"),
+		SpendAuthSig: true,
+		Cmx:          field("cmx"),
+		EphemeralKey: field("ephemeral key"),
+	}
+}
+
+// mockSighash returns the placeholder sighash of an input
+func mockSighash(p *pcztContents, inputIndex uint) [32]byte {
+	var tail [5]byte
+	binary.LittleEndian.PutUint32(tail[:], uint32(inputIndex))
+	tail[4] = p.Inputs[inputIndex].SighashType
+	return blake2b256Personal([]byte("t2z_mock_sighash"), encodeUnsigned(p), tail[:])
+}
+
+// encodeUnsigned serializes p without transparent signatures, sighash types
+// and the Orchard proof, which signatures do not commit to
+func encodeUnsigned(p *pcztContents) []byte {
+	unsigned := *p
+	unsigned.Inputs = slices.Clone(p.Inputs)
+	for i := range unsigned.Inputs {
+		unsigned.Inputs[i].PartialSignatures = nil
+		unsigned.Inputs[i].SighashType = 0
+		unsigned.Inputs[i].ScriptSig = nil
+	}
+	unsigned.OrchardProof = nil
+	return encodeMockPCZT(&unsigned)
+}
+
+// encodeMockPCZT serializes p in the format read by decodePCZT. Fields that
+// pcztContents does not keep are left empty, or zero if they are required.
+func encodeMockPCZT(p *pcztContents) []byte {
+	b := binary.LittleEndian.AppendUint32(slices.Clone(pcztMagic), pcztFormatVersion)
+
+	varint := func(v uint64) { b = binary.AppendUvarint(b, v) }
+	zeros := func(n int) { b = append(b, make([]byte, n)...) }
+	none := zeros // absent Options are a zero tag byte each
+	fixed := func(data []byte, n int) {
+		b = append(b, data...)
+		zeros(n - len(data))
+	}
+	bytesField := func(data []byte) {
+		varint(uint64(len(data)))
+		b = append(b, data...)
+	}
+	optionalBytes := func(data []byte) {
+		if data == nil {
+			none(1)
+			return
+		}
+		b = append(b, 1)
+		bytesField(data)
+	}
+	bytesMap := func(m map[string][]byte) {
+		varint(uint64(len(m)))
+		for _, key := range slices.Sorted(maps.Keys(m)) {
+			b = append(b, key...)
+			bytesField(m[key])
+		}
+	}
+
+	// Global
+	varint(uint64(p.TxVersion))
+	varint(uint64(p.VersionGroupID))
+	varint(uint64(p.ConsensusBranchID))
+	none(1) // fallback lock time
+	varint(uint64(p.ExpiryHeight))
+	varint(uint64(p.CoinType))
+	b = append(b, 0) // tx modifiable flags
+	varint(0)        // proprietary
+
+	// Transparent
+	varint(uint64(len(p.Inputs)))
+	for _, in := range p.Inputs {
+		b = append(b, in.PrevTxID[:]...)
+		varint(uint64(in.PrevIndex))
+		none(3) // sequence, required lock times
+		optionalBytes(in.ScriptSig)
+		varint(in.Value)
+		bytesField(in.ScriptPubKey)
+		none(1) // redeem script
+		bytesMap(in.PartialSignatures)
+		b = append(b, in.SighashType)
+		varint(0) // BIP 32 derivations
+		varint(0) // RIPEMD-160 preimages
+		varint(0) // SHA-256 preimages
+		bytesMap(in.Hash160Preimages)
+		varint(0) // HASH256 preimages
+		varint(0) // proprietary
+	}
+	varint(uint64(len(p.Outputs)))
+	for _, out := range p.Outputs {
+		varint(out.Value)
+		bytesField(out.ScriptPubKey)
+		none(1)   // redeem script
+		varint(0) // BIP 32 derivations
+		if out.UserAddress != "" {
+			optionalBytes([]byte(out.UserAddress))
+		} else {
+			none(1)
+		}
+		varint(0) // proprietary
+	}
+
+	// Sapling: no spends or outputs, zero value sum and anchor, no bsk
+	varint(0)
+	varint(0)
+	varint(0)
+	zeros(32)
+	none(1)
+
+	// Orchard
+	varint(uint64(len(p.OrchardActions)))
+	for _, a := range p.OrchardActions {
+		fixed(a.CvNet, 32)
+		fixed(a.Nullifier, 32)
+		fixed(a.Rk, 32)
+		if a.SpendAuthSig {
+			b = append(b, 1)
+			zeros(64)
+		} else {
+			none(1)
+		}
+		none(9)   // remaining spend fields
+		varint(0) // spend proprietary
+		fixed(a.Cmx, 32)
+		fixed(a.EphemeralKey, 32)
+		varint(orchardEncCiphertextSize)
+		fixed(a.EncCiphertext, orchardEncCiphertextSize)
+		varint(orchardOutCiphertextSize)
+		fixed(a.OutCiphertext, orchardOutCiphertextSize)
+		none(6)   // remaining output fields
+		varint(0) // output proprietary
+		none(1)   // rcv
+	}
+	b = append(b, p.OrchardFlags)
+	if p.OrchardValueBalance < 0 {
+		varint(uint64(-p.OrchardValueBalance))
+		b = append(b, 1)
+	} else {
+		varint(uint64(p.OrchardValueBalance))
+		b = append(b, 0)
+	}
+	zeros(32) // anchor
+	optionalBytes(p.OrchardProof)
+	none(1) // bsk
+
+	return b
+}
+
+// encodeMockTransaction serializes the v5 transaction of a finalized PCZT
+func encodeMockTransaction(p *pcztContents, scriptSigs [][]byte) []byte {
+	le := binary.LittleEndian
+	tx := le.AppendUint32(nil, txVersion5|1<<31)
+	tx = le.AppendUint32(tx, p.VersionGroupID)
+	tx = le.AppendUint32(tx, p.ConsensusBranchID)
+	tx = le.AppendUint32(tx, 0) // lock time
+	tx = le.AppendUint32(tx, p.ExpiryHeight)
+
+	tx = appendCompactSize(tx, uint64(len(p.Inputs)))
+	for i, in := range p.Inputs {
+		tx = append(tx, in.PrevTxID[:]...)
+		tx = le.AppendUint32(tx, in.PrevIndex)
+		tx = appendCompactSize(tx, uint64(len(scriptSigs[i])))
+		tx = append(tx, scriptSigs[i]...)
+		tx = le.AppendUint32(tx, 0xffffffff)
+	}
+	tx = appendCompactSize(tx, uint64(len(p.Outputs)))
+	for _, out := range p.Outputs {
+		tx = le.AppendUint64(tx, out.Value)
+		tx = appendCompactSize(tx, uint64(len(out.ScriptPubKey)))
+		tx = append(tx, out.ScriptPubKey...)
+	}
+
+	// No Sapling spends or outputs
+	tx = append(tx, 0, 0)
+
+	n := len(p.OrchardActions)
+	tx = appendCompactSize(tx, uint64(n))
+	if n > 0 {
+		fixed := func(data []byte, n int) {
+			tx = append(tx, data...)
+			tx = append(tx, make([]byte, n-len(data))...)
+		}
+		for _, a := range p.OrchardActions {
+			fixed(a.CvNet, 32)
+			fixed(a.Nullifier, 32)
+			fixed(a.Rk, 32)
+			fixed(a.Cmx, 32)
+			fixed(a.EphemeralKey, 32)
+			fixed(a.EncCiphertext, orchardEncCiphertextSize)
+			fixed(a.OutCiphertext, orchardOutCiphertextSize)
+		}
+		tx = append(tx, p.OrchardFlags)
+		tx = le.AppendUint64(tx, uint64(p.OrchardValueBalance))
+		tx = append(tx, make([]byte, 32)...) // anchor
+		tx = appendCompactSize(tx, uint64(len(p.OrchardProof)))
+		tx = append(tx, p.OrchardProof...)
+		tx = append(tx, make([]byte, (n+1)*64)...) // spend auth and binding signatures
+	}
+	return tx
+}
@@ -0,0 +1,170 @@
+//go:build t2z_mock
+
+package t2z
+
+import (
+	"errors"
+	"testing"
+)
+
+// mockBackend reports whether the tests run against the mock backend, whose
+// sighashes and PCZT encoding differ from the native library
+const mockBackend = true
+
+// hasBackend reports whether calls that need the native library work
+const hasBackend = true
+
+// Test the whole workflow against the mock backend
+func TestMockWorkflow(t *testing.T) {
+	privateKey, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_mock_workflow_00000000"))
+
+	inputs := []TransparentInput{{
+		Pubkey:       pubkey,
+		TxID:         txid,
+		Amount:       100_000_000,
+		ScriptPubKey: createP2PKHScript(pubkey),
+	}}
+	request, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 50_000_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("ProposeTransaction failed: %v", err)
+	}
+	change, err := ExpectedChange(inputs, request, "")
+	if err != nil || len(change) != 1 || change[0].Value != 50_000_000-CalculateFee(1, 2, 0) {
+		t.Fatalf("Unexpected change %+v: %v", change, err)
+	}
+	if err := VerifyBeforeSigning(pczt, request, nil); !errors.Is(err, ErrVerification) {
+		t.Errorf("Expected ErrVerification without the change output, got %v", err)
+	}
+	if err := VerifyBeforeSigning(pczt, request, change); err != nil {
+		t.Errorf("VerifyBeforeSigning failed: %v", err)
+	}
+
+	proved, err := ProveTransaction(pczt)
+	if err != nil {
+		t.Fatalf("ProveTransaction failed: %v", err)
+	}
+	sighash, err := GetSighash(proved, 0)
+	if err != nil {
+		t.Fatalf("GetSighash failed: %v", err)
+	}
+	if again, _ := GetSighash(proved, 0); again != sighash {
+		t.Error("Expected a deterministic sighash")
+	}
+
+	// Signatures are checked like by the native library
+	otherKey := make([]byte, 32)
+	otherKey[31] = 2
+	badSig, _ := signMessage(otherKey, sighash)
+	backup, _ := ClonePCZT(proved)
+	if _, err := AppendSignature(backup, 0, badSig); !errors.Is(err, ErrSignature) {
+		t.Errorf("Expected ErrSignature for a wrong key, got %v", err)
+	}
+
+	signed, err := SignAllInputs(proved, inputs, SignerFunc(func(sighash [32]byte, _ []byte) ([64]byte, error) {
+		return signMessage(privateKey, sighash)
+	}))
+	if err != nil {
+		t.Fatalf("SignAllInputs failed: %v", err)
+	}
+	txBytes, err := FinalizeAndExtract(signed)
+	if err != nil {
+		t.Fatalf("FinalizeAndExtract failed: %v", err)
+	}
+
+	tx, err := DecodeTransaction(txBytes)
+	if err != nil {
+		t.Fatalf("Failed to decode transaction: %v", err)
+	}
+	if len(tx.Inputs) != 1 || tx.Inputs[0].PrevTxID != txid || len(tx.Outputs) != 2 || tx.ExpiryHeight != 2_500_040 {
+		t.Errorf("Unexpected transaction %+v", tx)
+	}
+	if _, err := TransactionID(txBytes); err != nil {
+		t.Errorf("TransactionID failed: %v", err)
+	}
+}
+
+// Test that shielded payments produce a padded, proved Orchard bundle
+func TestMockShieldedOutput(t *testing.T) {
+	privateKey, pubkey := createTestKeypair()
+	inputs := []TransparentInput{{
+		Pubkey:       pubkey,
+		Amount:       100_000_000,
+		ScriptPubKey: createP2PKHScript(pubkey),
+	}}
+	request, err := NewTransactionRequest([]Payment{{Address: warmUpRecipient, Amount: 10_000_000, Memo: "hi"}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("ProposeTransaction failed: %v", err)
+	}
+	if _, err := FinalizeAndExtract(pczt); !errors.Is(err, ErrFinalization) {
+		t.Errorf("Expected ErrFinalization before proving, got %v", err)
+	}
+
+	pczt, _ = ProposeTransaction(inputs, request)
+	proved, err := ProveTransaction(pczt)
+	if err != nil {
+		t.Fatalf("ProveTransaction failed: %v", err)
+	}
+	if err := VerifyProofs(proved); err != nil {
+		t.Errorf("VerifyProofs failed: %v", err)
+	}
+
+	signed, err := SignAllInputs(proved, inputs, SignerFunc(func(sighash [32]byte, _ []byte) ([64]byte, error) {
+		return signMessage(privateKey, sighash)
+	}))
+	if err != nil {
+		t.Fatalf("SignAllInputs failed: %v", err)
+	}
+	txBytes, err := FinalizeAndExtract(signed)
+	if err != nil {
+		t.Fatalf("FinalizeAndExtract failed: %v", err)
+	}
+	tx, err := DecodeTransaction(txBytes)
+	if err != nil {
+		t.Fatalf("Failed to decode transaction: %v", err)
+	}
+	if len(tx.OrchardActions) != 2 || tx.OrchardValueBalance != -10_000_000 || len(tx.Outputs) != 1 {
+		t.Errorf("Unexpected transaction: %d actions, value balance %d, %d outputs", len(tx.OrchardActions), tx.OrchardValueBalance, len(tx.Outputs))
+	}
+}
+
+// Test that the mock fails like the native library on bad input
+func TestMockErrors(t *testing.T) {
+	_, pubkey := createTestKeypair()
+	inputs := []TransparentInput{{Pubkey: pubkey, Amount: 10_000, ScriptPubKey: createP2PKHScript(pubkey)}}
+	request, _ := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 50_000}})
+	defer request.Free()
+
+	_, err := ProposeTransaction(inputs, request)
+	var t2zErr *Error
+	if !errors.As(err, &t2zErr) || t2zErr.Code != ErrorProposal || lastError(0) != t2zErr.Message {
+		t.Errorf("Expected ErrorProposal for insufficient funds, got %v", err)
+	}
+
+	request.SetTargetHeight(1_000_000)
+	inputs[0].Amount = 100_000
+	if _, err := ProposeTransaction(inputs, request); !errors.Is(err, ErrProposal) {
+		t.Errorf("Expected ErrProposal before NU5, got %v", err)
+	}
+
+	if _, err := ParsePCZT([]byte("not a pczt")); !errors.Is(err, ErrParse) {
+		t.Errorf("Expected ErrParse, got %v", err)
+	}
+	if _, err := ParsePCZT([]byte("PC")); !errors.Is(err, ErrParse) {
+		t.Errorf("Expected ErrParse for truncated data, got %v", err)
+	}
+}
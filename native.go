@@ -0,0 +1,426 @@
+//go:build cgo && (darwin || linux || windows) && (amd64 || arm64) && !t2z_mock
+
+package t2z
+
+// #cgo CFLAGS: -I${SRCDIR}/include
+// #cgo darwin,arm64 LDFLAGS: ${SRCDIR}/lib/darwin-arm64/libt2z.a -ldl -lm -framework Security -framework Foundation
+// #cgo darwin,amd64 LDFLAGS: ${SRCDIR}/lib/darwin-x64/libt2z.a -ldl -lm -framework Security -framework Foundation
+// #cgo linux,amd64 LDFLAGS: ${SRCDIR}/lib/linux-x64/libt2z.a -ldl -lm -lpthread
+// #cgo linux,arm64 LDFLAGS: ${SRCDIR}/lib/linux-arm64/libt2z.a -ldl -lm -lpthread
+// #cgo windows,amd64 LDFLAGS: ${SRCDIR}/lib/windows-x64/t2z.lib
+// #cgo windows,arm64 LDFLAGS: ${SRCDIR}/lib/windows-arm64/t2z.lib
+// #include <stdlib.h>
+// #include "t2z.h"
+import "C"
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"slices"
+	"unsafe"
+)
+
+// The backend functions below are the only calls into the Rust library. The
+// mock backend (mock.go) implements the same set in Go.
+//
+// The native library keeps the last error message per OS thread, so every
+// function that can fail locks its goroutine to the thread for the call and
+// the error lookup.
+
+// requestHandle and pcztHandle are the native handles owned by
+// TransactionRequest and PCZT
+type (
+	requestHandle = *C.TransactionRequestHandle
+	pcztHandle    = *C.PcztHandle
+)
+
+// The result codes and fee constants in t2z.go mirror t2z.h so that they are
+// defined without cgo. Each index is out of range if the two differ.
+var _ = [...]int{
+	[1]int{}[Success-C.SUCCESS],
+	[1]int{}[ErrorNullPointer-C.ERROR_NULL_POINTER],
+	[1]int{}[ErrorInvalidUTF8-C.ERROR_INVALID_UTF8],
+	[1]int{}[ErrorBufferTooSmall-C.ERROR_BUFFER_TOO_SMALL],
+	[1]int{}[ErrorProposal-C.ERROR_PROPOSAL],
+	[1]int{}[ErrorProver-C.ERROR_PROVER],
+	[1]int{}[ErrorVerification-C.ERROR_VERIFICATION],
+	[1]int{}[ErrorSighash-C.ERROR_SIGHASH],
+	[1]int{}[ErrorSignature-C.ERROR_SIGNATURE],
+	[1]int{}[ErrorCombine-C.ERROR_COMBINE],
+	[1]int{}[ErrorFinalization-C.ERROR_FINALIZATION],
+	[1]int{}[ErrorParse-C.ERROR_PARSE],
+	[1]int{}[ErrorNotImplemented-C.ERROR_NOT_IMPLEMENTED],
+	[1]int{}[ZIP317MarginalFee-C.ZIP317_MARGINAL_FEE],
+	[1]int{}[ZIP317GraceActions-C.ZIP317_GRACE_ACTIONS],
+}
+
+// lastError retrieves the last error message, starting with a buffer of size
+// bytes and doubling it until the whole message fits
+func lastError(size int) string {
+	for {
+		buf := make([]byte, size)
+		code := C.pczt_get_last_error((*C.char)(unsafe.Pointer(&buf[0])), C.size_t(len(buf)))
+		if code == C.ERROR_BUFFER_TOO_SMALL && size < maxLastErrorBufferSize {
+			size *= 2
+			continue
+		}
+		if code != C.SUCCESS {
+			return "Failed to get last error"
+		}
+		// Find null terminator
+		if i := bytes.IndexByte(buf, 0); i >= 0 {
+			return string(buf[:i])
+		}
+		return string(buf)
+	}
+}
+
+// ffiRequestNew creates a request handle from validated payments, with
+// memos[i] the memo of payments[i] ("" if none)
+func ffiRequestNew(payments []Payment, memos []string) (requestHandle, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// Convert payments to C array
+	cPayments := make([]C.CPayment, len(payments))
+	var cStrings []*C.char
+
+	// Cleanup C strings when done
+	defer func() { freeCStrings(cStrings) }()
+	cString := func(s string) *C.char {
+		cs := C.CString(s)
+		cStrings = append(cStrings, cs)
+		return cs
+	}
+
+	for i, payment := range payments {
+		cPayments[i].address = cString(payment.Address)
+		cPayments[i].amount = C.uint64_t(payment.Amount)
+
+		// Convert optional fields
+		if memos[i] != "" {
+			cPayments[i].memo = cString(memos[i])
+		}
+		if payment.Label != "" {
+			cPayments[i].label = cString(payment.Label)
+		}
+		if payment.Message != "" {
+			cPayments[i].message = cString(payment.Message)
+		}
+	}
+
+	var handle *C.TransactionRequestHandle
+	code := C.pczt_transaction_request_new(
+		&cPayments[0],
+		C.size_t(len(payments)),
+		&handle,
+	)
+
+	if code != C.SUCCESS {
+		return nil, wrapError(ResultCode(code))
+	}
+
+	return handle, nil
+}
+
+// freeCStrings frees C strings allocated with C.CString
+func freeCStrings(cStrings []*C.char) {
+	for _, s := range cStrings {
+		C.free(unsafe.Pointer(s))
+	}
+}
+
+func ffiRequestFree(handle requestHandle) {
+	C.pczt_transaction_request_free(handle)
+}
+
+func ffiRequestSetTargetHeight(handle requestHandle, height uint32) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	code := C.pczt_transaction_request_set_target_height(
+		handle,
+		C.uint32_t(height),
+	)
+
+	if code != C.SUCCESS {
+		return wrapError(ResultCode(code))
+	}
+	return nil
+}
+
+func ffiRequestSetUseMainnet(handle requestHandle, useMainnet bool) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	code := C.pczt_transaction_request_set_use_mainnet(
+		handle,
+		C.bool(useMainnet),
+	)
+
+	if code != C.SUCCESS {
+		return wrapError(ResultCode(code))
+	}
+	return nil
+}
+
+func ffiPCZTFree(handle pcztHandle) {
+	C.pczt_free(handle)
+}
+
+// ffiProposeTransaction proposes a transaction with transparent change to
+// changeAddress, or to the first input's pubkey if it is empty
+func ffiProposeTransaction(inputs []TransparentInput, request requestHandle, changeAddress string) (pcztHandle, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// Serialize inputs to the binary format
+	inputBytes := serializeTransparentInputs(inputs)
+
+	// Convert change address to C string (nullable)
+	var cChangeAddr *C.char
+	if changeAddress != "" {
+		cChangeAddr = C.CString(changeAddress)
+		defer C.free(unsafe.Pointer(cChangeAddr))
+	}
+
+	var pcztHandle *C.PcztHandle
+	code := C.pczt_propose_transaction(
+		(*C.uint8_t)(unsafe.Pointer(&inputBytes[0])),
+		C.size_t(len(inputBytes)),
+		request,
+		cChangeAddr,
+		&pcztHandle,
+	)
+
+	if code != C.SUCCESS {
+		return nil, wrapError(ResultCode(code))
+	}
+
+	return pcztHandle, nil
+}
+
+// ffiProveTransaction consumes handle
+func ffiProveTransaction(handle pcztHandle) (pcztHandle, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var outHandle *C.PcztHandle
+	code := C.pczt_prove_transaction(handle, &outHandle)
+
+	if code != C.SUCCESS {
+		return nil, wrapError(ResultCode(code))
+	}
+
+	return outHandle, nil
+}
+
+func ffiGetSighash(handle pcztHandle, inputIndex uint) ([32]byte, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var sighash [32]byte
+	code := C.pczt_get_sighash(
+		handle,
+		C.size_t(inputIndex),
+		(*[32]C.uint8_t)(unsafe.Pointer(&sighash[0])),
+	)
+
+	if code != C.SUCCESS {
+		return [32]byte{}, wrapInputError(ResultCode(code), inputIndex)
+	}
+
+	return sighash, nil
+}
+
+// ffiAppendSignature consumes handle
+func ffiAppendSignature(handle pcztHandle, inputIndex uint, signature [64]byte) (pcztHandle, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var outHandle *C.PcztHandle
+	code := C.pczt_append_signature(
+		handle,
+		C.size_t(inputIndex),
+		(*[64]C.uint8_t)(unsafe.Pointer(&signature[0])),
+		&outHandle,
+	)
+
+	if code != C.SUCCESS {
+		return nil, wrapInputError(ResultCode(code), inputIndex)
+	}
+
+	return outHandle, nil
+}
+
+// ffiFinalizeAndExtract consumes handle
+func ffiFinalizeAndExtract(handle pcztHandle) ([]byte, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var txBytes *C.uint8_t
+	var txBytesLen C.size_t
+
+	code := C.pczt_finalize_and_extract(
+		handle,
+		&txBytes,
+		&txBytesLen,
+	)
+
+	if code != C.SUCCESS {
+		return nil, wrapError(ResultCode(code))
+	}
+
+	return copyNativeBytes(txBytes, txBytesLen)
+}
+
+// copyNativeBytes copies a buffer allocated by the Rust library into a Go
+// slice and frees it. An empty or missing buffer is an error rather than a
+// panic further down.
+func copyNativeBytes(ptr *C.uint8_t, n C.size_t) ([]byte, error) {
+	if ptr == nil {
+		return nil, errors.New("native library returned no data")
+	}
+	defer C.pczt_free_bytes(ptr, n)
+
+	if n == 0 {
+		return nil, errors.New("native library returned no data")
+	}
+	return slices.Clone(unsafe.Slice((*byte)(unsafe.Pointer(ptr)), n)), nil
+}
+
+func ffiParsePCZT(pcztBytes []byte) (pcztHandle, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var handle *C.PcztHandle
+	code := C.pczt_parse(
+		(*C.uint8_t)(unsafe.Pointer(&pcztBytes[0])),
+		C.size_t(len(pcztBytes)),
+		&handle,
+	)
+
+	if code != C.SUCCESS {
+		return nil, wrapError(ResultCode(code))
+	}
+
+	return handle, nil
+}
+
+func ffiSerializePCZT(handle pcztHandle) ([]byte, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var bytes *C.uint8_t
+	var bytesLen C.size_t
+
+	code := C.pczt_serialize(
+		handle,
+		&bytes,
+		&bytesLen,
+	)
+
+	if code != C.SUCCESS {
+		return nil, wrapError(ResultCode(code))
+	}
+
+	return copyNativeBytes(bytes, bytesLen)
+}
+
+// ffiClonePCZT hands the serialized bytes straight back to the parser,
+// without copying them into Go memory
+func ffiClonePCZT(handle pcztHandle) (pcztHandle, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var bytes *C.uint8_t
+	var bytesLen C.size_t
+
+	code := C.pczt_serialize(
+		handle,
+		&bytes,
+		&bytesLen,
+	)
+
+	if code != C.SUCCESS {
+		return nil, wrapError(ResultCode(code))
+	}
+
+	// Free the bytes allocated by Rust once parsed
+	defer C.pczt_free_bytes(bytes, bytesLen)
+
+	var clone *C.PcztHandle
+	code = C.pczt_parse(bytes, bytesLen, &clone)
+
+	if code != C.SUCCESS {
+		return nil, wrapError(ResultCode(code))
+	}
+
+	return clone, nil
+}
+
+// ffiCombine consumes all handles
+func ffiCombine(handles []pcztHandle) (pcztHandle, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var outHandle *C.PcztHandle
+	code := C.pczt_combine(
+		&handles[0],
+		C.uintptr_t(len(handles)),
+		&outHandle,
+	)
+
+	if code != C.SUCCESS {
+		return nil, wrapError(ResultCode(code))
+	}
+
+	return outHandle, nil
+}
+
+func ffiVerifyBeforeSigning(handle pcztHandle, request requestHandle, expectedChange []TransparentOutput) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// Convert expectedChange to C array, copying script data to C memory
+	// to avoid CGO pointer rules violation
+	cOutputs := make([]C.CTransparentOutput, len(expectedChange))
+	scriptPtrs := make([]unsafe.Pointer, len(expectedChange)) // Track for cleanup
+
+	for i, output := range expectedChange {
+		cOutputs[i].value = C.uint64_t(output.Value)
+		if len(output.ScriptPubKey) > 0 {
+			// Copy to C memory to avoid "Go pointer to Go pointer" issue
+			scriptPtrs[i] = C.CBytes(output.ScriptPubKey)
+			cOutputs[i].script_pub_key = (*C.uchar)(scriptPtrs[i])
+			cOutputs[i].script_pub_key_len = C.uintptr_t(len(output.ScriptPubKey))
+		}
+	}
+
+	// Ensure cleanup of C-allocated memory
+	defer func() {
+		for _, ptr := range scriptPtrs {
+			if ptr != nil {
+				C.free(ptr)
+			}
+		}
+	}()
+
+	var cOutputsPtr *C.CTransparentOutput
+	if len(cOutputs) > 0 {
+		cOutputsPtr = &cOutputs[0]
+	}
+
+	code := C.pczt_verify_before_signing(
+		handle,
+		request,
+		cOutputsPtr,
+		C.uintptr_t(len(expectedChange)),
+	)
+
+	if code != C.SUCCESS {
+		return wrapError(ResultCode(code))
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+//go:build cgo && (darwin || linux || windows) && (amd64 || arm64) && !t2z_mock
+
+package t2z
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// mockBackend reports whether the tests run against the mock backend, whose
+// sighashes and PCZT encoding differ from the native library
+const mockBackend = false
+
+// hasBackend reports whether calls that need the native library work
+const hasBackend = true
+
+// ExampleSerializePCZT demonstrates serializing a PCZT for transmission or storage.
+// Follows TypeScript patterns.
+func ExampleSerializePCZT() {
+	// Match TypeScript amounts
+	inputAmount := uint64(100_000_000) // 1 ZEC
+	paymentAmount := inputAmount / 2   // 50%
+
+	payments := []Payment{
+		{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: paymentAmount},
+	}
+	request, _ := NewTransactionRequest(payments)
+	defer request.Free()
+
+	// Mainnet is the default
+	request.SetTargetHeight(2_500_000)
+
+	privateKeyBytes := make([]byte, 32)
+	for i := range privateKeyBytes {
+		privateKeyBytes[i] = 1
+	}
+	privKey := secp256k1.PrivKeyFromBytes(privateKeyBytes)
+	pubKeyBytes := privKey.PubKey().SerializeCompressed()
+	scriptPubKey, _ := hex.DecodeString("76a91479b000887626b294a914501a4cd226b58b23598388ac")
+
+	var txid [32]byte
+	inputs := []TransparentInput{
+		{Pubkey: pubKeyBytes, TxID: txid, Vout: 0, Amount: inputAmount, ScriptPubKey: scriptPubKey},
+	}
+
+	pczt, _ := ProposeTransaction(inputs, request)
+
+	// Serialize PCZT (does not consume it)
+	pcztBytes, err := SerializePCZT(pczt)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Free after serialization
+	pczt.Free()
+
+	fmt.Printf("Serialized PCZT: %d bytes\n", len(pcztBytes))
+	// Output: Serialized PCZT: 367 bytes
+}
@@ -0,0 +1,98 @@
+package t2z
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+)
+
+// PCZT file layout: magic, format version, payload length and CRC-32 (all
+// little-endian), followed by the serialized PCZT
+var pcztFileMagic = []byte("T2ZP")
+
+const (
+	// PCZTFileVersion is the file format version written by WritePCZTFile
+	PCZTFileVersion = 1
+
+	pcztFileHeaderSize = 4 + 2 + 4 + 4
+)
+
+// WritePCZTFile stores a PCZT on disk with a header carrying a magic number,
+// the file format version and a CRC-32 of the PCZT, so ReadPCZTFile can
+// detect files that are truncated, corrupted or from a newer version.
+//
+// The file is written to a temporary file in the same directory and renamed
+// into place, so an interrupted write never leaves a partial file at path.
+// It is created with mode 0600. The PCZT is not consumed.
+func WritePCZTFile(path string, pczt *PCZT) error {
+	data, err := SerializePCZT(pczt)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 0, pcztFileHeaderSize)
+	header = append(header, pcztFileMagic...)
+	header = binary.LittleEndian.AppendUint16(header, PCZTFileVersion)
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(data)))
+	header = binary.LittleEndian.AppendUint32(header, crc32.ChecksumIEEE(data))
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".pczt-*")
+	if err != nil {
+		return fmt.Errorf("write PCZT file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	_, err = tmp.Write(append(header, data...))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("write PCZT file: %w", err)
+	}
+	return nil
+}
+
+// ReadPCZTFile reads a PCZT written by WritePCZTFile.
+//
+// Returns a descriptive error if the file is not a PCZT file, was written by
+// an unsupported format version, is truncated, or fails the CRC-32 check
+// (wrapping ErrChecksumMismatch).
+func ReadPCZTFile(path string) (*PCZT, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read PCZT file: %w", err)
+	}
+
+	if len(file) < len(pcztFileMagic) || !bytes.Equal(file[:len(pcztFileMagic)], pcztFileMagic) {
+		return nil, fmt.Errorf("read PCZT file %s: not a PCZT file (missing %q header)", path, pcztFileMagic)
+	}
+	if len(file) < pcztFileHeaderSize {
+		return nil, fmt.Errorf("read PCZT file %s: truncated header (%d bytes)", path, len(file))
+	}
+
+	version := binary.LittleEndian.Uint16(file[4:])
+	if version != PCZTFileVersion {
+		return nil, fmt.Errorf("read PCZT file %s: unsupported format version %d (supported: %d)", path, version, PCZTFileVersion)
+	}
+
+	length := binary.LittleEndian.Uint32(file[6:])
+	checksum := binary.LittleEndian.Uint32(file[10:])
+	data := file[pcztFileHeaderSize:]
+	if uint64(len(data)) < uint64(length) {
+		return nil, fmt.Errorf("read PCZT file %s: truncated (expected %d bytes of PCZT data, found %d)", path, length, len(data))
+	}
+	if uint64(len(data)) > uint64(length) {
+		return nil, fmt.Errorf("read PCZT file %s: %d unexpected bytes after the PCZT data", path, uint64(len(data))-uint64(length))
+	}
+	if crc32.ChecksumIEEE(data) != checksum {
+		return nil, fmt.Errorf("read PCZT file %s: %w", path, ErrChecksumMismatch)
+	}
+
+	return ParsePCZT(data)
+}
@@ -0,0 +1,111 @@
+//go:build (cgo && (darwin || linux || windows) && (amd64 || arm64)) || t2z_mock
+
+package t2z
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test that a PCZT round-trips through a file and damaged files are rejected
+// with descriptive errors
+func TestPCZTFile(t *testing.T) {
+	_, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_pczt_file_000000000000"))
+
+	inputs := []TransparentInput{{
+		Pubkey:       pubkey,
+		TxID:         txid,
+		Amount:       100_000_000,
+		ScriptPubKey: createP2PKHScript(pubkey),
+	}}
+	request, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 50_000_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+	request.SetTargetHeight(2_500_000)
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+	defer pczt.Free()
+
+	serialized, err := SerializePCZT(pczt)
+	if err != nil {
+		t.Fatalf("Failed to serialize PCZT: %v", err)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tx.pczt")
+	if err := WritePCZTFile(path, pczt); err != nil {
+		t.Fatalf("WritePCZTFile failed: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("file mode = %v, want 0600", info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("expected only the PCZT file in the directory, got %d entries", len(entries))
+	}
+
+	loaded, err := ReadPCZTFile(path)
+	if err != nil {
+		t.Fatalf("ReadPCZTFile failed: %v", err)
+	}
+	loadedBytes, err := SerializePCZT(loaded)
+	loaded.Free()
+	if err != nil || !bytes.Equal(loadedBytes, serialized) {
+		t.Errorf("file round-trip changed the PCZT (err: %v)", err)
+	}
+
+	file, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	corrupted := append([]byte(nil), file...)
+	corrupted[len(corrupted)-10] ^= 0x01
+	newer := append([]byte(nil), file...)
+	newer[4] = 2
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"hex", []byte("50435a5401000000"), "not a PCZT file"},
+		{"header", file[:8], "truncated header"},
+		{"version", newer, "unsupported format version 2"},
+		{"truncated", file[:len(file)-1], "truncated (expected"},
+		{"trailing", append(append([]byte(nil), file...), 0), "unexpected bytes"},
+		{"corrupted", corrupted, "checksum mismatch"},
+	}
+	for _, tt := range tests {
+		bad := filepath.Join(dir, tt.name)
+		if err := os.WriteFile(bad, tt.data, 0600); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		_, err := ReadPCZTFile(bad)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+		if tt.name == "corrupted" && !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("corrupted: expected ErrChecksumMismatch, got %v", err)
+		}
+	}
+
+	if _, err := ReadPCZTFile(filepath.Join(dir, "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist for a missing file, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package t2z
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+var (
+	// ErrInvalidProof is returned by VerifyProofs when the Orchard proof of a
+	// PCZT does not verify
+	ErrInvalidProof = errors.New("invalid Orchard proof")
+
+	// ErrProofMissing is returned by VerifyProofs for a PCZT with Orchard
+	// actions that has not been through ProveTransaction
+	ErrProofMissing = errors.New("PCZT has no Orchard proof")
+)
+
+// orchardProofSize returns the size of the Halo 2 proof for an Orchard bundle
+// with n actions
+func orchardProofSize(n int) int {
+	return 2720 + 2272*n
+}
+
+// VerifyProofs verifies the Orchard proof of a proved PCZT, for a party that
+// receives a PCZT from an untrusted prover and wants to check it before
+// signing.
+//
+// Orchard proves all actions of a bundle with a single proof, so a failure is
+// reported for the bundle as a whole, as an error wrapping ErrInvalidProof.
+// A PCZT with Orchard actions but no proof returns ErrProofMissing; a PCZT
+// without Orchard actions has nothing to verify and returns nil.
+//
+// The native library only verifies proofs while extracting a transaction, so
+// the check finalizes a throwaway copy in which unsigned transparent inputs
+// carry placeholder signatures. Transparent signatures are not part of the
+// Orchard statement, so this does not affect the result. Only P2PKH inputs
+// can be given placeholders.
+//
+// The PCZT is not consumed and may be signed before or after verification.
+func VerifyProofs(pczt *PCZT) error {
+	data, err := SerializePCZT(pczt)
+	if err != nil {
+		return err
+	}
+	p, err := decodePCZT(data)
+	if err != nil {
+		return err
+	}
+
+	if len(p.OrchardActions) == 0 {
+		return nil
+	}
+	if p.OrchardProof == nil {
+		return ErrProofMissing
+	}
+	if want := orchardProofSize(len(p.OrchardActions)); len(p.OrchardProof) != want {
+		return fmt.Errorf("%w: proof is %d bytes, want %d for %d actions", ErrInvalidProof, len(p.OrchardProof), want, len(p.OrchardActions))
+	}
+
+	patched, err := withPlaceholderSignatures(data, p)
+	if err != nil {
+		return fmt.Errorf("verify proofs: %w", err)
+	}
+	clone, err := ParsePCZT(patched)
+	if err != nil {
+		return fmt.Errorf("verify proofs: %w", err)
+	}
+
+	// Consumes the clone only
+	_, err = FinalizeAndExtract(clone)
+	var ffiErr *Error
+	if errors.As(err, &ffiErr) && strings.Contains(ffiErr.Message, "InvalidProof") {
+		return fmt.Errorf("%w: %s", ErrInvalidProof, ffiErr.Message)
+	}
+	if err != nil {
+		return fmt.Errorf("verify proofs: %w", err)
+	}
+	return nil
+}
+
+// withPlaceholderSignatures returns a copy of serialized PCZT data in which
+// every unsigned P2PKH input has a syntactically valid signature under its
+// own pubkey, so the copy can be finalized without the signing keys
+func withPlaceholderSignatures(data []byte, p *pcztContents) ([]byte, error) {
+	placeholder := placeholderSignature()
+
+	var out bytes.Buffer
+	last := 0
+	for i, in := range p.Inputs {
+		if in.Signed() {
+			continue
+		}
+		if !isP2PKHScript(in.ScriptPubKey) {
+			return nil, fmt.Errorf("input %d: placeholder signatures require a P2PKH input", i)
+		}
+		pubkey, ok := in.Pubkey()
+		if !ok {
+			return nil, fmt.Errorf("input %d: PCZT does not contain the input's public key", i)
+		}
+
+		// Replace the empty map (a single zero count byte) with one entry
+		sig := append(append([]byte(nil), placeholder...), in.SighashType)
+		out.Write(data[last:in.signaturesOffset])
+		out.WriteByte(1)
+		out.Write(pubkey)
+		out.WriteByte(byte(len(sig)))
+		out.Write(sig)
+		last = in.signaturesOffset + 1
+	}
+	out.Write(data[last:])
+	return out.Bytes(), nil
+}
+
+// placeholderSignature returns a DER-encoded signature by a fixed throwaway
+// key, used where a signature must be present but is never checked
+func placeholderSignature() []byte {
+	key := secp256k1.PrivKeyFromBytes(bytes.Repeat([]byte{0x01}, 32))
+	defer key.Zero()
+	return ecdsa.Sign(key, make([]byte, 32)).Serialize()
+}
@@ -0,0 +1,102 @@
+//go:build (cgo && (darwin || linux || windows) && (amd64 || arm64)) || t2z_mock
+
+package t2z
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// Test that VerifyProofs accepts a valid proof before signing and rejects a
+// tampered or missing proof
+func TestVerifyProofs(t *testing.T) {
+	privateKey, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_verify_proofs_00000000"))
+
+	inputs := []TransparentInput{{
+		Pubkey:       pubkey,
+		TxID:         txid,
+		Amount:       100_000_000,
+		ScriptPubKey: createP2PKHScript(pubkey),
+	}}
+
+	// Transparent-only PCZTs have no proof to verify
+	transparentRequest, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 50_000_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer transparentRequest.Free()
+	transparentRequest.SetTargetHeight(2_500_000)
+	transparent, err := ProposeTransaction(inputs, transparentRequest)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+	if err := VerifyProofs(transparent); err != nil {
+		t.Errorf("VerifyProofs on a transparent-only PCZT: %v", err)
+	}
+	transparent.Free()
+
+	request, err := NewTransactionRequest([]Payment{{Address: testShieldedAddress, Amount: 100_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+	request.SetTargetHeight(2_500_000)
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+	if err := VerifyProofs(pczt); !errors.Is(err, ErrProofMissing) {
+		t.Errorf("Expected ErrProofMissing before proving, got %v", err)
+	}
+
+	proved, err := ProveTransaction(pczt)
+	if err != nil {
+		t.Fatalf("Failed to prove transaction: %v", err)
+	}
+	if err := VerifyProofs(proved); err != nil {
+		t.Fatalf("VerifyProofs on a valid unsigned PCZT: %v", err)
+	}
+
+	// Flip one bit of the proof
+	data, err := SerializePCZT(proved)
+	if err != nil {
+		t.Fatalf("Failed to serialize PCZT: %v", err)
+	}
+	decoded, err := decodePCZT(data)
+	if err != nil {
+		t.Fatalf("decodePCZT failed: %v", err)
+	}
+	offset := bytes.Index(data, decoded.OrchardProof)
+	tampered := append([]byte(nil), data...)
+	tampered[offset+100] ^= 0x01
+	bad, err := ParsePCZT(tampered)
+	if err != nil {
+		t.Fatalf("Failed to parse tampered PCZT: %v", err)
+	}
+	if err := VerifyProofs(bad); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("Expected ErrInvalidProof for a tampered proof, got %v", err)
+	}
+	bad.Free()
+
+	// Verification leaves the PCZT usable for signing and finalization
+	sighash, err := GetSighash(proved, 0)
+	if err != nil {
+		t.Fatalf("Failed to get sighash: %v", err)
+	}
+	signature, err := signMessage(privateKey, sighash)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	signed, err := AppendSignature(proved, 0, signature)
+	if err != nil {
+		t.Fatalf("Failed to append signature: %v", err)
+	}
+	if _, err := FinalizeAndExtract(signed); err != nil {
+		t.Fatalf("Failed to finalize after VerifyProofs: %v", err)
+	}
+}
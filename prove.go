@@ -0,0 +1,107 @@
+package t2z
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// Unified address with an Orchard receiver paid by the warm-up proof,
+// derived from SpendingKey::from_bytes([42u8; 32])
+const warmUpRecipient = "u1eq7cm60un363n2sa862w4t5pq56tl5x0d7wqkzhhva0sxue7kqw85haa6w6xsz8n8ujmcpkzsza8knwgglau443s7ljdgu897yrvyhhz"
+
+var provingParams struct {
+	once sync.Once
+	err  error
+}
+
+// InitProvingParams builds the Orchard proving key ahead of the first
+// ProveTransaction, for a service that wants to pay the setup cost at boot
+// rather than on the first user request.
+//
+// The native library builds the proving key on the first Orchard proof in the
+// process and caches it for every later ProveTransaction, so there are no
+// parameters to load or pass around; the first proof just takes several
+// seconds longer than the rest. It exposes no call to build the key alone, so
+// InitProvingParams proves a throwaway transaction with an Orchard output.
+//
+// It is safe to call from several goroutines and only does the work once;
+// later calls return the first result immediately.
+func InitProvingParams() error {
+	provingParams.once.Do(func() {
+		provingParams.err = warmUpProver()
+	})
+	return provingParams.err
+}
+
+// warmUpProver proposes and proves a fixed transaction with an Orchard output
+func warmUpProver() error {
+	key := secp256k1.PrivKeyFromBytes(bytes.Repeat([]byte{0x01}, 32))
+	defer key.Zero()
+	pubkey := key.PubKey().SerializeCompressed()
+
+	var txid [32]byte
+	copy(txid[:], "t2z_prover_warm_up_funding_tx_00")
+	inputs := []TransparentInput{{
+		Pubkey:       pubkey,
+		TxID:         txid,
+		Amount:       100_000_000,
+		ScriptPubKey: p2pkhScript(hash160(pubkey)),
+	}}
+
+	request, err := NewTransactionRequestWithTargetHeight([]Payment{{Address: warmUpRecipient, Amount: 50_000_000}}, 2_500_000)
+	if err != nil {
+		return fmt.Errorf("init proving params: create request: %w", err)
+	}
+	defer request.Free()
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		return fmt.Errorf("init proving params: propose: %w", err)
+	}
+	proved, err := ProveTransaction(pczt)
+	if err != nil {
+		return fmt.Errorf("init proving params: prove: %w", err)
+	}
+	proved.Free()
+	return nil
+}
+
+// ProveBatch proves several independent PCZTs in parallel, running at most
+// concurrency ProveTransaction calls at a time (at least one).
+//
+// The native library is safe to call from several goroutines: each PCZT is
+// proved on its own handle, the cached proving key is shared read-only, and
+// error messages are kept per OS thread, which ProveTransaction locks for
+// the duration of the call. Each proof already spreads its work over the
+// library's own thread pool, so the gain from a higher concurrency depends
+// on the number of cores and is usually less than linear.
+//
+// Every PCZT in pczts is consumed, as by ProveTransaction. The results have
+// the same length and order as pczts: proved[i] is the proved PCZT for
+// pczts[i], or nil with the error in errs[i].
+func ProveBatch(pczts []*PCZT, concurrency int) (proved []*PCZT, errs []error) {
+	proved = make([]*PCZT, len(pczts))
+	errs = make([]error, len(pczts))
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range max(1, min(concurrency, len(pczts))) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				proved[i], errs[i] = ProveTransaction(pczts[i])
+			}
+		}()
+	}
+	for i := range pczts {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	return proved, errs
+}
@@ -0,0 +1,89 @@
+//go:build (cgo && (darwin || linux || windows) && (amd64 || arm64)) || t2z_mock
+
+package t2z
+
+import (
+	"testing"
+	"time"
+)
+
+// Test that InitProvingParams succeeds and only does the work once
+func TestInitProvingParams(t *testing.T) {
+	if err := InitProvingParams(); err != nil {
+		t.Fatalf("InitProvingParams failed: %v", err)
+	}
+
+	start := time.Now()
+	if err := InitProvingParams(); err != nil {
+		t.Fatalf("Second InitProvingParams failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Second InitProvingParams took %v", elapsed)
+	}
+}
+
+// Test that ProveBatch proves every PCZT and reports errors per PCZT
+func TestProveBatch(t *testing.T) {
+	privateKey, pubkey := createTestKeypair()
+
+	request, err := NewTransactionRequest([]Payment{{Address: testShieldedAddress, Amount: 50_000_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+	request.SetTargetHeight(2_500_000)
+
+	var pczts []*PCZT
+	for i := 0; i < 2; i++ {
+		var txid [32]byte
+		copy(txid[:], []byte("test_txid_prove_batch_0000000000"))
+		txid[31] = byte(i)
+		inputs := []TransparentInput{{Pubkey: pubkey, TxID: txid, Amount: 100_000_000, ScriptPubKey: createP2PKHScript(pubkey)}}
+
+		pczt, err := ProposeTransaction(inputs, request)
+		if err != nil {
+			t.Fatalf("Failed to propose transaction: %v", err)
+		}
+		pczts = append(pczts, pczt)
+	}
+
+	// An already consumed PCZT fails without affecting the others
+	consumed, err := ClonePCZT(pczts[0])
+	if err != nil {
+		t.Fatalf("Failed to clone PCZT: %v", err)
+	}
+	consumed.Free()
+	pczts = append(pczts, consumed)
+
+	proved, errs := ProveBatch(pczts, 2)
+	if len(proved) != len(pczts) || len(errs) != len(pczts) {
+		t.Fatalf("Expected %d results, got %d PCZTs and %d errors", len(pczts), len(proved), len(errs))
+	}
+	if errs[2] == nil || proved[2] != nil {
+		t.Errorf("Expected an error for the consumed PCZT, got %v", errs[2])
+	}
+
+	for i := range 2 {
+		if errs[i] != nil {
+			t.Fatalf("Failed to prove PCZT %d: %v", i, errs[i])
+		}
+		if err := VerifyProofs(proved[i]); err != nil {
+			t.Errorf("PCZT %d: proof does not verify: %v", i, err)
+		}
+		sighash, err := GetSighash(proved[i], 0)
+		if err != nil {
+			t.Fatalf("Failed to get sighash: %v", err)
+		}
+		signature, err := signMessage(privateKey, sighash)
+		if err != nil {
+			t.Fatalf("Failed to sign message: %v", err)
+		}
+		signed, err := AppendSignature(proved[i], 0, signature)
+		if err != nil {
+			t.Fatalf("Failed to append signature: %v", err)
+		}
+		if _, err := FinalizeAndExtract(signed); err != nil {
+			t.Errorf("PCZT %d: failed to finalize: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+//go:build (cgo && (darwin || linux || windows) && (amd64 || arm64)) || t2z_mock
+
+package t2z
+
+import (
+	"testing"
+	"time"
+)
+
+// Test that the self-test passes and is fast enough to run at startup
+func TestSelfTest(t *testing.T) {
+	start := time.Now()
+	if err := SelfTest(); err != nil {
+		t.Fatalf("SelfTest failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("SelfTest took %v", elapsed)
+	}
+}
@@ -0,0 +1,92 @@
+package t2z
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Broadcaster submits finalized transactions to the network, for example
+// through a zebrad or zcashd JSON-RPC node or a lightwalletd server
+type Broadcaster interface {
+	// Broadcast submits a raw transaction as hex and returns its txid
+	Broadcast(txHex string) (txid string, err error)
+}
+
+// BroadcasterFunc adapts an ordinary function, such as a JSON-RPC or HTTP
+// relay helper, to the Broadcaster interface
+type BroadcasterFunc func(txHex string) (txid string, err error)
+
+// Broadcast calls f(txHex)
+func (f BroadcasterFunc) Broadcast(txHex string) (string, error) {
+	return f(txHex)
+}
+
+// SendTransaction builds, signs and broadcasts a transaction paying request
+// from inputs in one call.
+//
+// It runs the whole workflow: ProposeTransaction, VerifyBeforeSigning (with
+// the change computed by ExpectedChange), ProveTransaction, SignAllInputs,
+// FinalizeAndExtract and Broadcast. Errors name the stage that failed. Use
+// the individual functions for anything beyond this, such as offline signing
+// or a custom change address.
+//
+// Parameters:
+//   - inputs: Transparent UTXOs to spend, all signable by signer
+//   - request: Transaction request with payment recipients (not consumed)
+//   - signer: Signs each input's sighash
+//   - client: Submits the finalized transaction
+//
+// Returns the txid reported by client. If broadcasting fails the error
+// includes the txid computed locally, so the transaction can be looked up in
+// case it reached the network anyway.
+func SendTransaction(inputs []TransparentInput, request *TransactionRequest, signer Signer, client Broadcaster) (string, error) {
+	if signer == nil {
+		return "", errors.New("signer is required")
+	}
+	if client == nil {
+		return "", errors.New("broadcaster is required")
+	}
+
+	// The expected change is computed before proposing, so verification
+	// checks the PCZT against the builder's view of the transaction
+	change, err := ExpectedChange(inputs, request, "")
+	if err != nil {
+		return "", fmt.Errorf("propose: %w", err)
+	}
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		return "", fmt.Errorf("propose: %w", err)
+	}
+
+	if err := VerifyBeforeSigning(pczt, request, change); err != nil {
+		pczt.Free()
+		return "", fmt.Errorf("verify: %w", err)
+	}
+
+	proved, err := ProveTransaction(pczt)
+	if err != nil {
+		return "", fmt.Errorf("prove: %w", err)
+	}
+
+	signed, err := SignAllInputs(proved, inputs, signer)
+	if err != nil {
+		return "", fmt.Errorf("sign: %w", err)
+	}
+
+	txBytes, err := FinalizeAndExtract(signed)
+	if err != nil {
+		return "", fmt.Errorf("finalize: %w", err)
+	}
+
+	localTxID, err := TransactionID(txBytes)
+	if err != nil {
+		return "", fmt.Errorf("finalize: %w", err)
+	}
+
+	txid, err := client.Broadcast(hex.EncodeToString(txBytes))
+	if err != nil {
+		return "", fmt.Errorf("broadcast %s: %w", localTxID, err)
+	}
+	return txid, nil
+}
@@ -0,0 +1,108 @@
+//go:build (cgo && (darwin || linux || windows) && (amd64 || arm64)) || t2z_mock
+
+package t2z
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// mockBroadcaster records broadcast transactions and returns err, if set
+type mockBroadcaster struct {
+	txHexes []string
+	err     error
+}
+
+func (m *mockBroadcaster) Broadcast(txHex string) (string, error) {
+	m.txHexes = append(m.txHexes, txHex)
+	if m.err != nil {
+		return "", m.err
+	}
+	txBytes, err := hex.DecodeString(txHex)
+	if err != nil {
+		return "", err
+	}
+	return TransactionID(txBytes)
+}
+
+// Test the whole workflow in one call, and that errors name the failed stage
+func TestSendTransaction(t *testing.T) {
+	privateKey, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_send_transaction_00000"))
+
+	inputs := []TransparentInput{{
+		Pubkey:       pubkey,
+		TxID:         txid,
+		Amount:       100_000_000,
+		ScriptPubKey: createP2PKHScript(pubkey),
+	}}
+	request, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 50_000_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+	request.SetTargetHeight(2_500_000)
+
+	signer := SignerFunc(func(sighash [32]byte, _ []byte) ([64]byte, error) {
+		return signMessage(privateKey, sighash)
+	})
+
+	client := &mockBroadcaster{}
+	sent, err := SendTransaction(inputs, request, signer, client)
+	if err != nil {
+		t.Fatalf("SendTransaction failed: %v", err)
+	}
+	if len(client.txHexes) != 1 {
+		t.Fatalf("Expected 1 broadcast, got %d", len(client.txHexes))
+	}
+	txBytes, _ := hex.DecodeString(client.txHexes[0])
+	tx, err := DecodeTransaction(txBytes)
+	if err != nil {
+		t.Fatalf("Failed to decode broadcast transaction: %v", err)
+	}
+	if len(tx.Inputs) != 1 || tx.Inputs[0].PrevTxID != txid || len(tx.Outputs) != 2 {
+		t.Errorf("Unexpected transaction: %d inputs, %d outputs", len(tx.Inputs), len(tx.Outputs))
+	}
+	// The change is the one computed from the inputs, not read back from the PCZT
+	change, err := ExpectedChange(inputs, request, "")
+	if err != nil || len(change) != 1 {
+		t.Fatalf("ExpectedChange = %+v, %v", change, err)
+	}
+	if out := tx.Outputs[len(tx.Outputs)-1]; out.Value != change[0].Value || !bytes.Equal(out.ScriptPubKey, change[0].ScriptPubKey) {
+		t.Errorf("Change output %+v, want %+v", out, change[0])
+	}
+	if want, _ := TransactionID(txBytes); sent != want {
+		t.Errorf("SendTransaction = %s, want %s", sent, want)
+	}
+
+	// Broadcast errors are wrapped and carry the local txid
+	errRejected := errors.New("rejected")
+	_, err = SendTransaction(inputs, request, signer, BroadcasterFunc(func(string) (string, error) {
+		return "", errRejected
+	}))
+	if !errors.Is(err, errRejected) || !strings.Contains(err.Error(), sent) {
+		t.Errorf("Expected broadcast error with txid %s, got %v", sent, err)
+	}
+
+	// Signer errors stop the workflow before broadcasting
+	client = &mockBroadcaster{}
+	errDevice := errors.New("device disconnected")
+	_, err = SendTransaction(inputs, request, SignerFunc(func([32]byte, []byte) ([64]byte, error) {
+		return [64]byte{}, errDevice
+	}), client)
+	if !errors.Is(err, errDevice) || !strings.HasPrefix(err.Error(), "sign: ") {
+		t.Errorf("Expected sign error, got %v", err)
+	}
+	if len(client.txHexes) != 0 {
+		t.Error("Expected no broadcast after a signing error")
+	}
+
+	if _, err := SendTransaction(inputs, request, signer, nil); err == nil {
+		t.Error("Expected error for nil broadcaster, got nil")
+	}
+}
@@ -0,0 +1,171 @@
+package t2z
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// SighashType selects which parts of a transaction a transparent signature
+// commits to, see ZIP 244
+type SighashType byte
+
+const (
+	// SighashAll commits to all inputs and outputs (the default)
+	SighashAll SighashType = 0x01
+
+	// SighashNone commits to the inputs but to none of the outputs
+	SighashNone SighashType = 0x02
+
+	// SighashSingle commits to the inputs and to the output with the same
+	// index as the signed input
+	SighashSingle SighashType = 0x03
+
+	// SighashAnyoneCanPay is combined with one of the types above to commit
+	// to the signed input only, so others can add inputs
+	SighashAnyoneCanPay SighashType = 0x80
+)
+
+// String returns the conventional name of the type, such as "SINGLE|ANYONECANPAY"
+func (t SighashType) String() string {
+	var name string
+	switch t &^ SighashAnyoneCanPay {
+	case SighashAll:
+		name = "ALL"
+	case SighashNone:
+		name = "NONE"
+	case SighashSingle:
+		name = "SINGLE"
+	default:
+		return fmt.Sprintf("SighashType(0x%02x)", byte(t))
+	}
+	if t&SighashAnyoneCanPay != 0 {
+		name += "|ANYONECANPAY"
+	}
+	return name
+}
+
+// valid reports whether t is one of the six types allowed by ZIP 244
+func (t SighashType) valid() bool {
+	base := t &^ SighashAnyoneCanPay
+	return base >= SighashAll && base <= SighashSingle
+}
+
+// GetSighashWithType gets the signature hash of a transparent input for the
+// given sighash type.
+//
+// The type is stored in the PCZT and used for the input by the next
+// AppendSignature, so the finalized scriptSig carries the matching flag. The
+// choice is not part of the serialized PCZT until the signature is appended;
+// if the PCZT is serialized in between, use AppendSignatureWithType.
+//
+// GetSighash is GetSighashWithType with SighashAll. The PCZT is not consumed.
+func GetSighashWithType(pczt *PCZT, inputIndex uint, hashType SighashType) ([32]byte, error) {
+	if !hashType.valid() {
+		return [32]byte{}, fmt.Errorf("invalid sighash type 0x%02x", byte(hashType))
+	}
+
+	data, err := SerializePCZT(pczt)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	patched, err := patchSighashType(data, inputIndex, hashType)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	tmp, err := ParsePCZT(patched)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	defer tmp.Free()
+
+	sighash, err := GetSighash(tmp, inputIndex)
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	pczt.mu.Lock()
+	if pczt.sighashTypes == nil {
+		pczt.sighashTypes = make(map[uint]SighashType)
+	}
+	pczt.sighashTypes[inputIndex] = hashType
+	pczt.mu.Unlock()
+
+	return sighash, nil
+}
+
+// AppendSignatureWithType adds a signature made over the sighash of the given
+// type, as returned by GetSighashWithType, to the PCZT.
+//
+// Like AppendSignature, it ALWAYS consumes the input PCZT, even on error.
+func AppendSignatureWithType(pczt *PCZT, inputIndex uint, signature [64]byte, hashType SighashType) (*PCZT, error) {
+	return appendSignatureWithType(context.Background(), pczt, inputIndex, signature, hashType)
+}
+
+// appendSignatureWithType implements AppendSignatureWithType, tracing the
+// native call in ctx
+func appendSignatureWithType(ctx context.Context, pczt *PCZT, inputIndex uint, signature [64]byte, hashType SighashType) (*PCZT, error) {
+	if pczt == nil {
+		return nil, errors.New("invalid PCZT")
+	}
+	if !hashType.valid() {
+		pczt.Free()
+		return nil, fmt.Errorf("invalid sighash type 0x%02x", byte(hashType))
+	}
+
+	pczt, err := withSighashType(pczt, inputIndex, hashType)
+	if err != nil {
+		return nil, err
+	}
+	return appendSignature(ctx, pczt, inputIndex, signature)
+}
+
+// sighashType returns the type chosen for an input with GetSighashWithType
+func (p *PCZT) sighashType(inputIndex uint) (SighashType, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	t, ok := p.sighashTypes[inputIndex]
+	return t, ok
+}
+
+// withSighashType returns a PCZT equal to pczt but with the sighash type of
+// an input set to hashType. The input PCZT is consumed.
+func withSighashType(pczt *PCZT, inputIndex uint, hashType SighashType) (*PCZT, error) {
+	defer pczt.Free()
+
+	data, err := SerializePCZT(pczt)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := patchSighashType(data, inputIndex, hashType)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePCZT(patched)
+}
+
+// patchSighashType sets the sighash type of an unsigned input of a
+// serialized PCZT
+func patchSighashType(data []byte, inputIndex uint, hashType SighashType) ([]byte, error) {
+	p, err := decodePCZT(data)
+	if err != nil {
+		return nil, err
+	}
+	if inputIndex >= uint(len(p.Inputs)) {
+		return nil, fmt.Errorf("input %d: index out of range, PCZT has %d transparent inputs", inputIndex, len(p.Inputs))
+	}
+
+	in := p.Inputs[inputIndex]
+	if SighashType(in.SighashType) == hashType {
+		return data, nil
+	}
+	if in.Signed() {
+		return nil, fmt.Errorf("input %d: already signed with sighash type %s", inputIndex, SighashType(in.SighashType))
+	}
+
+	// The sighash type follows the (empty) partial signature map
+	patched := append([]byte(nil), data...)
+	patched[in.signaturesOffset+1] = byte(hashType)
+	return patched, nil
+}
@@ -0,0 +1,98 @@
+package t2z
+
+import (
+	"testing"
+)
+
+// Test that the sighash type chosen with GetSighashWithType ends up in the
+// scriptSig of the extracted transaction
+func TestGetSighashWithType(t *testing.T) {
+	requireBackend(t)
+	privateKey, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_sighash_type_000000000"))
+
+	inputs := []TransparentInput{{
+		Pubkey:       pubkey,
+		TxID:         txid,
+		Amount:       100_000_000,
+		ScriptPubKey: createP2PKHScript(pubkey),
+	}}
+	request, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 50_000_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+	request.SetTargetHeight(2_500_000)
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+	pczt, err = ProveTransaction(pczt)
+	if err != nil {
+		t.Fatalf("Failed to prove transaction: %v", err)
+	}
+
+	if _, err := GetSighashWithType(pczt, 0, 0x04); err == nil {
+		t.Error("Expected error for invalid sighash type, got nil")
+	}
+	if _, err := GetSighashWithType(pczt, 1, SighashNone); err == nil {
+		t.Error("Expected error for out of range input, got nil")
+	}
+
+	all, err := GetSighash(pczt, 0)
+	if err != nil {
+		t.Fatalf("Failed to get sighash: %v", err)
+	}
+	if got, err := GetSighashWithType(pczt, 0, SighashAll); err != nil || got != all {
+		t.Errorf("GetSighashWithType(SighashAll) = %x, %v; want %x", got, err, all)
+	}
+
+	hashType := SighashSingle | SighashAnyoneCanPay
+	sighash, err := GetSighashWithType(pczt, 0, hashType)
+	if err != nil {
+		t.Fatalf("Failed to get sighash: %v", err)
+	}
+	if sighash == all {
+		t.Error("Expected SINGLE|ANYONECANPAY sighash to differ from SIGHASH_ALL")
+	}
+
+	signature, err := signMessage(privateKey, sighash)
+	if err != nil {
+		t.Fatalf("Failed to sign message: %v", err)
+	}
+	signed, err := AppendSignature(pczt, 0, signature)
+	if err != nil {
+		t.Fatalf("Failed to append signature: %v", err)
+	}
+	txBytes, err := FinalizeAndExtract(signed)
+	if err != nil {
+		t.Fatalf("Failed to finalize and extract: %v", err)
+	}
+
+	tx, err := DecodeTransaction(txBytes)
+	if err != nil {
+		t.Fatalf("Failed to decode transaction: %v", err)
+	}
+	// The first push of the scriptSig is the DER signature followed by the type
+	scriptSig := tx.Inputs[0].ScriptSig
+	if got := SighashType(scriptSig[scriptSig[0]]); got != hashType {
+		t.Errorf("scriptSig sighash type = %s, want %s", got, hashType)
+	}
+}
+
+func TestSighashTypeString(t *testing.T) {
+	tests := map[SighashType]string{
+		SighashAll:                          "ALL",
+		SighashNone | SighashAnyoneCanPay:   "NONE|ANYONECANPAY",
+		SighashSingle | SighashAnyoneCanPay: "SINGLE|ANYONECANPAY",
+		0x04:                                "SighashType(0x04)",
+	}
+	for hashType, want := range tests {
+		if got := hashType.String(); got != want {
+			t.Errorf("SighashType(0x%02x).String() = %q, want %q", byte(hashType), got, want)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package t2z
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// Signer signs the sighash of a transparent input with the key of its public
+// key.
+//
+// Implementations can hold keys in memory, forward to a hardware wallet, or
+// call a remote signing service, and can be swapped freely in SignAllInputs,
+// SignAllInputsProgress and SendTransaction.
+type Signer interface {
+	// Sign returns the 64-byte ECDSA signature (r: 32 bytes, s: 32 bytes) of
+	// sighash by the private key of pubkey (33-byte compressed)
+	Sign(sighash [32]byte, pubkey []byte) ([64]byte, error)
+}
+
+// SignerFunc adapts an ordinary function to the Signer interface
+type SignerFunc func(sighash [32]byte, pubkey []byte) ([64]byte, error)
+
+// Sign calls f(sighash, pubkey)
+func (f SignerFunc) Sign(sighash [32]byte, pubkey []byte) ([64]byte, error) {
+	return f(sighash, pubkey)
+}
+
+// VerifySignature reports whether sig (r: 32 bytes, s: 32 bytes) is a valid
+// ECDSA signature of sighash by pubkey (33-byte compressed or 65-byte
+// uncompressed secp256k1 public key).
+//
+// Use it between GetSighash and AppendSignature to catch a wrong key or a
+// corrupted signature from a remote signer with a clear error.
+func VerifySignature(pubkey []byte, sighash [32]byte, sig [64]byte) bool {
+	key, err := secp256k1.ParsePubKey(pubkey)
+	if err != nil {
+		return false
+	}
+
+	var r, s secp256k1.ModNScalar
+	if overflow := r.SetByteSlice(sig[:32]); overflow || r.IsZero() {
+		return false
+	}
+	if overflow := s.SetByteSlice(sig[32:]); overflow || s.IsZero() {
+		return false
+	}
+	return ecdsa.NewSignature(&r, &s).Verify(sighash[:], key)
+}
+
+// NormalizeSignature returns sig with S in the lower half of the curve order.
+//
+// Zcash, like Bitcoin, only accepts low-S signatures; (r, s) and (r, n-s) are
+// both valid ECDSA signatures, so a high S is replaced by n-s. Signatures
+// that are already low-S (including those from ecdsa.SignCompact) and
+// signatures with an out-of-range S are returned unchanged. AppendSignature
+// applies this automatically.
+func NormalizeSignature(sig [64]byte) [64]byte {
+	var s secp256k1.ModNScalar
+	if overflow := s.SetByteSlice(sig[32:]); overflow || !s.IsOverHalfOrder() {
+		return sig
+	}
+	s.Negate()
+	s.PutBytesUnchecked(sig[32:])
+	return sig
+}
+
+// IsFullySigned reports whether every transparent input of a PCZT has a
+// signature, so a PCZT that still needs signatures is not handed to
+// FinalizeAndExtract, which would consume it.
+//
+// The PCZT is not consumed.
+func IsFullySigned(pczt *PCZT) (bool, error) {
+	missing, err := MissingSignatures(pczt)
+	if err != nil {
+		return false, err
+	}
+	return len(missing) == 0, nil
+}
+
+// MissingSignatures returns the indices of the transparent inputs of a PCZT
+// that do not have a signature yet, in ascending order. In a multi-party
+// workflow this tells which signers still need to sign before Combine and
+// FinalizeAndExtract.
+//
+// The PCZT is not consumed.
+func MissingSignatures(pczt *PCZT) ([]uint, error) {
+	p, err := inspectPCZT(pczt)
+	if err != nil {
+		return nil, err
+	}
+	missing := []uint{}
+	for i := range p.Inputs {
+		if !p.Inputs[i].Signed() {
+			missing = append(missing, uint(i))
+		}
+	}
+	return missing, nil
+}
+
+// countTransparentInputs returns the number of transparent inputs in a PCZT
+func countTransparentInputs(pczt *PCZT) (int, error) {
+	p, err := inspectPCZT(pczt)
+	if err != nil {
+		return 0, err
+	}
+	return len(p.Inputs), nil
+}
+
+// SignAllInputsProgress signs every transparent input of a PCZT with signer,
+// calling progress after each input is signed.
+//
+// This wraps the GetSighash / AppendSignature loop for multi-input
+// transactions, so a UI can report e.g. "signing 7 of 50" while a slow
+// hardware wallet works through the inputs. The signer is asked for each
+// input by the pubkey the PCZT records for it, and each signature is checked
+// with VerifySignature before it is appended.
+//
+// IMPORTANT: This function ALWAYS consumes the input PCZT, even on error.
+// If you need to retry on failure, call ClonePCZT() before this function.
+//
+// Parameters:
+//   - pczt: The PCZT to sign (typically the result of ProveTransaction)
+//   - signer: Signs each input's sighash
+//   - progress: Optional callback, called with the number of inputs signed so far and the total
+//
+// Returns a new PCZT with all signatures added.
+func SignAllInputsProgress(pczt *PCZT, signer Signer, progress func(done, total int)) (*PCZT, error) {
+	if pczt == nil {
+		return nil, errors.New("invalid PCZT")
+	}
+	if signer == nil {
+		pczt.Free()
+		return nil, errors.New("signer is required")
+	}
+
+	p, err := inspectPCZT(pczt)
+	if err != nil {
+		pczt.Free()
+		return nil, err
+	}
+	pubkeys := make([][]byte, len(p.Inputs))
+	for i := range p.Inputs {
+		pubkey, ok := p.Inputs[i].Pubkey()
+		if !ok {
+			pczt.Free()
+			return nil, fmt.Errorf("input %d: PCZT does not contain the input's public key", i)
+		}
+		pubkeys[i] = pubkey
+	}
+
+	return signInputs(pczt, pubkeys, signer, progress)
+}
+
+// SignAllInputs signs every transparent input of a PCZT with signer, which is
+// asked for a signature by the pubkey of the corresponding input.
+//
+// This replaces the GetSighash / sign / AppendSignature loop. Each signature
+// is checked with VerifySignature before it is appended, so a signer that
+// used the wrong key fails with an error naming the input.
+//
+// IMPORTANT: This function ALWAYS consumes the input PCZT, even on error.
+// If you need to retry on failure, call ClonePCZT() before this function.
+//
+// Parameters:
+//   - pczt: The PCZT to sign (typically the result of ProveTransaction)
+//   - inputs: The inputs the PCZT was proposed with, in the same order
+//   - signer: Signs each input's sighash
+//
+// Returns a new PCZT with all signatures added.
+func SignAllInputs(pczt *PCZT, inputs []TransparentInput, signer Signer) (*PCZT, error) {
+	if pczt == nil {
+		return nil, errors.New("invalid PCZT")
+	}
+	if signer == nil {
+		pczt.Free()
+		return nil, errors.New("signer is required")
+	}
+
+	total, err := countTransparentInputs(pczt)
+	if err != nil {
+		pczt.Free()
+		return nil, err
+	}
+	if total != len(inputs) {
+		pczt.Free()
+		return nil, fmt.Errorf("PCZT has %d transparent inputs, got %d", total, len(inputs))
+	}
+
+	pubkeys := make([][]byte, len(inputs))
+	for i, input := range inputs {
+		pubkeys[i] = input.Pubkey
+	}
+	return signInputs(pczt, pubkeys, signer, nil)
+}
+
+// signInputs signs input i of a PCZT with the key of pubkeys[i], checking
+// each signature before appending it. The input PCZT is consumed.
+func signInputs(pczt *PCZT, pubkeys [][]byte, signer Signer, progress func(done, total int)) (*PCZT, error) {
+	current := pczt
+	for i, pubkey := range pubkeys {
+		sighash, err := GetSighash(current, uint(i))
+		if err != nil {
+			current.Free()
+			return nil, err
+		}
+
+		signature, err := signer.Sign(sighash, pubkey)
+		if err != nil {
+			current.Free()
+			return nil, fmt.Errorf("sign input %d: %w", i, err)
+		}
+		if !VerifySignature(pubkey, sighash, signature) {
+			current.Free()
+			return nil, fmt.Errorf("sign input %d: signature does not verify against pubkey %x", i, pubkey)
+		}
+
+		// Consumes current, even on error
+		current, err = AppendSignature(current, uint(i), signature)
+		if err != nil {
+			return nil, err
+		}
+
+		if progress != nil {
+			progress(i+1, len(pubkeys))
+		}
+	}
+
+	return current, nil
+}
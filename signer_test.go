@@ -0,0 +1,430 @@
+package t2z
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// Test signing all inputs with progress reporting
+func TestSignAllInputsProgress(t *testing.T) {
+	requireBackend(t)
+	privateKey, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_sign_all_inputs_000000"))
+
+	var inputs []TransparentInput
+	for i := 0; i < 3; i++ {
+		inputs = append(inputs, TransparentInput{
+			Pubkey:       pubkey,
+			TxID:         txid,
+			Vout:         uint32(i),
+			Amount:       10_000_000,
+			ScriptPubKey: createP2PKHScript(pubkey),
+		})
+	}
+
+	request, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 15_000_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+	request.SetTargetHeight(2_500_000)
+
+	signer := SignerFunc(func(sighash [32]byte, pk []byte) ([64]byte, error) {
+		if !bytes.Equal(pk, pubkey) {
+			t.Errorf("Signer asked for pubkey %x, expected %x", pk, pubkey)
+		}
+		return signMessage(privateKey, sighash)
+	})
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+
+	var calls [][2]int
+	signed, err := SignAllInputsProgress(pczt, signer, func(done, total int) {
+		calls = append(calls, [2]int{done, total})
+	})
+	if err != nil {
+		t.Fatalf("Failed to sign all inputs: %v", err)
+	}
+
+	if len(calls) != 3 || calls[0] != [2]int{1, 3} || calls[2] != [2]int{3, 3} {
+		t.Errorf("Unexpected progress calls: %v", calls)
+	}
+
+	if _, err := FinalizeAndExtract(signed); err != nil {
+		t.Fatalf("Failed to finalize signed PCZT: %v", err)
+	}
+
+	// Signer errors are returned and the PCZT is consumed
+	pczt, err = ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+	errDevice := errors.New("device disconnected")
+	_, err = SignAllInputsProgress(pczt, SignerFunc(func([32]byte, []byte) ([64]byte, error) {
+		return [64]byte{}, errDevice
+	}), nil)
+	if !errors.Is(err, errDevice) {
+		t.Errorf("Expected signer error, got %v", err)
+	}
+	if _, err := SerializePCZT(pczt); err == nil {
+		t.Error("Expected PCZT to be consumed after failed signing")
+	}
+
+	// Sighash errors are returned rather than ending the input count
+	pczt, err = ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+	data, err := SerializePCZT(pczt)
+	pczt.Free()
+	if err != nil {
+		t.Fatalf("Failed to serialize PCZT: %v", err)
+	}
+	contents, err := decodePCZT(data)
+	if err != nil {
+		t.Fatalf("Failed to decode PCZT: %v", err)
+	}
+	data[contents.Inputs[0].signaturesOffset+1] = 0x05 // invalid sighash type
+	bad, err := ParsePCZT(data)
+	if err != nil {
+		t.Fatalf("Failed to parse patched PCZT: %v", err)
+	}
+	if _, err := SignAllInputsProgress(bad, signer, nil); !errors.Is(err, ErrSighash) {
+		t.Errorf("Expected ErrSighash, got %v", err)
+	}
+}
+
+// Test signing all inputs with a signer keyed by pubkey
+func TestSignAllInputs(t *testing.T) {
+	requireBackend(t)
+	privateKey, pubkey := createTestKeypair()
+	otherPubkey := secp256k1.PrivKeyFromBytes([]byte{2}).PubKey().SerializeCompressed()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_sign_all_by_pubkey_000"))
+
+	var inputs []TransparentInput
+	for i := 0; i < 2; i++ {
+		inputs = append(inputs, TransparentInput{
+			Pubkey:       pubkey,
+			TxID:         txid,
+			Vout:         uint32(i),
+			Amount:       10_000_000,
+			ScriptPubKey: createP2PKHScript(pubkey),
+		})
+	}
+
+	request, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 15_000_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+	request.SetTargetHeight(2_500_000)
+
+	var pubkeys [][]byte
+	signer := SignerFunc(func(sighash [32]byte, pk []byte) ([64]byte, error) {
+		pubkeys = append(pubkeys, pk)
+		return signMessage(privateKey, sighash)
+	})
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+	signed, err := SignAllInputs(pczt, inputs, signer)
+	if err != nil {
+		t.Fatalf("Failed to sign all inputs: %v", err)
+	}
+	if !reflect.DeepEqual(pubkeys, [][]byte{pubkey, pubkey}) {
+		t.Errorf("Signer called with pubkeys %x, want the inputs' pubkeys", pubkeys)
+	}
+	if _, err := FinalizeAndExtract(signed); err != nil {
+		t.Fatalf("Failed to finalize signed PCZT: %v", err)
+	}
+
+	// Input count mismatch
+	pczt, err = ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+	if _, err := SignAllInputs(pczt, inputs[:1], signer); err == nil {
+		t.Error("Expected error for input count mismatch, got nil")
+	}
+
+	// A signature by the wrong key is rejected
+	pczt, err = ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+	wrong := slices.Clone(inputs)
+	wrong[1].Pubkey = otherPubkey
+	if _, err := SignAllInputs(pczt, wrong, signer); err == nil {
+		t.Error("Expected error for signature by the wrong key, got nil")
+	}
+	if _, err := SerializePCZT(pczt); err == nil {
+		t.Error("Expected PCZT to be consumed after failed signing")
+	}
+}
+
+// Test that VerifySignature accepts a valid signature and rejects tampering
+func TestVerifySignature(t *testing.T) {
+	privateKey, pubkey := createTestKeypair()
+
+	var sighash [32]byte
+	copy(sighash[:], []byte("test_sighash_verify_signature_00"))
+
+	sig, err := signMessage(privateKey, sighash)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	if !VerifySignature(pubkey, sighash, sig) {
+		t.Fatal("VerifySignature rejected a valid signature")
+	}
+
+	otherHash := sighash
+	otherHash[0] ^= 1
+	if VerifySignature(pubkey, otherHash, sig) {
+		t.Error("VerifySignature accepted a signature over a different sighash")
+	}
+
+	badSig := sig
+	badSig[40] ^= 1
+	if VerifySignature(pubkey, sighash, badSig) {
+		t.Error("VerifySignature accepted a corrupted signature")
+	}
+
+	otherKey := append([]byte(nil), pubkey...)
+	otherKey[0] ^= 1 // 0x03 -> 0x02, the negated point
+	if VerifySignature(otherKey, sighash, sig) {
+		t.Error("VerifySignature accepted the wrong public key")
+	}
+
+	if VerifySignature(pubkey[:32], sighash, sig) {
+		t.Error("VerifySignature accepted a truncated public key")
+	}
+	if VerifySignature(pubkey, sighash, [64]byte{}) {
+		t.Error("VerifySignature accepted an all-zero signature")
+	}
+}
+
+// highS returns the high-S form (r, n-s) of a low-S signature
+func highS(sig [64]byte) [64]byte {
+	s := new(big.Int).SetBytes(sig[32:])
+	s.Sub(secp256k1.S256().N, s)
+	s.FillBytes(sig[32:])
+	return sig
+}
+
+// Test that NormalizeSignature flips high S and leaves low S unchanged
+func TestNormalizeSignature(t *testing.T) {
+	privateKey, pubkey := createTestKeypair()
+
+	var sighash [32]byte
+	copy(sighash[:], []byte("test_sighash_normalize_signature"))
+
+	low, err := signMessage(privateKey, sighash)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	high := highS(low)
+	if high == low {
+		t.Fatal("highS did not change the signature")
+	}
+	if !VerifySignature(pubkey, sighash, high) {
+		t.Fatal("high-S signature should still verify")
+	}
+
+	if got := NormalizeSignature(low); got != low {
+		t.Errorf("NormalizeSignature changed a low-S signature: %x", got)
+	}
+	if got := NormalizeSignature(high); got != low {
+		t.Errorf("NormalizeSignature(high) = %x, want %x", got, low)
+	}
+
+	var overflow [64]byte
+	for i := 32; i < 64; i++ {
+		overflow[i] = 0xff
+	}
+	if got := NormalizeSignature(overflow); got != overflow {
+		t.Errorf("NormalizeSignature changed an out-of-range S: %x", got)
+	}
+}
+
+// Test that AppendSignature accepts a high-S signature and the transaction
+// finalizes
+func TestAppendSignatureHighS(t *testing.T) {
+	requireBackend(t)
+	privateKey, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_append_signature_highs"))
+
+	inputs := []TransparentInput{{
+		Pubkey:       pubkey,
+		TxID:         txid,
+		Vout:         0,
+		Amount:       10_000_000,
+		ScriptPubKey: createP2PKHScript(pubkey),
+	}}
+
+	request, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 5_000_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+	request.SetTargetHeight(2_500_000)
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+
+	sighash, err := GetSighash(pczt, 0)
+	if err != nil {
+		t.Fatalf("Failed to get sighash: %v", err)
+	}
+	signature, err := signMessage(privateKey, sighash)
+	if err != nil {
+		t.Fatalf("Failed to sign message: %v", err)
+	}
+
+	signed, err := AppendSignature(pczt, 0, highS(signature))
+	if err != nil {
+		t.Fatalf("Failed to append high-S signature: %v", err)
+	}
+	if _, err := FinalizeAndExtract(signed); err != nil {
+		t.Fatalf("Failed to finalize and extract: %v", err)
+	}
+}
+
+// Test that IsFullySigned only reports true once every input is signed and
+// does not consume the PCZT
+func TestIsFullySigned(t *testing.T) {
+	requireBackend(t)
+	privateKey, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_is_fully_signed_000000"))
+
+	var inputs []TransparentInput
+	for i := 0; i < 2; i++ {
+		inputs = append(inputs, TransparentInput{
+			Pubkey:       pubkey,
+			TxID:         txid,
+			Vout:         uint32(i),
+			Amount:       10_000_000,
+			ScriptPubKey: createP2PKHScript(pubkey),
+		})
+	}
+
+	request, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 15_000_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+	request.SetTargetHeight(2_500_000)
+
+	current, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+
+	for i := uint(0); i < 2; i++ {
+		if full, err := IsFullySigned(current); err != nil || full {
+			t.Fatalf("IsFullySigned before signing input %d = %v, %v", i, full, err)
+		}
+		sighash, err := GetSighash(current, i)
+		if err != nil {
+			t.Fatalf("Failed to get sighash: %v", err)
+		}
+		signature, err := signMessage(privateKey, sighash)
+		if err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		current, err = AppendSignature(current, i, signature)
+		if err != nil {
+			t.Fatalf("Failed to append signature: %v", err)
+		}
+	}
+
+	if full, err := IsFullySigned(current); err != nil || !full {
+		t.Fatalf("IsFullySigned after signing all inputs = %v, %v", full, err)
+	}
+	if _, err := FinalizeAndExtract(current); err != nil {
+		t.Fatalf("Failed to finalize after IsFullySigned: %v", err)
+	}
+
+	if _, err := IsFullySigned(current); err == nil {
+		t.Error("Expected error for a consumed PCZT")
+	}
+}
+
+// Test that MissingSignatures lists exactly the unsigned inputs
+func TestMissingSignatures(t *testing.T) {
+	requireBackend(t)
+	privateKey, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_missing_signatures_000"))
+
+	var inputs []TransparentInput
+	for i := 0; i < 3; i++ {
+		inputs = append(inputs, TransparentInput{
+			Pubkey:       pubkey,
+			TxID:         txid,
+			Vout:         uint32(i),
+			Amount:       10_000_000,
+			ScriptPubKey: createP2PKHScript(pubkey),
+		})
+	}
+
+	request, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 25_000_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+	request.SetTargetHeight(2_500_000)
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+
+	missing, err := MissingSignatures(pczt)
+	if err != nil || !reflect.DeepEqual(missing, []uint{0, 1, 2}) {
+		t.Fatalf("MissingSignatures before signing = %v, %v", missing, err)
+	}
+
+	sighash, err := GetSighash(pczt, 1)
+	if err != nil {
+		t.Fatalf("Failed to get sighash: %v", err)
+	}
+	signature, err := signMessage(privateKey, sighash)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	signed, err := AppendSignature(pczt, 1, signature)
+	if err != nil {
+		t.Fatalf("Failed to append signature: %v", err)
+	}
+	defer signed.Free()
+
+	missing, err = MissingSignatures(signed)
+	if err != nil || !reflect.DeepEqual(missing, []uint{0, 2}) {
+		t.Errorf("MissingSignatures after signing input 1 = %v, %v", missing, err)
+	}
+	if full, err := IsFullySigned(signed); err != nil || full {
+		t.Errorf("IsFullySigned with missing signatures = %v, %v", full, err)
+	}
+}
@@ -13,42 +13,54 @@
 //   - Combine - Merges multiple PCZTs
 //   - FinalizeAndExtract - Produces final transaction bytes
 //   - Parse/Serialize - PCZT serialization
+//
+// # Testing without the native library
+//
+// Building with the t2z_mock tag replaces the Rust library with a pure Go
+// mock, so code using t2z can be unit-tested where the prebuilt library is
+// not available:
+//
+//	go test -tags t2z_mock ./...
+//
+// The mock builds real PCZT and transaction encodings, computes fees and
+// change like the native library and checks signatures against the input
+// pubkeys, so a whole propose, prove, sign and finalize flow runs as usual.
+// Sighashes and Orchard proofs are deterministic placeholders, though, and
+// Orchard actions carry no notes, so its transactions are not valid on any
+// network. See mock.go for the details.
 package t2z
 
-// #cgo CFLAGS: -I${SRCDIR}/include
-// #cgo darwin,arm64 LDFLAGS: ${SRCDIR}/lib/darwin-arm64/libt2z.a -ldl -lm -framework Security -framework Foundation
-// #cgo darwin,amd64 LDFLAGS: ${SRCDIR}/lib/darwin-x64/libt2z.a -ldl -lm -framework Security -framework Foundation
-// #cgo linux,amd64 LDFLAGS: ${SRCDIR}/lib/linux-x64/libt2z.a -ldl -lm -lpthread
-// #cgo linux,arm64 LDFLAGS: ${SRCDIR}/lib/linux-arm64/libt2z.a -ldl -lm -lpthread
-// #cgo windows,amd64 LDFLAGS: ${SRCDIR}/lib/windows-x64/t2z.lib
-// #cgo windows,arm64 LDFLAGS: ${SRCDIR}/lib/windows-arm64/t2z.lib
-// #include <stdlib.h>
-// #include "t2z.h"
-import "C"
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"runtime"
-	"unsafe"
+	"sort"
+	"sync"
+	"unicode/utf8"
 )
 
 // ResultCode represents the result of an FFI function call
 type ResultCode int
 
+// Result codes, matching t2z.h
 const (
-	Success            ResultCode = C.SUCCESS
-	ErrorNullPointer   ResultCode = C.ERROR_NULL_POINTER
-	ErrorInvalidUTF8   ResultCode = C.ERROR_INVALID_UTF8
-	ErrorBufferTooSmall ResultCode = C.ERROR_BUFFER_TOO_SMALL
-	ErrorProposal      ResultCode = C.ERROR_PROPOSAL
-	ErrorProver        ResultCode = C.ERROR_PROVER
-	ErrorVerification  ResultCode = C.ERROR_VERIFICATION
-	ErrorSighash       ResultCode = C.ERROR_SIGHASH
-	ErrorSignature     ResultCode = C.ERROR_SIGNATURE
-	ErrorCombine       ResultCode = C.ERROR_COMBINE
-	ErrorFinalization  ResultCode = C.ERROR_FINALIZATION
-	ErrorParse         ResultCode = C.ERROR_PARSE
-	ErrorNotImplemented ResultCode = C.ERROR_NOT_IMPLEMENTED
+	Success            ResultCode = 0
+	ErrorNullPointer   ResultCode = 1
+	ErrorInvalidUTF8   ResultCode = 2
+	ErrorBufferTooSmall ResultCode = 3
+	ErrorProposal      ResultCode = 10
+	ErrorProver        ResultCode = 11
+	ErrorVerification  ResultCode = 12
+	ErrorSighash       ResultCode = 13
+	ErrorSignature     ResultCode = 14
+	ErrorCombine       ResultCode = 15
+	ErrorFinalization  ResultCode = 16
+	ErrorParse         ResultCode = 17
+	ErrorNotImplemented ResultCode = 99
 )
 
 // String returns the string representation of a ResultCode
@@ -85,20 +97,104 @@ func (r ResultCode) String() string {
 	}
 }
 
+// Sentinel errors for each failing ResultCode.
+//
+// Errors returned by FFI calls wrap one of these, so callers can branch on the
+// failure type with errors.Is, e.g. errors.Is(err, t2z.ErrVerification).
+var (
+	ErrNullPointer    = errors.New("t2z error: " + ErrorNullPointer.String())
+	ErrInvalidUTF8    = errors.New("t2z error: " + ErrorInvalidUTF8.String())
+	ErrBufferTooSmall = errors.New("t2z error: " + ErrorBufferTooSmall.String())
+	ErrProposal       = errors.New("t2z error: " + ErrorProposal.String())
+	ErrProver         = errors.New("t2z error: " + ErrorProver.String())
+	ErrVerification   = errors.New("t2z error: " + ErrorVerification.String())
+	ErrSighash        = errors.New("t2z error: " + ErrorSighash.String())
+	ErrSignature      = errors.New("t2z error: " + ErrorSignature.String())
+	ErrCombine        = errors.New("t2z error: " + ErrorCombine.String())
+	ErrFinalization   = errors.New("t2z error: " + ErrorFinalization.String())
+	ErrParse          = errors.New("t2z error: " + ErrorParse.String())
+	ErrNotImplemented = errors.New("t2z error: " + ErrorNotImplemented.String())
+)
+
+// ErrUnsupportedPlatform is returned by every function that needs the native
+// library when the package is built for a platform without one, or without
+// cgo. Bundled libraries cover darwin, linux and windows on amd64 and arm64.
+var ErrUnsupportedPlatform = errors.New("t2z: native library is not available for this platform")
+
+// Err returns the sentinel error for a ResultCode, or nil for Success and unknown codes
+func (r ResultCode) Err() error {
+	switch r {
+	case ErrorNullPointer:
+		return ErrNullPointer
+	case ErrorInvalidUTF8:
+		return ErrInvalidUTF8
+	case ErrorBufferTooSmall:
+		return ErrBufferTooSmall
+	case ErrorProposal:
+		return ErrProposal
+	case ErrorProver:
+		return ErrProver
+	case ErrorVerification:
+		return ErrVerification
+	case ErrorSighash:
+		return ErrSighash
+	case ErrorSignature:
+		return ErrSignature
+	case ErrorCombine:
+		return ErrCombine
+	case ErrorFinalization:
+		return ErrFinalization
+	case ErrorParse:
+		return ErrParse
+	case ErrorNotImplemented:
+		return ErrNotImplemented
+	default:
+		return nil
+	}
+}
+
+// Initial and maximum buffer sizes for the last error message
+const (
+	lastErrorBufferSize    = 512
+	maxLastErrorBufferSize = 1 << 20
+)
+
 // getLastError retrieves the last error message from the Rust library
 func getLastError() string {
-	buf := make([]byte, 512)
-	code := C.pczt_get_last_error((*C.char)(unsafe.Pointer(&buf[0])), C.size_t(len(buf)))
-	if code != C.SUCCESS {
-		return "Failed to get last error"
+	return lastError(lastErrorBufferSize)
+}
+
+// Error is the error returned when an FFI call fails.
+//
+// Callers can inspect it with errors.As to branch on the ResultCode, or use
+// errors.Is with the matching sentinel (e.g. ErrVerification).
+type Error struct {
+	// Code is the ResultCode returned by the Rust library
+	Code ResultCode
+
+	// Message is the descriptive message from the Rust library (may be empty)
+	Message string
+
+	// InputIndex is the transparent input the failing operation was working on,
+	// or -1 if the operation was not input-specific
+	InputIndex int
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	msg := "t2z error: " + e.Code.String()
+	if e.Message != "" {
+		msg += ": " + e.Message
 	}
-	// Find null terminator
-	for i, b := range buf {
-		if b == 0 {
-			return string(buf[:i])
-		}
+	if e.InputIndex >= 0 {
+		msg += fmt.Sprintf(" (input %d)", e.InputIndex)
 	}
-	return string(buf)
+	return msg
+}
+
+// Unwrap returns the sentinel error for the ResultCode, enabling errors.Is
+func (e *Error) Unwrap() error {
+	return e.Code.Err()
 }
 
 // wrapError wraps a ResultCode into a Go error with the last error message
@@ -106,13 +202,20 @@ func wrapError(code ResultCode) error {
 	if code == Success {
 		return nil
 	}
-	msg := getLastError()
-	if msg == "" {
-		return fmt.Errorf("t2z error: %s", code.String())
+	return &Error{Code: code, Message: getLastError(), InputIndex: -1}
+}
+
+// wrapInputError is like wrapError but records the input being processed
+func wrapInputError(code ResultCode, inputIndex uint) error {
+	if code == Success {
+		return nil
 	}
-	return fmt.Errorf("t2z error: %s: %s", code.String(), msg)
+	return &Error{Code: code, Message: getLastError(), InputIndex: int(inputIndex)}
 }
 
+// MaxMemoLength is the maximum length of a shielded memo in bytes
+const MaxMemoLength = 512
+
 // Payment represents a single payment to a recipient
 type Payment struct {
 	// Address can be a transparent address (starts with 't')
@@ -122,9 +225,16 @@ type Payment struct {
 	// Amount in zatoshis (1 ZEC = 100,000,000 zatoshis)
 	Amount uint64
 
-	// Optional memo for shielded outputs (max 512 bytes)
+	// Optional memo for shielded outputs (max 512 bytes, not allowed for transparent addresses)
 	Memo string
 
+	// Optional memo as raw bytes. When non-nil it takes precedence over Memo.
+	//
+	// The native library currently only accepts text memos, so the bytes must
+	// be valid UTF-8 without NUL bytes; NewTransactionRequest returns an error
+	// otherwise instead of silently mangling the memo.
+	MemoBytes []byte
+
 	// Optional label for the recipient
 	Label string
 
@@ -135,82 +245,216 @@ type Payment struct {
 // TransactionRequest represents a ZIP 321 payment request
 type TransactionRequest struct {
 	Payments []Payment
-	handle   *C.TransactionRequestHandle
+	handle   requestHandle
+
+	// Settings applied to the handle, kept so it can be rebuilt by AddRawOutput
+	targetHeight      uint32  // 0 if unset
+	network           Network // 0 until SetNetwork, see SetUseMainnet
+	consensusBranchID uint32  // 0 if unset, applied after proposal
+	expiryHeight      uint32  // 0 if unset, applied after proposal
+
+	allowDuplicates bool // see NewTransactionRequestAllowDuplicates
 }
 
-// NewTransactionRequest creates a new transaction request from a list of payments
-func NewTransactionRequest(payments []Payment) (*TransactionRequest, error) {
-	if len(payments) == 0 {
-		return nil, errors.New("at least one payment is required")
+// ErrDuplicateRecipient is returned when a request pays the same address more
+// than once, which ZIP-321 forbids
+var ErrDuplicateRecipient = errors.New("duplicate recipient address")
+
+// NewTransactionRequest creates a new transaction request from a list of
+// payments, configured by opts in order:
+//
+//	t2z.NewTransactionRequest(payments, t2z.WithNetwork(t2z.NetworkTestnet), t2z.WithTargetHeight(height))
+//
+// Returns an error wrapping ErrDuplicateRecipient if two payments share an
+// address; use NewTransactionRequestAllowDuplicates to accept them. Returns
+// the error of the first option that fails.
+func NewTransactionRequest(payments []Payment, opts ...RequestOption) (*TransactionRequest, error) {
+	if err := checkDuplicateRecipients(payments); err != nil {
+		return nil, err
 	}
+	return newTransactionRequest(payments, false, opts)
+}
 
-	// Convert payments to C array
-	cPayments := make([]C.CPayment, len(payments))
-	var cStrings []*C.char
+// NewTransactionRequestAllowDuplicates creates a transaction request like
+// NewTransactionRequest, but accepts several payments to the same address.
+//
+// Each payment becomes its own output. Such a request cannot be expressed as a
+// ZIP-321 URI, so only use it when the repeated recipient is intended.
+func NewTransactionRequestAllowDuplicates(payments []Payment, opts ...RequestOption) (*TransactionRequest, error) {
+	return newTransactionRequest(payments, true, opts)
+}
 
-	for i, payment := range payments {
-		// Convert address (required)
-		cAddr := C.CString(payment.Address)
-		cStrings = append(cStrings, cAddr)
-		cPayments[i].address = cAddr
-		cPayments[i].amount = C.uint64_t(payment.Amount)
+// RequestOption configures a TransactionRequest when it is created, see
+// NewTransactionRequest
+type RequestOption func(*TransactionRequest) error
 
-		// Convert optional fields
-		if payment.Memo != "" {
-			cMemo := C.CString(payment.Memo)
-			cStrings = append(cStrings, cMemo)
-			cPayments[i].memo = cMemo
-		}
-		if payment.Label != "" {
-			cLabel := C.CString(payment.Label)
-			cStrings = append(cStrings, cLabel)
-			cPayments[i].label = cLabel
-		}
-		if payment.Message != "" {
-			cMsg := C.CString(payment.Message)
-			cStrings = append(cStrings, cMsg)
-			cPayments[i].message = cMsg
+// WithTargetHeight sets the target height of the request, see
+// SetTargetHeight. The height must not be 0.
+func WithTargetHeight(height uint32) RequestOption {
+	return func(r *TransactionRequest) error {
+		if height == 0 {
+			return errors.New("invalid target height 0")
 		}
+		return r.SetTargetHeight(height)
 	}
+}
 
-	// Cleanup C strings when done
-	defer func() {
-		for _, s := range cStrings {
-			C.free(unsafe.Pointer(s))
-		}
-	}()
+// WithNetwork sets the network of the request, see SetNetwork
+func WithNetwork(net Network) RequestOption {
+	return func(r *TransactionRequest) error {
+		return r.SetNetwork(net)
+	}
+}
 
-	var handle *C.TransactionRequestHandle
-	code := C.pczt_transaction_request_new(
-		&cPayments[0],
-		C.size_t(len(payments)),
-		&handle,
-	)
+// WithExpiryHeight sets the expiry height of the request, see SetExpiryHeight
+func WithExpiryHeight(height uint32) RequestOption {
+	return func(r *TransactionRequest) error {
+		return r.SetExpiryHeight(height)
+	}
+}
 
-	if code != C.SUCCESS {
-		return nil, wrapError(ResultCode(code))
+// newTransactionRequest creates a request and its native handle, and applies opts
+func newTransactionRequest(payments []Payment, allowDuplicates bool, opts []RequestOption) (*TransactionRequest, error) {
+	if len(payments) == 0 {
+		return nil, errors.New("at least one payment is required")
+	}
+
+	handle, err := newRequestHandle(payments)
+	if err != nil {
+		return nil, err
 	}
 
 	req := &TransactionRequest{
-		Payments: payments,
-		handle:   handle,
+		Payments:        payments,
+		handle:          handle,
+		allowDuplicates: allowDuplicates,
 	}
 
 	// Set finalizer to free the handle when GC'd
 	runtime.SetFinalizer(req, func(r *TransactionRequest) {
 		if r.handle != nil {
-			C.pczt_transaction_request_free(r.handle)
+			ffiRequestFree(r.handle)
 		}
 	})
 
+	for _, opt := range opts {
+		if err := opt(req); err != nil {
+			req.Free()
+			return nil, err
+		}
+	}
 	return req, nil
 }
 
+// HasDuplicateRecipients reports whether two payments of the request share an
+// address. It can only be true for requests created with
+// NewTransactionRequestAllowDuplicates.
+func (r *TransactionRequest) HasDuplicateRecipients() bool {
+	return r != nil && checkDuplicateRecipients(r.Payments) != nil
+}
+
+// checkDuplicateRecipients returns an error wrapping ErrDuplicateRecipient for
+// the first address that is paid more than once
+func checkDuplicateRecipients(payments []Payment) error {
+	seen := make(map[string]int, len(payments))
+	for i, payment := range payments {
+		if j, ok := seen[payment.Address]; ok {
+			return fmt.Errorf("payments %d and %d: %w %s", j, i, ErrDuplicateRecipient, payment.Address)
+		}
+		seen[payment.Address] = i
+	}
+	return nil
+}
+
+// newRequestHandle validates payments and creates the native request handle
+func newRequestHandle(payments []Payment) (requestHandle, error) {
+	// Memos as passed to the native library, "" if none
+	memos := make([]string, len(payments))
+
+	for i, payment := range payments {
+		memo := payment.Memo
+		if payment.MemoBytes != nil {
+			if !utf8.Valid(payment.MemoBytes) || bytes.IndexByte(payment.MemoBytes, 0) >= 0 {
+				return nil, fmt.Errorf("payment %d: binary memos are not supported, MemoBytes must be UTF-8 text without NUL bytes", i)
+			}
+			memo = string(payment.MemoBytes)
+		}
+		if len(memo) > MaxMemoLength {
+			return nil, fmt.Errorf("payment %d: memo too long: %d bytes exceeds the %d byte limit", i, len(memo), MaxMemoLength)
+		}
+		if memo != "" && isTransparentAddress(payment.Address) {
+			return nil, fmt.Errorf("payment %d: transparent address %s cannot receive a memo", i, payment.Address)
+		}
+		memos[i] = memo
+	}
+
+	return ffiRequestNew(payments, memos)
+}
+
+// AddRawOutput appends a transparent output paying value to scriptPubKey.
+//
+// The native library builds outputs from ZIP-321 addresses, so only P2PKH and
+// P2SH scripts are supported: the script is converted to the equivalent
+// transparent address for the request's network and appended to Payments.
+// Like any other payment, the output counts towards the fee and is checked by
+// VerifyBeforeSigning. Other scripts return an error wrapping ErrNotImplemented.
+// An address the request already pays returns an error wrapping
+// ErrDuplicateRecipient, unless the request allows duplicates.
+func (r *TransactionRequest) AddRawOutput(scriptPubKey []byte, value uint64) error {
+	if r == nil || r.handle == nil {
+		return errors.New("invalid transaction request")
+	}
+
+	addr, err := scriptToAddress(scriptPubKey, r.network == 0 || r.network == NetworkMainnet)
+	if err != nil {
+		return err
+	}
+
+	payments := make([]Payment, len(r.Payments), len(r.Payments)+1)
+	copy(payments, r.Payments)
+	payments = append(payments, Payment{Address: addr, Amount: value})
+	if !r.allowDuplicates {
+		if err := checkDuplicateRecipients(payments); err != nil {
+			return err
+		}
+	}
+
+	handle, err := r.newHandleWithSettings(payments)
+	if err != nil {
+		return err
+	}
+
+	ffiRequestFree(r.handle)
+	r.handle = handle
+	r.Payments = payments
+	return nil
+}
+
+// newHandleWithSettings creates a native request handle for payments with the
+// same network and target height settings as r
+func (r *TransactionRequest) newHandleWithSettings(payments []Payment) (requestHandle, error) {
+	handle, err := newRequestHandle(payments)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ffiRequestSetUseMainnet(handle, r.mainnetParams())
+	if err == nil && r.targetHeight != 0 {
+		err = ffiRequestSetTargetHeight(handle, r.targetHeight)
+	}
+	if err != nil {
+		ffiRequestFree(handle)
+		return nil, err
+	}
+
+	return handle, nil
+}
+
 // Free explicitly frees the transaction request
 func (r *TransactionRequest) Free() {
 	if r.handle != nil {
 		runtime.SetFinalizer(r, nil) // Clear finalizer to prevent double-free
-		C.pczt_transaction_request_free(r.handle)
+		ffiRequestFree(r.handle)
 		r.handle = nil
 	}
 }
@@ -299,16 +543,27 @@ func serializeTransparentInputs(inputs []TransparentInput) []byte {
 }
 
 // PCZT represents a Partially Constructed Zcash Transaction
+//
+// A PCZT is safe for concurrent use. Non-consuming operations (GetSighash,
+// SerializePCZT, ClonePCZT, VerifyBeforeSigning and SignedSize) take a shared
+// lock and may run in parallel with each other. Consuming operations
+// (ProveTransaction, AppendSignature, Combine, FinalizeAndExtract) and Free
+// take an exclusive lock: they wait for in-flight reads to finish, and any
+// operation started after them fails with "invalid PCZT".
 type PCZT struct {
-	handle *C.PcztHandle
+	mu     sync.RWMutex
+	handle pcztHandle
+
+	// Sighash types chosen with GetSighashWithType, applied by AppendSignature
+	sighashTypes map[uint]SighashType
 }
 
 // newPCZT creates a new PCZT with automatic cleanup via finalizer
-func newPCZT(handle *C.PcztHandle) *PCZT {
+func newPCZT(handle pcztHandle) *PCZT {
 	p := &PCZT{handle: handle}
 	runtime.SetFinalizer(p, func(pczt *PCZT) {
 		if pczt.handle != nil {
-			C.pczt_free(pczt.handle)
+			ffiPCZTFree(pczt.handle)
 		}
 	})
 	return p
@@ -316,15 +571,21 @@ func newPCZT(handle *C.PcztHandle) *PCZT {
 
 // Free explicitly frees the PCZT handle (optional - GC will handle automatically)
 func (p *PCZT) Free() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	if p.handle != nil {
 		runtime.SetFinalizer(p, nil) // Clear finalizer to prevent double-free
-		C.pczt_free(p.handle)
+		ffiPCZTFree(p.handle)
 		p.handle = nil
 	}
 }
 
 // consumeHandle returns the handle and clears it (transfers ownership)
-func (p *PCZT) consumeHandle() *C.PcztHandle {
+func (p *PCZT) consumeHandle() pcztHandle {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	if p.handle == nil {
 		return nil
 	}
@@ -338,6 +599,14 @@ func (p *PCZT) consumeHandle() *C.PcztHandle {
 //
 // This implements the Creator, Constructor, and IO Finalizer roles.
 //
+// Only transparent inputs can be spent. Spending Orchard notes (z→t, z→z)
+// would need the note, its Merkle path and anchor, and a spend authorizing
+// key to be passed through the FFI, which the native library does not
+// support.
+//
+// Change goes to the address of the first input's pubkey. If inputs have
+// different pubkeys, use ProposeTransactionWithChange with a change address.
+//
 // Parameters:
 //   - inputs: List of transparent UTXOs to spend
 //   - request: Transaction request with payment recipients
@@ -347,17 +616,39 @@ func ProposeTransaction(inputs []TransparentInput, request *TransactionRequest)
 	return ProposeTransactionWithChange(inputs, request, "")
 }
 
+// ErrAmbiguousChange is returned when inputs belong to different pubkeys, the
+// transaction has change, and no change address was given
+var ErrAmbiguousChange = errors.New("inputs have different pubkeys, a change address is required")
+
 // ProposeTransactionWithChange creates a PCZT with an explicit change address.
 //
 // This implements the Creator, Constructor, and IO Finalizer roles.
 //
+// The change address may be a unified address, so the change leg is shielded
+// instead of linking the spent UTXOs to a transparent address. The native
+// library only builds transparent change, so shielded change is added as an
+// extra Orchard payment of inputs - payments - fee, with the fee accounting
+// for that output. VerifyBeforeSigning only checks transparent outputs, so it
+// does not check the shielded change.
+//
 // Parameters:
 //   - inputs: List of transparent UTXOs to spend
 //   - request: Transaction request with payment recipients
-//   - changeAddress: Optional transparent address for change. If empty, derives from first input's pubkey
+//   - changeAddress: Optional transparent or unified address for change. If empty, derives from first input's pubkey
+//
+// Deriving the change address from the first input would send the change of
+// a multi-party transaction to one party, so when inputs have different
+// pubkeys and the transaction has change, changeAddress is required and
+// ErrAmbiguousChange is returned without it.
 //
 // Returns the created PCZT or an error.
 func ProposeTransactionWithChange(inputs []TransparentInput, request *TransactionRequest, changeAddress string) (*PCZT, error) {
+	return proposeTransaction(context.Background(), inputs, request, changeAddress)
+}
+
+// proposeTransaction implements ProposeTransactionWithChange, tracing the
+// native calls in ctx
+func proposeTransaction(ctx context.Context, inputs []TransparentInput, request *TransactionRequest, changeAddress string) (*PCZT, error) {
 	if len(inputs) == 0 {
 		return nil, errors.New("at least one input is required")
 	}
@@ -365,35 +656,292 @@ func ProposeTransactionWithChange(inputs []TransparentInput, request *Transactio
 		return nil, errors.New("invalid transaction request")
 	}
 
-	// Serialize inputs to the binary format
-	inputBytes := serializeTransparentInputs(inputs)
+	if err := request.ValidateNetwork(); err != nil {
+		return nil, err
+	}
+	if err := request.checkExpiryHeight(); err != nil {
+		return nil, err
+	}
+	if changeAddress != "" {
+		if err := request.checkAddressNetwork(changeAddress); err != nil {
+			return nil, fmt.Errorf("change address: %w", err)
+		}
+	} else if !sharedPubkey(inputs) {
+		if change, err := ProposalChange(inputs, request); err == nil && change > 0 {
+			return nil, ErrAmbiguousChange
+		}
+	}
+
+	if isUnifiedAddress(changeAddress) {
+		return proposeWithShieldedChange(ctx, inputs, request, changeAddress)
+	}
+
+	done := startStage(ctx, StagePropose, -1)
+	pcztHandle, err := ffiProposeTransaction(inputs, request.handle, changeAddress)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+
+	pczt := newPCZT(pcztHandle)
+	if request.consensusBranchID != 0 {
+		if pczt, err = withConsensusBranchID(pczt, request.consensusBranchID); err != nil {
+			return nil, err
+		}
+	}
+	if request.expiryHeight != 0 {
+		return withExpiryHeight(pczt, request.expiryHeight)
+	}
+	return pczt, nil
+}
+
+// DefaultDustThreshold is the transparent output value in zatoshis below
+// which an output is dust, see DustThreshold. It is the default threshold of
+// DustPolicyReject.
+const DefaultDustThreshold = ZIP317MarginalFee
+
+// DustThreshold returns the value in zatoshis below which a transparent
+// output is dust.
+//
+// Under ZIP-317 every transparent input is a logical action, so spending an
+// output worth less than the marginal fee costs more than it is worth.
+// Avoid creating such outputs, and leave such UTXOs out of coin selection.
+// The threshold is well above the relay dust limit of zcashd (54 zatoshis
+// for P2PKH), so outputs that are not dust are always relayed.
+func DustThreshold() uint64 {
+	return DefaultDustThreshold
+}
+
+// IsDust reports whether an output of value zatoshis is dust.
+//
+// Transparent outputs are dust below DustThreshold. Shielded (Orchard)
+// outputs are only dust if they are worth nothing: the network has no dust
+// rule for them, and a note can be spent in an action that pays for an output
+// anyway, so even a small note can be spent without an extra fee.
+func IsDust(value uint64, isShielded bool) bool {
+	if isShielded {
+		return value == 0
+	}
+	return value < DustThreshold()
+}
+
+// DustPolicy controls what ProposeTransactionWithOptions does when the change
+// output would be dust.
+//
+// Rolling dust into the fee is not offered: the native library always pays
+// exactly the ZIP-317 fee, so the change cannot be absorbed. Add or drop an
+// input, or adjust a payment amount, to avoid it.
+type DustPolicy int
+
+const (
+	// DustPolicyAllow creates change outputs of any value (the default)
+	DustPolicyAllow DustPolicy = iota
+
+	// DustPolicyReject returns an error instead of creating dust change
+	DustPolicyReject
+)
+
+// proposeWithShieldedChange proposes a transaction that sends its change to
+// the unified address changeAddress as an extra Orchard payment
+func proposeWithShieldedChange(ctx context.Context, inputs []TransparentInput, request *TransactionRequest, changeAddress string) (*PCZT, error) {
+	_, change, err := shieldedChangeShape(inputs, request, changeAddress)
+	if err != nil {
+		return nil, err
+	}
+	if change == 0 {
+		// Without the extra output the fee may drop, and the difference
+		// would become transparent change
+		if leftover, err := ProposalChange(inputs, request); err == nil && leftover > 0 {
+			return nil, fmt.Errorf("inputs exceed payments plus fee by %d zatoshis, too little to pay for a shielded change output", leftover)
+		}
+		return proposeTransaction(ctx, inputs, request, "")
+	}
+
+	payments := make([]Payment, len(request.Payments), len(request.Payments)+1)
+	copy(payments, request.Payments)
+	payments = append(payments, Payment{Address: changeAddress, Amount: change})
 
-	// Convert change address to C string (nullable)
-	var cChangeAddr *C.char
+	handle, err := request.newHandleWithSettings(payments)
+	if err != nil {
+		return nil, err
+	}
+	withChange := &TransactionRequest{
+		Payments:          payments,
+		handle:            handle,
+		targetHeight:      request.targetHeight,
+		network:           request.network,
+		consensusBranchID: request.consensusBranchID,
+		expiryHeight:      request.expiryHeight,
+	}
+	defer withChange.Free()
+
+	return proposeTransaction(ctx, inputs, withChange, "")
+}
+
+// ProposeOptions configures ProposeTransactionWithOptions
+type ProposeOptions struct {
+	// Fee pins the transaction fee in zatoshis. If nil, the ZIP-317 fee is used.
+	Fee *uint64
+	// ChangeAddress is an optional transparent or unified address for change.
+	// If empty, change goes to the address of the first input's pubkey.
+	ChangeAddress string
+	// DustPolicy controls change outputs below DustThreshold
+	DustPolicy DustPolicy
+	// DustThreshold is the dust limit in zatoshis. If 0, change is dust as
+	// reported by IsDust: transparent change below DustThreshold().
+	DustThreshold uint64
+}
+
+// ProposeTransactionWithOptions creates a PCZT like ProposeTransactionWithChange,
+// optionally checking the fee and change against the options.
+//
+// The native library always pays exactly the ZIP-317 fee for the transaction
+// shape, so a pinned Fee acts as an assertion: a fee below the ZIP-317 minimum
+// is rejected, since the network would refuse the transaction, and a fee above
+// it returns an error wrapping ErrNotImplemented, since the library cannot
+// overpay. A matching fee proceeds normally.
+//
+// Use ProposalChange to find out whether a change output will be created.
+//
+// Returns the created PCZT or an error.
+func ProposeTransactionWithOptions(inputs []TransparentInput, request *TransactionRequest, opts ProposeOptions) (*PCZT, error) {
+	if (opts.Fee != nil || opts.DustPolicy != DustPolicyAllow) && request != nil {
+		var fee, change uint64
+		var err error
+		if isUnifiedAddress(opts.ChangeAddress) {
+			fee, change, err = shieldedChangeShape(inputs, request, opts.ChangeAddress)
+		} else {
+			fee, change, err = proposalShape(inputs, request)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if opts.Fee != nil && *opts.Fee < fee {
+			return nil, fmt.Errorf("fee %d is below the ZIP-317 minimum of %d zatoshis", *opts.Fee, fee)
+		}
+		if opts.Fee != nil && *opts.Fee > fee {
+			return nil, fmt.Errorf("fee %d exceeds the ZIP-317 fee of %d zatoshis; overpaying is not supported: %w", *opts.Fee, fee, ErrNotImplemented)
+		}
+
+		if opts.DustPolicy == DustPolicyReject && change > 0 {
+			if opts.DustThreshold != 0 && change < opts.DustThreshold {
+				return nil, fmt.Errorf("change of %d zatoshis is below the dust threshold of %d", change, opts.DustThreshold)
+			}
+			if opts.DustThreshold == 0 && IsDust(change, isUnifiedAddress(opts.ChangeAddress)) {
+				return nil, fmt.Errorf("change of %d zatoshis is below the dust threshold of %d", change, DustThreshold())
+			}
+		}
+	}
+
+	return ProposeTransactionWithChange(inputs, request, opts.ChangeAddress)
+}
+
+// ProposalChange returns the value of the change output ProposeTransaction
+// will create when spending inputs to request. A result of 0 means no change
+// output is created because the inputs exactly cover payments plus fee.
+//
+// Returns an error if the inputs do not cover the payments plus fee.
+func ProposalChange(inputs []TransparentInput, request *TransactionRequest) (uint64, error) {
+	if request == nil {
+		return 0, errors.New("invalid transaction request")
+	}
+	_, change, err := proposalShape(inputs, request)
+	return change, err
+}
+
+// ExpectedChange returns the transparent change output ProposeTransactionWithChange
+// creates when spending inputs to request with changeAddress. Pass the result
+// to VerifyBeforeSigning as expectedChange.
+//
+// The change is computed from the inputs and request like the proposal does,
+// not read from a PCZT, so an output added to the PCZT by someone else fails
+// verification instead of passing as change. It pays changeAddress, or the
+// address of the first input's pubkey if changeAddress is empty.
+//
+// Shielded change to a unified changeAddress is an Orchard output and is not
+// included. The result is empty when the proposal has no change.
+//
+// Returns an error if the inputs do not cover the payments plus fee.
+func ExpectedChange(inputs []TransparentInput, request *TransactionRequest, changeAddress string) ([]TransparentOutput, error) {
+	if len(inputs) == 0 {
+		return nil, errors.New("at least one input is required")
+	}
+	change, err := ProposalChange(inputs, request)
+	if err != nil {
+		return nil, err
+	}
+	if change == 0 || isUnifiedAddress(changeAddress) {
+		return nil, nil
+	}
+
+	script := p2pkhScript(hash160(inputs[0].Pubkey))
 	if changeAddress != "" {
-		cChangeAddr = C.CString(changeAddress)
-		defer C.free(unsafe.Pointer(cChangeAddr))
+		if script, err = addressToScript(changeAddress); err != nil {
+			return nil, fmt.Errorf("change address: %w", err)
+		}
+	}
+	return []TransparentOutput{{ScriptPubKey: script, Value: change}}, nil
+}
+
+// shieldedChangeShape returns the ZIP-317 fee and change when the change is
+// paid to the unified address changeAddress as an extra Orchard output
+func shieldedChangeShape(inputs []TransparentInput, request *TransactionRequest, changeAddress string) (fee, change uint64, err error) {
+	payments := make([]Payment, len(request.Payments), len(request.Payments)+1)
+	copy(payments, request.Payments)
+	payments = append(payments, Payment{Address: changeAddress})
+
+	fee = CalculateFeeForRequest(inputs, &TransactionRequest{Payments: payments}, false)
+	change, err = ChangeAmount(inputs, request.Payments, fee)
+	if err != nil {
+		return 0, 0, err
+	}
+	return fee, change, nil
+}
+
+// proposalShape returns the ZIP-317 fee and change ProposeTransaction will
+// use. A change output is added unless the inputs exactly cover payments plus fee.
+func proposalShape(inputs []TransparentInput, request *TransactionRequest) (fee, change uint64, err error) {
+	feeNoChange := CalculateFeeForRequest(inputs, request, false)
+	change, err = ChangeAmount(inputs, request.Payments, feeNoChange)
+	if err != nil || change == 0 {
+		return feeNoChange, 0, err
 	}
 
-	var pcztHandle *C.PcztHandle
-	code := C.pczt_propose_transaction(
-		(*C.uint8_t)(unsafe.Pointer(&inputBytes[0])),
-		C.size_t(len(inputBytes)),
-		request.handle,
-		cChangeAddr,
-		&pcztHandle,
-	)
+	fee = CalculateFeeForRequest(inputs, request, true)
+	change, err = ChangeAmount(inputs, request.Payments, fee)
+	if err != nil {
+		return 0, 0, err
+	}
+	return fee, change, nil
+}
 
-	if code != C.SUCCESS {
-		return nil, wrapError(ResultCode(code))
+// sharedPubkey reports whether all inputs have the same pubkey
+func sharedPubkey(inputs []TransparentInput) bool {
+	for _, input := range inputs[1:] {
+		if !bytes.Equal(input.Pubkey, inputs[0].Pubkey) {
+			return false
+		}
 	}
+	return true
+}
+
+// isTransparentAddress reports whether addr is a valid transparent address
+func isTransparentAddress(addr string) bool {
+	info, err := ValidateAddress(addr)
+	return err == nil && info.Kind.IsTransparent()
+}
 
-	return newPCZT(pcztHandle), nil
+// isUnifiedAddress reports whether addr is a valid unified address
+func isUnifiedAddress(addr string) bool {
+	info, err := ValidateAddress(addr)
+	return err == nil && info.Kind == AddressUnified
 }
 
 // ProveTransaction adds Orchard proofs to a PCZT.
 //
 // This implements the Prover role. The proving key is embedded in the Rust library.
+// It is built by the first Orchard proof in the process and cached, which
+// makes that proof several seconds slower; see InitProvingParams.
 //
 // This operation can be performed in parallel with signing operations.
 //
@@ -404,18 +952,26 @@ func ProposeTransactionWithChange(inputs []TransparentInput, request *Transactio
 //
 // Returns a new PCZT with proofs added.
 func ProveTransaction(pczt *PCZT) (*PCZT, error) {
-	if pczt == nil || pczt.handle == nil {
+	return proveTransaction(context.Background(), pczt)
+}
+
+// proveTransaction implements ProveTransaction, tracing the native call in ctx
+func proveTransaction(ctx context.Context, pczt *PCZT) (*PCZT, error) {
+	if pczt == nil {
 		return nil, errors.New("invalid PCZT")
 	}
 
 	// Consume input PCZT (transfers ownership to Rust)
 	handle := pczt.consumeHandle()
+	if handle == nil {
+		return nil, errors.New("invalid PCZT")
+	}
 
-	var outHandle *C.PcztHandle
-	code := C.pczt_prove_transaction(handle, &outHandle)
-
-	if code != C.SUCCESS {
-		return nil, wrapError(ResultCode(code))
+	done := startStage(ctx, StageProve, -1)
+	outHandle, err := ffiProveTransaction(handle)
+	done(err)
+	if err != nil {
+		return nil, err
 	}
 
 	return newPCZT(outHandle), nil
@@ -427,28 +983,27 @@ func ProveTransaction(pczt *PCZT) (*PCZT, error) {
 // you can sign it using your preferred signing infrastructure (including
 // hardware wallets), then use AppendSignature to add the signature to the PCZT.
 //
+// The sighash is computed for SIGHASH_ALL; see GetSighashWithType for the
+// other sighash types.
+//
 // Parameters:
 //   - pczt: The PCZT to get the sighash from
 //   - inputIndex: The index of the input to sign
 //
 // Returns the 32-byte signature hash.
 func GetSighash(pczt *PCZT, inputIndex uint) ([32]byte, error) {
-	if pczt == nil || pczt.handle == nil {
+	if pczt == nil {
 		return [32]byte{}, errors.New("invalid PCZT")
 	}
 
-	var sighash [32]byte
-	code := C.pczt_get_sighash(
-		pczt.handle,
-		C.size_t(inputIndex),
-		(*[32]C.uint8_t)(unsafe.Pointer(&sighash[0])),
-	)
+	pczt.mu.RLock()
+	defer pczt.mu.RUnlock()
 
-	if code != C.SUCCESS {
-		return [32]byte{}, wrapError(ResultCode(code))
+	if pczt.handle == nil {
+		return [32]byte{}, errors.New("invalid PCZT")
 	}
 
-	return sighash, nil
+	return ffiGetSighash(pczt.handle, inputIndex)
 }
 
 // AppendSignature adds a signature to the PCZT.
@@ -457,6 +1012,7 @@ func GetSighash(pczt *PCZT, inputIndex uint) ([32]byte, error) {
 // by signing the sighash obtained from GetSighash.
 //
 // The implementation verifies that the signature is valid for the input being spent.
+// A high-S signature is normalized to low-S first (see NormalizeSignature).
 //
 // IMPORTANT: This function ALWAYS consumes the input PCZT, even on error.
 // On error, the input PCZT is invalidated and cannot be reused.
@@ -468,25 +1024,41 @@ func GetSighash(pczt *PCZT, inputIndex uint) ([32]byte, error) {
 //   - inputIndex: The index of the input being signed
 //   - signature: The 64-byte ECDSA signature (r: 32 bytes, s: 32 bytes)
 //
+// If the sighash was obtained with GetSighashWithType, the signature is
+// appended with that sighash type.
+//
 // Returns a new PCZT with the signature added.
 func AppendSignature(pczt *PCZT, inputIndex uint, signature [64]byte) (*PCZT, error) {
-	if pczt == nil || pczt.handle == nil {
+	return appendSignatureContext(context.Background(), pczt, inputIndex, signature)
+}
+
+// appendSignatureContext implements AppendSignature, tracing the native call
+// in ctx
+func appendSignatureContext(ctx context.Context, pczt *PCZT, inputIndex uint, signature [64]byte) (*PCZT, error) {
+	if pczt == nil {
 		return nil, errors.New("invalid PCZT")
 	}
+	if hashType, ok := pczt.sighashType(inputIndex); ok {
+		return appendSignatureWithType(ctx, pczt, inputIndex, signature, hashType)
+	}
+	return appendSignature(ctx, pczt, inputIndex, signature)
+}
 
+// appendSignature appends a signature with the input's sighash type in the PCZT
+func appendSignature(ctx context.Context, pczt *PCZT, inputIndex uint, signature [64]byte) (*PCZT, error) {
 	// Consume input PCZT (transfers ownership to Rust)
 	handle := pczt.consumeHandle()
+	if handle == nil {
+		return nil, errors.New("invalid PCZT")
+	}
 
-	var outHandle *C.PcztHandle
-	code := C.pczt_append_signature(
-		handle,
-		C.size_t(inputIndex),
-		(*[64]C.uint8_t)(unsafe.Pointer(&signature[0])),
-		&outHandle,
-	)
+	signature = NormalizeSignature(signature)
 
-	if code != C.SUCCESS {
-		return nil, wrapError(ResultCode(code))
+	done := startStage(ctx, StageSign, int(inputIndex))
+	outHandle, err := ffiAppendSignature(handle, inputIndex, signature)
+	done(err)
+	if err != nil {
+		return nil, err
 	}
 
 	return newPCZT(outHandle), nil
@@ -506,33 +1078,26 @@ func AppendSignature(pczt *PCZT, inputIndex uint, signature [64]byte) (*PCZT, er
 //
 // Returns the transaction bytes or an error.
 func FinalizeAndExtract(pczt *PCZT) ([]byte, error) {
-	if pczt == nil || pczt.handle == nil {
+	return finalizeAndExtract(context.Background(), pczt)
+}
+
+// finalizeAndExtract implements FinalizeAndExtract, tracing the native call
+// in ctx
+func finalizeAndExtract(ctx context.Context, pczt *PCZT) ([]byte, error) {
+	if pczt == nil {
 		return nil, errors.New("invalid PCZT")
 	}
 
 	// Consume input PCZT (transfers ownership to Rust)
 	handle := pczt.consumeHandle()
-
-	var txBytes *C.uint8_t
-	var txBytesLen C.size_t
-
-	code := C.pczt_finalize_and_extract(
-		handle,
-		&txBytes,
-		&txBytesLen,
-	)
-
-	if code != C.SUCCESS {
-		return nil, wrapError(ResultCode(code))
+	if handle == nil {
+		return nil, errors.New("invalid PCZT")
 	}
 
-	// Copy bytes to Go slice
-	result := C.GoBytes(unsafe.Pointer(txBytes), C.int(txBytesLen))
-
-	// Free the bytes allocated by Rust
-	C.pczt_free_bytes(txBytes, txBytesLen)
-
-	return result, nil
+	done := startStage(ctx, StageFinalize, -1)
+	txBytes, err := ffiFinalizeAndExtract(handle)
+	done(err)
+	return txBytes, err
 }
 
 // ParsePCZT parses a PCZT from bytes.
@@ -546,15 +1111,9 @@ func ParsePCZT(pcztBytes []byte) (*PCZT, error) {
 		return nil, errors.New("empty PCZT bytes")
 	}
 
-	var handle *C.PcztHandle
-	code := C.pczt_parse(
-		(*C.uint8_t)(unsafe.Pointer(&pcztBytes[0])),
-		C.size_t(len(pcztBytes)),
-		&handle,
-	)
-
-	if code != C.SUCCESS {
-		return nil, wrapError(ResultCode(code))
+	handle, err := ffiParsePCZT(pcztBytes)
+	if err != nil {
+		return nil, err
 	}
 
 	return newPCZT(handle), nil
@@ -567,30 +1126,135 @@ func ParsePCZT(pcztBytes []byte) (*PCZT, error) {
 //
 // Returns the serialized bytes or an error.
 func SerializePCZT(pczt *PCZT) ([]byte, error) {
-	if pczt == nil || pczt.handle == nil {
+	if pczt == nil {
 		return nil, errors.New("invalid PCZT")
 	}
 
-	var bytes *C.uint8_t
-	var bytesLen C.size_t
+	pczt.mu.RLock()
+	defer pczt.mu.RUnlock()
 
-	code := C.pczt_serialize(
-		pczt.handle,
-		&bytes,
-		&bytesLen,
-	)
+	if pczt.handle == nil {
+		return nil, errors.New("invalid PCZT")
+	}
+
+	return ffiSerializePCZT(pczt.handle)
+}
+
+// SerializePCZTBase64 serializes a PCZT like SerializePCZT and encodes it as
+// URL-safe base64 without padding, which is about a third smaller than hex
+// and can be embedded in QR codes and URLs as is.
+//
+// Read it back with ParsePCZTBase64.
+func SerializePCZTBase64(pczt *PCZT) (string, error) {
+	data, err := SerializePCZT(pczt)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// ParsePCZTBase64 decodes a PCZT encoded by SerializePCZTBase64 and parses it
+func ParsePCZTBase64(s string) (*PCZT, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 PCZT: %w", err)
+	}
+	return ParsePCZT(data)
+}
+
+// pcztChecksumLength is the number of SHA-256 bytes appended by SerializeWithChecksum
+const pcztChecksumLength = 4
 
-	if code != C.SUCCESS {
-		return nil, wrapError(ResultCode(code))
+// ErrChecksumMismatch is returned by ParsePCZTWithChecksum when the checksum
+// does not match the data
+var ErrChecksumMismatch = errors.New("checksum mismatch: data corrupted in transit")
+
+// SerializeWithChecksum serializes the PCZT like SerializePCZT and appends the
+// first 4 bytes of the SHA-256 hash of the serialized data.
+//
+// Use this when moving a PCZT over a lossy channel (QR codes, clipboard) and
+// read it back with ParsePCZTWithChecksum.
+func (p *PCZT) SerializeWithChecksum() ([]byte, error) {
+	data, err := SerializePCZT(p)
+	if err != nil {
+		return nil, err
 	}
 
-	// Copy bytes to Go slice
-	result := C.GoBytes(unsafe.Pointer(bytes), C.int(bytesLen))
+	checksum := sha256.Sum256(data)
+	return append(data, checksum[:pcztChecksumLength]...), nil
+}
+
+// ParsePCZTWithChecksum verifies and strips the checksum added by
+// SerializeWithChecksum, then parses the PCZT.
+//
+// Returns ErrChecksumMismatch if the data was corrupted.
+func ParsePCZTWithChecksum(data []byte) (*PCZT, error) {
+	if len(data) <= pcztChecksumLength {
+		return nil, errors.New("PCZT data too short to contain a checksum")
+	}
 
-	// Free the bytes allocated by Rust
-	C.pczt_free_bytes(bytes, bytesLen)
+	pcztBytes := data[:len(data)-pcztChecksumLength]
+	checksum := sha256.Sum256(pcztBytes)
+	if !bytes.Equal(checksum[:pcztChecksumLength], data[len(pcztBytes):]) {
+		return nil, ErrChecksumMismatch
+	}
 
-	return result, nil
+	return ParsePCZT(pcztBytes)
+}
+
+// ClonePCZT creates an independent copy of a PCZT.
+//
+// The clone has its own handle, so it can be consumed or freed without
+// affecting the original. This is useful for keeping a pristine backup before
+// calling a consuming function, or for fanning out to multiple signers.
+//
+// The FFI has no clone call, so this is still a pczt_serialize and
+// pczt_parse round trip; it only skips copying the serialized bytes into Go
+// memory, as SerializePCZT followed by ParsePCZT would.
+//
+// Returns the cloned PCZT or an error. The input PCZT is not consumed.
+func ClonePCZT(pczt *PCZT) (*PCZT, error) {
+	if pczt == nil {
+		return nil, errors.New("invalid PCZT")
+	}
+
+	pczt.mu.RLock()
+	defer pczt.mu.RUnlock()
+
+	if pczt.handle == nil {
+		return nil, errors.New("invalid PCZT")
+	}
+
+	handle, err := ffiClonePCZT(pczt.handle)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPCZT(handle), nil
+}
+
+// SignedSize returns the exact size in bytes of the transaction that
+// FinalizeAndExtract would produce from this PCZT.
+//
+// All transparent inputs must already be signed. The size is computed by
+// finalizing an independent clone, so the PCZT itself is not consumed and can
+// still be passed to FinalizeAndExtract afterwards.
+//
+// Use this together with the fee to report the final fee rate of a signed
+// transaction.
+func (p *PCZT) SignedSize() (int, error) {
+	clone, err := ClonePCZT(p)
+	if err != nil {
+		return 0, err
+	}
+
+	// Consumes the clone only
+	txBytes, err := FinalizeAndExtract(clone)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(txBytes), nil
 }
 
 // Combine merges multiple PCZTs into one.
@@ -614,26 +1278,34 @@ func Combine(pczts []*PCZT) (*PCZT, error) {
 
 	// Validate all PCZTs
 	for i, pczt := range pczts {
-		if pczt == nil || pczt.handle == nil {
+		if pczt == nil {
 			return nil, fmt.Errorf("invalid PCZT at index %d", i)
 		}
 	}
 
 	// Consume all input PCZTs (transfers ownership to Rust)
-	handles := make([]*C.PcztHandle, len(pczts))
+	handles := make([]pcztHandle, len(pczts))
+	invalid := -1
 	for i, pczt := range pczts {
 		handles[i] = pczt.consumeHandle()
+		if handles[i] == nil && invalid < 0 {
+			invalid = i
+		}
 	}
 
-	var outHandle *C.PcztHandle
-	code := C.pczt_combine(
-		&handles[0],
-		C.uintptr_t(len(handles)),
-		&outHandle,
-	)
+	// A handle may have been consumed or freed concurrently
+	if invalid >= 0 {
+		for _, h := range handles {
+			if h != nil {
+				ffiPCZTFree(h)
+			}
+		}
+		return nil, fmt.Errorf("invalid PCZT at index %d", invalid)
+	}
 
-	if code != C.SUCCESS {
-		return nil, wrapError(ResultCode(code))
+	outHandle, err := ffiCombine(handles)
+	if err != nil {
+		return nil, err
 	}
 
 	return newPCZT(outHandle), nil
@@ -642,7 +1314,7 @@ func Combine(pczts []*PCZT) (*PCZT, error) {
 // TransparentOutput represents a transparent transaction output.
 // This is used for verifying expected change outputs.
 type TransparentOutput struct {
-	// ScriptPubKey is the P2PKH script of the output (raw bytes, no CompactSize prefix)
+	// ScriptPubKey is the P2PKH or P2SH script of the output (raw bytes, no CompactSize prefix)
 	ScriptPubKey []byte
 
 	// Value in zatoshis
@@ -661,59 +1333,40 @@ type TransparentOutput struct {
 //
 // Returns an error if verification fails.
 func VerifyBeforeSigning(pczt *PCZT, request *TransactionRequest, expectedChange []TransparentOutput) error {
-	if pczt == nil || pczt.handle == nil {
+	if pczt == nil {
+		return errors.New("invalid PCZT")
+	}
+
+	pczt.mu.RLock()
+	defer pczt.mu.RUnlock()
+
+	if pczt.handle == nil {
 		return errors.New("invalid PCZT")
 	}
 	if request == nil || request.handle == nil {
 		return errors.New("invalid transaction request")
 	}
 
-	// Convert expectedChange to C array, copying script data to C memory
-	// to avoid CGO pointer rules violation
-	cOutputs := make([]C.CTransparentOutput, len(expectedChange))
-	scriptPtrs := make([]unsafe.Pointer, len(expectedChange)) // Track for cleanup
-
+	// Reject malformed scripts up front; the Rust comparison would only
+	// report a generic verification failure
 	for i, output := range expectedChange {
-		cOutputs[i].value = C.uint64_t(output.Value)
-		if len(output.ScriptPubKey) > 0 {
-			// Copy to C memory to avoid "Go pointer to Go pointer" issue
-			scriptPtrs[i] = C.CBytes(output.ScriptPubKey)
-			cOutputs[i].script_pub_key = (*C.uchar)(scriptPtrs[i])
-			cOutputs[i].script_pub_key_len = C.uintptr_t(len(output.ScriptPubKey))
+		script := output.ScriptPubKey
+		if isP2PKHScript(script) || isP2SHScript(script) {
+			continue
 		}
-	}
-
-	// Ensure cleanup of C-allocated memory
-	defer func() {
-		for _, ptr := range scriptPtrs {
-			if ptr != nil {
-				C.free(ptr)
-			}
+		if len(script) > 0 && int(script[0]) == len(script)-1 && (isP2PKHScript(script[1:]) || isP2SHScript(script[1:])) {
+			return fmt.Errorf("expected change %d: ScriptPubKey has a CompactSize length prefix, pass the raw script without it", i)
 		}
-	}()
-
-	var cOutputsPtr *C.CTransparentOutput
-	if len(cOutputs) > 0 {
-		cOutputsPtr = &cOutputs[0]
+		return fmt.Errorf("expected change %d: ScriptPubKey is not a P2PKH or P2SH script (%d bytes: %x)", i, len(script), script)
 	}
 
-	code := C.pczt_verify_before_signing(
-		pczt.handle,
-		request.handle,
-		cOutputsPtr,
-		C.uintptr_t(len(expectedChange)),
-	)
-
-	if code != C.SUCCESS {
-		return wrapError(ResultCode(code))
-	}
-
-	return nil
+	return ffiVerifyBeforeSigning(pczt.handle, request.handle, expectedChange)
 }
 
 // SetTargetHeight sets the target block height for consensus branch ID selection.
 //
 // This is important for ensuring the transaction uses the correct consensus rules.
+// SetConsensusBranchID overrides the branch ID derived from the height.
 //
 // Parameters:
 //   - height: The target block height
@@ -722,43 +1375,176 @@ func (r *TransactionRequest) SetTargetHeight(height uint32) error {
 		return errors.New("invalid transaction request")
 	}
 
-	code := C.pczt_transaction_request_set_target_height(
-		r.handle,
-		C.uint32_t(height),
-	)
+	if err := ffiRequestSetTargetHeight(r.handle, height); err != nil {
+		return err
+	}
+
+	r.targetHeight = height
+	return nil
+}
+
+// ExpiryDelta is the number of blocks after the target height at which the
+// native library makes a transaction expire
+const ExpiryDelta uint32 = 40
+
+// TargetHeight returns the height set with SetTargetHeight, and whether one
+// was set. Without one, the native library picks its own default target
+// height when proposing.
+func (r *TransactionRequest) TargetHeight() (uint32, bool) {
+	if r == nil || r.targetHeight == 0 {
+		return 0, false
+	}
+	return r.targetHeight, true
+}
 
-	if code != C.SUCCESS {
-		return wrapError(ResultCode(code))
+// ExpiryHeight returns the expiry height of transactions proposed for the
+// request, and whether it is known. It is the height set with
+// SetExpiryHeight, or else the target height plus ExpiryDelta; it is not
+// known without either.
+func (r *TransactionRequest) ExpiryHeight() (uint32, bool) {
+	if r != nil && r.expiryHeight != 0 {
+		return r.expiryHeight, true
 	}
+	height, ok := r.TargetHeight()
+	if !ok {
+		return 0, false
+	}
+	return height + ExpiryDelta, true
+}
 
+// ErrStaleHeight is returned by Validate when the target or expiry height of
+// a request is not above the chain tip
+var ErrStaleHeight = errors.New("height is not above the chain tip")
+
+// Validate checks the heights of the request against currentHeight, the
+// height of the chain tip. A transaction is mined at the earliest in the next
+// block, so it returns an error wrapping ErrStaleHeight if the target height
+// or the expiry height (see ExpiryHeight) is at or below the tip: the target
+// height may select an outdated consensus branch ID, and the transaction
+// would be rejected as expired. Heights that are not set are not checked.
+//
+// Call it before proposing with a fresh tip height, to catch a target height
+// from a stale configuration or cache.
+func (r *TransactionRequest) Validate(currentHeight uint32) error {
+	if r == nil {
+		return errors.New("invalid transaction request")
+	}
+	if err := r.checkExpiryHeight(); err != nil {
+		return err
+	}
+	if height, ok := r.TargetHeight(); ok && height <= currentHeight {
+		return fmt.Errorf("target height %d at chain height %d: %w", height, currentHeight, ErrStaleHeight)
+	}
+	if height, ok := r.ExpiryHeight(); ok && height <= currentHeight {
+		return fmt.Errorf("expiry height %d at chain height %d: %w", height, currentHeight, ErrStaleHeight)
+	}
 	return nil
 }
 
-// SetUseMainnet sets whether to use mainnet parameters for consensus branch ID.
+// SetNetwork sets the network the transaction is built for.
 //
-// By default, the library uses mainnet parameters. Set this to false for testnet.
-// Regtest networks (like Zebra's regtest) typically use mainnet-like branch IDs,
-// so keep the default (true) for regtest.
+// Mainnet and regtest use mainnet consensus branch IDs (Zebra's regtest
+// activates the mainnet upgrades); testnet uses testnet branch IDs. The
+// network also decides which payment addresses ValidateNetwork accepts:
+//   - NetworkMainnet: mainnet addresses only
+//   - NetworkTestnet: testnet addresses only
+//   - NetworkRegtest: regtest shielded addresses and transparent addresses
+//     with the testnet encoding (tm..., t2...), which regtest shares
 //
-// Parameters:
-//   - useMainnet: True for mainnet/regtest, false for testnet
-func (r *TransactionRequest) SetUseMainnet(useMainnet bool) error {
+// Without SetNetwork, the request uses mainnet branch IDs and accepts mainnet
+// and regtest addresses (see SetUseMainnet).
+func (r *TransactionRequest) SetNetwork(net Network) error {
 	if r == nil || r.handle == nil {
 		return errors.New("invalid transaction request")
 	}
+	if net != NetworkMainnet && net != NetworkTestnet && net != NetworkRegtest {
+		return fmt.Errorf("invalid network %s", net)
+	}
 
-	code := C.pczt_transaction_request_set_use_mainnet(
-		r.handle,
-		C.bool(useMainnet),
-	)
+	if err := ffiRequestSetUseMainnet(r.handle, net != NetworkTestnet); err != nil {
+		return err
+	}
 
-	if code != C.SUCCESS {
-		return wrapError(ResultCode(code))
+	r.network = net
+	return nil
+}
+
+// SetUseMainnet sets whether to use mainnet parameters for consensus branch ID.
+//
+// Deprecated: Use SetNetwork, which also covers regtest. SetUseMainnet(false)
+// is SetNetwork(NetworkTestnet). SetUseMainnet(true) restores the default:
+// mainnet branch IDs, accepting both mainnet and regtest addresses.
+func (r *TransactionRequest) SetUseMainnet(useMainnet bool) error {
+	if !useMainnet {
+		return r.SetNetwork(NetworkTestnet)
+	}
+	if err := r.SetNetwork(NetworkMainnet); err != nil {
+		return err
 	}
+	r.network = 0
+	return nil
+}
+
+// mainnetParams reports whether the request uses mainnet consensus branch IDs
+func (r *TransactionRequest) mainnetParams() bool {
+	return r.network != NetworkTestnet
+}
+
+// ErrNetworkMismatch is returned when an address belongs to a different
+// network than the request's SetUseMainnet setting
+var ErrNetworkMismatch = errors.New("address network does not match request network")
 
+// ValidateNetwork checks every payment address against the request's network,
+// using the same decoding as ValidateAddress. It is called by the Propose
+// functions, so a mixed-network request fails with an explicit error instead
+// of a generic proposal error.
+//
+// The accepted addresses for each network are listed on SetNetwork. Without
+// SetNetwork, mainnet and regtest addresses are accepted, as are transparent
+// testnet addresses, since regtest shares their encoding.
+//
+// Addresses that fail to decode are left for the native library to reject.
+func (r *TransactionRequest) ValidateNetwork() error {
+	if r == nil {
+		return errors.New("invalid transaction request")
+	}
+	for i, payment := range r.Payments {
+		if err := r.checkAddressNetwork(payment.Address); err != nil {
+			return fmt.Errorf("payment %d: %w", i, err)
+		}
+	}
 	return nil
 }
 
+// checkAddressNetwork checks a single address against the request's network
+func (r *TransactionRequest) checkAddressNetwork(addr string) error {
+	info, err := ValidateAddress(addr)
+	if err != nil {
+		return nil
+	}
+
+	// Regtest transparent addresses decode as testnet
+	regtestTransparent := info.Network == NetworkTestnet && info.Kind.IsTransparent()
+
+	requestNetwork := r.network
+	switch r.network {
+	case 0:
+		requestNetwork = NetworkMainnet
+		if info.Network == NetworkMainnet || info.Network == NetworkRegtest || regtestTransparent {
+			return nil
+		}
+	case NetworkRegtest:
+		if info.Network == NetworkRegtest || regtestTransparent {
+			return nil
+		}
+	default:
+		if info.Network == r.network {
+			return nil
+		}
+	}
+	return fmt.Errorf("address %s is %s but request is %s: %w", addr, info.Network, requestNetwork, ErrNetworkMismatch)
+}
+
 // NewTransactionRequestWithTargetHeight creates a new transaction request
 // with a specific target block height.
 //
@@ -799,16 +1585,229 @@ func NewTransactionRequestWithTargetHeight(payments []Payment, targetHeight uint
 //	// Shielded: 1 input, 1 change, 1 orchard output
 //	fee := CalculateFee(1, 1, 1) // Returns 15000
 //
-//	// Calculate max sendable amount
+//	// Calculate max sendable amount (see also MaxSendableAmount)
 //	totalInput := uint64(100000000) // 1 ZEC
 //	fee := CalculateFee(1, 2, 0)    // 10000 zatoshis
 //	maxSend := totalInput - fee     // 99990000 zatoshis
 //
 // See ZIP-317: https://zips.z.cash/zip-0317
 func CalculateFee(numTransparentInputs, numTransparentOutputs, numOrchardOutputs int) uint64 {
-	return uint64(C.pczt_calculate_fee(
-		C.uintptr_t(numTransparentInputs),
-		C.uintptr_t(numTransparentOutputs),
-		C.uintptr_t(numOrchardOutputs),
-	))
+	return CalculateFeeDetailed(NewFeeParams(numTransparentInputs, numTransparentOutputs, numOrchardOutputs)).Fee
+}
+
+// CalculateFeeForRequest calculates the ZIP-317 fee for spending inputs to
+// the payments in request.
+//
+// Each payment address is parsed to decide whether it produces a transparent
+// or an Orchard output, so callers don't need to count outputs by hand. Set
+// hasChange if the transaction will include a transparent change output.
+func CalculateFeeForRequest(inputs []TransparentInput, request *TransactionRequest, hasChange bool) uint64 {
+	numTransparent, numOrchard := 0, 0
+	if hasChange {
+		numTransparent++
+	}
+	if request != nil {
+		for _, payment := range request.Payments {
+			if isTransparentAddress(payment.Address) {
+				numTransparent++
+			} else {
+				numOrchard++
+			}
+		}
+	}
+
+	return CalculateFee(len(inputs), numTransparent, numOrchard)
+}
+
+// ZIP-317 fee constants
+const (
+	// ZIP317MarginalFee is the fee per logical action in zatoshis
+	ZIP317MarginalFee uint64 = 5000
+
+	// ZIP317GraceActions is the number of logical actions every transaction
+	// pays for, even if it has fewer
+	ZIP317GraceActions = 2
+)
+
+// FeeParams describes a transaction shape and the ZIP-317 parameters used to
+// price it. Use NewFeeParams for the standard ZIP-317 parameters.
+type FeeParams struct {
+	NumTransparentInputs  int
+	NumTransparentOutputs int // including change, if any
+	NumOrchardOutputs     int
+
+	// MarginalFee is the fee per logical action in zatoshis
+	MarginalFee uint64
+
+	// GraceActions is the minimum number of logical actions charged
+	GraceActions int
+}
+
+// NewFeeParams returns FeeParams for the given shape with the standard
+// ZIP-317 marginal fee and grace actions
+func NewFeeParams(numTransparentInputs, numTransparentOutputs, numOrchardOutputs int) FeeParams {
+	return FeeParams{
+		NumTransparentInputs:  numTransparentInputs,
+		NumTransparentOutputs: numTransparentOutputs,
+		NumOrchardOutputs:     numOrchardOutputs,
+		MarginalFee:           ZIP317MarginalFee,
+		GraceActions:          ZIP317GraceActions,
+	}
+}
+
+// FeeResult is the breakdown of a ZIP-317 fee computed by CalculateFeeDetailed
+type FeeResult struct {
+	// Fee is the total fee in zatoshis: MarginalFee * (LogicalActions + GraceActionsApplied)
+	Fee uint64
+
+	// LogicalActions is TransparentActions + OrchardActions
+	LogicalActions int
+
+	// TransparentActions is max(transparent inputs, transparent outputs)
+	TransparentActions int
+
+	// OrchardActions is the number of Orchard actions in the bundle. Orchard
+	// outputs are padded to an even number of actions, so a lone output
+	// costs two.
+	OrchardActions int
+
+	// GraceActionsApplied is the number of extra actions charged to reach the
+	// grace minimum. While it is non-zero, adding an input or output that
+	// does not exceed the grace allowance leaves the fee unchanged.
+	GraceActionsApplied int
+
+	// MarginalFee is the fee per logical action in zatoshis
+	MarginalFee uint64
+}
+
+// CalculateFeeDetailed computes the ZIP-317 fee for a transaction shape and
+// returns the logical action counts behind it.
+//
+// See ZIP-317: https://zips.z.cash/zip-0317
+func CalculateFeeDetailed(params FeeParams) FeeResult {
+	transparentActions := max(params.NumTransparentInputs, params.NumTransparentOutputs)
+
+	orchardActions := 0
+	if params.NumOrchardOutputs > 0 {
+		orchardActions = (params.NumOrchardOutputs + 1) / 2 * 2
+	}
+
+	logicalActions := transparentActions + orchardActions
+	graceApplied := max(params.GraceActions-logicalActions, 0)
+
+	return FeeResult{
+		Fee:                 params.MarginalFee * uint64(logicalActions+graceApplied),
+		LogicalActions:      logicalActions,
+		TransparentActions:  transparentActions,
+		OrchardActions:      orchardActions,
+		GraceActionsApplied: graceApplied,
+		MarginalFee:         params.MarginalFee,
+	}
+}
+
+// ChangeAmount computes the change left over after paying all payments and the fee.
+//
+// A result of 0 means the inputs exactly cover payments plus fee; in that case
+// ProposeTransaction omits the change output entirely rather than creating a
+// zero-value output. Note that the fee depends on whether a change output is
+// present, so compute it with CalculateFee for the shape without change when
+// checking for an exact match.
+//
+// Returns an error if the inputs do not cover the payments plus fee.
+func ChangeAmount(inputs []TransparentInput, payments []Payment, fee uint64) (uint64, error) {
+	totalInput, err := sumInputs(inputs)
+	if err != nil {
+		return 0, err
+	}
+
+	required := Amount(fee)
+	for i, payment := range payments {
+		if required, err = required.AddChecked(Amount(payment.Amount)); err != nil {
+			return 0, fmt.Errorf("payment %d: %w", i, err)
+		}
+	}
+
+	change, err := totalInput.SubChecked(required)
+	if err != nil {
+		return 0, fmt.Errorf("insufficient funds: inputs total %d, payments plus fee require %d", totalInput, required)
+	}
+
+	return change.Zatoshis(), nil
+}
+
+// MaxSendableAmount returns the largest total amount that can be paid by
+// spending all inputs, for "send max" flows.
+//
+// The fee is computed for numOutputs transparent and numOrchardOutputs Orchard
+// outputs with no change output, since sending everything leaves no change.
+// When paying several recipients the result is their combined amount.
+//
+// Returns an error if the fee is not less than the input total.
+func MaxSendableAmount(inputs []TransparentInput, numOutputs, numOrchardOutputs int) (uint64, error) {
+	total, err := sumInputs(inputs)
+	if err != nil {
+		return 0, err
+	}
+
+	fee := CalculateFee(len(inputs), numOutputs, numOrchardOutputs)
+	if total.Zatoshis() <= fee {
+		return 0, fmt.Errorf("insufficient funds: inputs total %d, fee is %d", total, fee)
+	}
+
+	return total.Zatoshis() - fee, nil
+}
+
+// MinInputsForTarget returns the minimum number of inputs needed to cover
+// target plus the ZIP-317 fee, taking inputs largest first.
+//
+// The fee grows with each input added, so it is recomputed for every input
+// count. numOuts is the number of transparent outputs (including change) and
+// numOrchard the number of Orchard outputs. Callers should spend the returned
+// number of largest inputs from available.
+//
+// Returns an error if all available inputs together cannot cover the target.
+func MinInputsForTarget(available []TransparentInput, target uint64, numOuts, numOrchard int) (int, error) {
+	selected, _, err := SelectUTXOs(available, target, func(n int) uint64 {
+		return CalculateFee(n, numOuts, numOrchard)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(selected), nil
+}
+
+// SelectUTXOs picks inputs from available, largest first, until they cover
+// target plus the fee for the number of inputs selected so far.
+//
+// fee returns the total fee for a transaction spending n inputs, e.g.
+//
+//	func(n int) uint64 { return CalculateFee(n, 2, 0) }
+//
+// for one transparent payment plus change. Because each added input can raise
+// the fee, it is re-evaluated after every input.
+//
+// Returns the selected inputs and the resulting fee, or an error if all
+// available inputs together cannot cover the target.
+func SelectUTXOs(available []TransparentInput, target uint64, fee func(n int) uint64) ([]TransparentInput, uint64, error) {
+	sorted := make([]TransparentInput, len(available))
+	copy(sorted, available)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Amount > sorted[j].Amount })
+
+	var total Amount
+	for i, input := range sorted {
+		var err error
+		if total, err = total.AddChecked(Amount(input.Amount)); err != nil {
+			return nil, 0, err
+		}
+		f := fee(i + 1)
+		required, err := Amount(target).AddChecked(Amount(f))
+		if err != nil {
+			return nil, 0, fmt.Errorf("target plus fee: %w", err)
+		}
+		if total >= required {
+			return sorted[:i+1], f, nil
+		}
+	}
+
+	return nil, 0, fmt.Errorf("insufficient funds: %d inputs total %d, target %d plus fee %d", len(available), total, target, fee(len(available)))
 }
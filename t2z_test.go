@@ -2,11 +2,16 @@ package t2z
 
 import (
 	"encoding/hex"
+	"errors"
+	"math/rand/v2"
+	"runtime"
+	"strings"
 	"testing"
 )
 
 // Test creating a transaction request
 func TestNewTransactionRequest(t *testing.T) {
+	requireBackend(t)
 	payments := []Payment{
 		{
 			Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma",
@@ -31,6 +36,7 @@ func TestNewTransactionRequest(t *testing.T) {
 
 // Test creating transaction request with multiple payments
 func TestNewTransactionRequestMultiple(t *testing.T) {
+	requireBackend(t)
 	payments := []Payment{
 		{
 			Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma",
@@ -53,11 +59,48 @@ func TestNewTransactionRequestMultiple(t *testing.T) {
 	}
 }
 
+// Test that paying the same address twice is rejected unless allowed
+func TestNewTransactionRequestDuplicateRecipients(t *testing.T) {
+	requireBackend(t)
+	payments := []Payment{
+		{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 100_000},
+		{Address: "tmBsTi2xWTjUdEXnuTceL7fecEQKeWi4vxA", Amount: 200_000},
+		{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 300_000},
+	}
+
+	_, err := NewTransactionRequest(payments)
+	if !errors.Is(err, ErrDuplicateRecipient) {
+		t.Fatalf("Expected ErrDuplicateRecipient, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "payments 0 and 2") {
+		t.Errorf("Expected error to name the duplicate payments, got %v", err)
+	}
+
+	req, err := NewTransactionRequestAllowDuplicates(payments)
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer req.Free()
+	if !req.HasDuplicateRecipients() {
+		t.Error("Expected HasDuplicateRecipients to be true")
+	}
+
+	unique, err := NewTransactionRequest(payments[:2])
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer unique.Free()
+	if unique.HasDuplicateRecipients() {
+		t.Error("Expected HasDuplicateRecipients to be false")
+	}
+}
+
 // Test creating transaction request with memo
 func TestNewTransactionRequestWithMemo(t *testing.T) {
+	requireBackend(t)
 	payments := []Payment{
 		{
-			Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma",
+			Address: testShieldedAddress,
 			Amount:  100_000,
 			Memo:    "Test payment",
 		},
@@ -86,6 +129,7 @@ func TestNewTransactionRequestEmpty(t *testing.T) {
 
 // Test SetTargetHeight method
 func TestSetTargetHeight(t *testing.T) {
+	requireBackend(t)
 	payments := []Payment{
 		{
 			Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma",
@@ -99,15 +143,45 @@ func TestSetTargetHeight(t *testing.T) {
 	}
 	defer req.Free()
 
+	if _, ok := req.TargetHeight(); ok {
+		t.Error("Expected no target height before SetTargetHeight")
+	}
+	if _, ok := req.ExpiryHeight(); ok {
+		t.Error("Expected no expiry height before SetTargetHeight")
+	}
+
 	// Set target height (post-NU5 mainnet height, like TypeScript examples)
-	err = req.SetTargetHeight(2_500_000)
+	err = req.SetTargetHeight(2_600_000)
 	if err != nil {
 		t.Fatalf("Failed to set target height: %v", err)
 	}
+	if height, ok := req.TargetHeight(); !ok || height != 2_600_000 {
+		t.Errorf("Expected target height 2600000, got %d, %v", height, ok)
+	}
+	expiry, ok := req.ExpiryHeight()
+	if !ok || expiry != 2_600_000+ExpiryDelta {
+		t.Errorf("Expected expiry height %d, got %d, %v", 2_600_000+ExpiryDelta, expiry, ok)
+	}
+
+	// The proposal expires at the reported height
+	_, pubkey := createTestKeypair()
+	pczt, err := ProposeTransaction([]TransparentInput{{Pubkey: pubkey, Amount: 1_000_000, ScriptPubKey: createP2PKHScript(pubkey)}}, req)
+	if err != nil {
+		t.Fatalf("ProposeTransaction failed: %v", err)
+	}
+	defer pczt.Free()
+	contents, err := inspectPCZT(pczt)
+	if err != nil {
+		t.Fatalf("Failed to inspect PCZT: %v", err)
+	}
+	if contents.ExpiryHeight != expiry {
+		t.Errorf("Expected the PCZT to expire at %d, got %d", expiry, contents.ExpiryHeight)
+	}
 }
 
 // Test SetUseMainnet method (matches TypeScript example behavior)
 func TestSetUseMainnet(t *testing.T) {
+	requireBackend(t)
 	payments := []Payment{
 		{
 			Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma",
@@ -136,6 +210,7 @@ func TestSetUseMainnet(t *testing.T) {
 
 // Test NewTransactionRequestWithTargetHeight
 func TestNewTransactionRequestWithTargetHeight(t *testing.T) {
+	requireBackend(t)
 	payments := []Payment{
 		{
 			Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma",
@@ -154,6 +229,131 @@ func TestNewTransactionRequestWithTargetHeight(t *testing.T) {
 	}
 }
 
+// Test the functional options of NewTransactionRequest
+func TestNewTransactionRequestOptions(t *testing.T) {
+	requireBackend(t)
+	payments := []Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 100_000}}
+
+	req, err := NewTransactionRequest(payments, WithNetwork(NetworkTestnet), WithTargetHeight(3_000_000), WithExpiryHeight(3_000_100))
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer req.Free()
+
+	if req.network != NetworkTestnet {
+		t.Errorf("Expected testnet, got %s", req.network)
+	}
+	if height, ok := req.TargetHeight(); !ok || height != 3_000_000 {
+		t.Errorf("Expected target height 3000000, got %d, %v", height, ok)
+	}
+	if height, ok := req.ExpiryHeight(); !ok || height != 3_000_100 {
+		t.Errorf("Expected expiry height 3000100, got %d, %v", height, ok)
+	}
+
+	invalid := []RequestOption{WithNetwork(Network(0)), WithTargetHeight(0), WithExpiryHeight(0), WithExpiryHeight(MaxExpiryHeight + 1)}
+	for i, opt := range invalid {
+		if _, err := NewTransactionRequest(payments, opt); err == nil {
+			t.Errorf("Option %d: expected an error", i)
+		}
+	}
+}
+
+// Test that Validate flags heights at or below the chain tip
+func TestTransactionRequestValidate(t *testing.T) {
+	requireBackend(t)
+	payments := []Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 100_000}}
+	req, err := NewTransactionRequest(payments)
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer req.Free()
+
+	if err := req.Validate(3_000_000); err != nil {
+		t.Errorf("Expected no error without heights, got %v", err)
+	}
+
+	req.SetTargetHeight(2_500_001)
+	if err := req.Validate(2_500_000); err != nil {
+		t.Errorf("Expected no error for the next block, got %v", err)
+	}
+	for _, tip := range []uint32{2_500_001, 2_600_000} {
+		if err := req.Validate(tip); !errors.Is(err, ErrStaleHeight) {
+			t.Errorf("Expected ErrStaleHeight at chain height %d, got %v", tip, err)
+		}
+	}
+
+	// A past expiry height
+	expiring, err := NewTransactionRequest(payments, WithExpiryHeight(2_500_010))
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer expiring.Free()
+	if err := expiring.Validate(2_500_009); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if err := expiring.Validate(2_500_010); !errors.Is(err, ErrStaleHeight) || !strings.Contains(err.Error(), "expiry") {
+		t.Errorf("Expected ErrStaleHeight for the expiry height, got %v", err)
+	}
+}
+
+// Test that an expiry height override ends up in the signed transaction
+func TestSetExpiryHeight(t *testing.T) {
+	requireBackend(t)
+	privateKey, pubkey := createTestKeypair()
+	inputs := []TransparentInput{{Pubkey: pubkey, Amount: 1_000_000, ScriptPubKey: createP2PKHScript(pubkey)}}
+	payments := []Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 100_000}}
+
+	req, err := NewTransactionRequest(payments, WithTargetHeight(2_500_000), WithExpiryHeight(2_500_005))
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer req.Free()
+
+	pczt, err := ProposeTransaction(inputs, req)
+	if err != nil {
+		t.Fatalf("ProposeTransaction failed: %v", err)
+	}
+	proved, err := ProveTransaction(pczt)
+	if err != nil {
+		t.Fatalf("ProveTransaction failed: %v", err)
+	}
+	sighash, _ := GetSighash(proved, 0)
+	signature, _ := signMessage(privateKey, sighash)
+	signed, err := AppendSignature(proved, 0, signature)
+	if err != nil {
+		t.Fatalf("AppendSignature failed: %v", err)
+	}
+	txBytes, err := FinalizeAndExtract(signed)
+	if err != nil {
+		t.Fatalf("FinalizeAndExtract failed: %v", err)
+	}
+	tx, err := DecodeTransaction(txBytes)
+	if err != nil {
+		t.Fatalf("Failed to decode transaction: %v", err)
+	}
+	if tx.ExpiryHeight != 2_500_005 {
+		t.Errorf("Expected expiry height 2500005, got %d", tx.ExpiryHeight)
+	}
+
+	// Below the target height
+	if err := req.SetExpiryHeight(2_499_999); err != nil {
+		t.Fatalf("SetExpiryHeight failed: %v", err)
+	}
+	if _, err := ProposeTransaction(inputs, req); err == nil {
+		t.Error("Expected an error for an expiry height below the target height")
+	}
+
+	// Orchard actions are signed during proposal
+	shielded, err := NewTransactionRequest([]Payment{{Address: warmUpRecipient, Amount: 100_000}}, WithExpiryHeight(2_500_100))
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer shielded.Free()
+	if _, err := ProposeTransaction(inputs, shielded); !errors.Is(err, ErrNotImplemented) {
+		t.Errorf("Expected ErrNotImplemented for Orchard outputs, got %v", err)
+	}
+}
+
 // Test transparent input serialization
 func TestSerializeTransparentInputs(t *testing.T) {
 	// Create a test pubkey (33 bytes, compressed secp256k1)
@@ -223,3 +423,480 @@ func TestSerializeTransparentInputs(t *testing.T) {
 // 	// Skip for now - will add once we have the full integration test
 // 	t.Skip("Requires full PCZT creation workflow")
 // }
+
+// Test ChangeAmount, including the exact-change case
+func TestChangeAmount(t *testing.T) {
+	inputs := []TransparentInput{{Amount: 60_000}, {Amount: 50_000}}
+	payments := []Payment{{Amount: 40_000}, {Amount: 50_000}}
+
+	change, err := ChangeAmount(inputs, payments, 10_000)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if change != 10_000 {
+		t.Errorf("Expected change 10000, got %d", change)
+	}
+
+	change, err = ChangeAmount(inputs, payments, 20_000)
+	if err != nil {
+		t.Fatalf("Unexpected error for exact change: %v", err)
+	}
+	if change != 0 {
+		t.Errorf("Expected change 0, got %d", change)
+	}
+
+	_, err = ChangeAmount(inputs, payments, 20_001)
+	if err == nil {
+		t.Error("Expected error for insufficient funds, got nil")
+	}
+}
+
+// Test that ResultCode errors can be matched with errors.Is
+func TestResultCodeErrorsIs(t *testing.T) {
+	requireBackend(t)
+	_, err := ParsePCZT([]byte{0x00, 0x01, 0x02})
+	if err == nil {
+		t.Fatal("Expected error parsing garbage bytes, got nil")
+	}
+	if !errors.Is(err, ErrParse) {
+		t.Errorf("Expected errors.Is(err, ErrParse), got %v", err)
+	}
+	if errors.Is(err, ErrVerification) {
+		t.Error("Parse error should not match ErrVerification")
+	}
+
+	if ErrorVerification.Err() != ErrVerification {
+		t.Error("ErrorVerification.Err() should return ErrVerification")
+	}
+	if Success.Err() != nil {
+		t.Error("Success.Err() should return nil")
+	}
+}
+
+// Test that FFI failures return a typed *Error
+func TestTypedError(t *testing.T) {
+	requireBackend(t)
+	_, err := ParsePCZT([]byte{0x00, 0x01, 0x02})
+
+	var t2zErr *Error
+	if !errors.As(err, &t2zErr) {
+		t.Fatalf("Expected *Error, got %T", err)
+	}
+	if t2zErr.Code != ErrorParse {
+		t.Errorf("Expected code ErrorParse, got %s", t2zErr.Code)
+	}
+	if t2zErr.InputIndex != -1 {
+		t.Errorf("Expected InputIndex -1, got %d", t2zErr.InputIndex)
+	}
+
+	inputErr := &Error{Code: ErrorSignature, Message: "bad signature", InputIndex: 2}
+	if inputErr.Error() != "t2z error: ErrorSignature: bad signature (input 2)" {
+		t.Errorf("Unexpected error string: %s", inputErr.Error())
+	}
+	if !errors.Is(inputErr, ErrSignature) {
+		t.Error("Expected errors.Is(err, ErrSignature)")
+	}
+}
+
+// Test that error messages longer than the buffer are read in full
+func TestLastErrorGrowsBuffer(t *testing.T) {
+	requireBackend(t)
+	// The message is kept per OS thread
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	_, err := ParsePCZT([]byte{0x00, 0x01, 0x02})
+	var t2zErr *Error
+	if !errors.As(err, &t2zErr) || len(t2zErr.Message) <= 4 {
+		t.Fatalf("Expected *Error with a message, got %v", err)
+	}
+	if got := lastError(4); got != t2zErr.Message {
+		t.Errorf("lastError(4) = %q, want %q", got, t2zErr.Message)
+	}
+}
+
+// Test MemoBytes precedence and rejection of binary memos
+func TestNewTransactionRequestMemoBytes(t *testing.T) {
+	requireBackend(t)
+	payments := []Payment{
+		{
+			Address:   testShieldedAddress,
+			Amount:    100_000,
+			Memo:      "ignored",
+			MemoBytes: []byte("Test payment"),
+		},
+	}
+
+	req, err := NewTransactionRequest(payments)
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	req.Free()
+
+	payments[0].MemoBytes = []byte{0xf6, 0x00, 0x01}
+	_, err = NewTransactionRequest(payments)
+	if err == nil {
+		t.Fatal("Expected error for binary memo, got nil")
+	}
+}
+
+// Test memo length and transparent-address memo validation
+func TestNewTransactionRequestMemoValidation(t *testing.T) {
+	requireBackend(t)
+	longMemo := strings.Repeat("a", MaxMemoLength)
+
+	req, err := NewTransactionRequest([]Payment{{Address: testShieldedAddress, Amount: 100_000, Memo: longMemo}})
+	if err != nil {
+		t.Fatalf("Failed to create request with %d byte memo: %v", MaxMemoLength, err)
+	}
+	req.Free()
+
+	// Byte length counts, not runes: 171 three-byte runes = 513 bytes
+	_, err = NewTransactionRequest([]Payment{
+		{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 100_000},
+		{Address: testShieldedAddress, Amount: 100_000, Memo: strings.Repeat("€", 171)},
+	})
+	if err == nil || !strings.Contains(err.Error(), "payment 1") {
+		t.Errorf("Expected memo too long error for payment 1, got %v", err)
+	}
+
+	_, err = NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 100_000, Memo: "hi"}})
+	if err == nil {
+		t.Error("Expected error for memo on transparent address, got nil")
+	}
+}
+
+// Test MinInputsForTarget picks the fewest largest-first inputs
+func TestMinInputsForTarget(t *testing.T) {
+	available := []TransparentInput{{Amount: 10_000}, {Amount: 50_000}, {Amount: 30_000}, {Amount: 20_000}}
+
+	// 50k alone covers 30k plus the 1-input fee
+	n, err := MinInputsForTarget(available, 30_000, 2, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Expected 1 input, got %d", n)
+	}
+
+	// 50k + 30k = 80k covers 65k plus the 2-input fee (10000)
+	n, err = MinInputsForTarget(available, 65_000, 2, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 inputs, got %d", n)
+	}
+
+	// The fee for the third input must also be covered
+	fee3 := CalculateFee(3, 2, 0)
+	n, err = MinInputsForTarget(available, 100_000-fee3, 2, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("Expected 3 inputs, got %d", n)
+	}
+
+	if _, err := MinInputsForTarget(available, 110_000, 2, 0); err == nil {
+		t.Error("Expected error for insufficient funds, got nil")
+	}
+}
+
+// Test CalculateFeeDetailed breakdown and that CalculateFee matches it
+func TestCalculateFeeDetailed(t *testing.T) {
+	tests := []struct {
+		inputs, outputs, orchard int
+		logical, grace           int
+		fee                      uint64
+	}{
+		{1, 1, 0, 1, 1, 10_000},
+		{1, 2, 0, 2, 0, 10_000},
+		{1, 1, 1, 3, 0, 15_000}, // lone Orchard output padded to 2 actions
+		{0, 0, 3, 4, 0, 20_000},
+		{3, 2, 0, 3, 0, 15_000},
+		{2, 4, 2, 6, 0, 30_000},
+	}
+
+	for _, tt := range tests {
+		result := CalculateFeeDetailed(NewFeeParams(tt.inputs, tt.outputs, tt.orchard))
+		if result.LogicalActions != tt.logical || result.GraceActionsApplied != tt.grace || result.Fee != tt.fee {
+			t.Errorf("(%d, %d, %d): got %+v, want logical=%d grace=%d fee=%d",
+				tt.inputs, tt.outputs, tt.orchard, result, tt.logical, tt.grace, tt.fee)
+		}
+		if fee := CalculateFee(tt.inputs, tt.outputs, tt.orchard); fee != tt.fee {
+			t.Errorf("CalculateFee(%d, %d, %d) = %d, want %d", tt.inputs, tt.outputs, tt.orchard, fee, tt.fee)
+		}
+	}
+
+	// A custom grace allowance changes the marginal cost of a second action
+	params := NewFeeParams(1, 1, 0)
+	params.GraceActions = 0
+	result := CalculateFeeDetailed(params)
+	if result.Fee != ZIP317MarginalFee || result.GraceActionsApplied != 0 {
+		t.Errorf("Expected fee %d with no grace actions, got %+v", ZIP317MarginalFee, result)
+	}
+}
+
+// Test CalculateFeeForRequest derives the output shape from the addresses
+func TestCalculateFeeForRequest(t *testing.T) {
+	requireBackend(t)
+	req, err := NewTransactionRequest([]Payment{
+		{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 100_000},
+		{Address: testShieldedAddress, Amount: 100_000},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer req.Free()
+
+	inputs := make([]TransparentInput, 1)
+
+	if fee, want := CalculateFeeForRequest(inputs, req, true), CalculateFee(1, 2, 1); fee != want {
+		t.Errorf("With change: got %d, want %d", fee, want)
+	}
+	if fee, want := CalculateFeeForRequest(inputs, req, false), CalculateFee(1, 1, 1); fee != want {
+		t.Errorf("Without change: got %d, want %d", fee, want)
+	}
+}
+
+// Test SelectUTXOs returns the selected inputs and the fee for that count
+func TestSelectUTXOs(t *testing.T) {
+	available := []TransparentInput{{Amount: 10_000, Vout: 0}, {Amount: 50_000, Vout: 1}, {Amount: 30_000, Vout: 2}}
+	fee := func(n int) uint64 { return CalculateFee(n, 2, 0) }
+
+	selected, selectedFee, err := SelectUTXOs(available, 60_000, fee)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(selected) != 2 || selected[0].Vout != 1 || selected[1].Vout != 2 {
+		t.Errorf("Expected the 50k and 30k inputs, got %+v", selected)
+	}
+	if selectedFee != CalculateFee(2, 2, 0) {
+		t.Errorf("Expected fee %d, got %d", CalculateFee(2, 2, 0), selectedFee)
+	}
+	if available[0].Vout != 0 {
+		t.Error("SelectUTXOs must not reorder the caller's slice")
+	}
+
+	// 80k covers 70k + 10k for two inputs, but not 71k
+	if _, _, err := SelectUTXOs(available[1:], 71_000, fee); err == nil {
+		t.Error("Expected error for insufficient funds, got nil")
+	}
+}
+
+// Test that transparent outputs worth less than the marginal fee are dust
+func TestIsDust(t *testing.T) {
+	if DustThreshold() != ZIP317MarginalFee {
+		t.Errorf("DustThreshold() = %d, want %d", DustThreshold(), ZIP317MarginalFee)
+	}
+
+	tests := []struct {
+		value      uint64
+		isShielded bool
+		want       bool
+	}{
+		{0, false, true},
+		{ZIP317MarginalFee - 1, false, true},
+		{ZIP317MarginalFee, false, false},
+		{0, true, true},
+		{1, true, false},
+	}
+	for _, tt := range tests {
+		if got := IsDust(tt.value, tt.isShielded); got != tt.want {
+			t.Errorf("IsDust(%d, %v) = %v, want %v", tt.value, tt.isShielded, got, tt.want)
+		}
+	}
+}
+
+// Test MaxSendableAmount subtracts the no-change fee from the input total
+func TestMaxSendableAmount(t *testing.T) {
+	inputs := []TransparentInput{{Amount: 60_000}, {Amount: 50_000}}
+
+	amount, err := MaxSendableAmount(inputs, 1, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := 110_000 - CalculateFee(2, 1, 0); amount != want {
+		t.Errorf("Expected %d, got %d", want, amount)
+	}
+
+	amount, err = MaxSendableAmount(inputs, 0, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := 110_000 - CalculateFee(2, 0, 1); amount != want {
+		t.Errorf("Expected %d for Orchard output, got %d", want, amount)
+	}
+
+	if _, err := MaxSendableAmount([]TransparentInput{{Amount: 10_000}}, 1, 0); err == nil {
+		t.Error("Expected error when fee consumes the whole input, got nil")
+	}
+}
+
+// Test that payment addresses are checked against the request network
+func TestValidateNetwork(t *testing.T) {
+	testnetUA := encodeTestUnifiedAddress("utest", []Receiver{{ReceiverOrchard, make([]byte, 43)}})
+	regtestUA := encodeTestUnifiedAddress("uregtest", []Receiver{{ReceiverOrchard, make([]byte, 43)}})
+
+	// Network 0 is the default before SetNetwork
+	tests := []struct {
+		addr    string
+		network Network
+		ok      bool
+	}{
+		{"t1HsdDMzmJfq4vc7T17XYjEkLMLvbgM1fCi", 0, true},
+		{"t1HsdDMzmJfq4vc7T17XYjEkLMLvbgM1fCi", NetworkTestnet, false},
+		{testShieldedAddress, 0, true},
+		{testShieldedAddress, NetworkTestnet, false},
+		// Regtest shares the testnet transparent encoding
+		{"tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", 0, true},
+		{"tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", NetworkTestnet, true},
+		{testnetUA, 0, false},
+		{testnetUA, NetworkTestnet, true},
+		{regtestUA, 0, true},
+		{regtestUA, NetworkTestnet, false},
+		{"ztestsapling1qqqsyqcyq5rqwzqfpg9scrgwpugpzysnzs23v9ccrydpk8qarc0jqgfzyvjz2f389q5j5sum0xq", 0, false},
+		// An explicit network is strict
+		{"t1HsdDMzmJfq4vc7T17XYjEkLMLvbgM1fCi", NetworkMainnet, true},
+		{testShieldedAddress, NetworkMainnet, true},
+		{"tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", NetworkMainnet, false},
+		{regtestUA, NetworkMainnet, false},
+		{"t1HsdDMzmJfq4vc7T17XYjEkLMLvbgM1fCi", NetworkRegtest, false},
+		{testShieldedAddress, NetworkRegtest, false},
+		{"tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", NetworkRegtest, true},
+		{regtestUA, NetworkRegtest, true},
+		{testnetUA, NetworkRegtest, false},
+	}
+
+	for _, tt := range tests {
+		req := &TransactionRequest{Payments: []Payment{{Address: tt.addr, Amount: 1000}}, network: tt.network}
+		err := req.ValidateNetwork()
+		if tt.ok && err != nil {
+			t.Errorf("%s (network=%v): unexpected error %v", tt.addr, tt.network, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrNetworkMismatch) {
+			t.Errorf("%s (network=%v): expected ErrNetworkMismatch, got %v", tt.addr, tt.network, err)
+		}
+	}
+}
+
+// Test SetNetwork and the SetUseMainnet shim
+func TestSetNetwork(t *testing.T) {
+	requireBackend(t)
+	request, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 1000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+
+	if err := request.SetNetwork(Network(0)); err == nil {
+		t.Error("expected an error for an invalid network")
+	}
+
+	for _, net := range []Network{NetworkMainnet, NetworkTestnet, NetworkRegtest} {
+		if err := request.SetNetwork(net); err != nil {
+			t.Fatalf("SetNetwork(%v) failed: %v", net, err)
+		}
+		if request.network != net {
+			t.Errorf("SetNetwork(%v): network = %v", net, request.network)
+		}
+		if got, want := request.mainnetParams(), net != NetworkTestnet; got != want {
+			t.Errorf("SetNetwork(%v): mainnet params = %v, want %v", net, got, want)
+		}
+	}
+
+	if err := request.SetUseMainnet(false); err != nil || request.network != NetworkTestnet {
+		t.Errorf("SetUseMainnet(false): network = %v, err = %v", request.network, err)
+	}
+	if err := request.SetUseMainnet(true); err != nil || request.network != 0 || !request.mainnetParams() {
+		t.Errorf("SetUseMainnet(true): network = %v, err = %v", request.network, err)
+	}
+}
+
+// requireBackend skips a test that needs the native library (or the mock)
+// on platforms where such calls return ErrUnsupportedPlatform
+func requireBackend(tb testing.TB) {
+	tb.Helper()
+	if !hasBackend {
+		tb.Skip(ErrUnsupportedPlatform)
+	}
+}
+
+// seedPCZT returns a serialized proposed PCZT for parser tests
+func seedPCZT(t testing.TB) []byte {
+	t.Helper()
+	_, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	inputs := []TransparentInput{{Pubkey: pubkey, TxID: txid, Amount: 100_000_000, ScriptPubKey: createP2PKHScript(pubkey)}}
+	request, err := NewTransactionRequestWithTargetHeight([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 50_000_000}}, 2_500_000)
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+	defer pczt.Free()
+	data, err := SerializePCZT(pczt)
+	if err != nil {
+		t.Fatalf("Failed to serialize PCZT: %v", err)
+	}
+	return data
+}
+
+// Test that truncated and random bytes are rejected with an error, not a panic
+func TestParsePCZTMalformed(t *testing.T) {
+	requireBackend(t)
+	data := seedPCZT(t)
+
+	if _, err := ParsePCZT(nil); err == nil {
+		t.Error("Expected error for nil bytes, got nil")
+	}
+	for n := 0; n < len(data); n++ {
+		if pczt, err := ParsePCZT(data[:n]); err == nil {
+			pczt.Free()
+			t.Errorf("Expected error for PCZT truncated to %d bytes, got nil", n)
+		}
+		decodePCZT(data[:n])
+	}
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 1000; i++ {
+		garbage := make([]byte, rng.IntN(512))
+		for j := range garbage {
+			garbage[j] = byte(rng.Uint32())
+		}
+		if i%2 == 0 && len(garbage) >= 8 {
+			copy(garbage, data[:8]) // valid magic and version
+		}
+		if pczt, err := ParsePCZT(garbage); err == nil {
+			pczt.Free()
+		}
+		decodePCZT(garbage)
+	}
+}
+
+func FuzzParsePCZT(f *testing.F) {
+	requireBackend(f)
+	data := seedPCZT(f)
+	f.Add(data)
+	f.Add(data[:len(data)/2])
+	f.Add([]byte("PCZT"))
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		pczt, err := ParsePCZT(b)
+		if err != nil {
+			return
+		}
+		defer pczt.Free()
+
+		// A parsed PCZT serializes and decodes without panicking
+		if out, err := SerializePCZT(pczt); err == nil {
+			decodePCZT(out)
+		}
+	})
+}
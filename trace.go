@@ -0,0 +1,101 @@
+package t2z
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Span attributes set by the spans of a Tracer
+const (
+	// AttrResultCode is the ResultCode of the call, see LogEvent.Code
+	AttrResultCode = "t2z.result_code"
+	// AttrInputIndex is the input signed by a StageSign span
+	AttrInputIndex = "t2z.input_index"
+)
+
+// Tracer starts spans around the calls into the native library, for
+// distributed tracing. It is a minimal interface so that t2z does not
+// depend on a tracing library; an OpenTelemetry adapter wraps a
+// trace.Tracer and converts the attributes with attribute.Int64.
+type Tracer interface {
+	// StartSpan starts a span named "t2z." followed by the stage, such as
+	// "t2z.prove", as a child of the span in ctx.
+	StartSpan(ctx context.Context, name string) Span
+}
+
+// Span is a span started by a Tracer
+type Span interface {
+	// SetAttribute sets an attribute of the span. The values set by t2z
+	// are int64.
+	SetAttribute(key string, value any)
+	// End ends the span. It is called once, after the attributes are set.
+	End()
+}
+
+var tracer atomic.Pointer[Tracer]
+
+// SetTracer sets the Tracer that starts a span around each propose, prove,
+// sign and finalize call into the native library. The spans are children of
+// the context passed to ProposeTransactionContext, ProveTransactionContext,
+// AppendSignatureContext and FinalizeAndExtractContext; the functions
+// without a context use context.Background. A nil t removes the tracer;
+// there is none by default.
+//
+// Like the logger of SetLogger, t must be safe for concurrent use.
+func SetTracer(t Tracer) {
+	if t == nil {
+		tracer.Store(nil)
+		return
+	}
+	tracer.Store(&t)
+}
+
+// ProposeTransactionContext is ProposeTransaction with a context for the
+// tracer set with SetTracer. The native call cannot be interrupted, so ctx
+// is only checked before it starts.
+func ProposeTransactionContext(ctx context.Context, inputs []TransparentInput, request *TransactionRequest) (*PCZT, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return proposeTransaction(ctx, inputs, request, "")
+}
+
+// ProveTransactionContext is ProveTransaction with a context for the tracer
+// set with SetTracer. The native call cannot be interrupted, so ctx is only
+// checked before it starts. Like ProveTransaction it ALWAYS consumes the
+// input PCZT, even when ctx is already done.
+func ProveTransactionContext(ctx context.Context, pczt *PCZT) (*PCZT, error) {
+	if err := ctx.Err(); err != nil {
+		if pczt != nil {
+			pczt.Free()
+		}
+		return nil, err
+	}
+	return proveTransaction(ctx, pczt)
+}
+
+// AppendSignatureContext is AppendSignature with a context for the tracer
+// set with SetTracer. Like AppendSignature it ALWAYS consumes the input
+// PCZT, even when ctx is already done.
+func AppendSignatureContext(ctx context.Context, pczt *PCZT, inputIndex uint, signature [64]byte) (*PCZT, error) {
+	if err := ctx.Err(); err != nil {
+		if pczt != nil {
+			pczt.Free()
+		}
+		return nil, err
+	}
+	return appendSignatureContext(ctx, pczt, inputIndex, signature)
+}
+
+// FinalizeAndExtractContext is FinalizeAndExtract with a context for the
+// tracer set with SetTracer. Like FinalizeAndExtract it ALWAYS consumes the
+// input PCZT, even when ctx is already done.
+func FinalizeAndExtractContext(ctx context.Context, pczt *PCZT) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		if pczt != nil {
+			pczt.Free()
+		}
+		return nil, err
+	}
+	return finalizeAndExtract(ctx, pczt)
+}
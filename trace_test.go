@@ -0,0 +1,114 @@
+//go:build (cgo && (darwin || linux || windows) && (amd64 || arm64)) || t2z_mock
+
+package t2z
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+type traceKey struct{}
+
+// testSpan records a span started by testTracer
+type testSpan struct {
+	name   string
+	parent any
+	attrs  map[string]any
+	ended  bool
+}
+
+func (s *testSpan) SetAttribute(key string, value any) { s.attrs[key] = value }
+func (s *testSpan) End()                               { s.ended = true }
+
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) StartSpan(ctx context.Context, name string) Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	span := &testSpan{name: name, parent: ctx.Value(traceKey{}), attrs: map[string]any{}}
+	t.spans = append(t.spans, span)
+	return span
+}
+
+// Test that the context variants trace each native call in their context
+func TestSetTracer(t *testing.T) {
+	tracer := &testTracer{}
+	SetTracer(tracer)
+	defer SetTracer(nil)
+
+	privateKey, pubkey := createTestKeypair()
+	inputs := []TransparentInput{{
+		Pubkey:       pubkey,
+		Amount:       1_000_000,
+		ScriptPubKey: createP2PKHScript(pubkey),
+	}}
+	request, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 500_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+
+	ctx := context.WithValue(context.Background(), traceKey{}, "parent")
+	pczt, err := ProposeTransactionContext(ctx, inputs, request)
+	if err != nil {
+		t.Fatalf("ProposeTransactionContext failed: %v", err)
+	}
+	proved, err := ProveTransactionContext(ctx, pczt)
+	if err != nil {
+		t.Fatalf("ProveTransactionContext failed: %v", err)
+	}
+	sighash, _ := GetSighash(proved, 0)
+	signature, _ := signMessage(privateKey, sighash)
+	backup, _ := ClonePCZT(proved)
+
+	// A wrong signature is traced with its result code
+	if _, err := AppendSignatureContext(ctx, backup, 0, [64]byte{1}); !errors.Is(err, ErrSignature) {
+		t.Fatalf("Expected ErrSignature, got %v", err)
+	}
+	signed, err := AppendSignatureContext(ctx, proved, 0, signature)
+	if err != nil {
+		t.Fatalf("AppendSignatureContext failed: %v", err)
+	}
+	if _, err := FinalizeAndExtractContext(ctx, signed); err != nil {
+		t.Fatalf("FinalizeAndExtractContext failed: %v", err)
+	}
+
+	want := []struct {
+		name string
+		code ResultCode
+	}{
+		{"t2z.propose", Success},
+		{"t2z.prove", Success},
+		{"t2z.sign", ErrorSignature},
+		{"t2z.sign", Success},
+		{"t2z.finalize", Success},
+	}
+	if len(tracer.spans) != len(want) {
+		t.Fatalf("Expected %d spans, got %d", len(want), len(tracer.spans))
+	}
+	for i, w := range want {
+		s := tracer.spans[i]
+		if s.name != w.name || s.parent != "parent" || !s.ended || s.attrs[AttrResultCode] != int64(w.code) {
+			t.Errorf("Span %d: expected %s with code %d, got %+v", i, w.name, w.code, s)
+		}
+	}
+	if tracer.spans[2].attrs[AttrInputIndex] != int64(0) {
+		t.Errorf("Expected the input index on the sign span, got %v", tracer.spans[2].attrs)
+	}
+
+	// A done context fails before the native call
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := ProposeTransactionContext(cancelled, inputs, request); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if len(tracer.spans) != len(want) {
+		t.Errorf("Expected no span for a cancelled context, got %d", len(tracer.spans)-len(want))
+	}
+}
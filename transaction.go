@@ -0,0 +1,592 @@
+package t2z
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+
+	"golang.org/x/crypto/ripemd160"
+)
+
+// Transaction format constants for v5 (NU5) transactions, see ZIP 225
+const (
+	txVersion5         = 5
+	txVersionGroupIDV5 = 0x26A7270A
+
+	saplingEncCiphertextSize = 580
+	saplingOutCiphertextSize = 80
+	saplingProofSize         = 192
+
+	orchardEncCiphertextSize = 580
+	orchardOutCiphertextSize = 80
+)
+
+// TxInput is a transparent input of a decoded transaction
+type TxInput struct {
+	PrevTxID  [32]byte // internal byte order, as used by TransparentInput.TxID
+	PrevIndex uint32
+	ScriptSig []byte
+	Sequence  uint32
+}
+
+// SaplingSpend is a Sapling spend of a decoded transaction, with its proof
+// and spend authorization signature
+type SaplingSpend struct {
+	CV           [32]byte
+	Nullifier    [32]byte
+	RK           [32]byte
+	Proof        [saplingProofSize]byte
+	SpendAuthSig [64]byte
+}
+
+// SaplingOutput is a Sapling output of a decoded transaction, with its proof
+type SaplingOutput struct {
+	CV            [32]byte
+	CMU           [32]byte
+	EphemeralKey  [32]byte
+	EncCiphertext [saplingEncCiphertextSize]byte
+	OutCiphertext [saplingOutCiphertextSize]byte
+	Proof         [saplingProofSize]byte
+}
+
+// OrchardAction is a single Orchard action of a decoded transaction
+type OrchardAction struct {
+	CV            [32]byte
+	Nullifier     [32]byte
+	RK            [32]byte
+	CMX           [32]byte
+	EphemeralKey  [32]byte
+	EncCiphertext [orchardEncCiphertextSize]byte
+	OutCiphertext [orchardOutCiphertextSize]byte
+}
+
+// Orchard bundle flags, see ZIP 225
+const (
+	OrchardFlagSpendsEnabled  = 0x01
+	OrchardFlagOutputsEnabled = 0x02
+)
+
+// Transaction is a structurally decoded v5 transaction, see DecodeTransaction.
+//
+// Byte slices alias the buffer passed to DecodeTransaction. Notes are not
+// decrypted.
+type Transaction struct {
+	Header            uint32 // version | fOverwintered
+	VersionGroupID    uint32
+	ConsensusBranchID uint32
+	LockTime          uint32
+	ExpiryHeight      uint32
+
+	Inputs  []TxInput
+	Outputs []TransparentOutput
+
+	// The Sapling fields after the spends and outputs are only present if
+	// the bundle is not empty; SaplingAnchor only if it has spends
+	SaplingSpends       []SaplingSpend
+	SaplingOutputs      []SaplingOutput
+	SaplingValueBalance int64
+	SaplingAnchor       [32]byte
+	SaplingBindingSig   [64]byte
+
+	// The Orchard fields after the actions are only present if the bundle
+	// is not empty
+	OrchardActions      []OrchardAction
+	OrchardFlags        byte
+	OrchardValueBalance int64
+	OrchardAnchor       [32]byte
+	OrchardProof        []byte
+	OrchardSpendAuth    [][64]byte
+	OrchardBindingSig   [64]byte
+}
+
+// Version returns the transaction version, without the fOverwintered flag
+func (tx *Transaction) Version() uint32 {
+	return tx.Header & 0x7fffffff
+}
+
+// txReader reads little-endian transaction fields, remembering the first error
+type txReader struct {
+	buf []byte
+	off int
+	err error
+}
+
+func (r *txReader) read(n int, what string) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.buf)-r.off < n {
+		r.err = fmt.Errorf("transaction too short for %s", what)
+		return nil
+	}
+	b := r.buf[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *txReader) uint32(what string) uint32 {
+	b := r.read(4, what)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (r *txReader) uint64(what string) uint64 {
+	b := r.read(8, what)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+// compactSize reads a Bitcoin-style CompactSize and rejects values larger
+// than the remaining bytes, which no valid count or length can exceed
+func (r *txReader) compactSize(what string) int {
+	b := r.read(1, what)
+	if b == nil {
+		return 0
+	}
+
+	var n uint64
+	switch b[0] {
+	case 0xfd:
+		if v := r.read(2, what); v != nil {
+			n = uint64(binary.LittleEndian.Uint16(v))
+		}
+	case 0xfe:
+		if v := r.read(4, what); v != nil {
+			n = uint64(binary.LittleEndian.Uint32(v))
+		}
+	case 0xff:
+		n = r.uint64(what)
+	default:
+		n = uint64(b[0])
+	}
+
+	if r.err == nil && n > uint64(len(r.buf)-r.off) {
+		r.err = fmt.Errorf("invalid %s: %d exceeds remaining transaction size", what, n)
+	}
+	if r.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+// appendCompactSize appends n encoded as a Bitcoin-style CompactSize
+func appendCompactSize(buf []byte, n uint64) []byte {
+	switch {
+	case n < 0xfd:
+		return append(buf, byte(n))
+	case n <= 0xffff:
+		return binary.LittleEndian.AppendUint16(append(buf, 0xfd), uint16(n))
+	case n <= 0xffffffff:
+		return binary.LittleEndian.AppendUint32(append(buf, 0xfe), uint32(n))
+	default:
+		return binary.LittleEndian.AppendUint64(append(buf, 0xff), n)
+	}
+}
+
+// DecodeTransaction parses a v5 (NU5) transaction, such as one returned by
+// FinalizeAndExtract, into its header, transparent inputs and outputs, and
+// Sapling and Orchard bundles, see ZIP 225.
+//
+// Use it to check a finalized transaction before broadcasting it, for example
+// that it has the expected number of Orchard actions. The decoding is purely
+// structural: proofs and signatures are not verified and notes are not
+// decrypted.
+//
+// Returns an error if txBytes is not exactly one well-formed v5 transaction.
+func DecodeTransaction(txBytes []byte) (*Transaction, error) {
+	r := &txReader{buf: txBytes}
+	tx := &Transaction{}
+
+	tx.Header = r.uint32("header")
+	tx.VersionGroupID = r.uint32("version group id")
+	tx.ConsensusBranchID = r.uint32("consensus branch id")
+	tx.LockTime = r.uint32("lock time")
+	tx.ExpiryHeight = r.uint32("expiry height")
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if tx.Version() != txVersion5 || tx.VersionGroupID != txVersionGroupIDV5 {
+		return nil, fmt.Errorf("unsupported transaction version %d (group id %#x), expected v5", tx.Version(), tx.VersionGroupID)
+	}
+
+	numInputs := r.compactSize("input count")
+	for i := 0; i < numInputs && r.err == nil; i++ {
+		var in TxInput
+		copy(in.PrevTxID[:], r.read(32, "prevout txid"))
+		in.PrevIndex = r.uint32("prevout index")
+		in.ScriptSig = r.read(r.compactSize("script sig length"), "script sig")
+		in.Sequence = r.uint32("sequence")
+		tx.Inputs = append(tx.Inputs, in)
+	}
+
+	numOutputs := r.compactSize("output count")
+	for i := 0; i < numOutputs && r.err == nil; i++ {
+		var out TransparentOutput
+		out.Value = r.uint64("output value")
+		out.ScriptPubKey = r.read(r.compactSize("script pubkey length"), "script pubkey")
+		tx.Outputs = append(tx.Outputs, out)
+	}
+
+	numSpends := r.compactSize("sapling spend count")
+	for i := 0; i < numSpends && r.err == nil; i++ {
+		var spend SaplingSpend
+		copy(spend.CV[:], r.read(32, "sapling spend cv"))
+		copy(spend.Nullifier[:], r.read(32, "sapling nullifier"))
+		copy(spend.RK[:], r.read(32, "sapling rk"))
+		tx.SaplingSpends = append(tx.SaplingSpends, spend)
+	}
+
+	numSaplingOutputs := r.compactSize("sapling output count")
+	for i := 0; i < numSaplingOutputs && r.err == nil; i++ {
+		var out SaplingOutput
+		copy(out.CV[:], r.read(32, "sapling output cv"))
+		copy(out.CMU[:], r.read(32, "sapling cmu"))
+		copy(out.EphemeralKey[:], r.read(32, "sapling ephemeral key"))
+		copy(out.EncCiphertext[:], r.read(saplingEncCiphertextSize, "sapling enc ciphertext"))
+		copy(out.OutCiphertext[:], r.read(saplingOutCiphertextSize, "sapling out ciphertext"))
+		tx.SaplingOutputs = append(tx.SaplingOutputs, out)
+	}
+
+	if (numSpends > 0 || numSaplingOutputs > 0) && r.err == nil {
+		tx.SaplingValueBalance = int64(r.uint64("sapling value balance"))
+		if numSpends > 0 {
+			copy(tx.SaplingAnchor[:], r.read(32, "sapling anchor"))
+		}
+		for i := 0; i < numSpends && r.err == nil; i++ {
+			copy(tx.SaplingSpends[i].Proof[:], r.read(saplingProofSize, "sapling spend proof"))
+		}
+		for i := 0; i < numSpends && r.err == nil; i++ {
+			copy(tx.SaplingSpends[i].SpendAuthSig[:], r.read(64, "sapling spend auth signature"))
+		}
+		for i := 0; i < numSaplingOutputs && r.err == nil; i++ {
+			copy(tx.SaplingOutputs[i].Proof[:], r.read(saplingProofSize, "sapling output proof"))
+		}
+		copy(tx.SaplingBindingSig[:], r.read(64, "sapling binding signature"))
+	}
+
+	numActions := r.compactSize("orchard action count")
+	if numActions > 0 && r.err == nil {
+		for i := 0; i < numActions && r.err == nil; i++ {
+			var a OrchardAction
+			copy(a.CV[:], r.read(32, "orchard cv"))
+			copy(a.Nullifier[:], r.read(32, "orchard nullifier"))
+			copy(a.RK[:], r.read(32, "orchard rk"))
+			copy(a.CMX[:], r.read(32, "orchard cmx"))
+			copy(a.EphemeralKey[:], r.read(32, "orchard ephemeral key"))
+			copy(a.EncCiphertext[:], r.read(orchardEncCiphertextSize, "orchard enc ciphertext"))
+			copy(a.OutCiphertext[:], r.read(orchardOutCiphertextSize, "orchard out ciphertext"))
+			tx.OrchardActions = append(tx.OrchardActions, a)
+		}
+
+		if flags := r.read(1, "orchard flags"); flags != nil {
+			tx.OrchardFlags = flags[0]
+		}
+		tx.OrchardValueBalance = int64(r.uint64("orchard value balance"))
+		copy(tx.OrchardAnchor[:], r.read(32, "orchard anchor"))
+		tx.OrchardProof = r.read(r.compactSize("orchard proof size"), "orchard proof")
+		for i := 0; i < numActions && r.err == nil; i++ {
+			var sig [64]byte
+			copy(sig[:], r.read(64, "orchard spend auth signature"))
+			tx.OrchardSpendAuth = append(tx.OrchardSpendAuth, sig)
+		}
+		copy(tx.OrchardBindingSig[:], r.read(64, "orchard binding signature"))
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.off != len(txBytes) {
+		return nil, fmt.Errorf("%d trailing bytes after transaction", len(txBytes)-r.off)
+	}
+
+	return tx, nil
+}
+
+// headerDigest is the ZIP 244 header digest
+func (tx *Transaction) headerDigest() [32]byte {
+	var buf []byte
+	buf = binary.LittleEndian.AppendUint32(buf, tx.Header)
+	buf = binary.LittleEndian.AppendUint32(buf, tx.VersionGroupID)
+	buf = binary.LittleEndian.AppendUint32(buf, tx.ConsensusBranchID)
+	buf = binary.LittleEndian.AppendUint32(buf, tx.LockTime)
+	buf = binary.LittleEndian.AppendUint32(buf, tx.ExpiryHeight)
+	return blake2b256Personal([]byte("ZTxIdHeadersHash"), buf)
+}
+
+// prevoutsDigest is the ZIP 244 transparent prevouts digest
+func (tx *Transaction) prevoutsDigest() [32]byte {
+	var buf []byte
+	for _, in := range tx.Inputs {
+		buf = append(buf, in.PrevTxID[:]...)
+		buf = binary.LittleEndian.AppendUint32(buf, in.PrevIndex)
+	}
+	return blake2b256Personal([]byte("ZTxIdPrevoutHash"), buf)
+}
+
+// sequenceDigest is the ZIP 244 transparent sequence digest
+func (tx *Transaction) sequenceDigest() [32]byte {
+	var buf []byte
+	for _, in := range tx.Inputs {
+		buf = binary.LittleEndian.AppendUint32(buf, in.Sequence)
+	}
+	return blake2b256Personal([]byte("ZTxIdSequencHash"), buf)
+}
+
+// outputsDigest is the ZIP 244 transparent outputs digest
+func (tx *Transaction) outputsDigest() [32]byte {
+	var buf []byte
+	for _, out := range tx.Outputs {
+		buf = binary.LittleEndian.AppendUint64(buf, out.Value)
+		buf = appendCompactSize(buf, uint64(len(out.ScriptPubKey)))
+		buf = append(buf, out.ScriptPubKey...)
+	}
+	return blake2b256Personal([]byte("ZTxIdOutputsHash"), buf)
+}
+
+// transparentDigest is the ZIP 244 transparent digest used for the txid
+func (tx *Transaction) transparentDigest() [32]byte {
+	if len(tx.Inputs) == 0 && len(tx.Outputs) == 0 {
+		return blake2b256Personal([]byte("ZTxIdTranspaHash"))
+	}
+	prevouts := tx.prevoutsDigest()
+	sequence := tx.sequenceDigest()
+	outputs := tx.outputsDigest()
+	return blake2b256Personal([]byte("ZTxIdTranspaHash"), prevouts[:], sequence[:], outputs[:])
+}
+
+// saplingDigest is the ZIP 244 sapling digest of an empty Sapling bundle, the
+// only kind t2z creates
+func (tx *Transaction) saplingDigest() [32]byte {
+	return blake2b256Personal([]byte("ZTxIdSaplingHash"))
+}
+
+// orchardDigest is the ZIP 244 orchard digest
+func (tx *Transaction) orchardDigest() [32]byte {
+	if len(tx.OrchardActions) == 0 {
+		return blake2b256Personal([]byte("ZTxIdOrchardHash"))
+	}
+
+	var compact, memos, noncompact []byte
+	for _, a := range tx.OrchardActions {
+		compact = append(compact, a.Nullifier[:]...)
+		compact = append(compact, a.CMX[:]...)
+		compact = append(compact, a.EphemeralKey[:]...)
+		compact = append(compact, a.EncCiphertext[:52]...)
+
+		memos = append(memos, a.EncCiphertext[52:564]...)
+
+		noncompact = append(noncompact, a.CV[:]...)
+		noncompact = append(noncompact, a.RK[:]...)
+		noncompact = append(noncompact, a.EncCiphertext[564:]...)
+		noncompact = append(noncompact, a.OutCiphertext[:]...)
+	}
+
+	compactDigest := blake2b256Personal([]byte("ZTxIdOrcActCHash"), compact)
+	memosDigest := blake2b256Personal([]byte("ZTxIdOrcActMHash"), memos)
+	noncompactDigest := blake2b256Personal([]byte("ZTxIdOrcActNHash"), noncompact)
+
+	var tail []byte
+	tail = append(tail, tx.OrchardFlags)
+	tail = binary.LittleEndian.AppendUint64(tail, uint64(tx.OrchardValueBalance))
+	tail = append(tail, tx.OrchardAnchor[:]...)
+
+	return blake2b256Personal([]byte("ZTxIdOrchardHash"), compactDigest[:], memosDigest[:], noncompactDigest[:], tail)
+}
+
+// txID computes the ZIP 244 transaction id in internal byte order, the same
+// order used by TransparentInput.TxID
+func (tx *Transaction) txID() ([32]byte, error) {
+	if len(tx.SaplingSpends) > 0 || len(tx.SaplingOutputs) > 0 {
+		return [32]byte{}, errors.New("txid of transactions with a Sapling bundle is not supported")
+	}
+
+	personal := make([]byte, 16)
+	copy(personal, "ZcashTxHash_")
+	binary.LittleEndian.PutUint32(personal[12:], tx.ConsensusBranchID)
+
+	header := tx.headerDigest()
+	transparent := tx.transparentDigest()
+	sapling := tx.saplingDigest()
+	orchard := tx.orchardDigest()
+	return blake2b256Personal(personal, header[:], transparent[:], sapling[:], orchard[:]), nil
+}
+
+// Serialized sizes used by EstimateTxSize, see ZIP 225
+const (
+	txHeaderSize = 20 // header, version group id, branch id, lock time, expiry height
+
+	// Outpoint, script length, scriptSig of a low-S DER signature (at most
+	// 72 bytes with the sighash type) and a compressed pubkey, and sequence
+	p2pkhInputMaxSize = 36 + 1 + (1 + 72 + 1 + 33) + 4
+
+	// Value, script length and a 25-byte P2PKH script (P2SH is 2 bytes shorter)
+	p2pkhOutputSize = 8 + 1 + 25
+
+	orchardActionSize = 5*32 + orchardEncCiphertextSize + orchardOutCiphertextSize
+	orchardMinActions = 2
+)
+
+// EstimateTxSize returns the size in bytes of the signed transaction that a
+// proposal of this shape produces, without building or proving it.
+//
+// Transparent inputs are assumed to be P2PKH and counted at their largest
+// signature size, so the estimate may exceed the real size by a byte per
+// input but never falls short. Outputs are counted as P2PKH. The Orchard
+// bundle is padded to at least two actions, and its proof grows with every
+// action, which makes it by far the largest part of a shielded transaction.
+//
+// Use it with CalculateFee to show a fee rate before the slow prove step;
+// once signed, PCZT.SignedSize gives the exact size.
+func EstimateTxSize(numTransparentInputs, numTransparentOutputs, numOrchardOutputs int) int {
+	size := txHeaderSize
+	size += compactSizeLen(numTransparentInputs) + numTransparentInputs*p2pkhInputMaxSize
+	size += compactSizeLen(numTransparentOutputs) + numTransparentOutputs*p2pkhOutputSize
+	size += 2 // empty Sapling spends and outputs
+
+	if numOrchardOutputs <= 0 {
+		return size + compactSizeLen(0)
+	}
+	actions := max(numOrchardOutputs, orchardMinActions)
+	proofSize := orchardProofSize(actions)
+	size += compactSizeLen(actions) + actions*orchardActionSize
+	size += 1 + 8 + 32 // flags, value balance, anchor
+	size += compactSizeLen(proofSize) + proofSize
+	size += actions*64 + 64 // spend auth signatures and binding signature
+	return size
+}
+
+// compactSizeLen returns the encoded length of n as a CompactSize
+func compactSizeLen(n int) int {
+	return len(appendCompactSize(nil, uint64(n)))
+}
+
+// TransactionID returns the txid of a finalized transaction as hex, in the
+// reversed byte order used by zebrad RPCs and block explorers.
+//
+// The txid of a v5 transaction is the ZIP 244 BLAKE2b digest tree, not the
+// double-SHA256 of the bytes used by earlier versions, so it can only be
+// computed by parsing the transaction. Use it to record the txid before
+// broadcasting; it matches the one sendrawtransaction returns.
+//
+// Returns an error if txBytes is not a v5 transaction as produced by
+// FinalizeAndExtract.
+func TransactionID(txBytes []byte) (string, error) {
+	tx, err := DecodeTransaction(txBytes)
+	if err != nil {
+		return "", err
+	}
+	id, err := tx.txID()
+	if err != nil {
+		return "", err
+	}
+	slices.Reverse(id[:])
+	return hex.EncodeToString(id[:]), nil
+}
+
+// hash160 computes RIPEMD160(SHA256(data))
+func hash160(data []byte) []byte {
+	sha := sha256.Sum256(data)
+	h := ripemd160.New()
+	h.Write(sha[:])
+	return h.Sum(nil)
+}
+
+// P2PKHScript returns the P2PKH scriptPubKey that pays to a 33-byte
+// compressed secp256k1 public key, as needed for TransparentInput.ScriptPubKey
+func P2PKHScript(pubkey []byte) ([]byte, error) {
+	if len(pubkey) != 33 || (pubkey[0] != 0x02 && pubkey[0] != 0x03) {
+		return nil, fmt.Errorf("invalid compressed public key (%d bytes)", len(pubkey))
+	}
+	return p2pkhScript(hash160(pubkey)), nil
+}
+
+// P2PKHScriptFromHash returns the P2PKH scriptPubKey for a 20-byte public key
+// hash (RIPEMD160(SHA256(pubkey)))
+func P2PKHScriptFromHash(hash160 []byte) ([]byte, error) {
+	if len(hash160) != 20 {
+		return nil, fmt.Errorf("invalid public key hash (%d bytes, expected 20)", len(hash160))
+	}
+	return p2pkhScript(hash160), nil
+}
+
+// p2pkhScript builds the raw P2PKH script for a 20-byte public key hash
+func p2pkhScript(pubkeyHash []byte) []byte {
+	script := make([]byte, 0, 25)
+	script = append(script, 0x76, 0xa9, 0x14) // OP_DUP OP_HASH160 PUSH20
+	script = append(script, pubkeyHash...)
+	script = append(script, 0x88, 0xac) // OP_EQUALVERIFY OP_CHECKSIG
+	return script
+}
+
+// isP2PKHScript reports whether script is OP_DUP OP_HASH160 <20 bytes> OP_EQUALVERIFY OP_CHECKSIG
+func isP2PKHScript(script []byte) bool {
+	return len(script) == 25 &&
+		script[0] == 0x76 && script[1] == 0xa9 && script[2] == 0x14 &&
+		script[23] == 0x88 && script[24] == 0xac
+}
+
+// isP2SHScript reports whether script is OP_HASH160 <20 bytes> OP_EQUAL
+func isP2SHScript(script []byte) bool {
+	return len(script) == 23 &&
+		script[0] == 0xa9 && script[1] == 0x14 && script[22] == 0x87
+}
+
+// OutputsAsInputs returns the outputs of a finalized transaction that pay to
+// the given public key, ready to be spent as TransparentInputs.
+//
+// This allows chaining transactions (e.g. spending the change of a transaction
+// that was just broadcast) without querying a node for the new UTXOs. The txid
+// is computed from the transaction bytes as specified in ZIP 244. Only P2PKH
+// outputs are matched. Their scripts are identical on every network, so net
+// is only checked to be a valid network, like ScriptToAddress does.
+//
+// Parameters:
+//   - txBytes: Transaction bytes as returned by FinalizeAndExtract
+//   - pubkey: 33-byte compressed secp256k1 public key
+//   - net: Network the transaction was built for
+//
+// Returns the matching outputs (possibly none) or an error if the transaction cannot be parsed.
+func OutputsAsInputs(txBytes []byte, pubkey []byte, net Network) ([]TransparentInput, error) {
+	if len(pubkey) != 33 {
+		return nil, fmt.Errorf("invalid pubkey length: expected 33, got %d", len(pubkey))
+	}
+	if net != NetworkMainnet && net != NetworkTestnet && net != NetworkRegtest {
+		return nil, fmt.Errorf("invalid network %s", net)
+	}
+
+	tx, err := DecodeTransaction(txBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	txid, err := tx.txID()
+	if err != nil {
+		return nil, err
+	}
+	script := p2pkhScript(hash160(pubkey))
+
+	var inputs []TransparentInput
+	for i, out := range tx.Outputs {
+		if !bytes.Equal(out.ScriptPubKey, script) {
+			continue
+		}
+		inputs = append(inputs, TransparentInput{
+			Pubkey:       pubkey,
+			TxID:         txid,
+			Vout:         uint32(i),
+			Amount:       out.Value,
+			ScriptPubKey: append([]byte(nil), out.ScriptPubKey...),
+		})
+	}
+
+	return inputs, nil
+}
@@ -0,0 +1,409 @@
+package t2z
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"slices"
+	"testing"
+)
+
+const testShieldedAddress = "u1eq7cm60un363n2sa862w4t5pq56tl5x0d7wqkzhhva0sxue7kqw85haa6w6xsz8n8ujmcpkzsza8knwgglau443s7ljdgu897yrvyhhz"
+
+// buildSignedTransaction runs the full workflow for a single input and returns the transaction bytes
+// along with the sighash that the Rust library computed for input 0
+func buildSignedTransaction(t *testing.T, inputs []TransparentInput, payments []Payment) ([]byte, [32]byte) {
+	t.Helper()
+
+	privateKey, _ := createTestKeypair()
+
+	// Duplicates let tests build several outputs to the test addresses
+	request, err := NewTransactionRequestAllowDuplicates(payments)
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+
+	request.SetTargetHeight(2_500_000)
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+
+	proved, err := ProveTransaction(pczt)
+	if err != nil {
+		t.Fatalf("Failed to prove transaction: %v", err)
+	}
+
+	var firstSighash [32]byte
+	for i := range inputs {
+		sighash, err := GetSighash(proved, uint(i))
+		if err != nil {
+			t.Fatalf("Failed to get sighash: %v", err)
+		}
+		if i == 0 {
+			firstSighash = sighash
+		}
+
+		signature, err := signMessage(privateKey, sighash)
+		if err != nil {
+			t.Fatalf("Failed to sign message: %v", err)
+		}
+
+		proved, err = AppendSignature(proved, uint(i), signature)
+		if err != nil {
+			t.Fatalf("Failed to append signature: %v", err)
+		}
+	}
+
+	txBytes, err := FinalizeAndExtract(proved)
+	if err != nil {
+		t.Fatalf("Failed to finalize and extract: %v", err)
+	}
+
+	return txBytes, firstSighash
+}
+
+// transparentSighash computes the ZIP 244 SIGHASH_ALL digest for a transparent input.
+// It shares the header, sapling and orchard digests with the txid, so matching the
+// Rust library's sighash validates the decoder and those digests.
+func transparentSighash(tx *Transaction, index int, amounts []uint64, scripts [][]byte) [32]byte {
+	var amountsBuf, scriptsBuf []byte
+	for i := range amounts {
+		amountsBuf = binary.LittleEndian.AppendUint64(amountsBuf, amounts[i])
+		scriptsBuf = appendCompactSize(scriptsBuf, uint64(len(scripts[i])))
+		scriptsBuf = append(scriptsBuf, scripts[i]...)
+	}
+	amountsDigest := blake2b256Personal([]byte("ZTxTrAmountsHash"), amountsBuf)
+	scriptsDigest := blake2b256Personal([]byte("ZTxTrScriptsHash"), scriptsBuf)
+
+	in := tx.Inputs[index]
+	var txinBuf []byte
+	txinBuf = append(txinBuf, in.PrevTxID[:]...)
+	txinBuf = binary.LittleEndian.AppendUint32(txinBuf, in.PrevIndex)
+	txinBuf = binary.LittleEndian.AppendUint64(txinBuf, amounts[index])
+	txinBuf = appendCompactSize(txinBuf, uint64(len(scripts[index])))
+	txinBuf = append(txinBuf, scripts[index]...)
+	txinBuf = binary.LittleEndian.AppendUint32(txinBuf, in.Sequence)
+	txinDigest := blake2b256Personal([]byte("Zcash___TxInHash"), txinBuf)
+
+	prevouts := tx.prevoutsDigest()
+	sequence := tx.sequenceDigest()
+	outputs := tx.outputsDigest()
+	transparent := blake2b256Personal([]byte("ZTxIdTranspaHash"),
+		[]byte{0x01}, prevouts[:], amountsDigest[:], scriptsDigest[:], sequence[:], outputs[:], txinDigest[:])
+
+	personal := make([]byte, 16)
+	copy(personal, "ZcashTxHash_")
+	binary.LittleEndian.PutUint32(personal[12:], tx.ConsensusBranchID)
+
+	header := tx.headerDigest()
+	sapling := tx.saplingDigest()
+	orchard := tx.orchardDigest()
+	return blake2b256Personal(personal, header[:], transparent[:], sapling[:], orchard[:])
+}
+
+// Test BLAKE2b personalization against known digests
+func TestBlake2b256Personal(t *testing.T) {
+	data := make([]byte, 300)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	tests := []struct {
+		len      int
+		expected string
+	}{
+		{0, "c33f2e95705faab35f8d533fa61e95c3b7aaba0776b874a9f74fc12784376a59"},
+		{128, "41135b98aaf9b2871b8beaae3a7843e7749fb37f26f673cb5da77cda80ac3224"},
+		{129, "eaef3115f3da9f79b8a095cad3986a8f1467683d5fec4b39bb0a5438f1fecc2e"},
+		{300, "3bf6bbb868e8b2b2068d9c65303e39ea512dbc211eff2bf9d08390bd1ae9dd87"},
+	}
+
+	for _, tt := range tests {
+		digest := blake2b256Personal([]byte("ZTxIdTranspaHash"), data[:tt.len])
+		if got := hex.EncodeToString(digest[:]); got != tt.expected {
+			t.Errorf("len %d: expected %s, got %s", tt.len, tt.expected, got)
+		}
+	}
+}
+
+// Test decoding and digests of transparent and shielded transactions
+func TestDecodeTransactionDigests(t *testing.T) {
+	requireBackend(t)
+	_, pubkey := createTestKeypair()
+	script := createP2PKHScript(pubkey)
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_decode_transaction_000"))
+
+	inputs := []TransparentInput{
+		{Pubkey: pubkey, TxID: txid, Vout: 0, Amount: 100_000_000, ScriptPubKey: script},
+		{Pubkey: pubkey, TxID: txid, Vout: 1, Amount: 50_000_000, ScriptPubKey: script},
+	}
+
+	tests := []struct {
+		name           string
+		payments       []Payment
+		orchardActions bool
+	}{
+		{"transparent", []Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 50_000_000}}, false},
+		{"shielded", []Payment{{Address: testShieldedAddress, Amount: 50_000_000, Memo: "decode test"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txBytes, sighash := buildSignedTransaction(t, inputs, tt.payments)
+
+			tx, err := DecodeTransaction(txBytes)
+			if err != nil {
+				t.Fatalf("Failed to decode transaction: %v", err)
+			}
+
+			if len(tx.Inputs) != len(inputs) {
+				t.Errorf("Expected %d inputs, got %d", len(inputs), len(tx.Inputs))
+			}
+			if tx.Inputs[1].PrevTxID != txid || tx.Inputs[1].PrevIndex != 1 {
+				t.Error("Input prevout mismatch")
+			}
+			if tx.Version() != 5 || len(tx.SaplingSpends) != 0 || len(tx.SaplingOutputs) != 0 {
+				t.Errorf("Unexpected version %d or Sapling bundle", tx.Version())
+			}
+			if tt.orchardActions {
+				// One output is padded to the minimum of two actions
+				if len(tx.OrchardActions) != orchardMinActions || len(tx.OrchardSpendAuth) != orchardMinActions {
+					t.Errorf("Expected %d orchard actions, got %d", orchardMinActions, len(tx.OrchardActions))
+				}
+				if tx.OrchardFlags&OrchardFlagOutputsEnabled == 0 || tx.OrchardValueBalance >= 0 {
+					t.Errorf("Unexpected orchard flags %#x or value balance %d", tx.OrchardFlags, tx.OrchardValueBalance)
+				}
+			} else if len(tx.OrchardActions) != 0 {
+				t.Errorf("Unexpected orchard action count %d", len(tx.OrchardActions))
+			}
+
+			// Mock sighashes are not ZIP 244 digests
+			amounts := []uint64{inputs[0].Amount, inputs[1].Amount}
+			scripts := [][]byte{script, script}
+			if got := transparentSighash(tx, 0, amounts, scripts); got != sighash && !mockBackend {
+				t.Errorf("Sighash mismatch: expected %x, got %x", sighash, got)
+			}
+
+			if _, err := DecodeTransaction(txBytes[:len(txBytes)-1]); err == nil {
+				t.Error("Expected error for truncated transaction, got nil")
+			}
+		})
+	}
+}
+
+// Test decoding a Sapling bundle, which t2z never creates itself
+func TestDecodeTransactionSapling(t *testing.T) {
+	requireBackend(t)
+	_, pubkey := createTestKeypair()
+	inputs := []TransparentInput{
+		{Pubkey: pubkey, Vout: 0, Amount: 100_000_000, ScriptPubKey: createP2PKHScript(pubkey)},
+	}
+	txBytes, _ := buildSignedTransaction(t, inputs, []Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 50_000_000}})
+
+	// Replace the empty Sapling and Orchard bundles with one Sapling spend
+	// and output, filling each field with its own byte
+	sapling := slices.Clone(txBytes[:len(txBytes)-3])
+	fill := func(b byte, n int) []byte { return bytes.Repeat([]byte{b}, n) }
+	sapling = append(sapling, 1)
+	sapling = append(sapling, slices.Concat(fill(1, 32), fill(2, 32), fill(3, 32))...)
+	sapling = append(sapling, 1)
+	sapling = append(sapling, slices.Concat(fill(4, 32), fill(5, 32), fill(6, 32), fill(7, saplingEncCiphertextSize), fill(8, saplingOutCiphertextSize))...)
+	sapling = binary.LittleEndian.AppendUint64(sapling, uint64(25_000))
+	sapling = append(sapling, slices.Concat(fill(9, 32), fill(10, saplingProofSize), fill(11, 64), fill(12, saplingProofSize), fill(13, 64))...)
+	sapling = append(sapling, 0) // no Orchard actions
+
+	tx, err := DecodeTransaction(sapling)
+	if err != nil {
+		t.Fatalf("Failed to decode transaction: %v", err)
+	}
+	if len(tx.SaplingSpends) != 1 || len(tx.SaplingOutputs) != 1 {
+		t.Fatalf("Expected 1 Sapling spend and output, got %d and %d", len(tx.SaplingSpends), len(tx.SaplingOutputs))
+	}
+	spend, out := tx.SaplingSpends[0], tx.SaplingOutputs[0]
+	if spend.Nullifier[0] != 2 || spend.RK[0] != 3 || spend.Proof[0] != 10 || spend.SpendAuthSig[0] != 11 {
+		t.Error("Sapling spend mismatch")
+	}
+	if out.CMU[0] != 5 || out.EncCiphertext[0] != 7 || out.OutCiphertext[0] != 8 || out.Proof[0] != 12 {
+		t.Error("Sapling output mismatch")
+	}
+	if tx.SaplingValueBalance != 25_000 || tx.SaplingAnchor[0] != 9 || tx.SaplingBindingSig[0] != 13 {
+		t.Error("Sapling bundle fields mismatch")
+	}
+	if len(tx.Outputs) != 2 || len(tx.OrchardActions) != 0 {
+		t.Errorf("Unexpected transparent outputs %d or orchard actions %d", len(tx.Outputs), len(tx.OrchardActions))
+	}
+
+	if _, err := TransactionID(sapling); err == nil {
+		t.Error("Expected error for txid of a Sapling transaction, got nil")
+	}
+	for _, n := range []int{1, 65, 64 + saplingProofSize + 1} {
+		if _, err := DecodeTransaction(sapling[:len(sapling)-n]); err == nil {
+			t.Errorf("Expected error for transaction truncated by %d bytes, got nil", n)
+		}
+	}
+}
+
+// Test OutputsAsInputs returns the change output as a spendable input
+func TestOutputsAsInputs(t *testing.T) {
+	requireBackend(t)
+	_, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_outputs_as_inputs_0000"))
+
+	inputAmount := uint64(100_000_000)
+	paymentAmount := inputAmount / 2
+	fee := CalculateFee(1, 2, 0)
+
+	inputs := []TransparentInput{
+		{Pubkey: pubkey, TxID: txid, Vout: 0, Amount: inputAmount, ScriptPubKey: createP2PKHScript(pubkey)},
+	}
+	payments := []Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: paymentAmount}}
+
+	txBytes, _ := buildSignedTransaction(t, inputs, payments)
+
+	chained, err := OutputsAsInputs(txBytes, pubkey, NetworkTestnet)
+	if err != nil {
+		t.Fatalf("Failed to get outputs as inputs: %v", err)
+	}
+
+	if len(chained) != 1 {
+		t.Fatalf("Expected 1 output paying to our key, got %d", len(chained))
+	}
+	if chained[0].Amount != inputAmount-paymentAmount-fee {
+		t.Errorf("Expected change %d, got %d", inputAmount-paymentAmount-fee, chained[0].Amount)
+	}
+
+	tx, _ := DecodeTransaction(txBytes)
+	if txid, _ := tx.txID(); chained[0].TxID != txid {
+		t.Error("TxID mismatch")
+	}
+	if chained[0].TxID == txid {
+		t.Error("TxID should be the new transaction, not the spent one")
+	}
+
+	// TransactionID is the same txid in display order
+	display := chained[0].TxID
+	slices.Reverse(display[:])
+	if id, err := TransactionID(txBytes); err != nil || id != hex.EncodeToString(display[:]) {
+		t.Errorf("TransactionID = %q, %v; want %x", id, err, display)
+	}
+	if _, err := TransactionID(txBytes[:len(txBytes)-1]); err == nil {
+		t.Error("Expected error for truncated transaction, got nil")
+	}
+
+	// The chained input can be proposed in a follow-up transaction
+	request, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 10_000_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+	request.SetTargetHeight(2_500_000)
+
+	pczt, err := ProposeTransaction(chained, request)
+	if err != nil {
+		t.Fatalf("Failed to propose chained transaction: %v", err)
+	}
+	pczt.Free()
+
+	if _, err := OutputsAsInputs(txBytes, pubkey[:32], NetworkTestnet); err == nil {
+		t.Error("Expected error for invalid pubkey, got nil")
+	}
+	if _, err := OutputsAsInputs(txBytes, pubkey, Network(0)); err == nil {
+		t.Error("Expected error for invalid network, got nil")
+	}
+}
+
+func TestEstimateTxSize(t *testing.T) {
+	// A lone Orchard output is padded to two actions
+	if one, two := EstimateTxSize(1, 0, 1), EstimateTxSize(1, 0, 2); one != two {
+		t.Errorf("Expected 1 and 2 Orchard outputs to have the same size, got %d and %d", one, two)
+	}
+	if base, more := EstimateTxSize(1, 1, 0), EstimateTxSize(2, 1, 0); more-base != p2pkhInputMaxSize {
+		t.Errorf("Expected an input to add %d bytes, got %d", p2pkhInputMaxSize, more-base)
+	}
+	if two, three := EstimateTxSize(1, 0, 2), EstimateTxSize(1, 0, 3); three <= two {
+		t.Errorf("Expected a third Orchard action to grow the size, got %d and %d", two, three)
+	}
+}
+
+// Test building P2PKH scripts from a pubkey and from its hash
+func TestP2PKHScript(t *testing.T) {
+	_, pubkey := createTestKeypair()
+	script, err := P2PKHScript(pubkey)
+	if err != nil {
+		t.Fatalf("P2PKHScript failed: %v", err)
+	}
+	if !bytes.Equal(script, createP2PKHScript(pubkey)) {
+		t.Errorf("P2PKHScript = %x, want %x", script, createP2PKHScript(pubkey))
+	}
+	fromHash, err := P2PKHScriptFromHash(script[3:23])
+	if err != nil || !bytes.Equal(fromHash, script) {
+		t.Errorf("P2PKHScriptFromHash = %x, %v", fromHash, err)
+	}
+
+	uncompressed := append([]byte{0x04}, make([]byte, 64)...)
+	for _, bad := range [][]byte{nil, pubkey[:32], uncompressed, append([]byte{0x05}, pubkey[1:]...)} {
+		if _, err := P2PKHScript(bad); err == nil {
+			t.Errorf("Expected an error for pubkey %x", bad)
+		}
+	}
+	if _, err := P2PKHScriptFromHash(make([]byte, 32)); err == nil {
+		t.Error("Expected an error for a 32-byte hash")
+	}
+}
+
+// Test that EstimateTxSize matches the size of signed transactions of the
+// same shape, overestimating by at most a byte per input
+func TestEstimateTxSizeMatchesTransactions(t *testing.T) {
+	requireBackend(t)
+	_, pubkey := createTestKeypair()
+	script := createP2PKHScript(pubkey)
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_estimate_tx_size_00000"))
+
+	var inputs []TransparentInput
+	for i := 0; i < 2; i++ {
+		inputs = append(inputs, TransparentInput{Pubkey: pubkey, TxID: txid, Vout: uint32(i), Amount: 100_000_000, ScriptPubKey: script})
+	}
+	transparent := Payment{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 1_000_000}
+	shielded := Payment{Address: testShieldedAddress, Amount: 1_000_000}
+
+	tests := []struct {
+		name     string
+		inputs   int
+		payments []Payment
+		orchard  int
+	}{
+		{"transparent", 1, []Payment{transparent}, 0},
+		{"two inputs", 2, []Payment{transparent}, 0},
+		{"one shielded output", 1, []Payment{shielded}, 1},
+		{"two shielded outputs", 2, []Payment{transparent, shielded, shielded}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txBytes, _ := buildSignedTransaction(t, inputs[:tt.inputs], tt.payments)
+			tx, err := DecodeTransaction(txBytes)
+			if err != nil {
+				t.Fatalf("Failed to decode transaction: %v", err)
+			}
+
+			est := EstimateTxSize(len(tx.Inputs), len(tx.Outputs), tt.orchard)
+			if est < len(txBytes) || est > len(txBytes)+tt.inputs {
+				t.Errorf("EstimateTxSize(%d, %d, %d) = %d, actual size %d", len(tx.Inputs), len(tx.Outputs), tt.orchard, est, len(txBytes))
+			}
+		})
+	}
+
+	// 1 input and 2 outputs: header, 1+148 input bytes, 1+2*34 output
+	// bytes, empty Sapling and Orchard bundles
+	if est := EstimateTxSize(1, 2, 0); est != 20+1+148+1+68+2+1 {
+		t.Errorf("EstimateTxSize(1, 2, 0) = %d, want %d", est, 20+1+148+1+68+2+1)
+	}
+}
@@ -0,0 +1,284 @@
+package t2z
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ReceiverType is the typecode of a receiver in a unified address (ZIP 316)
+type ReceiverType uint32
+
+const (
+	// ReceiverP2PKH is a transparent P2PKH receiver (20-byte pubkey hash)
+	ReceiverP2PKH ReceiverType = 0x00
+	// ReceiverP2SH is a transparent P2SH receiver (20-byte script hash)
+	ReceiverP2SH ReceiverType = 0x01
+	// ReceiverSapling is a Sapling receiver (43-byte payment address)
+	ReceiverSapling ReceiverType = 0x02
+	// ReceiverOrchard is an Orchard receiver (43-byte raw address)
+	ReceiverOrchard ReceiverType = 0x03
+)
+
+// String returns the string representation of a ReceiverType
+func (t ReceiverType) String() string {
+	switch t {
+	case ReceiverP2PKH:
+		return "P2PKH"
+	case ReceiverP2SH:
+		return "P2SH"
+	case ReceiverSapling:
+		return "Sapling"
+	case ReceiverOrchard:
+		return "Orchard"
+	default:
+		return fmt.Sprintf("Unknown(0x%02x)", uint32(t))
+	}
+}
+
+// receiverLengths are the required encoded lengths of the known receivers
+var receiverLengths = map[ReceiverType]int{
+	ReceiverP2PKH:   20,
+	ReceiverP2SH:    20,
+	ReceiverSapling: 43,
+	ReceiverOrchard: 43,
+}
+
+// Receiver is a single receiver of a unified address
+type Receiver struct {
+	Type ReceiverType
+	Data []byte
+}
+
+// UnifiedAddress is a decoded unified address
+type UnifiedAddress struct {
+	Network Network
+	// Receivers in encoding order (ascending typecode). Receivers with
+	// unknown typecodes are kept with their raw bytes.
+	Receivers []Receiver
+}
+
+// Receiver returns the raw bytes of the receiver of type t, if present
+func (ua *UnifiedAddress) Receiver(t ReceiverType) ([]byte, bool) {
+	for _, r := range ua.Receivers {
+		if r.Type == t {
+			return r.Data, true
+		}
+	}
+	return nil, false
+}
+
+// HasOrchard reports whether the address contains an Orchard receiver
+func (ua *UnifiedAddress) HasOrchard() bool {
+	_, ok := ua.Receiver(ReceiverOrchard)
+	return ok
+}
+
+// ReceiverTypes returns the types of all receivers in the address
+func (ua *UnifiedAddress) ReceiverTypes() []ReceiverType {
+	types := make([]ReceiverType, len(ua.Receivers))
+	for i, r := range ua.Receivers {
+		types[i] = r.Type
+	}
+	return types
+}
+
+// OrchardReceiver returns the Orchard receiver, or an error listing the
+// receivers the address does contain
+func (ua *UnifiedAddress) OrchardReceiver() ([]byte, error) {
+	if data, ok := ua.Receiver(ReceiverOrchard); ok {
+		return data, nil
+	}
+	names := make([]string, len(ua.Receivers))
+	for i, r := range ua.Receivers {
+		names[i] = r.Type.String()
+	}
+	return nil, fmt.Errorf("unified address has no Orchard receiver (contains %s)", strings.Join(names, ", "))
+}
+
+// F4Jumble message length bounds (ZIP 316)
+const (
+	f4JumbleMinLength = 48
+	f4JumbleMaxLength = 4194368
+)
+
+// DecodeUnifiedAddress decodes a unified address and lists its receivers.
+//
+// The Bech32m checksum, F4Jumble padding and receiver encoding are verified.
+// Known receivers must have the correct length, typecodes must be ascending
+// and unique, and at least one shielded receiver must be present.
+func DecodeUnifiedAddress(ua string) (*UnifiedAddress, error) {
+	hrp, data5, variant, err := bech32Decode(ua)
+	if err != nil {
+		return nil, fmt.Errorf("invalid unified address %s: %w", ua, err)
+	}
+	info, ok := shieldedHRPs[hrp]
+	if !ok || info.Kind != AddressUnified {
+		return nil, fmt.Errorf("invalid unified address %s: unknown prefix %q", ua, hrp)
+	}
+	if variant != bech32mVariant {
+		return nil, fmt.Errorf("invalid unified address %s: expected Bech32m checksum", ua)
+	}
+	jumbled, err := convertBits(data5, 5, 8, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid unified address %s: %w", ua, err)
+	}
+	if len(jumbled) < f4JumbleMinLength || len(jumbled) > f4JumbleMaxLength {
+		return nil, fmt.Errorf("invalid unified address %s: invalid length %d", ua, len(jumbled))
+	}
+
+	raw := f4Unjumble(jumbled)
+
+	var padding [16]byte
+	copy(padding[:], hrp)
+	if !bytes.Equal(raw[len(raw)-16:], padding[:]) {
+		return nil, fmt.Errorf("invalid unified address %s: invalid padding", ua)
+	}
+
+	receivers, err := parseReceivers(raw[:len(raw)-16])
+	if err != nil {
+		return nil, fmt.Errorf("invalid unified address %s: %w", ua, err)
+	}
+	return &UnifiedAddress{Network: info.Network, Receivers: receivers}, nil
+}
+
+// parseReceivers parses the typecode, length, value encoding of a unified
+// address and checks the ZIP 316 constraints on its receivers
+func parseReceivers(raw []byte) ([]Receiver, error) {
+	var receivers []Receiver
+	hasShielded := false
+	hasTransparent := false
+
+	for len(raw) > 0 {
+		typecode, n, err := readCompactSize(raw)
+		if err != nil {
+			return nil, fmt.Errorf("receiver typecode: %w", err)
+		}
+		raw = raw[n:]
+		length, n, err := readCompactSize(raw)
+		if err != nil {
+			return nil, fmt.Errorf("receiver length: %w", err)
+		}
+		raw = raw[n:]
+		if length > uint64(len(raw)) {
+			return nil, fmt.Errorf("receiver length %d exceeds remaining %d bytes", length, len(raw))
+		}
+
+		if typecode > 0xffffffff {
+			return nil, fmt.Errorf("receiver typecode %d out of range", typecode)
+		}
+		t := ReceiverType(typecode)
+		if len(receivers) > 0 && t <= receivers[len(receivers)-1].Type {
+			return nil, fmt.Errorf("receiver %s is out of order or duplicated", t)
+		}
+		if want, ok := receiverLengths[t]; ok && int(length) != want {
+			return nil, fmt.Errorf("%s receiver must be %d bytes, got %d", t, want, length)
+		}
+
+		switch t {
+		case ReceiverP2PKH, ReceiverP2SH:
+			if hasTransparent {
+				return nil, errors.New("both P2PKH and P2SH receivers present")
+			}
+			hasTransparent = true
+		case ReceiverSapling, ReceiverOrchard:
+			hasShielded = true
+		}
+
+		receivers = append(receivers, Receiver{Type: t, Data: append([]byte(nil), raw[:length]...)})
+		raw = raw[length:]
+	}
+
+	if !hasShielded {
+		return nil, errors.New("no shielded receiver")
+	}
+	return receivers, nil
+}
+
+// readCompactSize reads a canonical Bitcoin-style CompactSize, returning the
+// value and the number of bytes consumed
+func readCompactSize(b []byte) (uint64, int, error) {
+	if len(b) == 0 {
+		return 0, 0, errors.New("unexpected end of data")
+	}
+
+	var v, minValue uint64
+	var n int
+	switch b[0] {
+	case 0xfd:
+		if len(b) < 3 {
+			return 0, 0, errors.New("unexpected end of data")
+		}
+		v, minValue, n = uint64(binary.LittleEndian.Uint16(b[1:])), 0xfd, 3
+	case 0xfe:
+		if len(b) < 5 {
+			return 0, 0, errors.New("unexpected end of data")
+		}
+		v, minValue, n = uint64(binary.LittleEndian.Uint32(b[1:])), 0x10000, 5
+	case 0xff:
+		if len(b) < 9 {
+			return 0, 0, errors.New("unexpected end of data")
+		}
+		v, minValue, n = binary.LittleEndian.Uint64(b[1:]), 0x100000000, 9
+	default:
+		return uint64(b[0]), 1, nil
+	}
+
+	if v < minValue {
+		return 0, 0, errors.New("non-canonical CompactSize")
+	}
+	return v, n, nil
+}
+
+// f4JumbleH is the H_i round function of F4Jumble, producing len(left) bytes
+func f4JumbleH(i byte, left, right []byte) []byte {
+	personal := append([]byte("UA_F4Jumble_H"), i, 0, 0)
+	return blake2bPersonal(len(left), personal, right)
+}
+
+// f4JumbleG is the G_i round function of F4Jumble, producing len(right) bytes
+func f4JumbleG(i byte, left, right []byte) []byte {
+	var out []byte
+	for j := 0; len(out) < len(right); j++ {
+		personal := append([]byte("UA_F4Jumble_G"), i, byte(j), byte(j>>8))
+		out = append(out, blake2bPersonal(64, personal, left)...)
+	}
+	return out[:len(right)]
+}
+
+// xorInto sets dst[i] ^= src[i]
+func xorInto(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+// f4Split returns copies of the left and right halves of an F4Jumble message
+func f4Split(msg []byte) (left, right []byte) {
+	leftLen := min(64, len(msg)/2)
+	left = append([]byte(nil), msg[:leftLen]...)
+	right = append([]byte(nil), msg[leftLen:]...)
+	return left, right
+}
+
+// f4Jumble applies the ZIP 316 F4Jumble permutation
+func f4Jumble(msg []byte) []byte {
+	x, y := f4Split(msg)
+	xorInto(y, f4JumbleG(0, x, y))
+	xorInto(x, f4JumbleH(0, x, y))
+	xorInto(y, f4JumbleG(1, x, y))
+	xorInto(x, f4JumbleH(1, x, y))
+	return append(x, y...)
+}
+
+// f4Unjumble inverts f4Jumble
+func f4Unjumble(msg []byte) []byte {
+	x, y := f4Split(msg)
+	xorInto(x, f4JumbleH(1, x, y))
+	xorInto(y, f4JumbleG(1, x, y))
+	xorInto(x, f4JumbleH(0, x, y))
+	xorInto(y, f4JumbleG(0, x, y))
+	return append(x, y...)
+}
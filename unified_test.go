@@ -0,0 +1,155 @@
+package t2z
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// encodeTestUnifiedAddress builds a unified address from raw receiver
+// encodings, without checking them, so tests can produce invalid addresses
+func encodeTestUnifiedAddress(hrp string, receivers []Receiver) string {
+	var raw []byte
+	for _, r := range receivers {
+		raw = appendCompactSize(raw, uint64(r.Type))
+		raw = appendCompactSize(raw, uint64(len(r.Data)))
+		raw = append(raw, r.Data...)
+	}
+	var padding [16]byte
+	copy(padding[:], hrp)
+	raw = append(raw, padding[:]...)
+
+	data5, _ := convertBits(f4Jumble(raw), 8, 5, true)
+
+	values := make([]byte, 0, len(hrp)*2+1+len(data5)+6)
+	for i := 0; i < len(hrp); i++ {
+		values = append(values, hrp[i]>>5)
+	}
+	values = append(values, 0)
+	for i := 0; i < len(hrp); i++ {
+		values = append(values, hrp[i]&31)
+	}
+	values = append(values, data5...)
+	polymod := bech32Polymod(append(values, 0, 0, 0, 0, 0, 0)) ^ bech32mVariant
+
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, v := range data5 {
+		sb.WriteByte(bech32Charset[v])
+	}
+	for i := 0; i < 6; i++ {
+		sb.WriteByte(bech32Charset[(polymod>>(5*(5-i)))&31])
+	}
+	return sb.String()
+}
+
+// Test that a real Orchard-only unified address decodes
+func TestDecodeUnifiedAddress(t *testing.T) {
+	ua, err := DecodeUnifiedAddress(testShieldedAddress)
+	if err != nil {
+		t.Fatalf("DecodeUnifiedAddress failed: %v", err)
+	}
+	if ua.Network != NetworkMainnet {
+		t.Errorf("Network = %s, want mainnet", ua.Network)
+	}
+	if !reflect.DeepEqual(ua.ReceiverTypes(), []ReceiverType{ReceiverOrchard}) {
+		t.Errorf("ReceiverTypes = %v, want [Orchard]", ua.ReceiverTypes())
+	}
+	orchard, err := ua.OrchardReceiver()
+	if err != nil || len(orchard) != 43 {
+		t.Errorf("OrchardReceiver = %d bytes, %v", len(orchard), err)
+	}
+}
+
+// Test that receivers survive an encode/decode round trip
+func TestDecodeUnifiedAddressReceivers(t *testing.T) {
+	receivers := []Receiver{
+		{ReceiverP2PKH, bytes.Repeat([]byte{0x11}, 20)},
+		{ReceiverSapling, bytes.Repeat([]byte{0x22}, 43)},
+		{ReceiverOrchard, bytes.Repeat([]byte{0x33}, 43)},
+		{0x7f, []byte{0x44, 0x55}},
+	}
+	addr := encodeTestUnifiedAddress("utest", receivers)
+
+	ua, err := DecodeUnifiedAddress(addr)
+	if err != nil {
+		t.Fatalf("DecodeUnifiedAddress failed: %v", err)
+	}
+	if ua.Network != NetworkTestnet {
+		t.Errorf("Network = %s, want testnet", ua.Network)
+	}
+	if !reflect.DeepEqual(ua.Receivers, receivers) {
+		t.Errorf("Receivers = %v, want %v", ua.Receivers, receivers)
+	}
+	if data, ok := ua.Receiver(ReceiverSapling); !ok || !bytes.Equal(data, receivers[1].Data) {
+		t.Errorf("Receiver(Sapling) = %x, %v", data, ok)
+	}
+
+	info, err := ValidateAddress(addr)
+	if err != nil || info.Kind != AddressUnified {
+		t.Errorf("ValidateAddress = %+v, %v", info, err)
+	}
+}
+
+// Test that a Sapling-only address reports the missing Orchard receiver
+func TestUnifiedAddressNoOrchard(t *testing.T) {
+	addr := encodeTestUnifiedAddress("u", []Receiver{{ReceiverSapling, make([]byte, 43)}})
+
+	ua, err := DecodeUnifiedAddress(addr)
+	if err != nil {
+		t.Fatalf("DecodeUnifiedAddress failed: %v", err)
+	}
+	if ua.HasOrchard() {
+		t.Error("HasOrchard = true for a Sapling-only address")
+	}
+	if _, err := ua.OrchardReceiver(); err == nil || !strings.Contains(err.Error(), "contains Sapling") {
+		t.Errorf("OrchardReceiver error = %v", err)
+	}
+}
+
+// Test that malformed unified addresses are rejected
+func TestDecodeUnifiedAddressInvalid(t *testing.T) {
+	p2pkh := Receiver{ReceiverP2PKH, make([]byte, 20)}
+	p2sh := Receiver{ReceiverP2SH, make([]byte, 20)}
+	orchard := Receiver{ReceiverOrchard, make([]byte, 43)}
+
+	tests := []struct {
+		name string
+		addr string
+		want string
+	}{
+		{"sapling address", "zs1qqqsyqcyq5rqwzqfpg9scrgwpugpzysnzs23v9ccrydpk8qarc0jqgfzyvjz2f389q5j5ctfvp5", "unknown prefix"},
+		{"checksum", testShieldedAddress[:len(testShieldedAddress)-1] + "q", "checksum mismatch"},
+		{"out of order", encodeTestUnifiedAddress("u", []Receiver{orchard, p2pkh}), "out of order"},
+		{"duplicate", encodeTestUnifiedAddress("u", []Receiver{orchard, orchard}), "out of order or duplicated"},
+		{"both transparent", encodeTestUnifiedAddress("u", []Receiver{p2pkh, p2sh, orchard}), "both P2PKH and P2SH"},
+		{"transparent only", encodeTestUnifiedAddress("u", []Receiver{p2pkh, {0x7f, make([]byte, 30)}}), "no shielded receiver"},
+		{"bad length", encodeTestUnifiedAddress("u", []Receiver{{ReceiverOrchard, make([]byte, 42)}, {0x7f, make([]byte, 8)}}), "must be 43 bytes"},
+	}
+
+	for _, tt := range tests {
+		_, err := DecodeUnifiedAddress(tt.addr)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+// Test that f4Unjumble inverts f4Jumble for short and multi-block messages
+func TestF4Jumble(t *testing.T) {
+	for _, n := range []int{48, 83, 200, 300} {
+		msg := make([]byte, n)
+		for i := range msg {
+			msg[i] = byte(i)
+		}
+		jumbled := f4Jumble(msg)
+		if bytes.Equal(jumbled, msg) {
+			t.Errorf("length %d: f4Jumble left the message unchanged", n)
+		}
+		if !bytes.Equal(f4Unjumble(jumbled), msg) {
+			t.Errorf("length %d: f4Unjumble did not invert f4Jumble", n)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+//go:build !(cgo && (darwin || linux || windows) && (amd64 || arm64)) && !t2z_mock
+
+package t2z
+
+// Fallback backend for platforms without a bundled native library, or builds
+// without cgo. The package compiles, so code that imports it can be built
+// anywhere, but every call that needs the native library returns
+// ErrUnsupportedPlatform. Pure Go helpers (addresses, fees, transaction
+// decoding) work as usual; build with the t2z_mock tag for a working mock.
+
+// requestHandle and pcztHandle are never created on these platforms
+type (
+	requestHandle = *struct{}
+	pcztHandle    = *struct{}
+)
+
+func lastError(int) string {
+	return ""
+}
+
+func ffiRequestNew([]Payment, []string) (requestHandle, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func ffiRequestFree(requestHandle) {}
+
+func ffiRequestSetTargetHeight(requestHandle, uint32) error {
+	return ErrUnsupportedPlatform
+}
+
+func ffiRequestSetUseMainnet(requestHandle, bool) error {
+	return ErrUnsupportedPlatform
+}
+
+func ffiPCZTFree(pcztHandle) {}
+
+func ffiProposeTransaction([]TransparentInput, requestHandle, string) (pcztHandle, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func ffiProveTransaction(pcztHandle) (pcztHandle, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func ffiGetSighash(pcztHandle, uint) ([32]byte, error) {
+	return [32]byte{}, ErrUnsupportedPlatform
+}
+
+func ffiAppendSignature(pcztHandle, uint, [64]byte) (pcztHandle, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func ffiFinalizeAndExtract(pcztHandle) ([]byte, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func ffiParsePCZT([]byte) (pcztHandle, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func ffiSerializePCZT(pcztHandle) ([]byte, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func ffiClonePCZT(pcztHandle) (pcztHandle, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func ffiCombine([]pcztHandle) (pcztHandle, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+func ffiVerifyBeforeSigning(pcztHandle, requestHandle, []TransparentOutput) error {
+	return ErrUnsupportedPlatform
+}
@@ -0,0 +1,36 @@
+//go:build !(cgo && (darwin || linux || windows) && (amd64 || arm64)) && !t2z_mock
+
+package t2z
+
+import (
+	"errors"
+	"testing"
+)
+
+// mockBackend reports whether the tests run against the mock backend, whose
+// sighashes and PCZT encoding differ from the native library
+const mockBackend = false
+
+// hasBackend reports whether calls that need the native library work. Tests
+// that need it are skipped with requireBackend, or built only with a backend.
+const hasBackend = false
+
+// Test that native calls fail cleanly and pure Go helpers still work
+func TestUnsupportedPlatform(t *testing.T) {
+	if _, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 1000}}); !errors.Is(err, ErrUnsupportedPlatform) {
+		t.Errorf("NewTransactionRequest: expected ErrUnsupportedPlatform, got %v", err)
+	}
+	if _, err := ParsePCZT([]byte("PCZT")); !errors.Is(err, ErrUnsupportedPlatform) {
+		t.Errorf("ParsePCZT: expected ErrUnsupportedPlatform, got %v", err)
+	}
+	if err := SelfTest(); !errors.Is(err, ErrUnsupportedPlatform) {
+		t.Errorf("SelfTest: expected ErrUnsupportedPlatform, got %v", err)
+	}
+
+	if _, err := ValidateAddress("tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma"); err != nil {
+		t.Errorf("ValidateAddress failed: %v", err)
+	}
+	if fee := CalculateFee(1, 2, 0); fee != 10_000 {
+		t.Errorf("CalculateFee = %d, want 10000", fee)
+	}
+}
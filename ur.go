@@ -0,0 +1,639 @@
+package t2z
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// URTypePCZT is the Uniform Resource type of a PCZT. The UR message is the
+// serialized PCZT as a CBOR byte string, as crypto-psbt does for PSBTs.
+const URTypePCZT = "zcash-pczt"
+
+// urMinFragmentLength is the smallest fragment the encoder will produce
+// (BCR-2020-005)
+const urMinFragmentLength = 10
+
+// EncodePCZTToURParts encodes a PCZT as multi-part Uniform Resources
+// (BCR-2020-005) for display as an animated QR code.
+//
+// Each part carries at most maxChunkBytes of the PCZT. A PCZT that fits in a
+// single part is returned as one single-part UR ("ur:zcash-pczt/..."). Parts
+// are uppercase-safe: a QR encoder can uppercase them to use alphanumeric
+// mode.
+//
+// The parts returned are the "pure" fountain fragments: displaying them in a
+// loop lets any UR decoder, including URDecoder, reassemble the PCZT.
+func EncodePCZTToURParts(pczt *PCZT, maxChunkBytes int) ([]string, error) {
+	if maxChunkBytes < urMinFragmentLength {
+		return nil, fmt.Errorf("maxChunkBytes must be at least %d, got %d", urMinFragmentLength, maxChunkBytes)
+	}
+
+	data, err := SerializePCZT(pczt)
+	if err != nil {
+		return nil, err
+	}
+	message := cborAppendHeader(nil, cborBytes, uint64(len(data)))
+	message = append(message, data...)
+
+	if len(message) <= maxChunkBytes {
+		return []string{"ur:" + URTypePCZT + "/" + bytewordsEncode(message)}, nil
+	}
+
+	enc := newFountainEncoder(message, maxChunkBytes)
+	parts := make([]string, enc.seqLen)
+	for i := range parts {
+		parts[i] = enc.urPart(uint32(i + 1))
+	}
+	return parts, nil
+}
+
+// DecodePCZTFromURParts reassembles a PCZT from UR parts in any order.
+// Duplicate parts are ignored. Returns an error if the parts do not form a
+// complete message.
+func DecodePCZTFromURParts(parts []string) (*PCZT, error) {
+	dec := NewURDecoder()
+	for _, part := range parts {
+		if err := dec.Receive(part); err != nil {
+			return nil, err
+		}
+	}
+	return dec.PCZT()
+}
+
+// URDecoder reassembles a PCZT from UR parts as they are scanned.
+//
+// Parts may arrive in any order and may repeat. Both pure and mixed fountain
+// parts are accepted, so scanning can start at any frame of an animated QR
+// code, including frames from encoders that keep generating parts past the
+// first cycle.
+type URDecoder struct {
+	message []byte
+
+	seqLen     int
+	messageLen int
+	checksum   uint32
+	fragLen    int
+
+	fragments map[int][]byte
+	mixed     []mixedFragment
+	seen      map[uint32]bool
+}
+
+// mixedFragment is the XOR of the fragments at indexes
+type mixedFragment struct {
+	indexes []int
+	data    []byte
+}
+
+// NewURDecoder creates an empty decoder
+func NewURDecoder() *URDecoder {
+	return &URDecoder{}
+}
+
+// Receive adds a scanned part (single-part or multi-part UR, any case).
+// Returns an error for malformed parts, parts of a different type, or parts
+// that belong to a different message than the ones already received.
+func (d *URDecoder) Receive(part string) error {
+	if d.IsComplete() {
+		return nil
+	}
+
+	urType, components, err := parseUR(part)
+	if err != nil {
+		return err
+	}
+	if urType != URTypePCZT {
+		return fmt.Errorf("unexpected UR type %q, want %q", urType, URTypePCZT)
+	}
+
+	switch len(components) {
+	case 1:
+		message, err := bytewordsDecode(components[0])
+		if err != nil {
+			return fmt.Errorf("invalid UR: %w", err)
+		}
+		d.message = message
+		return nil
+	case 2:
+		return d.receiveMultipart(components[0], components[1])
+	default:
+		return fmt.Errorf("invalid UR: expected 1 or 2 path components, got %d", len(components))
+	}
+}
+
+// receiveMultipart adds a "seqNum-seqLen/body" part
+func (d *URDecoder) receiveMultipart(seq, body string) error {
+	seqNum, seqLen, err := parseURSequence(seq)
+	if err != nil {
+		return err
+	}
+	data, err := bytewordsDecode(body)
+	if err != nil {
+		return fmt.Errorf("invalid UR part %s: %w", seq, err)
+	}
+	p, err := decodeFountainPart(data)
+	if err != nil {
+		return fmt.Errorf("invalid UR part %s: %w", seq, err)
+	}
+	if p.seqNum != seqNum || p.seqLen != seqLen {
+		return fmt.Errorf("invalid UR part %s: sequence does not match its body", seq)
+	}
+
+	if d.fragments == nil {
+		d.seqLen = int(p.seqLen)
+		d.messageLen = int(p.messageLen)
+		d.checksum = p.checksum
+		d.fragLen = len(p.data)
+		d.fragments = make(map[int][]byte)
+		d.seen = make(map[uint32]bool)
+	} else if int(p.seqLen) != d.seqLen || int(p.messageLen) != d.messageLen ||
+		p.checksum != d.checksum || len(p.data) != d.fragLen {
+		return fmt.Errorf("UR part %s belongs to a different message", seq)
+	}
+
+	if d.seen[p.seqNum] {
+		return nil
+	}
+	d.seen[p.seqNum] = true
+
+	d.addFragment(chooseFragments(p.seqNum, d.seqLen, d.checksum), p.data)
+	if len(d.fragments) < d.seqLen {
+		return nil
+	}
+
+	// All fragments are known: join, strip padding and check the checksum
+	message := make([]byte, 0, d.seqLen*d.fragLen)
+	for i := 0; i < d.seqLen; i++ {
+		message = append(message, d.fragments[i]...)
+	}
+	message = message[:d.messageLen]
+	if crc32.ChecksumIEEE(message) != d.checksum {
+		return errors.New("UR message checksum mismatch")
+	}
+	d.message = message
+	return nil
+}
+
+// addFragment records a fragment that is the XOR of the fragments at
+// indexes, and reduces every pending mixed fragment by the pure fragments
+// that become known
+func (d *URDecoder) addFragment(indexes []int, data []byte) {
+	pending := []mixedFragment{{indexes, append([]byte(nil), data...)}}
+	for len(pending) > 0 {
+		f := pending[0]
+		pending = pending[1:]
+		f = d.reduce(f)
+
+		switch len(f.indexes) {
+		case 0:
+			continue
+		case 1:
+			index := f.indexes[0]
+			if _, ok := d.fragments[index]; ok {
+				continue
+			}
+			d.fragments[index] = f.data
+
+			// A new pure fragment may reduce mixed fragments to pure ones
+			remaining := d.mixed[:0]
+			for _, m := range d.mixed {
+				if slices.Contains(m.indexes, index) {
+					pending = append(pending, m)
+				} else {
+					remaining = append(remaining, m)
+				}
+			}
+			d.mixed = remaining
+		default:
+			d.mixed = append(d.mixed, f)
+		}
+	}
+}
+
+// reduce removes the known pure fragments from a mixed fragment
+func (d *URDecoder) reduce(f mixedFragment) mixedFragment {
+	var indexes []int
+	for _, index := range f.indexes {
+		if fragment, ok := d.fragments[index]; ok {
+			xorInto(f.data, fragment)
+		} else {
+			indexes = append(indexes, index)
+		}
+	}
+	return mixedFragment{indexes, f.data}
+}
+
+// IsComplete reports whether the full PCZT has been received
+func (d *URDecoder) IsComplete() bool {
+	return d.message != nil
+}
+
+// Progress returns the number of fragments recovered and the total needed.
+// Both are 0 before the first multi-part UR is received.
+func (d *URDecoder) Progress() (done, total int) {
+	if d.IsComplete() {
+		return max(d.seqLen, 1), max(d.seqLen, 1)
+	}
+	return len(d.fragments), d.seqLen
+}
+
+// PCZT parses the reassembled PCZT. Returns an error if the message is not
+// complete yet.
+func (d *URDecoder) PCZT() (*PCZT, error) {
+	if !d.IsComplete() {
+		done, total := d.Progress()
+		return nil, fmt.Errorf("UR message incomplete: %d of %d fragments", done, total)
+	}
+
+	major, length, n, err := cborReadHeader(d.message)
+	if err != nil {
+		return nil, fmt.Errorf("invalid UR message: %w", err)
+	}
+	if major != cborBytes || uint64(len(d.message)-n) != length {
+		return nil, errors.New("invalid UR message: expected a CBOR byte string")
+	}
+	return ParsePCZT(d.message[n:])
+}
+
+// parseUR splits "ur:type/component/..." into the type and path components
+func parseUR(s string) (string, []string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	rest, ok := strings.CutPrefix(s, "ur:")
+	if !ok {
+		return "", nil, errors.New("invalid UR: missing ur: scheme")
+	}
+	components := strings.Split(rest, "/")
+	if len(components) < 2 {
+		return "", nil, errors.New("invalid UR: missing body")
+	}
+	for _, c := range components[0] {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return "", nil, fmt.Errorf("invalid UR type %q", components[0])
+		}
+	}
+	return components[0], components[1:], nil
+}
+
+// parseURSequence parses a "seqNum-seqLen" component
+func parseURSequence(s string) (uint32, uint32, error) {
+	numStr, lenStr, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid UR sequence %q", s)
+	}
+	seqNum, err1 := strconv.ParseUint(numStr, 10, 32)
+	seqLen, err2 := strconv.ParseUint(lenStr, 10, 32)
+	if err1 != nil || err2 != nil || seqNum == 0 || seqLen == 0 {
+		return 0, 0, fmt.Errorf("invalid UR sequence %q", s)
+	}
+	return uint32(seqNum), uint32(seqLen), nil
+}
+
+// fountainEncoder splits a message into equal, zero-padded fragments
+type fountainEncoder struct {
+	fragments [][]byte
+	seqLen    int
+	msgLen    int
+	checksum  uint32
+}
+
+// newFountainEncoder splits message into the fewest fragments of at most
+// maxFragmentLen bytes, balancing their lengths
+func newFountainEncoder(message []byte, maxFragmentLen int) *fountainEncoder {
+	fragLen := nominalFragmentLength(len(message), urMinFragmentLength, maxFragmentLen)
+	seqLen := (len(message) + fragLen - 1) / fragLen
+
+	padded := make([]byte, seqLen*fragLen)
+	copy(padded, message)
+	fragments := make([][]byte, seqLen)
+	for i := range fragments {
+		fragments[i] = padded[i*fragLen : (i+1)*fragLen]
+	}
+
+	return &fountainEncoder{
+		fragments: fragments,
+		seqLen:    seqLen,
+		msgLen:    len(message),
+		checksum:  crc32.ChecksumIEEE(message),
+	}
+}
+
+// nominalFragmentLength returns the fragment length for the smallest number
+// of fragments that keeps each fragment within maxFragmentLen
+func nominalFragmentLength(messageLen, minFragmentLen, maxFragmentLen int) int {
+	maxFragmentCount := max(messageLen/minFragmentLen, 1)
+	fragLen := messageLen
+	for count := 1; count <= maxFragmentCount; count++ {
+		fragLen = (messageLen + count - 1) / count
+		if fragLen <= maxFragmentLen {
+			break
+		}
+	}
+	return fragLen
+}
+
+// urPart returns part seqNum as a multi-part UR. Parts 1..seqLen are the
+// pure fragments; later parts mix several fragments.
+func (e *fountainEncoder) urPart(seqNum uint32) string {
+	data := make([]byte, len(e.fragments[0]))
+	for _, index := range chooseFragments(seqNum, e.seqLen, e.checksum) {
+		xorInto(data, e.fragments[index])
+	}
+
+	body := cborAppendHeader(nil, cborArray, 5)
+	body = cborAppendHeader(body, cborUint, uint64(seqNum))
+	body = cborAppendHeader(body, cborUint, uint64(e.seqLen))
+	body = cborAppendHeader(body, cborUint, uint64(e.msgLen))
+	body = cborAppendHeader(body, cborUint, uint64(e.checksum))
+	body = cborAppendHeader(body, cborBytes, uint64(len(data)))
+	body = append(body, data...)
+
+	return fmt.Sprintf("ur:%s/%d-%d/%s", URTypePCZT, seqNum, e.seqLen, bytewordsEncode(body))
+}
+
+// fountainPart is a decoded multi-part UR body
+type fountainPart struct {
+	seqNum     uint32
+	seqLen     uint32
+	messageLen uint32
+	checksum   uint32
+	data       []byte
+}
+
+// decodeFountainPart decodes the CBOR array
+// [seqNum, seqLen, messageLen, checksum, data] of a multi-part UR
+func decodeFountainPart(b []byte) (*fountainPart, error) {
+	major, count, n, err := cborReadHeader(b)
+	if err != nil {
+		return nil, err
+	}
+	if major != cborArray || count != 5 {
+		return nil, errors.New("expected a 5-element CBOR array")
+	}
+	b = b[n:]
+
+	var fields [4]uint32
+	for i := range fields {
+		major, v, n, err := cborReadHeader(b)
+		if err != nil {
+			return nil, err
+		}
+		if major != cborUint || v > math.MaxUint32 {
+			return nil, fmt.Errorf("field %d: expected a 32-bit unsigned integer", i)
+		}
+		fields[i] = uint32(v)
+		b = b[n:]
+	}
+
+	major, length, n, err := cborReadHeader(b)
+	if err != nil {
+		return nil, err
+	}
+	if major != cborBytes || uint64(len(b)-n) != length {
+		return nil, errors.New("fragment: expected a CBOR byte string")
+	}
+
+	p := &fountainPart{
+		seqNum:     fields[0],
+		seqLen:     fields[1],
+		messageLen: fields[2],
+		checksum:   fields[3],
+		data:       b[n:],
+	}
+	if p.seqNum == 0 || p.seqLen == 0 || len(p.data) == 0 ||
+		uint64(p.messageLen) > uint64(p.seqLen)*uint64(len(p.data)) {
+		return nil, errors.New("inconsistent sequence and message lengths")
+	}
+	return p, nil
+}
+
+// chooseFragments returns the indexes of the fragments mixed into part
+// seqNum. The first seqLen parts are pure; later parts pick a pseudorandom
+// degree and subset seeded by the part number and message checksum.
+func chooseFragments(seqNum uint32, seqLen int, checksum uint32) []int {
+	if int(seqNum) <= seqLen {
+		return []int{int(seqNum) - 1}
+	}
+
+	var seed [8]byte
+	binary.BigEndian.PutUint32(seed[:4], seqNum)
+	binary.BigEndian.PutUint32(seed[4:], checksum)
+	rng := newXoshiro256(seed[:])
+
+	probabilities := make([]float64, seqLen)
+	for i := range probabilities {
+		probabilities[i] = 1 / float64(i+1)
+	}
+	degree := newRandomSampler(probabilities).next(rng) + 1
+
+	remaining := make([]int, seqLen)
+	for i := range remaining {
+		remaining[i] = i
+	}
+	shuffled := make([]int, 0, seqLen)
+	for len(remaining) > 0 {
+		i := rng.nextInt(0, len(remaining)-1)
+		shuffled = append(shuffled, remaining[i])
+		remaining = append(remaining[:i], remaining[i+1:]...)
+	}
+	return shuffled[:degree]
+}
+
+// xoshiro256 is the xoshiro256** generator used by the UR fountain code
+type xoshiro256 [4]uint64
+
+// newXoshiro256 seeds the generator from the SHA-256 of seed
+func newXoshiro256(seed []byte) *xoshiro256 {
+	digest := sha256.Sum256(seed)
+	var x xoshiro256
+	for i := range x {
+		x[i] = binary.BigEndian.Uint64(digest[i*8:])
+	}
+	return &x
+}
+
+func (x *xoshiro256) next() uint64 {
+	result := bits.RotateLeft64(x[1]*5, 7) * 9
+	t := x[1] << 17
+	x[2] ^= x[0]
+	x[3] ^= x[1]
+	x[1] ^= x[2]
+	x[0] ^= x[3]
+	x[2] ^= t
+	x[3] = bits.RotateLeft64(x[3], 45)
+	return result
+}
+
+// nextDouble returns a value in [0, 1)
+func (x *xoshiro256) nextDouble() float64 {
+	return float64(x.next()) / (float64(math.MaxUint64) + 1)
+}
+
+// nextInt returns a value in [low, high]
+func (x *xoshiro256) nextInt(low, high int) int {
+	return int(x.nextDouble()*float64(high-low+1)) + low
+}
+
+// randomSampler draws indexes with given weights (Vose's alias method)
+type randomSampler struct {
+	probs   []float64
+	aliases []int
+}
+
+func newRandomSampler(weights []float64) *randomSampler {
+	n := len(weights)
+	sum := 0.0
+	for _, w := range weights {
+		sum += w
+	}
+	p := make([]float64, n)
+	for i, w := range weights {
+		p[i] = w * float64(n) / sum
+	}
+
+	// Index lists are filled in reverse order, as in the reference encoder
+	var small, large []int
+	for i := n - 1; i >= 0; i-- {
+		if p[i] < 1 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+
+	s := &randomSampler{probs: make([]float64, n), aliases: make([]int, n)}
+	for len(small) > 0 && len(large) > 0 {
+		a := small[len(small)-1]
+		small = small[:len(small)-1]
+		g := large[len(large)-1]
+		large = large[:len(large)-1]
+
+		s.probs[a] = p[a]
+		s.aliases[a] = g
+		p[g] += p[a] - 1
+		if p[g] < 1 {
+			small = append(small, g)
+		} else {
+			large = append(large, g)
+		}
+	}
+	for _, i := range large {
+		s.probs[i] = 1
+	}
+	for _, i := range small {
+		s.probs[i] = 1
+	}
+	return s
+}
+
+func (s *randomSampler) next(rng *xoshiro256) int {
+	r1 := rng.nextDouble()
+	r2 := rng.nextDouble()
+	i := int(float64(len(s.probs)) * r1)
+	if r2 < s.probs[i] {
+		return i
+	}
+	return s.aliases[i]
+}
+
+// bytewords is the Bytewords word list (BCR-2020-012); the minimal encoding
+// of a byte is the first and last letter of its word
+const bytewords = "ableacidalsoapexaquaarchatomauntawayaxisbackbaldbarnbeltbetabiasbluebodybragbrewbulbbuzzcalmcashcatschefcityclawcodecolacookcostcruxcurlcuspcyandarkdatadaysdelidicedietdoordowndrawdropdrumdulldutyeacheasyechoedgeepicevenexamexiteyesfactfairfernfigsfilmfishfizzflapflewfluxfoxyfreefrogfuelfundgalagamegeargemsgiftgirlglowgoodgraygrimgurugushgyrohalfhanghardhawkheathelphighhillholyhopehornhutsicedideaidleinchinkyintoirisironitemjadejazzjoinjoltjowljudojugsjumpjunkjurykeepkenokeptkeyskickkilnkingkitekiwiknoblamblavalazyleaflegsliarlimplionlistlogoloudloveluaulucklungmainmanymathmazememomenumeowmildmintmissmonknailnavyneednewsnextnoonnotenumbobeyoboeomitonyxopenovalowlspaidpartpeckplaypluspoempoolposepuffpumapurrquadquizraceramprealredorichroadrockroofrubyruinrunsrustsafesagascarsetssilkskewslotsoapsolosongstubsurfswantacotasktaxitenttiedtimetinytoiltombtoystriptunatwinuglyundouniturgeuservastveryvetovialvibeviewvisavoidvowswallwandwarmwaspwavewaxywebswhatwhenwhizwolfworkyankyawnyellyogayurtzapszerozestzinczonezoom"
+
+// bytewordsMinimal maps a two-letter minimal Byteword to its byte value
+var bytewordsMinimal = func() map[string]byte {
+	m := make(map[string]byte, 256)
+	for i := 0; i < 256; i++ {
+		m[bytewords[i*4:i*4+1]+bytewords[i*4+3:i*4+4]] = byte(i)
+	}
+	return m
+}()
+
+// bytewordsEncode encodes data and its CRC-32 as minimal Bytewords
+func bytewordsEncode(data []byte) string {
+	data = binary.BigEndian.AppendUint32(append([]byte(nil), data...), crc32.ChecksumIEEE(data))
+	var sb strings.Builder
+	sb.Grow(len(data) * 2)
+	for _, b := range data {
+		sb.WriteByte(bytewords[int(b)*4])
+		sb.WriteByte(bytewords[int(b)*4+3])
+	}
+	return sb.String()
+}
+
+// bytewordsDecode decodes minimal Bytewords and verifies the trailing CRC-32
+func bytewordsDecode(s string) ([]byte, error) {
+	if len(s)%2 != 0 {
+		return nil, errors.New("invalid Bytewords length")
+	}
+	data := make([]byte, len(s)/2)
+	for i := range data {
+		b, ok := bytewordsMinimal[s[i*2:i*2+2]]
+		if !ok {
+			return nil, fmt.Errorf("invalid Byteword %q", s[i*2:i*2+2])
+		}
+		data[i] = b
+	}
+	if len(data) < 5 {
+		return nil, errors.New("Bytewords too short")
+	}
+	body, checksum := data[:len(data)-4], data[len(data)-4:]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(checksum) {
+		return nil, errors.New("Bytewords checksum mismatch")
+	}
+	return body, nil
+}
+
+// CBOR major types used by UR
+const (
+	cborUint  = 0
+	cborBytes = 2
+	cborArray = 4
+)
+
+// cborAppendHeader appends a CBOR head with the shortest argument encoding
+func cborAppendHeader(b []byte, major byte, v uint64) []byte {
+	switch {
+	case v < 24:
+		return append(b, major<<5|byte(v))
+	case v <= math.MaxUint8:
+		return append(b, major<<5|24, byte(v))
+	case v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, major<<5|25), uint16(v))
+	case v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, major<<5|26), uint32(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, major<<5|27), v)
+	}
+}
+
+// cborReadHeader reads a CBOR head, returning the major type, argument and
+// number of bytes consumed
+func cborReadHeader(b []byte) (byte, uint64, int, error) {
+	if len(b) == 0 {
+		return 0, 0, 0, errors.New("unexpected end of CBOR data")
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	if info < 24 {
+		return major, uint64(info), 1, nil
+	}
+	if info > 27 {
+		return 0, 0, 0, errors.New("unsupported CBOR encoding")
+	}
+	size := 1 << (info - 24)
+	if len(b) < 1+size {
+		return 0, 0, 0, errors.New("unexpected end of CBOR data")
+	}
+	var v uint64
+	for _, c := range b[1 : 1+size] {
+		v = v<<8 | uint64(c)
+	}
+	return major, v, 1 + size, nil
+}
@@ -0,0 +1,171 @@
+package t2z
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test the Bytewords, CRC-32 and xoshiro256** vectors from the UR reference
+// implementation
+func TestURVectors(t *testing.T) {
+	if got := bytewordsEncode([]byte{0x00, 0x01, 0x02, 0x80, 0xff}); got != "aeadaolazmjendeoti" {
+		t.Errorf("bytewordsEncode = %s, want aeadaolazmjendeoti", got)
+	}
+	decoded, err := bytewordsDecode("aeadaolazmjendeoti")
+	if err != nil || !bytes.Equal(decoded, []byte{0x00, 0x01, 0x02, 0x80, 0xff}) {
+		t.Errorf("bytewordsDecode = %x, %v", decoded, err)
+	}
+	if _, err := bytewordsDecode("aeadaolazmjendeota"); err == nil {
+		t.Error("bytewordsDecode accepted a bad checksum")
+	}
+
+	rng := newXoshiro256([]byte("Wolf"))
+	want := []uint64{42, 81, 85, 8, 82, 84, 76, 73, 70, 88}
+	var got []uint64
+	for range want {
+		got = append(got, rng.next()%100)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("xoshiro256 = %v, want %v", got, want)
+	}
+
+	if n := nominalFragmentLength(12345, 1005, 1955); n != 1764 {
+		t.Errorf("nominalFragmentLength = %d, want 1764", n)
+	}
+}
+
+// Test that a message is recovered from mixed fountain parts alone, as when
+// scanning starts after the first cycle of an animated QR code
+func TestURDecoderMixedParts(t *testing.T) {
+	message := make([]byte, 500)
+	for i := range message {
+		message[i] = byte(i * 7)
+	}
+	enc := newFountainEncoder(message, 40)
+
+	dec := NewURDecoder()
+	for seqNum := uint32(enc.seqLen + 1); !dec.IsComplete(); seqNum++ {
+		if seqNum > uint32(enc.seqLen*20) {
+			t.Fatalf("not complete after %d parts", seqNum)
+		}
+		if err := dec.Receive(strings.ToUpper(enc.urPart(seqNum))); err != nil {
+			t.Fatalf("Receive part %d: %v", seqNum, err)
+		}
+	}
+	if !bytes.Equal(dec.message, message) {
+		t.Error("reassembled message differs from the original")
+	}
+	if done, total := dec.Progress(); done != total || total != enc.seqLen {
+		t.Errorf("Progress = %d/%d, want %d/%d", done, total, enc.seqLen, enc.seqLen)
+	}
+}
+
+// Test that malformed and mismatched parts are rejected
+func TestURDecoderInvalid(t *testing.T) {
+	enc := newFountainEncoder(make([]byte, 100), 30)
+	other := newFountainEncoder(bytes.Repeat([]byte{1}, 100), 30)
+	part := enc.urPart(1)
+
+	tests := []struct {
+		name string
+		part string
+		want string
+	}{
+		{"scheme", strings.TrimPrefix(part, "ur:"), "missing ur: scheme"},
+		{"type", strings.Replace(part, URTypePCZT, "crypto-psbt", 1), "unexpected UR type"},
+		{"sequence", strings.Replace(part, "/1-", "/0-", 1), "invalid UR sequence"},
+		{"body", part[:len(part)-2] + "ae", "checksum mismatch"},
+		{"sequence mismatch", strings.Replace(part, "/1-", "/2-", 1), "does not match"},
+	}
+	for _, tt := range tests {
+		if err := NewURDecoder().Receive(tt.part); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	dec := NewURDecoder()
+	if err := dec.Receive(part); err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	if err := dec.Receive(other.urPart(2)); err == nil || !strings.Contains(err.Error(), "different message") {
+		t.Errorf("expected different message error, got %v", err)
+	}
+	if _, err := dec.PCZT(); err == nil || !strings.Contains(err.Error(), "incomplete") {
+		t.Errorf("expected incomplete error, got %v", err)
+	}
+}
+
+// Test that a PCZT survives UR encoding with parts shuffled and repeated
+func TestPCZTURRoundTrip(t *testing.T) {
+	requireBackend(t)
+	_, pubkey := createTestKeypair()
+
+	var txid [32]byte
+	copy(txid[:], []byte("test_txid_ur_round_trip_00000000"))
+
+	inputs := []TransparentInput{{
+		Pubkey:       pubkey,
+		TxID:         txid,
+		Vout:         0,
+		Amount:       100_000_000,
+		ScriptPubKey: createP2PKHScript(pubkey),
+	}}
+
+	request, err := NewTransactionRequest([]Payment{{Address: "tm9iMLAuYMzJ6jtFLcA7rzUmfreGuKvr7Ma", Amount: 50_000_000}})
+	if err != nil {
+		t.Fatalf("Failed to create transaction request: %v", err)
+	}
+	defer request.Free()
+	request.SetTargetHeight(2_500_000)
+
+	pczt, err := ProposeTransaction(inputs, request)
+	if err != nil {
+		t.Fatalf("Failed to propose transaction: %v", err)
+	}
+	defer pczt.Free()
+
+	serialized, err := SerializePCZT(pczt)
+	if err != nil {
+		t.Fatalf("Failed to serialize PCZT: %v", err)
+	}
+
+	parts, err := EncodePCZTToURParts(pczt, 100)
+	if err != nil {
+		t.Fatalf("EncodePCZTToURParts failed: %v", err)
+	}
+	if len(parts) < 2 {
+		t.Fatalf("expected several parts, got %d", len(parts))
+	}
+	t.Logf("✓ %d-byte PCZT encoded as %d UR parts", len(serialized), len(parts))
+
+	// Reverse order, with the last part repeated after every part
+	var scanned []string
+	for i := len(parts) - 1; i >= 0; i-- {
+		scanned = append(scanned, parts[i], parts[len(parts)-1])
+	}
+	decoded, err := DecodePCZTFromURParts(scanned)
+	if err != nil {
+		t.Fatalf("DecodePCZTFromURParts failed: %v", err)
+	}
+	decodedBytes, err := SerializePCZT(decoded)
+	decoded.Free()
+	if err != nil || !bytes.Equal(decodedBytes, serialized) {
+		t.Errorf("UR round-trip changed the PCZT (err: %v)", err)
+	}
+
+	if _, err := DecodePCZTFromURParts(parts[1:]); err == nil || !strings.Contains(err.Error(), "incomplete") {
+		t.Errorf("expected incomplete error for a missing part, got %v", err)
+	}
+
+	single, err := EncodePCZTToURParts(pczt, len(serialized)+10)
+	if err != nil || len(single) != 1 || strings.Count(single[0], "/") != 1 {
+		t.Fatalf("expected a single-part UR, got %d parts, %v", len(single), err)
+	}
+	decoded, err = DecodePCZTFromURParts(single)
+	if err != nil {
+		t.Fatalf("decode single-part UR failed: %v", err)
+	}
+	decoded.Free()
+}